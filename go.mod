@@ -4,6 +4,7 @@ go 1.24.7
 
 require (
 	github.com/go-git/go-git/v5 v5.16.5
+	github.com/google/pprof v0.0.0-20250317173921-a4b03ec1a45e
 	github.com/spf13/cobra v1.10.2
 	github.com/wow-look-at-my/ansi-writer v0.0.0-20260218162455-f5112b042a12
 	github.com/wow-look-at-my/go-containers v0.0.0-20260226090040-03a6a05ff69b
@@ -8,9 +8,7 @@ import (
 
 func init() {
 	// Disable Go's phone-home behavior - bypass proxy and checksum database
-	os.Setenv("GOPROXY", "direct")
-	os.Setenv("GOSUMDB", "off")
-	os.Setenv("GONOSUMCHECK", "*")
+	cmd.UseDirectModules()
 }
 
 func main() {
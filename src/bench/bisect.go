@@ -0,0 +1,348 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// DefaultBisectCount is the number of samples bisect takes at each commit.
+const DefaultBisectCount = 10
+
+// BisectOptions configures a bisection.
+type BisectOptions struct {
+	Good      string // revision before the change
+	Bad       string // revision after the change
+	Benchmark string // e.g. BenchmarkEncode/size=1k; the Benchmark prefix is optional
+	Unit      string // metric to bisect on (default ns/op)
+
+	// Run configures the benchmark runs; Bench and Dir are set by Bisect
+	// and Count defaults to DefaultBisectCount.
+	Run Options
+
+	// Progress, if set, is called with each commit once it is classified.
+	Progress func(BisectStep)
+}
+
+// BisectStep is one commit measured by a bisection.
+type BisectStep struct {
+	Commit    string  `json:"commit"`
+	Stats     Stats   `json:"stats"`
+	Bad       bool    `json:"bad"`
+	Ambiguous bool    `json:"ambiguous,omitempty"` // the tests against both ends agree
+	PGood     float64 `json:"p_good"`              // Mann-Whitney U p-value against the good samples
+	PBad      float64 `json:"p_bad"`               // and against the bad ones
+	Cached    bool    `json:"cached,omitempty"`    // samples came from the notes
+	Skipped   string  `json:"skipped,omitempty"`   // why the commit couldn't be measured
+
+	samples []float64
+}
+
+// BisectResult is the outcome of a bisection.
+type BisectResult struct {
+	Benchmark string       `json:"benchmark"`
+	Unit      string       `json:"unit"`
+	Good      BisectStep   `json:"good"`
+	Bad       BisectStep   `json:"bad"`
+	Delta     float64      `json:"delta"` // percentage change from good to bad
+	Steps     []BisectStep `json:"steps"` // commits in between, in test order
+	FirstBad  string       `json:"first_bad"`
+	// Candidates lists the commits that may be the first bad one, oldest
+	// first: FirstBad and any skipped commits just before it.
+	Candidates []string `json:"candidates"`
+}
+
+// Bisect finds the commit between Good and Bad where a benchmark changed.
+// Each commit is checked out into a temporary worktree and the benchmark
+// run there, unless the benchmark notes already hold enough samples for
+// it; new samples are stored in the notes. A commit counts as bad when
+// its median is closer to the bad end's than the good end's. Commits that
+// fail to build or run are skipped.
+func Bisect(r runner.CommandRunner, opts BisectOptions) (*BisectResult, error) {
+	if opts.Unit == "" {
+		opts.Unit = "ns/op"
+	}
+	if opts.Run.Count < 1 {
+		opts.Run.Count = DefaultBisectCount
+	}
+	name := benchmarkName(opts.Benchmark)
+	opts.Run.Bench = benchPattern(name)
+
+	good, err := resolveCommit(r, opts.Good)
+	if err != nil {
+		return nil, err
+	}
+	bad, err := resolveCommit(r, opts.Bad)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := ancestryPath(r, good, bad)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("%s is not a descendant of %s", opts.Bad, opts.Good)
+	}
+
+	b := &bisector{r: r, opts: opts, name: name, wt: NewWorktree(r)}
+	defer b.wt.Close()
+
+	result := &BisectResult{Benchmark: name, Unit: opts.Unit, Steps: []BisectStep{}}
+	for _, end := range []struct {
+		step *BisectStep
+		sha  string
+		bad  bool
+	}{{&result.Good, good, false}, {&result.Bad, bad, true}} {
+		samples, cached, err := b.samples(end.sha)
+		if err != nil {
+			return nil, err
+		}
+		*end.step = BisectStep{Commit: end.sha, Stats: Summarize(samples), Bad: end.bad, Cached: cached, samples: samples}
+		b.progress(*end.step)
+	}
+
+	p := MannWhitneyU(result.Good.samples, result.Bad.samples)
+	if p >= Alpha {
+		return nil, fmt.Errorf("%s doesn't differ significantly between %s and %s (p=%.3f); nothing to bisect", name, opts.Good, opts.Bad, p)
+	}
+	result.Delta = percentChange(result.Good.Stats.Median, result.Bad.Stats.Median)
+
+	// commits[hi] is the earliest known bad commit and commits[lo] the
+	// latest known good one, with -1 standing for Good itself
+	lo, hi := -1, len(commits)-1
+	skipped := make(map[int]bool)
+	for {
+		mid := pickMidpoint(lo, hi, skipped)
+		if mid < 0 {
+			break
+		}
+		step := BisectStep{Commit: commits[mid]}
+		samples, cached, err := b.samples(commits[mid])
+		if err != nil {
+			step.Skipped = err.Error()
+			skipped[mid] = true
+		} else {
+			step.Cached = cached
+			classify(&step, samples, result.Good.samples, result.Bad.samples)
+			if step.Bad {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		result.Steps = append(result.Steps, step)
+		b.progress(step)
+	}
+
+	result.FirstBad = commits[hi]
+	result.Candidates = commits[lo+1 : hi+1]
+	return result, nil
+}
+
+// classify compares a commit's samples with the good and bad ends'.
+func classify(step *BisectStep, samples, good, bad []float64) {
+	step.samples = samples
+	step.Stats = Summarize(samples)
+	step.PGood = MannWhitneyU(good, samples)
+	step.PBad = MannWhitneyU(bad, samples)
+	m := step.Stats.Median
+	step.Bad = math.Abs(m-Summarize(bad).Median) < math.Abs(m-Summarize(good).Median)
+	// A clear verdict differs significantly from exactly one end
+	step.Ambiguous = (step.PGood < Alpha) == (step.PBad < Alpha)
+}
+
+// pickMidpoint returns the untested commit nearest the middle of lo and
+// hi, exclusive, that hasn't been skipped, or -1 if none is left.
+func pickMidpoint(lo, hi int, skipped map[int]bool) int {
+	mid := lo + (hi-lo)/2
+	for d := 0; mid-d > lo || mid+d < hi; d++ {
+		for _, i := range []int{mid - d, mid + d} {
+			if i > lo && i < hi && !skipped[i] {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// benchmarkName returns the full name of a benchmark given with or without
+// its Benchmark prefix and CPU suffix.
+func benchmarkName(name string) string {
+	name = stripCPUSuffix(name)
+	if !strings.HasPrefix(name, "Benchmark") {
+		name = "Benchmark" + name
+	}
+	return name
+}
+
+// benchPattern returns a -bench regexp that matches only the named
+// benchmark, anchoring each "/"-separated level.
+func benchPattern(name string) string {
+	levels := strings.Split(name, "/")
+	for i, level := range levels {
+		levels[i] = "^" + regexp.QuoteMeta(level) + "$"
+	}
+	return strings.Join(levels, "/")
+}
+
+// benchmarkSamples returns a benchmark's samples in a unit from a report.
+func benchmarkSamples(report *BenchmarkReport, name, unit string) ([]float64, error) {
+	pkgs := make([]string, 0, len(report.Packages))
+	for pkg := range report.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	var values []float64
+	found := ""
+	for _, pkg := range pkgs {
+		_, samples := groupSamples(report.Packages[pkg])
+		if len(samples[name]) == 0 {
+			continue
+		}
+		if found != "" {
+			return nil, fmt.Errorf("%s is in both %s and %s; pass the package to bisect", name, found, pkg)
+		}
+		found = pkg
+		for _, s := range samples[name] {
+			if v, ok := s.Value(unit); ok {
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// resolveCommit returns the full SHA of a revision.
+func resolveCommit(r runner.CommandRunner, rev string) (string, error) {
+	proc, err := runner.Cmd("git", "rev-parse", "--verify", rev+"^{commit}").WithQuiet().Run(r)
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s: %w", rev, err)
+	}
+	output, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return "", fmt.Errorf("unknown revision %s: %w", rev, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ancestryPath lists the commits that are descendants of good and
+// ancestors of bad, oldest first, ending with bad.
+func ancestryPath(r runner.CommandRunner, good, bad string) ([]string, error) {
+	proc, err := runner.Cmd("git", "rev-list", "--reverse", "--ancestry-path", good+".."+bad).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits between %s and %s: %w", shortSHA(good), shortSHA(bad), err)
+	}
+	output, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to list commits between %s and %s: %w", shortSHA(good), shortSHA(bad), err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// bisector measures commits in a temporary worktree.
+type bisector struct {
+	r    runner.CommandRunner
+	opts BisectOptions
+	name string
+	wt   *Worktree
+	env  *Environment // of this machine, to check cached results against
+}
+
+func (b *bisector) progress(step BisectStep) {
+	if b.opts.Progress != nil {
+		b.opts.Progress(step)
+	}
+}
+
+// samples returns the benchmark's samples at a commit, and whether they
+// came from the notes. Stored samples are only used if there are enough
+// of them and they were measured in a compatible environment.
+func (b *bisector) samples(sha string) ([]float64, bool, error) {
+	if b.env == nil {
+		b.env = captureEnvironment(b.r, "")
+	}
+	stored, err := FetchForCommit(b.r, sha)
+	if err == nil {
+		values, err := benchmarkSamples(stored, b.name, b.opts.Unit)
+		compatible := true
+		if stored.Env != nil {
+			incompatible, _ := b.env.Diff(stored.Env)
+			compatible = len(incompatible) == 0
+		}
+		if err == nil && compatible && len(values) >= b.opts.Run.Count {
+			return values, true, nil
+		}
+	} else {
+		stored = &BenchmarkReport{}
+	}
+
+	if err := b.wt.Checkout(sha); err != nil {
+		return nil, false, err
+	}
+	opts := b.opts.Run
+	opts.Dir = b.wt.Dir
+	report, err := RunBenchmarks(b.r, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	values, err := benchmarkSamples(report, b.name, b.opts.Unit)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		return nil, false, fmt.Errorf("no %s results for %s at %s", b.opts.Unit, b.name, shortSHA(sha))
+	}
+
+	if !stored.SameEnvironment(report.Env) {
+		stored = &BenchmarkReport{}
+	}
+	stored.Merge(report)
+	if err := StoreNotesForCommit(b.r, sha, stored); err != nil {
+		return nil, false, err
+	}
+	return values, false, nil
+}
+
+// Describe formats a step as a line of progress output.
+func (s BisectStep) Describe(unit string) string {
+	if s.Skipped != "" {
+		return fmt.Sprintf("%s  skip  %s", shortSHA(s.Commit), s.Skipped)
+	}
+	verdict := "good"
+	if s.Bad {
+		verdict = "bad "
+	}
+	value := strings.TrimSpace(formatValue(s.Stats.Median, unit) + " " + formatSpread(s.Stats))
+	line := fmt.Sprintf("%s  %s  %s", shortSHA(s.Commit), verdict, value)
+	if s.PGood != 0 || s.PBad != 0 {
+		line += fmt.Sprintf("  p=%.3f vs good, p=%.3f vs bad", s.PGood, s.PBad)
+	}
+	if s.Ambiguous {
+		line += "  (ambiguous)"
+	}
+	if s.Cached {
+		line += "  (cached)"
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// Print outputs the first bad commit and the change between the ends.
+func (res *BisectResult) Print() {
+	change := fmt.Sprintf("%s %+.1f%% (%s → %s)", res.Unit, res.Delta,
+		formatValue(res.Good.Stats.Median, res.Unit), formatValue(res.Bad.Stats.Median, res.Unit))
+	if len(res.Candidates) > 1 {
+		short := make([]string, len(res.Candidates))
+		for i, c := range res.Candidates {
+			short[i] = shortSHA(c)
+		}
+		fmt.Printf("%s changed %s in one of: %s (the others were skipped)\n", displayName(res.Benchmark), change, strings.Join(short, " "))
+		return
+	}
+	fmt.Printf("%s changed %s at %s\n", displayName(res.Benchmark), change, res.FirstBad)
+}
@@ -0,0 +1,174 @@
+package bench
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// bisectRepo simulates a history c0..c8 where BenchmarkFoo slows from
+// ~100ns to ~150ns at c5. Commits in broken fail to build.
+type bisectRepo struct {
+	notes    map[string]string
+	broken   map[string]bool
+	checkout string
+	runs     []string
+}
+
+func newBisectRepo(broken ...string) *bisectRepo {
+	repo := &bisectRepo{notes: make(map[string]string), broken: make(map[string]bool)}
+	for _, sha := range broken {
+		repo.broken[sha] = true
+	}
+	return repo
+}
+
+func (repo *bisectRepo) handle(cfg runner.Config) (runner.IProcess, error) {
+	switch {
+	case cfg.IsCmd("git", "rev-parse", "--verify"):
+		rev := strings.TrimSuffix(cfg.Args[2], "^{commit}")
+		return runner.MockProcess([]byte(map[string]string{"v1": "c0", "HEAD": "c8"}[rev]+"\n"), nil), nil
+	case cfg.IsCmd("git", "rev-list", "--reverse", "--ancestry-path", "c0..c8"):
+		return runner.MockProcess([]byte("c1\nc2\nc3\nc4\nc5\nc6\nc7\nc8\n"), nil), nil
+	case cfg.IsCmd("git", "notes", "--ref=benchmarks", "show"):
+		note, ok := repo.notes[cfg.Args[3]]
+		if !ok {
+			return runner.MockProcess(nil, fmt.Errorf("no note")), nil
+		}
+		return runner.MockProcess([]byte(note), nil), nil
+	case cfg.IsCmd("git", "notes", "--ref=benchmarks", "add", "-f", "-m"):
+		repo.notes[cfg.Args[6]] = cfg.Args[5]
+	case cfg.IsCmd("git", "worktree", "add", "--detach"):
+		repo.checkout = cfg.Args[4]
+	case cfg.IsCmd("git", "-C") && cfg.HasArg("checkout"):
+		repo.checkout = cfg.Args[len(cfg.Args)-1]
+	case cfg.IsCmd("go", "-C") && cfg.HasArg("test"):
+		repo.runs = append(repo.runs, repo.checkout)
+		if repo.broken[repo.checkout] {
+			return runner.MockProcess(nil, fmt.Errorf("build failed")), nil
+		}
+		base := 100.0
+		if repo.checkout >= "c5" {
+			base = 150
+		}
+		var out strings.Builder
+		for i := range 10 {
+			fmt.Fprintf(&out, `{"Action":"output","Package":"pkg","Output":"BenchmarkFoo-8   \t 1000\t  %.1f ns/op\n"}`+"\n", base+float64(i)/10)
+		}
+		return runner.MockProcess([]byte(out.String()), nil), nil
+	}
+	return nil, nil
+}
+
+func TestBisect(t *testing.T) {
+	repo := newBisectRepo()
+	mock := runner.NewMock()
+	mock.Handler = repo.handle
+
+	var progress []string
+	res, err := Bisect(mock, BisectOptions{Good: "v1", Bad: "HEAD", Benchmark: "Foo", Progress: func(s BisectStep) {
+		progress = append(progress, s.Commit)
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "BenchmarkFoo", res.Benchmark)
+	assert.Equal(t, "c5", res.FirstBad)
+	assert.Equal(t, []string{"c5"}, res.Candidates)
+	assert.InDelta(t, 50.0, res.Delta, 0.5)
+	assert.Equal(t, []string{"c0", "c8", "c4", "c6", "c5"}, progress)
+	assert.Equal(t, []string{"c0", "c8", "c4", "c6", "c5"}, repo.runs)
+	for _, s := range res.Steps {
+		assert.Equal(t, s.Commit >= "c5", s.Bad, s.Commit)
+		assert.False(t, s.Ambiguous, s.Commit)
+	}
+
+	// Every run is stored, so bisecting again needs no benchmark runs
+	assert.Contains(t, repo.notes["c4"], `"name":"BenchmarkFoo-8"`)
+	repo.runs = nil
+	res, err = Bisect(mock, BisectOptions{Good: "v1", Bad: "HEAD", Benchmark: "BenchmarkFoo-8"})
+	require.NoError(t, err)
+	assert.Equal(t, "c5", res.FirstBad)
+	assert.Empty(t, repo.runs)
+	assert.True(t, res.Good.Cached)
+
+	var removed bool
+	for _, c := range mock.Calls() {
+		if c.IsCmd("git", "worktree", "remove", "--force") {
+			removed = true
+		}
+		if c.IsCmd("go", "-C") && c.HasArg("test") {
+			assert.True(t, c.HasArg("^BenchmarkFoo$"))
+			assert.True(t, c.HasArg("10"))
+		}
+	}
+	assert.True(t, removed)
+}
+
+func TestBisectSkipsBrokenCommits(t *testing.T) {
+	repo := newBisectRepo("c5")
+	mock := runner.NewMock()
+	mock.Handler = repo.handle
+
+	res, err := Bisect(mock, BisectOptions{Good: "v1", Bad: "HEAD", Benchmark: "Foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "c6", res.FirstBad)
+	assert.Equal(t, []string{"c5", "c6"}, res.Candidates)
+	assert.Contains(t, res.Steps[len(res.Steps)-1].Skipped, "build failed")
+}
+
+func TestBisectNoChange(t *testing.T) {
+	repo := newBisectRepo()
+	mock := runner.NewMock()
+	mock.Handler = repo.handle
+	data := `{"packages":{"pkg":[` + strings.Repeat(`{"name":"BenchmarkFoo-8","ns_per_op":100},`, 9) + `{"name":"BenchmarkFoo-8","ns_per_op":100}]}}`
+	repo.notes["c0"], repo.notes["c8"] = data, data
+
+	_, err := Bisect(mock, BisectOptions{Good: "v1", Bad: "HEAD", Benchmark: "Foo"})
+	assert.ErrorContains(t, err, "doesn't differ significantly")
+}
+
+func TestPickMidpoint(t *testing.T) {
+	assert.Equal(t, 3, pickMidpoint(-1, 7, nil))
+	assert.Equal(t, 0, pickMidpoint(-1, 1, nil))
+	assert.Equal(t, -1, pickMidpoint(-1, 0, nil))
+	assert.Equal(t, 2, pickMidpoint(1, 4, map[int]bool{}))
+	assert.Equal(t, 3, pickMidpoint(1, 4, map[int]bool{2: true}))
+	assert.Equal(t, -1, pickMidpoint(1, 4, map[int]bool{2: true, 3: true}))
+}
+
+func TestBenchPattern(t *testing.T) {
+	assert.Equal(t, "BenchmarkFoo", benchmarkName("Foo-8"))
+	assert.Equal(t, "BenchmarkFoo/size=1k", benchmarkName("BenchmarkFoo/size=1k"))
+	assert.Equal(t, `^BenchmarkFoo$/^size=1k\.x$`, benchPattern("BenchmarkFoo/size=1k.x"))
+}
+
+func TestBisectStepDescribe(t *testing.T) {
+	s := BisectStep{Commit: "0123456789", Bad: true, Stats: Summarize([]float64{150}), PGood: 0.001, PBad: 0.4, Cached: true}
+	assert.Equal(t, "0123456  bad   150.0 ns  p=0.001 vs good, p=0.400 vs bad  (cached)", s.Describe("ns/op"))
+	s = BisectStep{Commit: "abc", Skipped: "build failed"}
+	assert.Equal(t, "abc  skip  build failed", s.Describe("ns/op"))
+}
+
+func TestBisectIgnoresNotesFromOtherMachines(t *testing.T) {
+	repo := newBisectRepo()
+	mock := runner.NewMock()
+	mock.Handler = repo.handle
+	data := `{"packages":{"pkg":[` + strings.Repeat(`{"name":"BenchmarkFoo-8","ns_per_op":100},`, 9) + `{"name":"BenchmarkFoo-8","ns_per_op":100}]},"env":{"goos":"plan9","goarch":"mips"}}`
+	repo.notes["c0"] = data
+
+	res, err := Bisect(mock, BisectOptions{Good: "v1", Bad: "HEAD", Benchmark: "Foo"})
+	require.NoError(t, err)
+	assert.False(t, res.Good.Cached)
+	assert.Equal(t, "c0", repo.runs[0])
+	// The other machine's results are kept in their own entry
+	entries, err := parseNote([]byte(repo.notes["c0"]))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Len(t, e.Packages["pkg"], 10)
+	}
+}
@@ -2,7 +2,9 @@ package bench
 
 import (
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -10,16 +12,54 @@ import (
 type Comparison struct {
 	Packages       map[string][]Delta
 	PreviousCommit string
+	// EnvMismatch lists the environment differences that make the reports
+	// incomparable, EnvNotes those that only add noise (see
+	// Environment.Diff)
+	EnvMismatch []string `json:",omitempty"`
+	EnvNotes    []string `json:",omitempty"`
 }
 
-// Delta represents the change in a single benchmark
+// Delta represents the change in a single benchmark. Current and Previous
+// hold the median of each metric over the benchmark's samples (one per
+// -count run), and the deltas compare those medians.
 type Delta struct {
 	Name         string
 	Current      BenchmarkResult
 	Previous     *BenchmarkResult
-	NsPerOpDelta float64 // percentage change, negative = faster
-	BytesDelta   float64 // percentage change
-	AllocsDelta  float64 // percentage change
+	NsPerOpDelta float64       // percentage change, negative = faster
+	BytesDelta   float64       // percentage change
+	AllocsDelta  float64       // percentage change
+	Time         Stats         // ns/op samples of the current report
+	PreviousTime Stats         // ns/op samples of the previous report
+	P            float64       // Mann-Whitney U p-value of the ns/op change
+	BytesP       float64       // p-value of the B/op change
+	AllocsP      float64       // p-value of the allocs/op change
+	Metrics      []MetricDelta // custom units, in Units order
+}
+
+// MetricDelta compares the samples of a custom metric, e.g. MB/s
+type MetricDelta struct {
+	Unit     string
+	Current  Stats
+	Previous *Stats  // nil when the previous results lack the unit
+	Delta    float64 // percentage change of the medians
+	P        float64 // Mann-Whitney U p-value
+}
+
+// Significant reports whether the change is unlikely to be noise.
+func (m MetricDelta) Significant() bool {
+	return m.Previous != nil && m.P < Alpha
+}
+
+// HigherIsBetter reports whether larger values of a unit are improvements,
+// as for rates such as MB/s; for every other unit smaller is better.
+func HigherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s")
+}
+
+// Significant reports whether the ns/op change is unlikely to be noise.
+func (d Delta) Significant() bool {
+	return d.Previous != nil && d.P < Alpha
 }
 
 // Compare calculates deltas between current and previous benchmark reports
@@ -32,43 +72,56 @@ func Compare(current, previous *BenchmarkReport) *Comparison {
 		return comp
 	}
 
-	// Build lookup map from previous results
-	prevMap := make(map[string]map[string]BenchmarkResult)
+	if previous != nil && current.Env != nil {
+		if previous.Env != nil {
+			comp.EnvMismatch, comp.EnvNotes = current.Env.Diff(previous.Env)
+		} else {
+			comp.EnvNotes = append(comp.EnvNotes, "the previous results don't record their environment")
+		}
+	}
+
+	// Collect every previous sample per benchmark
+	prevMap := make(map[string]map[string][]BenchmarkResult)
 	if previous != nil {
 		for pkg, results := range previous.Packages {
-			if prevMap[pkg] == nil {
-				prevMap[pkg] = make(map[string]BenchmarkResult)
-			}
-			for _, r := range results {
-				// Normalize name by stripping -N suffix
-				name := stripCPUSuffix(r.Name)
-				prevMap[pkg][name] = r
-			}
+			_, prevMap[pkg] = groupSamples(results)
 		}
 	}
 
-	// Calculate deltas for each current result
 	for pkg, results := range current.Packages {
-		for _, curr := range results {
-			name := stripCPUSuffix(curr.Name)
+		names, samples := groupSamples(results)
+		for _, name := range names {
+			curr := samples[name]
 			delta := Delta{
 				Name:    name,
-				Current: curr,
+				Current: medianResult(curr),
+				Time:    Summarize(nsPerOpSamples(curr)),
+				P:       1,
+				BytesP:  1,
+				AllocsP: 1,
+			}
+
+			if prevSamples := prevMap[pkg][name]; len(prevSamples) > 0 {
+				prev := medianResult(prevSamples)
+				delta.Previous = &prev
+				delta.PreviousTime = Summarize(nsPerOpSamples(prevSamples))
+				delta.P = MannWhitneyU(nsPerOpSamples(curr), nsPerOpSamples(prevSamples))
+				delta.BytesP = countP(bytesSamples(curr), bytesSamples(prevSamples))
+				delta.AllocsP = countP(allocsSamples(curr), allocsSamples(prevSamples))
+				delta.NsPerOpDelta = percentChange(prev.NsPerOp, delta.Current.NsPerOp)
+				delta.BytesDelta = percentChange(float64(prev.BytesPerOp), float64(delta.Current.BytesPerOp))
+				delta.AllocsDelta = percentChange(float64(prev.AllocsPerOp), float64(delta.Current.AllocsPerOp))
 			}
 
-			if pkgMap, ok := prevMap[pkg]; ok {
-				if prev, ok := pkgMap[name]; ok {
-					delta.Previous = &prev
-					if prev.NsPerOp > 0 {
-						delta.NsPerOpDelta = ((curr.NsPerOp - prev.NsPerOp) / prev.NsPerOp) * 100
-					}
-					if prev.BytesPerOp > 0 {
-						delta.BytesDelta = ((float64(curr.BytesPerOp) - float64(prev.BytesPerOp)) / float64(prev.BytesPerOp)) * 100
-					}
-					if prev.AllocsPerOp > 0 {
-						delta.AllocsDelta = ((float64(curr.AllocsPerOp) - float64(prev.AllocsPerOp)) / float64(prev.AllocsPerOp)) * 100
-					}
+			for _, unit := range delta.Current.Units() {
+				m := MetricDelta{Unit: unit, Current: Summarize(metricSamples(curr, unit)), P: 1}
+				if prevValues := metricSamples(prevMap[pkg][name], unit); len(prevValues) > 0 {
+					prev := Summarize(prevValues)
+					m.Previous = &prev
+					m.Delta = percentChange(prev.Median, m.Current.Median)
+					m.P = MannWhitneyU(metricSamples(curr, unit), prevValues)
 				}
+				delta.Metrics = append(delta.Metrics, m)
 			}
 
 			comp.Packages[pkg] = append(comp.Packages[pkg], delta)
@@ -78,12 +131,133 @@ func Compare(current, previous *BenchmarkReport) *Comparison {
 	return comp
 }
 
+// groupSamples groups a package's results by benchmark name, with the CPU
+// suffix stripped, returning the names in first-seen order.
+func groupSamples(results []BenchmarkResult) ([]string, map[string][]BenchmarkResult) {
+	var names []string
+	samples := make(map[string][]BenchmarkResult)
+	for _, r := range results {
+		name := stripCPUSuffix(r.Name)
+		if _, ok := samples[name]; !ok {
+			names = append(names, name)
+		}
+		samples[name] = append(samples[name], r)
+	}
+	return names, samples
+}
+
+// medianResult folds a benchmark's samples into one result holding the
+// median of each metric.
+func medianResult(samples []BenchmarkResult) BenchmarkResult {
+	iters := make([]float64, len(samples))
+	bytes := make([]float64, len(samples))
+	allocs := make([]float64, len(samples))
+	for i, s := range samples {
+		iters[i] = float64(s.Iterations)
+		bytes[i] = float64(s.BytesPerOp)
+		allocs[i] = float64(s.AllocsPerOp)
+	}
+	result := BenchmarkResult{
+		Name:        samples[0].Name,
+		Package:     samples[0].Package,
+		Iterations:  int64(Summarize(iters).Median),
+		NsPerOp:     Summarize(nsPerOpSamples(samples)).Median,
+		BytesPerOp:  int64(math.Round(Summarize(bytes).Median)),
+		AllocsPerOp: int64(math.Round(Summarize(allocs).Median)),
+	}
+	for _, s := range samples {
+		for unit := range s.Metrics {
+			if _, ok := result.Metrics[unit]; !ok {
+				if result.Metrics == nil {
+					result.Metrics = make(map[string]float64)
+				}
+				result.Metrics[unit] = Summarize(metricSamples(samples, unit)).Median
+			}
+		}
+	}
+	return result
+}
+
+// metricSamples returns the values of a custom metric, from the samples
+// that report it.
+func metricSamples(samples []BenchmarkResult, unit string) []float64 {
+	var values []float64
+	for _, s := range samples {
+		if v, ok := s.Metrics[unit]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func nsPerOpSamples(samples []BenchmarkResult) []float64 {
+	ns := make([]float64, len(samples))
+	for i, s := range samples {
+		ns[i] = s.NsPerOp
+	}
+	return ns
+}
+
+func bytesSamples(samples []BenchmarkResult) []float64 {
+	b := make([]float64, len(samples))
+	for i, s := range samples {
+		b[i] = float64(s.BytesPerOp)
+	}
+	return b
+}
+
+func allocsSamples(samples []BenchmarkResult) []float64 {
+	a := make([]float64, len(samples))
+	for i, s := range samples {
+		a[i] = float64(s.AllocsPerOp)
+	}
+	return a
+}
+
+// countP returns the p-value of a change in a memory metric. Unlike
+// timings, B/op and allocs/op are usually deterministic: when every sample
+// on each side is the same, a difference is certain (p = 0) even with a
+// single run each.
+func countP(curr, prev []float64) float64 {
+	if constant(curr) && constant(prev) {
+		if curr[0] == prev[0] {
+			return 1
+		}
+		return 0
+	}
+	return MannWhitneyU(curr, prev)
+}
+
+func constant(xs []float64) bool {
+	for _, x := range xs {
+		if x != xs[0] {
+			return false
+		}
+	}
+	return len(xs) > 0
+}
+
+// percentChange returns the change from prev to curr in percent, or 0 if
+// prev is 0.
+func percentChange(prev, curr float64) float64 {
+	if prev <= 0 {
+		return 0
+	}
+	return (curr - prev) / prev * 100
+}
+
 // Print outputs the comparison in a formatted table
 func (c *Comparison) Print() {
 	if len(c.Packages) == 0 {
 		fmt.Println("     (no benchmarks to compare)")
 		return
 	}
+	for _, m := range c.EnvMismatch {
+		fmt.Printf("  \033[38;2;255;255;0mwarning: different environment: %s\033[0m\n", m)
+	}
+	for _, n := range c.EnvNotes {
+		fmt.Printf("  note: %s\n", n)
+	}
 
 	// Sort packages
 	pkgNames := make([]string, 0, len(c.Packages))
@@ -92,13 +266,16 @@ func (c *Comparison) Print() {
 	}
 	sort.Strings(pkgNames)
 
-	fmt.Println("        time/op       delta     alloc/op   allocs/op  name")
+	fmt.Printf("  %12s %-5s  %-22s  %10s  %9s  %s\n", "time/op", "", "delta", "alloc/op", "allocs/op", "name")
+	tooFew := false
 	for _, pkg := range pkgNames {
 		deltas := c.Packages[pkg]
-		// Sort by ns/op (fastest first)
-		sort.Slice(deltas, func(i, j int) bool {
-			return deltas[i].Current.NsPerOp < deltas[j].Current.NsPerOp
-		})
+		names := make([]string, len(deltas))
+		nsPerOp := make([]float64, len(deltas))
+		for i, d := range deltas {
+			names[i] = d.Name
+			nsPerOp[i] = d.Current.NsPerOp
+		}
 
 		// Print package header
 		shortPkg := pkg
@@ -107,19 +284,28 @@ func (c *Comparison) Print() {
 		}
 		fmt.Printf("\033[1m%s\033[0m\n", shortPkg)
 
-		for _, d := range deltas {
-			name := d.Name
-			if strings.HasPrefix(name, "Benchmark") {
-				name = name[9:]
-			}
-
+		printTree(names, nsPerOp, func(i int, name string) {
+			d := deltas[i]
 			timeStr := formatBenchTime(d.Current.NsPerOp)
 			allocStr := formatBenchBytes(d.Current.BytesPerOp)
-			deltaStr := formatDelta(d.NsPerOpDelta, d.Previous != nil)
+			deltaStr := formatDelta(d)
 
-			fmt.Printf("  %12s  %10s  %10s  %9d  %s\n",
-				timeStr, deltaStr, allocStr, d.Current.AllocsPerOp, name)
-		}
+			fmt.Printf("  %12s %-5s  %s  %10s  %9d  %s\n",
+				timeStr, formatSpread(d.Time), deltaStr, allocStr, d.Current.AllocsPerOp, name)
+
+			for _, m := range d.Metrics {
+				fmt.Printf("  %12s %-5s  %s\n", formatMetric(m.Current.Median, m.Unit), formatSpread(m.Current), formatMetricDelta(m))
+			}
+
+			if d.Previous != nil && min(d.Time.N, d.PreviousTime.N) < minSamplesForSignificance() {
+				tooFew = true
+			}
+		}, func(name string) {
+			fmt.Printf("  %12s %-5s  %-22s  %10s  %9s  %s\n", "", "", "", "", "", name)
+		})
+	}
+	if tooFew {
+		fmt.Printf("  ~ no significant change; need %d or more samples (--count) on each side to detect one\n", minSamplesForSignificance())
 	}
 }
 
@@ -135,31 +321,70 @@ func (c *Comparison) HasDeltas() bool {
 	return false
 }
 
+// stripCPUSuffix strips the -N GOMAXPROCS suffix, which the testing
+// package leaves off when GOMAXPROCS is 1.
 func stripCPUSuffix(name string) string {
 	if idx := strings.LastIndex(name, "-"); idx > 0 {
-		return name[:idx]
+		if _, err := strconv.Atoi(name[idx+1:]); err == nil {
+			return name[:idx]
+		}
 	}
 	return name
 }
 
-func formatDelta(pct float64, hasPrevious bool) string {
-	if !hasPrevious {
-		return "     -"
+// formatSpread formats the confidence interval of a median as "± N%",
+// or "± ∞" when there are too few samples for one.
+func formatSpread(s Stats) string {
+	switch {
+	case s.N <= 1:
+		return ""
+	case !s.HasInterval():
+		return "± ∞"
 	}
+	return fmt.Sprintf("± %.0f%%", s.Spread())
+}
 
-	// Color: green for improvement (negative), red for regression (positive)
-	var color string
-	if pct < -1 {
-		color = "\033[38;2;0;255;0m" // green
-	} else if pct > 1 {
-		color = "\033[38;2;255;128;128m" // red
-	} else {
-		color = "\033[38;2;128;128;128m" // gray for ~0
+// formatDelta formats the ns/op change as "+10.2% (p=0.008 n=5)", or
+// "~ (p=0.400 n=5)" when it isn't significant.
+func formatDelta(d Delta) string {
+	if d.Previous == nil {
+		return fmt.Sprintf("%-22s", "     -")
 	}
+	return formatChange(d.NsPerOpDelta, d.P, d.Significant(), d.PreviousTime.N, d.Time.N, false)
+}
+
+// formatMetricDelta formats a custom metric's change like formatDelta.
+func formatMetricDelta(m MetricDelta) string {
+	if m.Previous == nil {
+		return fmt.Sprintf("%-22s", "     -")
+	}
+	return formatChange(m.Delta, m.P, m.Significant(), m.Previous.N, m.Current.N, HigherIsBetter(m.Unit))
+}
 
-	sign := ""
-	if pct > 0 {
-		sign = "+"
+func formatChange(pct, p float64, significant bool, prevN, currN int, higherIsBetter bool) string {
+	n := fmt.Sprintf("n=%d", currN)
+	if prevN != currN {
+		n = fmt.Sprintf("n=%d+%d", prevN, currN)
+	}
+
+	// Color: green for improvement, red for regression, gray for changes
+	// that could be noise
+	var color, text string
+	switch {
+	case !significant:
+		color = "\033[38;2;128;128;128m" // gray
+		text = fmt.Sprintf("~ (p=%.3f %s)", p, n)
+	default:
+		if (pct < 0) != higherIsBetter {
+			color = "\033[38;2;0;255;0m" // green
+		} else {
+			color = "\033[38;2;255;128;128m" // red
+		}
+		sign := ""
+		if pct > 0 {
+			sign = "+"
+		}
+		text = fmt.Sprintf("%s%.1f%% (p=%.3f %s)", sign, pct, p, n)
 	}
-	return fmt.Sprintf("%s%s%.1f%%\033[0m", color, sign, pct)
+	return fmt.Sprintf("%s%-22s\033[0m", color, text)
 }
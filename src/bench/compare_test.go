@@ -196,14 +196,131 @@ func TestComparisonPrintEmpty(t *testing.T) {
 
 func TestFormatDelta(t *testing.T) {
 	// No previous - should show dash
-	result := formatDelta(0, false)
+	result := formatDelta(Delta{})
 	assert.Contains(t, result, "-")
 
+	prev := &BenchmarkResult{}
+	five := Stats{N: 5}
+
 	// Improvement (negative)
-	result = formatDelta(-15.5, true)
-	assert.Contains(t, result, "-15.5%")
+	result = formatDelta(Delta{Previous: prev, NsPerOpDelta: -15.5, P: 0.008, Time: five, PreviousTime: five})
+	assert.Contains(t, result, "-15.5% (p=0.008 n=5)")
 
 	// Regression (positive)
-	result = formatDelta(10.2, true)
-	assert.Contains(t, result, "+10.2%")
+	result = formatDelta(Delta{Previous: prev, NsPerOpDelta: 10.2, P: 0.016, Time: five, PreviousTime: Stats{N: 3}})
+	assert.Contains(t, result, "+10.2% (p=0.016 n=3+5)")
+
+	// Not significant
+	result = formatDelta(Delta{Previous: prev, NsPerOpDelta: 10.2, P: 0.4, Time: five, PreviousTime: five})
+	assert.Contains(t, result, "~ (p=0.400 n=5)")
+	assert.NotContains(t, result, "10.2")
+}
+
+func samples(name string, ns ...float64) []BenchmarkResult {
+	var results []BenchmarkResult
+	for _, v := range ns {
+		results = append(results, BenchmarkResult{Name: name, NsPerOp: v, BytesPerOp: 64, AllocsPerOp: 2})
+	}
+	return results
+}
+
+func TestCompareSamples(t *testing.T) {
+	previous := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"pkg": append(samples("BenchmarkFoo-8", 100, 102, 98, 101, 99), samples("BenchmarkBar-8", 50, 52, 48, 51, 49)...),
+	}}
+	current := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"pkg": append(samples("BenchmarkFoo-8", 120, 119, 121, 118, 122), samples("BenchmarkBar-8", 51, 49, 50, 53, 47)...),
+	}}
+
+	comp := Compare(current, previous)
+	deltas := comp.Packages["pkg"]
+	require.Equal(t, 2, len(deltas))
+
+	// Samples are kept rather than overwriting one another
+	foo := deltas[0]
+	assert.Equal(t, "BenchmarkFoo", foo.Name)
+	assert.Equal(t, 5, foo.Time.N)
+	assert.Equal(t, 5, foo.PreviousTime.N)
+	assert.Equal(t, float64(120), foo.Current.NsPerOp)
+	assert.Equal(t, float64(100), foo.Previous.NsPerOp)
+	assert.Equal(t, int64(64), foo.Current.BytesPerOp)
+	assert.InDelta(t, 20, foo.NsPerOpDelta, 0.01)
+	assert.InDelta(t, 2.0/252, foo.P, 1e-9)
+	assert.True(t, foo.Significant())
+
+	bar := deltas[1]
+	assert.Equal(t, float64(50), bar.Current.NsPerOp)
+	assert.False(t, bar.Significant())
+}
+
+func TestCompareSingleSampleNotSignificant(t *testing.T) {
+	current := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": samples("BenchmarkFoo-8", 200)}}
+	previous := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": samples("BenchmarkFoo-8", 100)}}
+
+	d := Compare(current, previous).Packages["pkg"][0]
+	assert.InDelta(t, 100, d.NsPerOpDelta, 0.01)
+	assert.Equal(t, float64(1), d.P)
+	assert.False(t, d.Significant())
+}
+
+func TestComparisonPrintSignificance(t *testing.T) {
+	current := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": samples("BenchmarkFoo-8", 200)}}
+	previous := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": samples("BenchmarkFoo-8", 100)}}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	Compare(current, previous).Print()
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	assert.Contains(t, output, "~ (p=1.000 n=1)")
+	assert.Contains(t, output, "need 4 or more samples")
+}
+
+func TestCompareCustomMetrics(t *testing.T) {
+	withMetric := func(unit string, values ...float64) []BenchmarkResult {
+		var results []BenchmarkResult
+		for _, v := range values {
+			results = append(results, BenchmarkResult{Name: "BenchmarkCopy-8", NsPerOp: 100, Metrics: map[string]float64{unit: v}})
+		}
+		return results
+	}
+	previous := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": withMetric("MB/s", 400, 401, 399, 398, 402)}}
+	current := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": append(
+		withMetric("MB/s", 300, 301, 299, 298, 302),
+		BenchmarkResult{Name: "BenchmarkCopy-8", NsPerOp: 100, Metrics: map[string]float64{"p99-ns": 7}},
+	)}}
+
+	d := Compare(current, previous).Packages["pkg"][0]
+	assert.Equal(t, map[string]float64{"MB/s": 300, "p99-ns": 7}, d.Current.Metrics)
+	require.Equal(t, 2, len(d.Metrics))
+
+	mbs := d.Metrics[0]
+	assert.Equal(t, "MB/s", mbs.Unit)
+	assert.Equal(t, 5, mbs.Current.N)
+	require.NotNil(t, mbs.Previous)
+	assert.InDelta(t, -25, mbs.Delta, 0.01)
+	assert.True(t, mbs.Significant())
+	// Lower throughput is a regression
+	assert.Contains(t, formatMetricDelta(mbs), "255;128;128m-25.0% (p=0.008 n=5)")
+
+	p99 := d.Metrics[1]
+	assert.Equal(t, "p99-ns", p99.Unit)
+	assert.Nil(t, p99.Previous)
+	assert.False(t, p99.Significant())
+	assert.Contains(t, formatMetricDelta(p99), "-")
+}
+
+func TestHigherIsBetter(t *testing.T) {
+	assert.True(t, HigherIsBetter("MB/s"))
+	assert.True(t, HigherIsBetter("ops/s"))
+	assert.False(t, HigherIsBetter("p99-ns"))
+	assert.False(t, HigherIsBetter("items/op"))
 }
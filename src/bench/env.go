@@ -0,0 +1,197 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// Environment describes the machine and toolchain a report was measured
+// on.
+type Environment struct {
+	GOOS       string  `json:"goos,omitempty"`
+	GOARCH     string  `json:"goarch,omitempty"`
+	CPU        string  `json:"cpu,omitempty"` // model, from the cpu: line of go test
+	GOMAXPROCS int     `json:"gomaxprocs,omitempty"`
+	GoVersion  string  `json:"go_version,omitempty"`
+	Kernel     string  `json:"kernel,omitempty"`
+	LoadAvg    float64 `json:"loadavg,omitempty"` // 1-minute load average before the run
+}
+
+// String formats the environment on one line.
+func (e *Environment) String() string {
+	var parts []string
+	if e.GOOS != "" || e.GOARCH != "" {
+		parts = append(parts, e.GOOS+"/"+e.GOARCH)
+	}
+	if e.CPU != "" {
+		parts = append(parts, e.CPU)
+	}
+	if e.GOMAXPROCS > 0 {
+		parts = append(parts, fmt.Sprintf("GOMAXPROCS=%d", e.GOMAXPROCS))
+	}
+	if e.GoVersion != "" {
+		parts = append(parts, e.GoVersion)
+	}
+	if e.Kernel != "" {
+		parts = append(parts, "kernel "+e.Kernel)
+	}
+	if e.LoadAvg > 0 {
+		parts = append(parts, fmt.Sprintf("load %.2f", e.LoadAvg))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Busy reports whether the load average before the run was high enough to
+// skew the results.
+func (e *Environment) Busy() bool {
+	return e.GOMAXPROCS > 0 && e.LoadAvg > DefaultMaxLoad(e.GOMAXPROCS)
+}
+
+// Diff compares the environment of current results with that of previous
+// ones. Incompatible differences (platform, CPU model, GOMAXPROCS) make
+// the results incomparable; notable ones (Go version, kernel, a busy
+// machine) only add noise. Fields missing on either side aren't compared.
+func (e *Environment) Diff(previous *Environment) (incompatible, notable []string) {
+	changed := func(curr, prev string) bool {
+		return curr != "" && prev != "" && curr != prev
+	}
+	if changed(e.GOOS, previous.GOOS) || changed(e.GOARCH, previous.GOARCH) {
+		incompatible = append(incompatible, fmt.Sprintf("platform %s/%s (was %s/%s)", e.GOOS, e.GOARCH, previous.GOOS, previous.GOARCH))
+	}
+	if changed(e.CPU, previous.CPU) {
+		incompatible = append(incompatible, fmt.Sprintf("cpu %s (was %s)", e.CPU, previous.CPU))
+	}
+	if e.GOMAXPROCS > 0 && previous.GOMAXPROCS > 0 && e.GOMAXPROCS != previous.GOMAXPROCS {
+		incompatible = append(incompatible, fmt.Sprintf("GOMAXPROCS %d (was %d)", e.GOMAXPROCS, previous.GOMAXPROCS))
+	}
+	if changed(e.GoVersion, previous.GoVersion) {
+		notable = append(notable, fmt.Sprintf("Go %s (was %s)", e.GoVersion, previous.GoVersion))
+	}
+	if changed(e.Kernel, previous.Kernel) {
+		notable = append(notable, fmt.Sprintf("kernel %s (was %s)", e.Kernel, previous.Kernel))
+	}
+	if e.Busy() {
+		notable = append(notable, fmt.Sprintf("load average was %.2f before the current run", e.LoadAvg))
+	}
+	if previous.Busy() {
+		notable = append(notable, fmt.Sprintf("load average was %.2f before the previous run", previous.LoadAvg))
+	}
+	return incompatible, notable
+}
+
+// hostEnvironment describes this machine as far as choosing between
+// stored results goes, without running anything.
+func hostEnvironment() *Environment {
+	return &Environment{
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		CPU:        cpuModel(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+}
+
+// captureEnvironment records the environment benchmarks are about to run
+// in, with the Go version of the toolchain selected in dir. The platform
+// and CPU model as go test reports them replace the host's afterwards.
+func captureEnvironment(r runner.CommandRunner, dir string) *Environment {
+	env := hostEnvironment()
+	env.Kernel = kernelRelease()
+	if load, err := loadAverage(); err == nil {
+		env.LoadAvg = load
+	}
+
+	args := []string{"env", "GOVERSION"}
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	if proc, err := runner.Cmd("go", args...).WithQuiet().Run(r); err == nil {
+		output, _ := io.ReadAll(proc.Stdout())
+		if proc.Wait() == nil {
+			env.GoVersion = strings.TrimSpace(string(output))
+		}
+	}
+	return env
+}
+
+// update takes the fields go test reported from the environment parsed
+// out of its output.
+func (e *Environment) update(parsed *Environment) {
+	if parsed == nil {
+		return
+	}
+	if parsed.GOOS != "" {
+		e.GOOS = parsed.GOOS
+	}
+	if parsed.GOARCH != "" {
+		e.GOARCH = parsed.GOARCH
+	}
+	if parsed.CPU != "" {
+		e.CPU = parsed.CPU
+	}
+}
+
+// DefaultMaxLoad is the highest 1-minute load average at which a machine
+// with cpus CPUs counts as idle enough to benchmark on: a quarter of the
+// CPUs, and at least 1.
+func DefaultMaxLoad(cpus int) float64 {
+	return max(1, float64(cpus)/4)
+}
+
+// StabilizeOptions configures the pre-run stability check.
+type StabilizeOptions struct {
+	MaxLoad float64       // highest acceptable load average (default DefaultMaxLoad)
+	Timeout time.Duration // how long to wait for the load to drop (default 2m)
+}
+
+// stabilizePoll is how often Stabilize checks the load average.
+const stabilizePoll = 5 * time.Second
+
+// Stabilize checks that the machine is fit for benchmarking. It waits up
+// to Timeout for the 1-minute load average to drop to MaxLoad, failing if
+// it doesn't, and warns about CPUs whose frequency governor isn't
+// "performance". Checks the platform doesn't support are skipped.
+func Stabilize(opts StabilizeOptions) ([]string, error) {
+	return stabilize(opts, loadAverage, cpuGovernors, time.Sleep)
+}
+
+func stabilize(opts StabilizeOptions, load func() (float64, error), governors func() []string, sleep func(time.Duration)) ([]string, error) {
+	if opts.MaxLoad <= 0 {
+		opts.MaxLoad = DefaultMaxLoad(runtime.GOMAXPROCS(0))
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+
+	var warnings []string
+	counts := make(map[string]int)
+	govs := governors()
+	for _, g := range govs {
+		counts[g]++
+	}
+	for _, g := range slices.Sorted(maps.Keys(counts)) {
+		if g != "performance" {
+			warnings = append(warnings, fmt.Sprintf("%d of %d CPUs use the %q frequency governor rather than \"performance\" (try: sudo cpupower frequency-set -g performance)", counts[g], len(govs), g))
+		}
+	}
+
+	for waited := time.Duration(0); ; waited += stabilizePoll {
+		l, err := load()
+		if err != nil {
+			return append(warnings, fmt.Sprintf("can't check the load average: %v", err)), nil
+		}
+		if l <= opts.MaxLoad {
+			return warnings, nil
+		}
+		if waited >= opts.Timeout {
+			return warnings, fmt.Errorf("machine is busy: load average %.2f is still above %.2f after %s", l, opts.MaxLoad, opts.Timeout)
+		}
+		sleep(stabilizePoll)
+	}
+}
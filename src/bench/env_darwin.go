@@ -0,0 +1,39 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// loadAverage returns the 1-minute load average.
+func loadAverage() (float64, error) {
+	// struct loadavg { fixpt_t ldavg[3]; long fscale; }
+	raw, err := unix.SysctlRaw("vm.loadavg")
+	if err != nil {
+		return 0, err
+	}
+	if len(raw) < 24 {
+		return 0, fmt.Errorf("unexpected vm.loadavg size %d", len(raw))
+	}
+	fscale := binary.LittleEndian.Uint64(raw[16:24])
+	if fscale == 0 {
+		return 0, fmt.Errorf("vm.loadavg has no scale")
+	}
+	return float64(binary.LittleEndian.Uint32(raw[0:4])) / float64(fscale), nil
+}
+
+// cpuGovernors returns nothing: macOS doesn't expose frequency governors.
+func cpuGovernors() []string {
+	return nil
+}
+
+// cpuModel returns the CPU brand string, as go test prints it.
+func cpuModel() string {
+	name, err := unix.Sysctl("machdep.cpu.brand_string")
+	if err != nil {
+		return ""
+	}
+	return name
+}
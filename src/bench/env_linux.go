@@ -0,0 +1,50 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loadAverage returns the 1-minute load average.
+func loadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected /proc/loadavg contents %q", data)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// cpuGovernors returns each CPU's frequency scaling governor, or nothing
+// where frequency scaling isn't exposed (as in most VMs and containers).
+func cpuGovernors() []string {
+	paths, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor")
+	var governors []string
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			governors = append(governors, strings.TrimSpace(string(data)))
+		}
+	}
+	return governors
+}
+
+// cpuModel returns the CPU model name from /proc/cpuinfo, as go test
+// prints it, or "" where the kernel doesn't report one.
+func cpuModel() string {
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package bench
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func loadAverage() (float64, error) {
+	return 0, fmt.Errorf("not supported on %s", runtime.GOOS)
+}
+
+func cpuGovernors() []string {
+	return nil
+}
+
+func cpuModel() string {
+	return ""
+}
@@ -0,0 +1,114 @@
+package bench
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestEnvironmentDiff(t *testing.T) {
+	laptop := &Environment{GOOS: "darwin", GOARCH: "arm64", CPU: "Apple M2", GOMAXPROCS: 8, GoVersion: "go1.22.1", Kernel: "23.4.0", LoadAvg: 0.5}
+	ci := &Environment{GOOS: "linux", GOARCH: "amd64", CPU: "AMD EPYC 7B13", GOMAXPROCS: 4, GoVersion: "go1.22.2", Kernel: "6.8.0", LoadAvg: 3.5}
+
+	incompatible, notable := ci.Diff(laptop)
+	assert.Equal(t, []string{
+		"platform linux/amd64 (was darwin/arm64)",
+		"cpu AMD EPYC 7B13 (was Apple M2)",
+		"GOMAXPROCS 4 (was 8)",
+	}, incompatible)
+	assert.Equal(t, []string{
+		"Go go1.22.2 (was go1.22.1)",
+		"kernel 6.8.0 (was 23.4.0)",
+		"load average was 3.50 before the current run",
+	}, notable)
+
+	incompatible, notable = laptop.Diff(laptop)
+	assert.Empty(t, incompatible)
+	assert.Empty(t, notable)
+
+	// Fields missing on either side aren't compared
+	incompatible, _ = (&Environment{GOOS: "linux"}).Diff(&Environment{CPU: "Apple M2"})
+	assert.Empty(t, incompatible)
+}
+
+func TestEnvironmentString(t *testing.T) {
+	env := &Environment{GOOS: "linux", GOARCH: "amd64", CPU: "AMD EPYC 7B13", GOMAXPROCS: 4, GoVersion: "go1.22.2", Kernel: "6.8.0", LoadAvg: 0.25}
+	assert.Equal(t, "linux/amd64, AMD EPYC 7B13, GOMAXPROCS=4, go1.22.2, kernel 6.8.0, load 0.25", env.String())
+	assert.Equal(t, "", (&Environment{}).String())
+}
+
+func TestDefaultMaxLoad(t *testing.T) {
+	assert.Equal(t, 1.0, DefaultMaxLoad(2))
+	assert.Equal(t, 4.0, DefaultMaxLoad(16))
+}
+
+func TestRunBenchmarksRecordsEnvironment(t *testing.T) {
+	mock := runner.NewMock()
+	output := `{"Action":"output","Package":"pkg","Output":"goos: plan9\n"}
+{"Action":"output","Package":"pkg","Output":"goarch: mips\n"}
+{"Action":"output","Package":"pkg","Output":"cpu: Test CPU @ 1.00GHz\n"}
+{"Action":"output","Package":"pkg","Output":"BenchmarkFoo-8   \t 1000\t  1234 ns/op\n"}`
+	mock.SetResponse("go", []string{"test", "-json", "-run", "^$", "-bench", ".", "-benchmem", "./..."}, []byte(output), nil)
+	mock.SetResponse("go", []string{"env", "GOVERSION"}, []byte("go1.99.0\n"), nil)
+
+	report, err := RunBenchmarks(mock, Options{})
+	require.NoError(t, err)
+	require.NotNil(t, report.Env)
+	assert.Equal(t, "plan9", report.Env.GOOS)
+	assert.Equal(t, "mips", report.Env.GOARCH)
+	assert.Equal(t, "Test CPU @ 1.00GHz", report.Env.CPU)
+	assert.Equal(t, "go1.99.0", report.Env.GoVersion)
+	assert.Positive(t, report.Env.GOMAXPROCS)
+	assert.Len(t, report.Packages["pkg"], 1)
+}
+
+func TestCompareEnvironment(t *testing.T) {
+	results := map[string][]BenchmarkResult{"pkg": {{Name: "BenchmarkFoo-8", NsPerOp: 100}}}
+	curr := &BenchmarkReport{Packages: results, Env: &Environment{GOOS: "linux", GOARCH: "amd64", CPU: "A"}}
+
+	comp := Compare(curr, &BenchmarkReport{Packages: results, Env: &Environment{GOOS: "linux", GOARCH: "amd64", CPU: "B"}})
+	assert.Equal(t, []string{"cpu A (was B)"}, comp.EnvMismatch)
+
+	comp = Compare(curr, &BenchmarkReport{Packages: results})
+	assert.Empty(t, comp.EnvMismatch)
+	assert.Equal(t, []string{"the previous results don't record their environment"}, comp.EnvNotes)
+
+	comp = Compare(&BenchmarkReport{Packages: results}, &BenchmarkReport{Packages: results})
+	assert.Empty(t, comp.EnvMismatch)
+	assert.Empty(t, comp.EnvNotes)
+}
+
+func TestStabilize(t *testing.T) {
+	var slept time.Duration
+	sleep := func(d time.Duration) { slept += d }
+	loads := []float64{3, 2, 0.5}
+	load := func() (float64, error) {
+		l := loads[0]
+		if len(loads) > 1 {
+			loads = loads[1:]
+		}
+		return l, nil
+	}
+	governors := func() []string { return []string{"performance", "powersave", "powersave"} }
+
+	warnings, err := stabilize(StabilizeOptions{MaxLoad: 1, Timeout: time.Minute}, load, governors, sleep)
+	require.NoError(t, err)
+	assert.Equal(t, 2*stabilizePoll, slept)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], `2 of 3 CPUs use the "powersave" frequency governor`)
+
+	// The load never drops
+	slept = 0
+	_, err = stabilize(StabilizeOptions{MaxLoad: 1, Timeout: 10 * time.Second}, func() (float64, error) { return 5, nil }, func() []string { return nil }, sleep)
+	assert.ErrorContains(t, err, "load average 5.00 is still above 1.00 after 10s")
+	assert.Equal(t, 10*time.Second, slept)
+
+	// Unsupported checks are skipped with a warning
+	warnings, err = stabilize(StabilizeOptions{}, func() (float64, error) { return 0, fmt.Errorf("not supported") }, func() []string { return nil }, sleep)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"can't check the load average: not supported"}, warnings)
+}
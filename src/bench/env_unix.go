@@ -0,0 +1,14 @@
+//go:build unix
+
+package bench
+
+import "golang.org/x/sys/unix"
+
+// kernelRelease returns the operating system's release, e.g. 6.8.0-45-generic.
+func kernelRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
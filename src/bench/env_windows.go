@@ -0,0 +1,5 @@
+package bench
+
+func kernelRelease() string {
+	return ""
+}
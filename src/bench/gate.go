@@ -0,0 +1,105 @@
+package bench
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// Threshold limits how far the benchmarks it matches may regress, in
+// percent over the baseline. A nil limit isn't checked; set limits are
+// positive, as config.Load requires.
+type Threshold struct {
+	Package   string // comma-separated package path globs, matched against path prefixes; empty matches every package
+	Benchmark string // benchmark name glob; empty matches every benchmark
+	MaxTime   *float64
+	MaxBytes  *float64
+	MaxAllocs *float64
+}
+
+// Matches reports whether the threshold applies to a benchmark. The name
+// glob is tried against the full name, without the "Benchmark" prefix,
+// and against the top-level benchmark of a sub-benchmark.
+func (t Threshold) Matches(pkg, name string) bool {
+	if t.Package != "" && !module.MatchPrefixPatterns(t.Package, pkg) {
+		return false
+	}
+	if t.Benchmark == "" {
+		return true
+	}
+	top, _, _ := strings.Cut(name, "/")
+	for _, n := range []string{name, top} {
+		for _, candidate := range []string{n, strings.TrimPrefix(n, "Benchmark")} {
+			if ok, _ := path.Match(t.Benchmark, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Gate fails significant regressions against the baseline. For each
+// benchmark and metric, the first matching threshold that limits the
+// metric applies, so a catch-all threshold goes last.
+type Gate struct {
+	Thresholds []Threshold
+}
+
+// limit returns the first matching limit for a metric, or nil.
+func (g Gate) limit(pkg, name string, field func(Threshold) *float64) *float64 {
+	for _, t := range g.Thresholds {
+		if max := field(t); max != nil && t.Matches(pkg, name) {
+			return max
+		}
+	}
+	return nil
+}
+
+// Check returns one message per benchmark metric that regressed
+// significantly by more than its limit.
+func (g Gate) Check(c *Comparison) []string {
+	pkgNames := make([]string, 0, len(c.Packages))
+	for pkg := range c.Packages {
+		pkgNames = append(pkgNames, pkg)
+	}
+	sort.Strings(pkgNames)
+
+	var violations []string
+	for _, pkg := range pkgNames {
+		deltas := append([]Delta(nil), c.Packages[pkg]...)
+		sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+		for _, d := range deltas {
+			if d.Previous == nil {
+				continue
+			}
+			metrics := []struct {
+				unit       string
+				prev, curr float64
+				delta      float64
+				p          float64
+				limit      *float64
+			}{
+				{"time/op", d.Previous.NsPerOp, d.Current.NsPerOp, d.NsPerOpDelta, d.P, g.limit(pkg, d.Name, func(t Threshold) *float64 { return t.MaxTime })},
+				{"B/op", float64(d.Previous.BytesPerOp), float64(d.Current.BytesPerOp), d.BytesDelta, d.BytesP, g.limit(pkg, d.Name, func(t Threshold) *float64 { return t.MaxBytes })},
+				{"allocs/op", float64(d.Previous.AllocsPerOp), float64(d.Current.AllocsPerOp), d.AllocsDelta, d.AllocsP, g.limit(pkg, d.Name, func(t Threshold) *float64 { return t.MaxAllocs })},
+			}
+			for _, m := range metrics {
+				if m.limit == nil || m.p >= Alpha || m.curr <= m.prev {
+					continue
+				}
+				// A metric that was zero, such as allocs/op of a benchmark
+				// that didn't allocate, has no percentage change, so any
+				// significant growth from it exceeds the limit
+				if m.prev == 0 {
+					violations = append(violations, fmt.Sprintf("%s %s: %s %g -> %g (p=%.3f)", pkg, d.Name, m.unit, m.prev, m.curr, m.p))
+				} else if m.delta > *m.limit {
+					violations = append(violations, fmt.Sprintf("%s %s: %s +%.1f%% > %.1f%% (p=%.3f)", pkg, d.Name, m.unit, m.delta, *m.limit, m.p))
+				}
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+)
+
+func limit(v float64) *float64 { return &v }
+
+func TestThresholdMatches(t *testing.T) {
+	tests := []struct {
+		threshold Threshold
+		pkg, name string
+		want      bool
+	}{
+		{Threshold{}, "example.com/app", "BenchmarkFoo", true},
+		{Threshold{Package: "example.com/app"}, "example.com/app/parser", "BenchmarkFoo", true},
+		{Threshold{Package: "example.com/other"}, "example.com/app", "BenchmarkFoo", false},
+		{Threshold{Package: "example.com/*/parser,example.com/lib"}, "example.com/app/parser", "BenchmarkFoo", true},
+		{Threshold{Benchmark: "Parse*"}, "example.com/app", "BenchmarkParseJSON", true},
+		{Threshold{Benchmark: "BenchmarkParse*"}, "example.com/app", "BenchmarkParseJSON", true},
+		{Threshold{Benchmark: "Parse"}, "example.com/app", "BenchmarkParse/size=10", true},
+		{Threshold{Benchmark: "Parse/size=1*"}, "example.com/app", "BenchmarkParse/size=10", true},
+		{Threshold{Benchmark: "Parse"}, "example.com/app", "BenchmarkParseJSON", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.threshold.Matches(tt.pkg, tt.name), "%+v %s %s", tt.threshold, tt.pkg, tt.name)
+	}
+}
+
+func TestGateCheck(t *testing.T) {
+	previous := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"example.com/app/parser": append(samples("BenchmarkParse-8", 100, 101, 99, 98, 102), samples("BenchmarkLex-8", 100, 101, 99, 98, 102)...),
+		"example.com/app/server": {
+			{Name: "BenchmarkServe-8", NsPerOp: 1000, BytesPerOp: 0, AllocsPerOp: 0},
+		},
+	}}
+	current := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		// Parse: 20% slower, significantly; Lex: 5% slower
+		"example.com/app/parser": append(samples("BenchmarkParse-8", 120, 121, 119, 118, 122), samples("BenchmarkLex-8", 103, 104, 107, 106, 105)...),
+		// Serve: a single sample each, so time is noise but allocs aren't
+		"example.com/app/server": {
+			{Name: "BenchmarkServe-8", NsPerOp: 5000, BytesPerOp: 64, AllocsPerOp: 1},
+		},
+	}}
+	comp := Compare(current, previous)
+
+	assert.Empty(t, Gate{}.Check(comp))
+
+	gate := Gate{Thresholds: []Threshold{
+		{Package: "example.com/app/server", MaxAllocs: limit(100)},
+		{Benchmark: "Lex", MaxTime: limit(5)},
+		{MaxTime: limit(10), MaxBytes: limit(50)},
+	}}
+	assert.Equal(t, []string{
+		"example.com/app/parser BenchmarkParse: time/op +20.0% > 10.0% (p=0.008)",
+		"example.com/app/server BenchmarkServe: B/op 0 -> 64 (p=0.000)",
+		"example.com/app/server BenchmarkServe: allocs/op 0 -> 1 (p=0.000)",
+	}, gate.Check(comp))
+
+	// The first threshold that limits a metric wins
+	gate = Gate{Thresholds: []Threshold{
+		{Benchmark: "Parse", MaxTime: limit(25)},
+		{MaxTime: limit(1)},
+	}}
+	assert.Equal(t, []string{"example.com/app/parser BenchmarkLex: time/op +5.0% > 1.0% (p=0.008)"}, gate.Check(comp))
+}
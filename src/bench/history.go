@@ -0,0 +1,235 @@
+package bench
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// DefaultStepThreshold is the smallest change, in percent, that History
+// flags as a step.
+const DefaultStepThreshold = 10.0
+
+// History is the time series of every benchmark over a range of commits.
+type History struct {
+	Commits []string `json:"commits"` // oldest first
+	Unit    string   `json:"unit"`
+	Series  []Series `json:"series"`
+}
+
+// Series is one benchmark's history: a point per commit with results.
+type Series struct {
+	Package   string  `json:"package"`
+	Benchmark string  `json:"benchmark"`
+	Points    []Point `json:"points"`
+	Step      *Step   `json:"step,omitempty"`
+}
+
+// Point is a benchmark's median at one commit.
+type Point struct {
+	Commit  string    `json:"commit"`
+	Value   float64   `json:"value"`
+	N       int       `json:"n"`
+	samples []float64 // every sample, for step detection
+}
+
+// Step is the most significant shift in a series: the medians of the
+// samples before and from Commit on.
+type Step struct {
+	Commit string  `json:"commit"` // first commit with the new level
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"` // percentage change
+	P      float64 `json:"p"`     // Mann-Whitney U p-value
+}
+
+// CommitRange lists the commits in a git revision range (e.g.
+// v1.0.0..HEAD), oldest first. A plain revision lists its ancestors. max
+// keeps only the newest commits; zero keeps all of them.
+func CommitRange(r runner.CommandRunner, revRange string, max int) ([]string, error) {
+	args := []string{"rev-list", "--reverse"}
+	if max > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", max))
+	}
+	proc, err := runner.Cmd("git", append(args, revRange)...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits in %s: %w", revRange, err)
+	}
+	output, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to list commits in %s: %w", revRange, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// BuildHistory builds each benchmark's series in a unit from the notes of
+// the commits, oldest first, and flags steps of at least threshold
+// percent.
+func BuildHistory(commits []string, notes map[string]*BenchmarkReport, unit string, threshold float64) *History {
+	h := &History{Commits: commits, Unit: unit, Series: []Series{}}
+	index := make(map[[2]string]int)
+	for _, sha := range commits {
+		report := notes[sha]
+		if report == nil {
+			continue
+		}
+		for pkg, results := range report.Packages {
+			names, samples := groupSamples(results)
+			for _, name := range names {
+				var values []float64
+				for _, s := range samples[name] {
+					if v, ok := s.Value(unit); ok {
+						values = append(values, v)
+					}
+				}
+				if len(values) == 0 {
+					continue
+				}
+				key := [2]string{pkg, name}
+				i, ok := index[key]
+				if !ok {
+					i = len(h.Series)
+					index[key] = i
+					h.Series = append(h.Series, Series{Package: pkg, Benchmark: name})
+				}
+				h.Series[i].Points = append(h.Series[i].Points, Point{Commit: sha, Value: Summarize(values).Median, N: len(values), samples: values})
+			}
+		}
+	}
+
+	for i := range h.Series {
+		h.Series[i].Step = detectStep(h.Series[i].Points, threshold)
+	}
+	sort.Slice(h.Series, func(i, j int) bool {
+		if h.Series[i].Package != h.Series[j].Package {
+			return h.Series[i].Package < h.Series[j].Package
+		}
+		return h.Series[i].Benchmark < h.Series[j].Benchmark
+	})
+	return h
+}
+
+// detectStep finds the most significant split of a series into an
+// earlier and a later run of commits, comparing the samples on either
+// side, among the splits whose medians differ by at least threshold
+// percent. Ties go to the larger change.
+func detectStep(points []Point, threshold float64) *Step {
+	var best *Step
+	for k := 1; k < len(points); k++ {
+		var before, after []float64
+		for _, p := range points[:k] {
+			before = append(before, p.samples...)
+		}
+		for _, p := range points[k:] {
+			after = append(after, p.samples...)
+		}
+		b, a := Summarize(before).Median, Summarize(after).Median
+		delta := percentChange(b, a)
+		if math.Abs(delta) < threshold {
+			continue
+		}
+		p := MannWhitneyU(before, after)
+		if p >= Alpha || (best != nil && (p > best.P || (p == best.P && math.Abs(delta) <= math.Abs(best.Delta)))) {
+			continue
+		}
+		best = &Step{Commit: points[k].Commit, Before: b, After: a, Delta: delta, P: p}
+	}
+	return best
+}
+
+// sparkBars are the sparkline levels, lowest first.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws a series across the commits, one character per commit,
+// with a space for commits without results.
+func sparkline(commits []string, points []Point) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	byCommit := make(map[string]float64, len(points))
+	for _, p := range points {
+		byCommit[p.Commit] = p.Value
+		lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+	}
+	var sb strings.Builder
+	for _, sha := range commits {
+		v, ok := byCommit[sha]
+		switch {
+		case !ok:
+			sb.WriteRune(' ')
+		case hi == lo:
+			sb.WriteRune(sparkBars[len(sparkBars)/2])
+		default:
+			level := int((v - lo) / (hi - lo) * float64(len(sparkBars)-1))
+			sb.WriteRune(sparkBars[level])
+		}
+	}
+	return sb.String()
+}
+
+// shortSHA abbreviates a commit for display.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// Print outputs a sparkline per benchmark, its first and last values, and
+// any step change.
+func (h *History) Print() {
+	if len(h.Series) == 0 {
+		fmt.Println("     (no benchmark results in range)")
+		return
+	}
+
+	width := 0
+	for _, s := range h.Series {
+		width = max(width, len([]rune(displayName(s.Benchmark))))
+	}
+
+	pkg := ""
+	for _, s := range h.Series {
+		if s.Package != pkg {
+			pkg = s.Package
+			shortPkg := pkg
+			if idx := strings.LastIndex(pkg, "/"); idx >= 0 {
+				shortPkg = pkg[idx+1:]
+			}
+			fmt.Printf("\033[1m%s\033[0m\n", shortPkg)
+		}
+
+		first, last := s.Points[0], s.Points[len(s.Points)-1]
+		line := fmt.Sprintf("  %-*s  %s  %12s → %-12s", width, displayName(s.Benchmark), sparkline(h.Commits, s.Points),
+			formatValue(first.Value, h.Unit), formatValue(last.Value, h.Unit))
+		if s.Step != nil {
+			color := "\033[38;2;255;128;128m" // red
+			if (s.Step.Delta < 0) != HigherIsBetter(h.Unit) {
+				color = "\033[38;2;0;255;0m" // green
+			}
+			line += fmt.Sprintf("  %sstep %+.1f%% at %s (p=%.3f)\033[0m", color, s.Step.Delta, shortSHA(s.Step.Commit), s.Step.P)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+// WriteCSV writes one row per benchmark and commit with results.
+func (h *History) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"commit", "package", "benchmark", "unit", "value", "n", "step"})
+	for _, s := range h.Series {
+		for _, p := range s.Points {
+			step := ""
+			if s.Step != nil && s.Step.Commit == p.Commit {
+				step = strconv.FormatFloat(s.Step.Delta, 'f', 1, 64)
+			}
+			cw.Write([]string{p.Commit, s.Package, s.Benchmark, h.Unit, strconv.FormatFloat(p.Value, 'f', -1, 64), strconv.Itoa(p.N), step})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// historyNotes returns notes for commits c0..c7 where BenchmarkFoo jumps
+// from ~100ns to ~150ns at c4, BenchmarkBar stays flat and c2 has no note.
+func historyNotes() ([]string, map[string]*BenchmarkReport) {
+	var commits []string
+	notes := make(map[string]*BenchmarkReport)
+	for i := range 8 {
+		sha := fmt.Sprintf("c%d", i)
+		commits = append(commits, sha)
+		if i == 2 {
+			continue
+		}
+		foo := 100.0 + float64(i)
+		if i >= 4 {
+			foo += 50
+		}
+		notes[sha] = &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+			"example.com/pkg": {
+				{Name: "BenchmarkFoo-8", NsPerOp: foo, AllocsPerOp: 2},
+				{Name: "BenchmarkFoo-8", NsPerOp: foo + 0.5, AllocsPerOp: 2},
+				{Name: "BenchmarkBar-8", NsPerOp: 50 + float64(i%2), Metrics: map[string]float64{"MB/s": 200}},
+			},
+		}}
+	}
+	return commits, notes
+}
+
+func TestCommitRange(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"rev-list", "--reverse", "--max-count=50", "v1.0.0..HEAD"}, []byte("aaa\nbbb\nccc\n"), nil)
+	commits, err := CommitRange(mock, "v1.0.0..HEAD", 50)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aaa", "bbb", "ccc"}, commits)
+
+	mock.SetResponse("git", []string{"rev-list", "--reverse", "nope"}, nil, fmt.Errorf("bad revision"))
+	_, err = CommitRange(mock, "nope", 0)
+	assert.NotNil(t, err)
+}
+
+func TestLoadNotes(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "list"}, []byte("blob1 aaa\nblob2 ccc\nblob3 zzz\n"), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "aaa"}, []byte(`{"packages":{"pkg":[{"name":"BenchmarkFoo-8","ns_per_op":1}]}}`), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "ccc"}, []byte(`{"packages":{}}`), nil)
+
+	notes, err := LoadNotes(mock, []string{"aaa", "bbb", "ccc"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(notes))
+	assert.Equal(t, 1.0, notes["aaa"].Packages["pkg"][0].NsPerOp)
+
+	// Only noted commits are fetched
+	for _, c := range mock.Calls() {
+		assert.False(t, c.IsCmd("git", "notes", "--ref=benchmarks", "show", "bbb"))
+	}
+
+	// No notes ref yet
+	mock = runner.NewMock()
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "list"}, nil, fmt.Errorf("no notes"))
+	notes, err = LoadNotes(mock, []string{"aaa"})
+	require.NoError(t, err)
+	assert.Empty(t, notes)
+}
+
+func TestBuildHistory(t *testing.T) {
+	commits, notes := historyNotes()
+	h := BuildHistory(commits, notes, "ns/op", DefaultStepThreshold)
+
+	require.Equal(t, 2, len(h.Series))
+	bar, foo := h.Series[0], h.Series[1]
+	assert.Equal(t, "BenchmarkBar", bar.Benchmark)
+	assert.Equal(t, 7, len(bar.Points))
+	assert.Nil(t, bar.Step)
+
+	assert.Equal(t, "BenchmarkFoo", foo.Benchmark)
+	assert.Equal(t, Point{Commit: "c0", Value: 100.25, N: 2, samples: []float64{100, 100.5}}, foo.Points[0])
+	require.NotNil(t, foo.Step)
+	assert.Equal(t, "c4", foo.Step.Commit)
+	assert.InDelta(t, 101.25, foo.Step.Before, 0.01)
+	assert.InDelta(t, 155.75, foo.Step.After, 0.01)
+	assert.Less(t, foo.Step.P, Alpha)
+
+	// A step below the threshold isn't flagged
+	h = BuildHistory(commits, notes, "ns/op", 60)
+	assert.Nil(t, h.Series[1].Step)
+
+	// Other units only include benchmarks that report them
+	h = BuildHistory(commits, notes, "MB/s", DefaultStepThreshold)
+	require.Equal(t, 1, len(h.Series))
+	assert.Equal(t, "BenchmarkBar", h.Series[0].Benchmark)
+}
+
+func TestSparkline(t *testing.T) {
+	points := []Point{{Commit: "a", Value: 10}, {Commit: "c", Value: 20}, {Commit: "d", Value: 15}}
+	assert.Equal(t, "▁ █▄", sparkline([]string{"a", "b", "c", "d"}, points))
+	assert.Equal(t, "▅▅", sparkline([]string{"a", "b"}, []Point{{Commit: "a", Value: 1}, {Commit: "b", Value: 1}}))
+}
+
+func TestHistoryWriteCSV(t *testing.T) {
+	commits, notes := historyNotes()
+	h := BuildHistory(commits, notes, "ns/op", DefaultStepThreshold)
+
+	var buf bytes.Buffer
+	require.NoError(t, h.WriteCSV(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 15, len(lines))
+	assert.Equal(t, "commit,package,benchmark,unit,value,n,step", lines[0])
+	assert.Equal(t, "c0,example.com/pkg,BenchmarkBar,ns/op,50,1,", lines[1])
+	assert.Contains(t, lines, "c4,example.com/pkg,BenchmarkFoo,ns/op,154.25,2,53.8")
+}
+
+func TestHistoryPrint(t *testing.T) {
+	commits, notes := historyNotes()
+	h := BuildHistory(commits, notes, "ns/op", DefaultStepThreshold)
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	h.Print()
+	(&History{Unit: "ns/op"}).Print()
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	assert.Contains(t, output, "pkg")
+	assert.Contains(t, output, "Foo  ▁▁ ▁▇▇▇█      100.2 ns → 157.2 ns")
+	assert.Contains(t, output, "step +53.8% at c4")
+	assert.Contains(t, output, "no benchmark results in range")
+}
@@ -1,9 +1,12 @@
 package bench
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
@@ -12,15 +15,33 @@ import (
 // NotesRef is the git notes reference for benchmark data
 const NotesRef = "refs/notes/benchmarks"
 
+// A note holds one JSON report per line, one per environment (see
+// envKey), so notes written on different machines merge as the union of
+// their lines. Readers pick the entry that matches this machine.
+
 // StoreNotes stores benchmark results in git notes for HEAD
 func StoreNotes(r runner.CommandRunner, report *BenchmarkReport) error {
-	data, err := json.Marshal(report)
+	return StoreNotesForCommit(r, "HEAD", report)
+}
+
+// StoreNotesForCommit stores benchmark results in git notes for a commit,
+// replacing any stored before from the same environment
+func StoreNotesForCommit(r runner.CommandRunner, sha string, report *BenchmarkReport) error {
+	entries := []*BenchmarkReport{report}
+	if stored, err := fetchEntries(r, sha); err == nil {
+		for _, e := range stored {
+			if !e.SameEnvironment(report.Env) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	data, err := formatNote(entries)
 	if err != nil {
 		return fmt.Errorf("failed to marshal report: %w", err)
 	}
 
 	// Use git notes add -f to overwrite any existing note
-	proc, err := runner.Cmd("git", "notes", "--ref=benchmarks", "add", "-f", "-m", string(data), "HEAD").
+	proc, err := runner.Cmd("git", "notes", "--ref=benchmarks", "add", "-f", "-m", string(data), sha).
 		WithQuiet().
 		Run(r)
 	if err != nil {
@@ -75,6 +96,15 @@ func FetchPrevious(r runner.CommandRunner) (*BenchmarkReport, string, error) {
 
 // FetchForCommit retrieves stored benchmark results for a specific commit
 func FetchForCommit(r runner.CommandRunner, sha string) (*BenchmarkReport, error) {
+	entries, err := fetchEntries(r, sha)
+	if err != nil {
+		return nil, err
+	}
+	return selectEntry(entries, hostEnvironment()), nil
+}
+
+// fetchEntries retrieves every environment's results for a commit
+func fetchEntries(r runner.CommandRunner, sha string) ([]*BenchmarkReport, error) {
 	proc, err := runner.Cmd("git", "notes", "--ref=benchmarks", "show", sha).
 		WithQuiet().
 		Run(r)
@@ -86,7 +116,76 @@ func FetchForCommit(r runner.CommandRunner, sha string) (*BenchmarkReport, error
 		return nil, fmt.Errorf("no benchmark data for commit %s", sha)
 	}
 
-	return parseNotesJSON(output)
+	return parseNote(output)
+}
+
+// LoadNotes loads the stored results of every commit in commits that has
+// them, keyed by commit.
+func LoadNotes(r runner.CommandRunner, commits []string) (map[string]*BenchmarkReport, error) {
+	notes := make(map[string]*BenchmarkReport)
+
+	// `git notes list` prints "<note blob> <annotated commit>" per line
+	proc, err := runner.Cmd("git", "notes", "--ref=benchmarks", "list").WithQuiet().Run(r)
+	if err != nil {
+		return notes, nil // no notes yet
+	}
+	output, _ := io.ReadAll(proc.Stdout())
+	if proc.Wait() != nil {
+		return notes, nil // no notes yet
+	}
+	noted := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			noted[fields[1]] = true
+		}
+	}
+
+	for _, sha := range commits {
+		if !noted[sha] {
+			continue
+		}
+		report, err := FetchForCommit(r, sha)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load benchmark notes for %s: %w", sha, err)
+		}
+		notes[sha] = report
+	}
+	return notes, nil
+}
+
+// FindBaseline returns the results to compare against and their commit.
+// A pinned commit must have stored results. With a base ref (e.g.
+// origin/main) the baseline is the merge base of HEAD and the ref, or
+// nothing if that commit has no results; otherwise it is the most recent
+// stored results.
+func FindBaseline(r runner.CommandRunner, pinned, baseRef string) (*BenchmarkReport, string, error) {
+	if pinned != "" {
+		report, err := FetchForCommit(r, pinned)
+		if err != nil {
+			return nil, "", fmt.Errorf("baseline: %w", err)
+		}
+		return report, pinned, nil
+	}
+
+	if baseRef != "" {
+		proc, err := runner.Cmd("git", "merge-base", "HEAD", baseRef).WithQuiet().Run(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to find merge base with %s: %w", baseRef, err)
+		}
+		output, _ := io.ReadAll(proc.Stdout())
+		if err := proc.Wait(); err != nil {
+			return nil, "", fmt.Errorf("failed to find merge base with %s: %w", baseRef, err)
+		}
+		sha := strings.TrimSpace(string(output))
+		report, err := FetchForCommit(r, sha)
+		if err != nil {
+			return nil, "", nil // base has no stored results
+		}
+		return report, sha, nil
+	}
+
+	report, sha, _ := FetchPrevious(r)
+	return report, sha, nil
 }
 
 // GetHeadSHA returns the current HEAD commit SHA
@@ -104,10 +203,90 @@ func GetHeadSHA(r runner.CommandRunner) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// parseNotesJSON parses a note and returns the entry for this machine
 func parseNotesJSON(data []byte) (*BenchmarkReport, error) {
-	var report BenchmarkReport
-	if err := json.Unmarshal(data, &report); err != nil {
+	entries, err := parseNote(data)
+	if err != nil {
 		return nil, err
 	}
-	return &report, nil
+	return selectEntry(entries, hostEnvironment()), nil
+}
+
+// parseNote parses the entries of a note, keeping the newest one per
+// environment: a merge can leave both sides' results for one.
+func parseNote(data []byte) ([]*BenchmarkReport, error) {
+	byKey := make(map[string]*BenchmarkReport)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var report BenchmarkReport
+		if err := dec.Decode(&report); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		key := envKey(report.Env)
+		if prev, ok := byKey[key]; !ok || !report.Time.Before(prev.Time) {
+			byKey[key] = &report
+		}
+	}
+	if len(byKey) == 0 {
+		return nil, fmt.Errorf("empty benchmark note")
+	}
+
+	entries := make([]*BenchmarkReport, 0, len(byKey))
+	for _, key := range slices.Sorted(maps.Keys(byKey)) {
+		entries = append(entries, byKey[key])
+	}
+	return entries, nil
+}
+
+// formatNote writes entries one per line, sorted by environment.
+func formatNote(entries []*BenchmarkReport) (string, error) {
+	sorted := slices.Clone(entries)
+	slices.SortStableFunc(sorted, func(a, b *BenchmarkReport) int {
+		return strings.Compare(envKey(a.Env), envKey(b.Env))
+	})
+	lines := make([]string, len(sorted))
+	for i, e := range sorted {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = string(data)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// envKey identifies the environment a note entry belongs to: results
+// from environments that Diff finds incompatible get separate entries.
+func envKey(env *Environment) string {
+	if env == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s|%s|%d", env.GOOS, env.GOARCH, env.CPU, env.GOMAXPROCS)
+}
+
+// SameEnvironment reports whether stored results were measured in env,
+// so that new results from it can be merged in. Results stored before
+// environments were recorded count as the same.
+func (r *BenchmarkReport) SameEnvironment(env *Environment) bool {
+	return r.Env == nil || envKey(r.Env) == envKey(env)
+}
+
+// selectEntry picks the newest entry measured in an environment
+// compatible with env, or the newest entry if none is.
+func selectEntry(entries []*BenchmarkReport, env *Environment) *BenchmarkReport {
+	var best *BenchmarkReport
+	bestMatch := false
+	for _, e := range entries {
+		match := false
+		if e.Env != nil {
+			incompatible, _ := env.Diff(e.Env)
+			match = len(incompatible) == 0
+		}
+		if best == nil || (match && !bestMatch) || (match == bestMatch && !e.Time.Before(best.Time)) {
+			best, bestMatch = e, match
+		}
+	}
+	return best
 }
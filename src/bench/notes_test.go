@@ -21,10 +21,11 @@ func TestStoreNotes(t *testing.T) {
 	err := StoreNotes(mock, report)
 	assert.Nil(t, err)
 
-	// Verify git notes command was called
+	// The existing note is read so other environments' results are kept
 	calls := mock.Calls()
-	require.Equal(t, 1, len(calls))
-	assert.Equal(t, "git", calls[0].Name)
+	require.Equal(t, 2, len(calls))
+	assert.True(t, calls[0].IsCmd("git", "notes", "--ref=benchmarks", "show", "HEAD"))
+	assert.True(t, calls[1].IsCmd("git", "notes", "--ref=benchmarks", "add"))
 }
 
 func TestStoreNotesError(t *testing.T) {
@@ -125,3 +126,55 @@ func TestParseNotesJSONInvalid(t *testing.T) {
 	_, err := parseNotesJSON([]byte("not json"))
 	assert.NotNil(t, err)
 }
+
+func TestFindBaseline(t *testing.T) {
+	note := []byte(`{"packages":{"pkg":[{"name":"BenchmarkFoo-8","ns_per_op":1500}]}}`)
+
+	// Pinned commit
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "v1.0.0"}, note, nil)
+	report, sha, err := FindBaseline(mock, "v1.0.0", "origin/main")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", sha)
+	assert.Equal(t, 1500.0, report.Packages["pkg"][0].NsPerOp)
+
+	// A pinned commit without results is an error
+	mock = runner.NewMock()
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "v0.9.0"}, nil, fmt.Errorf("no note"))
+	_, _, err = FindBaseline(mock, "v0.9.0", "")
+	assert.NotNil(t, err)
+
+	// Merge base with the base ref
+	mock = runner.NewMock()
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/main"}, []byte("abc123\n"), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "abc123"}, note, nil)
+	report, sha, err = FindBaseline(mock, "", "origin/main")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+	assert.NotNil(t, report)
+
+	// A merge base without results means no baseline
+	mock = runner.NewMock()
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/main"}, []byte("abc123\n"), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "abc123"}, nil, fmt.Errorf("no note"))
+	report, sha, err = FindBaseline(mock, "", "origin/main")
+	require.NoError(t, err)
+	assert.Nil(t, report)
+	assert.Equal(t, "", sha)
+
+	mock = runner.NewMock()
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/gone"}, nil, fmt.Errorf("unknown ref"))
+	_, _, err = FindBaseline(mock, "", "origin/gone")
+	assert.NotNil(t, err)
+}
+
+func TestStoreNotesForCommit(t *testing.T) {
+	mock := runner.NewMock()
+	report := &BenchmarkReport{Packages: map[string][]BenchmarkResult{"pkg": {{Name: "BenchmarkFoo-8", NsPerOp: 100}}}}
+
+	assert.Nil(t, StoreNotesForCommit(mock, "abc123", report))
+	calls := mock.Calls()
+	last := calls[len(calls)-1]
+	assert.True(t, last.IsCmd("git", "notes", "--ref=benchmarks", "add", "-f", "-m"))
+	assert.Equal(t, "abc123", last.Args[6])
+}
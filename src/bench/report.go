@@ -5,10 +5,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"regexp"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // BenchmarkResult holds parsed benchmark data
@@ -19,11 +20,51 @@ type BenchmarkResult struct {
 	NsPerOp    float64 `json:"ns_per_op"`
 	BytesPerOp int64   `json:"bytes_per_op"`
 	AllocsPerOp int64  `json:"allocs_per_op"`
+	// Metrics holds every other unit, e.g. MB/s from b.SetBytes or units
+	// reported with b.ReportMetric
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// Value returns the result's value in a unit: ns/op, B/op, allocs/op or a
+// custom metric.
+func (b BenchmarkResult) Value(unit string) (float64, bool) {
+	switch unit {
+	case "ns/op":
+		return b.NsPerOp, true
+	case "B/op":
+		return float64(b.BytesPerOp), true
+	case "allocs/op":
+		return float64(b.AllocsPerOp), true
+	}
+	v, ok := b.Metrics[unit]
+	return v, ok
+}
+
+// Units returns the units in Metrics, MB/s first and then alphabetically,
+// the order the testing package prints them in.
+func (b BenchmarkResult) Units() []string {
+	units := make([]string, 0, len(b.Metrics))
+	for unit := range b.Metrics {
+		units = append(units, unit)
+	}
+	sortUnits(units)
+	return units
+}
+
+func sortUnits(units []string) {
+	sort.Slice(units, func(i, j int) bool {
+		if (units[i] == "MB/s") != (units[j] == "MB/s") {
+			return units[i] == "MB/s"
+		}
+		return units[i] < units[j]
+	})
 }
 
 // BenchmarkReport holds all benchmark results grouped by package
 type BenchmarkReport struct {
 	Packages map[string][]BenchmarkResult `json:"packages"`
+	Env      *Environment                 `json:"env,omitempty"` // unset in results stored before it was recorded
+	Time     time.Time                    `json:"time,omitzero"` // when the benchmarks ran
 }
 
 // testEvent matches go test -json output
@@ -33,18 +74,16 @@ type testEvent struct {
 	Output  string `json:"Output"`
 }
 
-// benchPattern matches benchmark output lines:
-// BenchmarkFoo-8     10000    123456 ns/op    1234 B/op    56 allocs/op
-var benchPattern = regexp.MustCompile(
-	`^(Benchmark\S+)\s+(\d+)\s+([\d.]+)\s+ns/op(?:\s+(\d+)\s+B/op)?(?:\s+(\d+)\s+allocs/op)?`,
-)
-
 // ParseBenchmarkOutput parses go test -json output into a BenchmarkReport
 func ParseBenchmarkOutput(data []byte) (*BenchmarkReport, error) {
 	report := &BenchmarkReport{
 		Packages: make(map[string][]BenchmarkResult),
 	}
 
+	// test2json may split a benchmark's line in two: the name is printed
+	// before the benchmark runs and the results after
+	pending := make(map[string]string)
+
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		var event testEvent
@@ -56,39 +95,84 @@ func ParseBenchmarkOutput(data []byte) (*BenchmarkReport, error) {
 			continue
 		}
 
-		matches := benchPattern.FindStringSubmatch(strings.TrimSpace(event.Output))
-		if matches == nil {
+		output := pending[event.Package] + event.Output
+		if !strings.HasSuffix(output, "\n") && strings.HasPrefix(output, "Benchmark") {
+			pending[event.Package] = output
 			continue
 		}
+		delete(pending, event.Package)
 
-		result := BenchmarkResult{
-			Name:    matches[1],
-			Package: event.Package,
-		}
-
-		if v, err := strconv.ParseInt(matches[2], 10, 64); err == nil {
-			result.Iterations = v
-		}
-		if v, err := strconv.ParseFloat(matches[3], 64); err == nil {
-			result.NsPerOp = v
-		}
-		if len(matches) > 4 && matches[4] != "" {
-			if v, err := strconv.ParseInt(matches[4], 10, 64); err == nil {
-				result.BytesPerOp = v
+		// go test prints the platform and CPU model before each package's
+		// benchmarks
+		if key, value, ok := strings.Cut(strings.TrimSpace(output), ": "); ok && (key == "goos" || key == "goarch" || key == "cpu") {
+			if report.Env == nil {
+				report.Env = &Environment{}
 			}
-		}
-		if len(matches) > 5 && matches[5] != "" {
-			if v, err := strconv.ParseInt(matches[5], 10, 64); err == nil {
-				result.AllocsPerOp = v
+			switch key {
+			case "goos":
+				report.Env.GOOS = value
+			case "goarch":
+				report.Env.GOARCH = value
+			case "cpu":
+				report.Env.CPU = value
 			}
+			continue
 		}
 
+		result, ok := parseBenchLine(strings.TrimSpace(output))
+		if !ok {
+			continue
+		}
+		result.Package = event.Package
 		report.Packages[event.Package] = append(report.Packages[event.Package], result)
 	}
+	for pkg, output := range pending {
+		if result, ok := parseBenchLine(strings.TrimSpace(output)); ok {
+			result.Package = pkg
+			report.Packages[pkg] = append(report.Packages[pkg], result)
+		}
+	}
 
 	return report, scanner.Err()
 }
 
+// parseBenchLine parses a benchmark result line: the name, the iteration
+// count, then value/unit pairs.
+//
+//	BenchmarkFoo-8  10000  123456 ns/op  45.67 MB/s  1234 B/op  56 allocs/op  12.5 p99-ns
+func parseBenchLine(line string) (BenchmarkResult, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return BenchmarkResult{}, false
+	}
+	iterations, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return BenchmarkResult{}, false
+	}
+
+	result := BenchmarkResult{Name: fields[0], Iterations: iterations}
+	for i := 2; i < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return BenchmarkResult{}, false
+		}
+		switch unit := fields[i+1]; unit {
+		case "ns/op":
+			result.NsPerOp = value
+		case "B/op":
+			result.BytesPerOp = int64(value)
+		case "allocs/op":
+			result.AllocsPerOp = int64(value)
+		default:
+			if result.Metrics == nil {
+				result.Metrics = make(map[string]float64)
+			}
+			result.Metrics[unit] = value
+		}
+	}
+	return result, true
+}
+
 // formatBenchTime formats nanoseconds into human-readable duration
 func formatBenchTime(ns float64) string {
 	switch {
@@ -115,12 +199,34 @@ func formatBenchBytes(b int64) string {
 	}
 }
 
+// formatMetric formats a custom metric's value with its unit
+func formatMetric(value float64, unit string) string {
+	if math.Abs(value) >= 1e4 {
+		return fmt.Sprintf("%.0f %s", value, unit)
+	}
+	return fmt.Sprintf("%.4g %s", value, unit)
+}
+
+// formatValue formats a value in any unit.
+func formatValue(v float64, unit string) string {
+	switch unit {
+	case "ns/op":
+		return formatBenchTime(v)
+	case "B/op":
+		return formatBenchBytes(int64(v))
+	}
+	return formatMetric(v, unit)
+}
+
 // Print outputs the benchmark report in a pretty format
 func (r *BenchmarkReport) Print() {
 	if len(r.Packages) == 0 {
 		fmt.Println("     (no benchmarks found)")
 		return
 	}
+	if r.Env != nil {
+		fmt.Printf("  env: %s\n", r.Env)
+	}
 
 	// Sort packages by name
 	pkgNames := make([]string, 0, len(r.Packages))
@@ -131,11 +237,14 @@ func (r *BenchmarkReport) Print() {
 
 	fmt.Println("        time/op      alloc/op   allocs/op  name")
 	for _, pkg := range pkgNames {
-		results := r.Packages[pkg]
-		// Sort by ns/op (fastest first)
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].NsPerOp < results[j].NsPerOp
-		})
+		// Fold -count runs into one row of medians
+		names, samples := groupSamples(r.Packages[pkg])
+		results := make([]BenchmarkResult, len(names))
+		nsPerOp := make([]float64, len(names))
+		for i, name := range names {
+			results[i] = medianResult(samples[name])
+			nsPerOp[i] = results[i].NsPerOp
+		}
 
 		// Print package header
 		shortPkg := pkg
@@ -144,23 +253,44 @@ func (r *BenchmarkReport) Print() {
 		}
 		fmt.Printf("\033[1m%s\033[0m\n", shortPkg)
 
-		for _, b := range results {
-			// Strip package prefix and Benchmark prefix from name
-			name := b.Name
-			if strings.HasPrefix(name, "Benchmark") {
-				name = name[9:]
-			}
-			// Strip -N suffix (CPU count)
-			if idx := strings.LastIndex(name, "-"); idx > 0 {
-				name = name[:idx]
-			}
-
+		printTree(names, nsPerOp, func(i int, name string) {
+			b := results[i]
 			timeStr := formatBenchTime(b.NsPerOp)
 			allocStr := formatBenchBytes(b.BytesPerOp)
 
 			fmt.Printf("  %12s  %12s  %9d  %s\n",
 				timeStr, allocStr, b.AllocsPerOp, name)
+			for _, unit := range b.Units() {
+				fmt.Printf("  %12s\n", formatMetric(b.Metrics[unit], unit))
+			}
+		}, func(name string) {
+			fmt.Printf("  %12s  %12s  %9s  %s\n", "", "", "", name)
+		})
+	}
+}
+
+// Merge replaces the samples of every benchmark in other, keeping the
+// benchmarks other didn't run, so a filtered run updates a stored report.
+// The environment and time become other's.
+func (r *BenchmarkReport) Merge(other *BenchmarkReport) {
+	if r.Packages == nil {
+		r.Packages = make(map[string][]BenchmarkResult)
+	}
+	if other.Env != nil {
+		r.Env = other.Env
+	}
+	if !other.Time.IsZero() {
+		r.Time = other.Time
+	}
+	for pkg, results := range other.Packages {
+		_, rerun := groupSamples(results)
+		kept := []BenchmarkResult{}
+		for _, b := range r.Packages[pkg] {
+			if _, ok := rerun[stripCPUSuffix(b.Name)]; !ok {
+				kept = append(kept, b)
+			}
 		}
+		r.Packages[pkg] = append(kept, results...)
 	}
 }
 
@@ -190,6 +320,9 @@ func (r *BenchmarkReport) ToBenchstat() string {
 		for _, b := range results {
 			// Format: BenchmarkName-N    iterations    ns/op    B/op    allocs/op
 			sb.WriteString(fmt.Sprintf("%s\t%d\t%.2f ns/op", b.Name, b.Iterations, b.NsPerOp))
+			for _, unit := range b.Units() {
+				sb.WriteString(fmt.Sprintf("\t%s %s", strconv.FormatFloat(b.Metrics[unit], 'f', -1, 64), unit))
+			}
 			if b.BytesPerOp > 0 || b.AllocsPerOp > 0 {
 				sb.WriteString(fmt.Sprintf("\t%d B/op\t%d allocs/op", b.BytesPerOp, b.AllocsPerOp))
 			}
@@ -220,3 +220,113 @@ func TestToBenchstatNoAllocs(t *testing.T) {
 	assert.NotContains(t, output, "B/op")
 	assert.NotContains(t, output, "allocs/op")
 }
+
+func TestParseBenchmarkOutputCustomMetrics(t *testing.T) {
+	input := `{"Action":"output","Package":"pkg","Output":"BenchmarkCopy-8   \t   50000\t     23456 ns/op\t 447.05 MB/s\t   12.50 p99-ns\t    1024 B/op\t       1 allocs/op\n"}
+{"Action":"output","Package":"pkg","Output":"BenchmarkItems-8   \t"}
+{"Action":"output","Package":"pkg","Output":"    1000\t      3.000 items/op\n"}`
+
+	report, err := ParseBenchmarkOutput([]byte(input))
+	require.Nil(t, err)
+
+	results := report.Packages["pkg"]
+	require.Equal(t, 2, len(results))
+
+	copyResult := results[0]
+	assert.Equal(t, float64(23456), copyResult.NsPerOp)
+	assert.Equal(t, int64(1024), copyResult.BytesPerOp)
+	assert.Equal(t, int64(1), copyResult.AllocsPerOp)
+	assert.Equal(t, map[string]float64{"MB/s": 447.05, "p99-ns": 12.5}, copyResult.Metrics)
+	assert.Equal(t, []string{"MB/s", "p99-ns"}, copyResult.Units())
+
+	// Split across two events, and without ns/op
+	items := results[1]
+	assert.Equal(t, "BenchmarkItems-8", items.Name)
+	assert.Equal(t, "pkg", items.Package)
+	assert.Equal(t, int64(1000), items.Iterations)
+	assert.Equal(t, float64(0), items.NsPerOp)
+	assert.Equal(t, map[string]float64{"items/op": 3}, items.Metrics)
+
+	// Metrics survive the notes round trip
+	data, err := json.Marshal(report)
+	require.Nil(t, err)
+	var decoded BenchmarkReport
+	require.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, copyResult.Metrics, decoded.Packages["pkg"][0].Metrics)
+}
+
+func TestParseBenchLine(t *testing.T) {
+	for _, line := range []string{
+		"BenchmarkFoo-8",
+		"BenchmarkFoo-8 1000",
+		"BenchmarkFoo-8 1000 12 ns/op 5",
+		"BenchmarkFoo-8 many 12 ns/op",
+		"BenchmarkFoo-8 1000 fast ns/op",
+		"PASS",
+	} {
+		_, ok := parseBenchLine(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestToBenchstatCustomMetrics(t *testing.T) {
+	report := &BenchmarkReport{
+		Packages: map[string][]BenchmarkResult{
+			"pkg": {
+				{Name: "BenchmarkCopy-8", Iterations: 50000, NsPerOp: 23456, BytesPerOp: 1024, AllocsPerOp: 1,
+					Metrics: map[string]float64{"p99-ns": 12.5, "MB/s": 447.05}},
+			},
+		},
+	}
+
+	assert.Equal(t, "BenchmarkCopy-8\t50000\t23456.00 ns/op\t447.05 MB/s\t12.5 p99-ns\t1024 B/op\t1 allocs/op\n", report.ToBenchstat())
+
+	// The output parses back to the same metrics
+	line := strings.TrimSpace(report.ToBenchstat())
+	result, ok := parseBenchLine(line)
+	require.True(t, ok)
+	assert.Equal(t, report.Packages["pkg"][0].Metrics, result.Metrics)
+}
+
+func TestBenchmarkReportPrintCustomMetrics(t *testing.T) {
+	report := &BenchmarkReport{
+		Packages: map[string][]BenchmarkResult{
+			"pkg": {{Name: "BenchmarkCopy-8", NsPerOp: 23456, Metrics: map[string]float64{"MB/s": 447.05, "items/op": 123456}}},
+		},
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	report.Print()
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	assert.Contains(t, output, "447.1 MB/s")
+	assert.Contains(t, output, "123456 items/op")
+}
+
+func TestBenchmarkReportMerge(t *testing.T) {
+	stored := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"pkg": {
+			{Name: "BenchmarkFoo-8", NsPerOp: 100},
+			{Name: "BenchmarkFoo-8", NsPerOp: 101},
+			{Name: "BenchmarkBar-8", NsPerOp: 200},
+		},
+		"other": {{Name: "BenchmarkBaz-8", NsPerOp: 300}},
+	}}
+	stored.Merge(&BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"pkg": {{Name: "BenchmarkFoo-4", NsPerOp: 90}},
+		"new": {{Name: "BenchmarkNew-8", NsPerOp: 10}},
+	}})
+
+	assert.Equal(t, []BenchmarkResult{{Name: "BenchmarkBar-8", NsPerOp: 200}, {Name: "BenchmarkFoo-4", NsPerOp: 90}}, stored.Packages["pkg"])
+	assert.Equal(t, 1, len(stored.Packages["other"]))
+	assert.Equal(t, 1, len(stored.Packages["new"]))
+}
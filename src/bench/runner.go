@@ -3,6 +3,9 @@ package bench
 import (
 	"fmt"
 	"io"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
@@ -13,13 +16,37 @@ type Options struct {
 	Count   int    // -count
 	CPU     string // -cpu
 	Verbose bool
+
+	Packages []string // package patterns (default ./...)
+	Bench    string   // -bench regexp (default .)
+	Skip     string   // -skip regexp: benchmarks to leave out
+	Exclude  []string // package patterns to leave out
+	Dir      string   // run in this directory instead of the current one
+	PGO      string   // -pgo: a profile, or off
 }
 
 // RunBenchmarks executes go test -bench and returns parsed results
 func RunBenchmarks(r runner.CommandRunner, opts Options) (*BenchmarkReport, error) {
+	if len(opts.Exclude) > 0 {
+		pkgs, err := resolvePackages(r, opts.Dir, opts.Packages, opts.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		if len(pkgs) == 0 {
+			return nil, fmt.Errorf("no packages left to benchmark after excluding %s", strings.Join(opts.Exclude, ", "))
+		}
+		opts.Packages = pkgs
+	}
+
 	goTestArgs := buildBenchArgs(opts)
 	// Always run with -json so we can parse results
 	goTestArgs = append([]string{goTestArgs[0], "-json"}, goTestArgs[1:]...)
+	if opts.Dir != "" {
+		goTestArgs = append([]string{"-C", opts.Dir}, goTestArgs...)
+	}
+
+	env := captureEnvironment(r, opts.Dir)
+	start := time.Now().UTC()
 
 	proc, err := runner.Cmd("go", goTestArgs...).WithQuiet().Run(r)
 	if err != nil {
@@ -32,6 +59,8 @@ func RunBenchmarks(r runner.CommandRunner, opts Options) (*BenchmarkReport, erro
 		// Try to parse and return partial results on failure
 		if len(output) > 0 {
 			if report, parseErr := ParseBenchmarkOutput(output); parseErr == nil && report.HasResults() {
+				env.update(report.Env)
+				report.Env, report.Time = env, start
 				return report, fmt.Errorf("benchmarks failed: %w", waitErr)
 			}
 		}
@@ -42,12 +71,61 @@ func RunBenchmarks(r runner.CommandRunner, opts Options) (*BenchmarkReport, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse benchmark output: %w", err)
 	}
+	env.update(report.Env)
+	report.Env, report.Time = env, start
 
 	return report, nil
 }
 
+// resolvePackages expands package patterns with go list in dir (the
+// current directory if empty) and drops the packages matched by the
+// exclude patterns.
+func resolvePackages(r runner.CommandRunner, dir string, patterns, exclude []string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgs, err := listPackages(r, dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := listPackages(r, dir, exclude)
+	if err != nil {
+		return nil, err
+	}
+	var kept []string
+	for _, pkg := range pkgs {
+		if !slices.Contains(excluded, pkg) {
+			kept = append(kept, pkg)
+		}
+	}
+	return kept, nil
+}
+
+func listPackages(r runner.CommandRunner, dir string, patterns []string) ([]string, error) {
+	args := append([]string{"list"}, patterns...)
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	proc, err := runner.Cmd("go", args...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	output, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go list %s failed: %w", strings.Join(patterns, " "), err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
 func buildBenchArgs(opts Options) []string {
-	goTestArgs := []string{"test", "-run", "^$", "-bench", ".", "-benchmem"}
+	bench := opts.Bench
+	if bench == "" {
+		bench = "."
+	}
+	goTestArgs := []string{"test", "-run", "^$", "-bench", bench, "-benchmem"}
+	if opts.Skip != "" {
+		goTestArgs = append(goTestArgs, "-skip", opts.Skip)
+	}
 	if opts.Time != "" {
 		goTestArgs = append(goTestArgs, "-benchtime", opts.Time)
 	}
@@ -57,9 +135,14 @@ func buildBenchArgs(opts Options) []string {
 	if opts.CPU != "" {
 		goTestArgs = append(goTestArgs, "-cpu", opts.CPU)
 	}
+	if opts.PGO != "" {
+		goTestArgs = append(goTestArgs, "-pgo", opts.PGO)
+	}
 	if opts.Verbose {
 		goTestArgs = append(goTestArgs, "-v")
 	}
-	goTestArgs = append(goTestArgs, "./...")
-	return goTestArgs
+	if len(opts.Packages) == 0 {
+		return append(goTestArgs, "./...")
+	}
+	return append(goTestArgs, opts.Packages...)
 }
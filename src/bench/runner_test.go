@@ -26,6 +26,7 @@ func TestBuildBenchArgsAllOptions(t *testing.T) {
 		Count:   3,
 		CPU:     "1,2,4",
 		Verbose: true,
+		PGO:     "off",
 	}
 	args := buildBenchArgs(opts)
 
@@ -35,6 +36,7 @@ func TestBuildBenchArgsAllOptions(t *testing.T) {
 	assertContains(t, args, "-count", "3")
 	assertContains(t, args, "-cpu", "1,2,4")
 	assertContains(t, args, "-v")
+	assertContains(t, args, "-pgo", "off")
 	assert.Equal(t, "./...", args[len(args)-1])
 }
 
@@ -102,3 +104,51 @@ func assertContains(t *testing.T, args []string, values ...string) {
 	}
 	t.Errorf("args %v does not contain %v", args, values)
 }
+
+func TestBuildBenchArgsFilters(t *testing.T) {
+	args := buildBenchArgs(Options{Bench: "^BenchmarkParse", Skip: "/size=1m", Packages: []string{"./parser/...", "./lexer"}})
+
+	assertContains(t, args, "-bench", "^BenchmarkParse")
+	assertContains(t, args, "-skip", "/size=1m")
+	assert.Equal(t, []string{"./parser/...", "./lexer"}, args[len(args)-2:])
+}
+
+func TestRunBenchmarksExclude(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "./..."}, []byte("example.com/app\nexample.com/app/internal/gen\nexample.com/app/parser\n"), nil)
+	mock.SetResponse("go", []string{"list", "./internal/..."}, []byte("example.com/app/internal/gen\n"), nil)
+
+	_, err := RunBenchmarks(mock, Options{Exclude: []string{"./internal/..."}})
+	assert.Nil(t, err)
+
+	var testArgs []string
+	for _, c := range mock.Calls() {
+		if c.IsCmd("go", "test") {
+			testArgs = c.Args
+		}
+	}
+	assert.Equal(t, []string{"example.com/app", "example.com/app/parser"}, testArgs[len(testArgs)-2:])
+
+	// Excluding everything is an error rather than benchmarking ./...
+	mock.SetResponse("go", []string{"list", "./..."}, []byte("example.com/app/internal/gen\n"), nil)
+	_, err = RunBenchmarks(mock, Options{Exclude: []string{"./internal/..."}})
+	assert.NotNil(t, err)
+
+	mock.SetResponse("go", []string{"list", "./..."}, nil, fmt.Errorf("no go.mod"))
+	_, err = RunBenchmarks(mock, Options{Exclude: []string{"./internal/..."}})
+	assert.NotNil(t, err)
+}
+
+func TestRunBenchmarksDir(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"-C", "/tmp/wt", "list", "./..."}, []byte("example.com/app\nexample.com/app/gen\n"), nil)
+	mock.SetResponse("go", []string{"-C", "/tmp/wt", "list", "./gen"}, []byte("example.com/app/gen\n"), nil)
+
+	_, err := RunBenchmarks(mock, Options{Dir: "/tmp/wt", Exclude: []string{"./gen"}})
+	assert.Nil(t, err)
+
+	calls := mock.Calls()
+	last := calls[len(calls)-1]
+	assert.True(t, last.IsCmd("go", "-C", "/tmp/wt", "test", "-json"))
+	assert.Equal(t, "example.com/app", last.Args[len(last.Args)-1])
+}
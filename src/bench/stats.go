@@ -0,0 +1,199 @@
+package bench
+
+import (
+	"math"
+	"sort"
+)
+
+// Alpha is the significance level for comparisons: a change whose
+// Mann-Whitney U test p-value isn't below it is reported as noise.
+const Alpha = 0.05
+
+// Confidence is the confidence level of the interval around the median.
+const Confidence = 0.95
+
+// maxExactSamples bounds the sample sizes for which the exact U
+// distribution is computed; larger samples use the normal approximation.
+const maxExactSamples = 50
+
+// Stats summarizes the samples of one benchmark metric.
+type Stats struct {
+	N      int     `json:"n"`
+	Median float64 `json:"median"`
+	Low    float64 `json:"low,omitempty"`  // confidence interval for the median;
+	High   float64 `json:"high,omitempty"` // unset when N is too small for one
+}
+
+// Summarize computes the median of the samples and, given enough of them,
+// a distribution-free confidence interval for it.
+func Summarize(samples []float64) Stats {
+	s := Stats{N: len(samples)}
+	if s.N == 0 {
+		return s
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	if s.N%2 == 1 {
+		s.Median = sorted[s.N/2]
+	} else {
+		s.Median = (sorted[s.N/2-1] + sorted[s.N/2]) / 2
+	}
+	if k := intervalRank(s.N); k > 0 {
+		s.Low, s.High = sorted[k-1], sorted[s.N-k]
+	}
+	return s
+}
+
+// HasInterval reports whether there were enough samples for a confidence
+// interval.
+func (s Stats) HasInterval() bool {
+	return intervalRank(s.N) > 0
+}
+
+// Spread returns the larger distance from the median to an end of the
+// confidence interval, as a percentage of the median.
+func (s Stats) Spread() float64 {
+	if s.Median == 0 {
+		return 0
+	}
+	return math.Max(s.Median-s.Low, s.High-s.Median) / math.Abs(s.Median) * 100
+}
+
+// intervalRank returns the 1-based rank k such that the k-th smallest and
+// k-th largest of n samples bound the median with at least Confidence
+// probability, or 0 if n is too small. The number of samples below the
+// median is Binomial(n, 1/2), so k is the largest rank whose lower tail
+// stays within (1-Confidence)/2.
+func intervalRank(n int) int {
+	if n == 0 {
+		return 0
+	}
+	tail := (1 - Confidence) / 2
+	p := math.Ldexp(1, -n) // P(X = 0)
+	cdf, k := 0.0, 0
+	for i := 0; i < n/2; i++ {
+		cdf += p
+		if cdf > tail {
+			break
+		}
+		k = i + 1
+		p *= float64(n-i) / float64(i+1)
+	}
+	return k
+}
+
+// MannWhitneyU returns the two-sided p-value of the Mann-Whitney U test
+// that the samples come from the same distribution. It uses the exact U
+// distribution for small samples without ties and the normal
+// approximation, corrected for ties, otherwise.
+func MannWhitneyU(x, y []float64) float64 {
+	m, n := len(x), len(y)
+	if m == 0 || n == 0 {
+		return 1
+	}
+
+	type obs struct {
+		v   float64
+		inX bool
+	}
+	all := make([]obs, 0, m+n)
+	for _, v := range x {
+		all = append(all, obs{v, true})
+	}
+	for _, v := range y {
+		all = append(all, obs{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Rank with ties sharing their average rank
+	var rankX, tieSum float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for _, o := range all[i:j] {
+			if o.inX {
+				rankX += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieSum += t*t*t - t
+		}
+		i = j
+	}
+	u := rankX - float64(m*(m+1))/2
+
+	if !ties && m <= maxExactSamples && n <= maxExactSamples {
+		dist := uDistribution(m, n)
+		var total, lower, upper float64
+		for k, c := range dist {
+			total += c
+			if float64(k) <= u {
+				lower += c
+			}
+			if float64(k) >= u {
+				upper += c
+			}
+		}
+		return math.Min(1, 2*math.Min(lower, upper)/total)
+	}
+
+	size := float64(m + n)
+	mean := float64(m*n) / 2
+	variance := float64(m*n) / 12 * (size + 1 - tieSum/(size*(size-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := math.Max(0, math.Abs(u-mean)-0.5) / math.Sqrt(variance)
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// uDistribution returns the number of orderings of m x-samples and n
+// y-samples (without ties) that give each value of U, the count of
+// (x, y) pairs with x > y. The largest sample is either an x, beating all
+// n ys, or a y, beating none, which gives the recurrence
+// f(m, n, u) = f(m-1, n, u-n) + f(m, n-1, u).
+func uDistribution(m, n int) []float64 {
+	// prev[i] is the distribution for i xs and j-1 ys
+	prev := make([][]float64, m+1)
+	for i := range prev {
+		prev[i] = []float64{1}
+	}
+	for j := 1; j <= n; j++ {
+		cur := make([][]float64, m+1)
+		cur[0] = []float64{1}
+		for i := 1; i <= m; i++ {
+			d := make([]float64, i*j+1)
+			copy(d, prev[i])
+			for u, c := range cur[i-1] {
+				d[u+j] += c
+			}
+			cur[i] = d
+		}
+		prev = cur
+	}
+	return prev[m]
+}
+
+// minSamplesForSignificance returns the smallest equal sample size at
+// which the exact test can reach Alpha: with n samples on each side, the
+// smallest two-sided p-value is 2/C(2n, n).
+func minSamplesForSignificance() int {
+	for n := 1; ; n++ {
+		if 2/binomial(2*n, n) < Alpha {
+			return n
+		}
+	}
+}
+
+func binomial(n, k int) float64 {
+	c := 1.0
+	for i := 1; i <= k; i++ {
+		c = c * float64(n-k+i) / float64(i)
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	assert.Equal(t, Stats{}, Summarize(nil))
+	assert.Equal(t, Stats{N: 1, Median: 5}, Summarize([]float64{5}))
+	assert.Equal(t, Stats{N: 4, Median: 2.5}, Summarize([]float64{4, 1, 3, 2}))
+
+	// Six samples is the fewest with a 95% interval: the extremes
+	s := Summarize([]float64{10, 12, 11, 9, 13, 8})
+	assert.Equal(t, Stats{N: 6, Median: 10.5, Low: 8, High: 13}, s)
+	assert.True(t, s.HasInterval())
+	assert.InDelta(t, 23.81, s.Spread(), 0.01)
+	assert.False(t, Summarize([]float64{1, 2, 3, 4, 5}).HasInterval())
+}
+
+func TestIntervalRank(t *testing.T) {
+	tests := []struct {
+		n, rank int
+	}{
+		{0, 0}, {1, 0}, {5, 0}, {6, 1}, {8, 1}, {9, 2}, {10, 2}, {20, 6},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.rank, intervalRank(tt.n), "n=%d", tt.n)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// Completely separated samples: the exact p-value is 2/C(m+n, m)
+	assert.InDelta(t, 2.0/252, MannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}), 1e-12)
+	assert.InDelta(t, 2.0/252, MannWhitneyU([]float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}), 1e-12)
+	assert.InDelta(t, 0.1, MannWhitneyU([]float64{1, 2, 3}, []float64{4, 5, 6}), 1e-12)
+
+	// Interleaved samples are indistinguishable
+	assert.Equal(t, float64(1), MannWhitneyU([]float64{1, 4, 5, 8}, []float64{2, 3, 6, 7}))
+
+	// A single sample on each side can never be significant
+	assert.Equal(t, float64(1), MannWhitneyU([]float64{1}, []float64{100}))
+	assert.Equal(t, float64(1), MannWhitneyU(nil, []float64{1}))
+
+	// Ties use the normal approximation
+	assert.Equal(t, float64(1), MannWhitneyU([]float64{3, 3, 3}, []float64{3, 3, 3}))
+	p := MannWhitneyU([]float64{1, 1, 2, 2, 2, 3, 3, 3}, []float64{4, 4, 5, 5, 5, 6, 6, 6})
+	assert.Less(t, p, 0.01)
+	assert.Greater(t, p, 0.0001)
+}
+
+func TestUDistribution(t *testing.T) {
+	assert.Equal(t, []float64{1}, uDistribution(2, 0))
+	assert.Equal(t, []float64{1, 1, 2, 2, 2, 1, 1}, uDistribution(2, 3))
+
+	total := 0.0
+	for _, c := range uDistribution(5, 5) {
+		total += c
+	}
+	assert.Equal(t, float64(252), total)
+}
+
+func TestMinSamplesForSignificance(t *testing.T) {
+	assert.Equal(t, 4, minSamplesForSignificance())
+}
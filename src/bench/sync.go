@@ -0,0 +1,83 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// remoteNotesRef is where PullNotes fetches a remote's benchmark notes to
+// before merging them.
+func remoteNotesRef(remote string) string {
+	return "refs/notes/remotes/" + remote + "/benchmarks"
+}
+
+// HasRemote reports whether the repository has a remote by that name.
+func HasRemote(r runner.CommandRunner, remote string) bool {
+	proc, err := runner.Cmd("git", "remote", "get-url", remote).WithQuiet().Run(r)
+	if err != nil {
+		return false
+	}
+	io.ReadAll(proc.Stdout())
+	return proc.Wait() == nil
+}
+
+// PullNotes fetches a remote's benchmark notes and merges them into the
+// local ones. Each note's lines are one environment's results, so the
+// merge takes the union of both sides' lines and can't conflict. A remote
+// without benchmark notes is not an error.
+func PullNotes(r runner.CommandRunner, remote string) error {
+	ref := remoteNotesRef(remote)
+	proc, err := runner.Cmd("git", "fetch", "--quiet", remote, "+"+NotesRef+":"+ref).WithQuiet().Run(r)
+	if err != nil {
+		return fmt.Errorf("failed to fetch benchmark notes from %s: %w", remote, err)
+	}
+	io.ReadAll(proc.Stdout())
+	stderr, _ := io.ReadAll(proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		if strings.Contains(string(stderr), "couldn't find remote ref") {
+			return nil // nothing pushed there yet
+		}
+		return fmt.Errorf("failed to fetch benchmark notes from %s: %w: %s", remote, err, strings.TrimSpace(string(stderr)))
+	}
+
+	proc, err = runner.Cmd("git", "notes", "--ref=benchmarks", "merge", "--quiet", "--strategy=cat_sort_uniq", ref).WithQuiet().Run(r)
+	if err != nil {
+		return fmt.Errorf("failed to merge benchmark notes from %s: %w", remote, err)
+	}
+	io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return fmt.Errorf("failed to merge benchmark notes from %s: %w", remote, err)
+	}
+	return nil
+}
+
+// PushNotes merges in the remote's benchmark notes, so the push is a fast
+// forward, and pushes the result.
+func PushNotes(r runner.CommandRunner, remote string) error {
+	proc, err := runner.Cmd("git", "rev-parse", "--verify", "--quiet", NotesRef).WithQuiet().Run(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", NotesRef, err)
+	}
+	io.ReadAll(proc.Stdout())
+	if proc.Wait() != nil {
+		return fmt.Errorf("no benchmark notes to push (run bench save first)")
+	}
+
+	if err := PullNotes(r, remote); err != nil {
+		return err
+	}
+
+	proc, err = runner.Cmd("git", "push", "--quiet", remote, NotesRef+":"+NotesRef).WithQuiet().Run(r)
+	if err != nil {
+		return fmt.Errorf("failed to push benchmark notes to %s: %w", remote, err)
+	}
+	io.ReadAll(proc.Stdout())
+	stderr, _ := io.ReadAll(proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		return fmt.Errorf("failed to push benchmark notes to %s: %w: %s", remote, err, strings.TrimSpace(string(stderr)))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// git runs a git command in dir for test setup.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@test.com")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %s: %s", strings.Join(args, " "), out)
+	return strings.TrimSpace(string(out))
+}
+
+func envReport(goos string, ns float64) *BenchmarkReport {
+	return &BenchmarkReport{
+		Packages: map[string][]BenchmarkResult{"pkg": {{Name: "BenchmarkFoo-8", NsPerOp: ns}}},
+		Env:      &Environment{GOOS: goos, GOARCH: "amd64"},
+		Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestPushPullNotes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	remote := filepath.Join(root, "remote.git")
+	ci, laptop := filepath.Join(root, "ci"), filepath.Join(root, "laptop")
+	git(t, root, "init", "--quiet", "--bare", remote)
+	git(t, root, "clone", "--quiet", remote, ci)
+	git(t, ci, "commit", "--quiet", "--allow-empty", "-m", "initial")
+	git(t, ci, "push", "--quiet", "origin", "HEAD")
+	git(t, root, "clone", "--quiet", remote, laptop)
+	sha := git(t, ci, "rev-parse", "HEAD")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@test.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@test.com")
+	r := runner.New()
+
+	// Nothing to pull or push yet
+	t.Chdir(laptop)
+	assert.True(t, HasRemote(r, "origin"))
+	assert.False(t, HasRemote(r, "upstream"))
+	require.NoError(t, PullNotes(r, "origin"))
+	assert.ErrorContains(t, PushNotes(r, "origin"), "no benchmark notes to push")
+
+	// Both machines store results for the same commit and push them
+	t.Chdir(ci)
+	require.NoError(t, StoreNotesForCommit(r, sha, envReport("linux", 100)))
+	require.NoError(t, PushNotes(r, "origin"))
+	t.Chdir(laptop)
+	require.NoError(t, StoreNotesForCommit(r, sha, envReport("darwin", 200)))
+	require.NoError(t, PushNotes(r, "origin"))
+
+	// The laptop's push merged in the CI results
+	entries, err := fetchEntries(r, sha)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "darwin", entries[0].Env.GOOS)
+	assert.Equal(t, "linux", entries[1].Env.GOOS)
+
+	// CI reruns the benchmark, then pulls the laptop's results: the
+	// newer CI entry wins over the one it pushed before
+	t.Chdir(ci)
+	rerun := envReport("linux", 110)
+	rerun.Time = rerun.Time.Add(time.Hour)
+	require.NoError(t, StoreNotesForCommit(r, sha, rerun))
+	require.NoError(t, PullNotes(r, "origin"))
+	entries, err = fetchEntries(r, sha)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, 200.0, entries[0].Packages["pkg"][0].NsPerOp)
+	assert.Equal(t, 110.0, entries[1].Packages["pkg"][0].NsPerOp)
+}
+
+func TestPullNotesErrors(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"fetch", "--quiet", "origin", "+refs/notes/benchmarks:refs/notes/remotes/origin/benchmarks"}, nil, fmt.Errorf("exit status 128"))
+	mock.SetStderr("git", []string{"fetch", "--quiet", "origin", "+refs/notes/benchmarks:refs/notes/remotes/origin/benchmarks"}, []byte("fatal: couldn't find remote ref refs/notes/benchmarks\n"))
+	assert.NoError(t, PullNotes(mock, "origin"))
+	for _, c := range mock.Calls() {
+		assert.False(t, c.IsCmd("git", "notes"))
+	}
+
+	mock.SetStderr("git", []string{"fetch", "--quiet", "origin", "+refs/notes/benchmarks:refs/notes/remotes/origin/benchmarks"}, []byte("fatal: unable to access\n"))
+	assert.ErrorContains(t, PullNotes(mock, "origin"), "unable to access")
+}
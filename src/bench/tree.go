@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// benchGroup is a top-level benchmark and its sub-benchmarks.
+type benchGroup struct {
+	name string     // top-level name, without the Benchmark prefix
+	self int        // index of the top-level benchmark's own result, or -1
+	subs []subBench // in run order
+}
+
+// subBench is a sub-benchmark: the "/"-separated segments of its name
+// below the top-level benchmark.
+type subBench struct {
+	segments []string
+	index    int
+}
+
+// displayName strips the Benchmark prefix and the CPU suffix from a name.
+func displayName(name string) string {
+	return strings.TrimPrefix(stripCPUSuffix(name), "Benchmark")
+}
+
+// groupTree groups benchmark names by top-level benchmark, in first-seen
+// order.
+func groupTree(names []string) []*benchGroup {
+	var groups []*benchGroup
+	byName := make(map[string]*benchGroup)
+	for i, name := range names {
+		top, rest, isSub := strings.Cut(displayName(name), "/")
+		g, ok := byName[top]
+		if !ok {
+			g = &benchGroup{name: top, self: -1}
+			byName[top] = g
+			groups = append(groups, g)
+		}
+		if isSub {
+			g.subs = append(g.subs, subBench{segments: strings.Split(rest, "/"), index: i})
+		} else {
+			g.self = i
+		}
+	}
+	return groups
+}
+
+// paramColumns lays out sub-benchmarks as a table with one column per
+// name segment. A column whose segments all share a key (size=1k,
+// size=4k) is headed by the key and holds the values; other columns have
+// no header and hold the whole segment.
+func paramColumns(subs []subBench) (headers []string, rows [][]string) {
+	depth := 0
+	for _, s := range subs {
+		depth = max(depth, len(s.segments))
+	}
+	headers = make([]string, depth)
+	for col := range depth {
+		key, shared, seen := "", true, false
+		for _, s := range subs {
+			if col >= len(s.segments) {
+				continue
+			}
+			k, _, ok := strings.Cut(s.segments[col], "=")
+			if !ok || (seen && k != key) {
+				shared = false
+				break
+			}
+			key, seen = k, true
+		}
+		if shared {
+			headers[col] = key
+		}
+	}
+
+	for _, s := range subs {
+		row := make([]string, depth)
+		for col, seg := range s.segments {
+			if headers[col] != "" {
+				_, seg, _ = strings.Cut(seg, "=")
+			}
+			row[col] = seg
+		}
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
+
+// printTree prints a package's benchmarks in the name column of a table.
+// Top-level benchmarks come fastest first; sub-benchmarks are nested under
+// theirs in run order, with their key=value segments as parameter columns.
+// emit prints benchmark i's row with the given name cell; label prints a
+// row with only a name cell.
+func printTree(names []string, nsPerOp []float64, emit func(i int, name string), label func(name string)) {
+	groups := groupTree(names)
+	fastest := func(g *benchGroup) float64 {
+		best := -1.0
+		if g.self >= 0 {
+			best = nsPerOp[g.self]
+		}
+		for _, s := range g.subs {
+			if best < 0 || nsPerOp[s.index] < best {
+				best = nsPerOp[s.index]
+			}
+		}
+		return best
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return fastest(groups[i]) < fastest(groups[j]) })
+
+	for _, g := range groups {
+		if len(g.subs) == 0 {
+			emit(g.self, g.name)
+			continue
+		}
+		if g.self >= 0 {
+			emit(g.self, g.name)
+		} else {
+			label(g.name)
+		}
+
+		headers, rows := paramColumns(g.subs)
+		widths := make([]int, len(headers))
+		for col, h := range headers {
+			widths[col] = len([]rune(h))
+			for _, row := range rows {
+				widths[col] = max(widths[col], len([]rune(row[col])))
+			}
+		}
+		format := func(cells []string) string {
+			padded := make([]string, len(cells))
+			for col, c := range cells {
+				padded[col] = fmt.Sprintf("%-*s", widths[col], c)
+			}
+			return "  " + strings.TrimRight(strings.Join(padded, "  "), " ")
+		}
+
+		if strings.Join(headers, "") != "" {
+			label(format(headers))
+		}
+		for i, s := range g.subs {
+			emit(s.index, format(rows[i]))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestGroupTree(t *testing.T) {
+	groups := groupTree([]string{
+		"BenchmarkEncode/size=1k-8",
+		"BenchmarkDecode-8",
+		"BenchmarkEncode/size=4k-8",
+		"BenchmarkEncode-8",
+	})
+	require.Equal(t, 2, len(groups))
+	assert.Equal(t, "Encode", groups[0].name)
+	assert.Equal(t, 3, groups[0].self)
+	assert.Equal(t, []subBench{{[]string{"size=1k"}, 0}, {[]string{"size=4k"}, 2}}, groups[0].subs)
+	assert.Equal(t, "Decode", groups[1].name)
+	assert.Equal(t, 1, groups[1].self)
+	assert.Empty(t, groups[1].subs)
+}
+
+func TestParamColumns(t *testing.T) {
+	headers, rows := paramColumns([]subBench{
+		{segments: []string{"size=1k", "codec=gzip", "fast"}},
+		{segments: []string{"size=1m", "codec=zstd", "slow"}},
+		{segments: []string{"size=1g", "level=9"}},
+	})
+	assert.Equal(t, []string{"size", "", ""}, headers)
+	assert.Equal(t, [][]string{
+		{"1k", "codec=gzip", "fast"},
+		{"1m", "codec=zstd", "slow"},
+		{"1g", "level=9", ""},
+	}, rows)
+}
+
+func TestStripCPUSuffixKeepsParameters(t *testing.T) {
+	assert.Equal(t, "BenchmarkFoo/mode=fast-path", stripCPUSuffix("BenchmarkFoo/mode=fast-path"))
+	assert.Equal(t, "BenchmarkFoo/n=-1", stripCPUSuffix("BenchmarkFoo/n=-1-8"))
+}
+
+func TestBenchmarkReportPrintTree(t *testing.T) {
+	report := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"pkg": {
+			{Name: "BenchmarkEncode/size=1k/codec=gzip-8", NsPerOp: 1000},
+			{Name: "BenchmarkEncode/size=1k/codec=zstd-8", NsPerOp: 800},
+			{Name: "BenchmarkEncode/size=1m/codec=gzip-8", NsPerOp: 900000},
+			{Name: "BenchmarkEncode/size=1m/codec=gzip-8", NsPerOp: 910000},
+			{Name: "BenchmarkEncode/size=1m/codec=gzip-8", NsPerOp: 920000},
+			{Name: "BenchmarkHash-8", NsPerOp: 50},
+		},
+	}}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	report.Print()
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	require.Equal(t, 8, len(lines))
+	// Fastest top-level benchmark first, sub-benchmarks in run order
+	assert.True(t, strings.HasSuffix(lines[2], "  Hash"), lines[2])
+	assert.True(t, strings.HasSuffix(lines[3], "  Encode"), lines[3])
+	assert.True(t, strings.HasSuffix(lines[4], "    size  codec"), lines[4])
+	assert.True(t, strings.HasSuffix(lines[5], "    1k    gzip"), lines[5])
+	assert.True(t, strings.HasSuffix(lines[6], "    1k    zstd"), lines[6])
+	// -count samples are folded into their median
+	assert.Contains(t, lines[7], "910.00 µs")
+	assert.True(t, strings.HasSuffix(lines[7], "    1m    gzip"), lines[7])
+}
+
+func TestComparisonPrintTree(t *testing.T) {
+	current := &BenchmarkReport{Packages: map[string][]BenchmarkResult{
+		"pkg": {
+			{Name: "BenchmarkParse/n=10-8", NsPerOp: 100},
+			{Name: "BenchmarkParse/n=100-8", NsPerOp: 1000},
+		},
+	}}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	Compare(current, nil).Print()
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	assert.Contains(t, output, "  Parse\n")
+	assert.Contains(t, output, "    n\n")
+	assert.Contains(t, output, "    10\n")
+	assert.Contains(t, output, "    100\n")
+}
@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// Worktree is a temporary git worktree for building and measuring other
+// commits without touching the working copy. It's created by the first
+// Checkout; Close removes it.
+type Worktree struct {
+	r   runner.CommandRunner
+	Dir string // empty until the first Checkout
+}
+
+// NewWorktree returns a worktree that is created on first use.
+func NewWorktree(r runner.CommandRunner) *Worktree {
+	return &Worktree{r: r}
+}
+
+// Checkout checks a commit out into the worktree, creating it if needed.
+func (w *Worktree) Checkout(rev string) error {
+	if w.Dir != "" {
+		return runGit(w.r, "-C", w.Dir, "checkout", "--quiet", "--detach", rev)
+	}
+	dir, err := os.MkdirTemp("", "go-toolchain-worktree-")
+	if err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	if err := runGit(w.r, "worktree", "add", "--detach", dir, rev); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	w.Dir = dir
+	return nil
+}
+
+// Close removes the worktree.
+func (w *Worktree) Close() {
+	if w.Dir == "" {
+		return
+	}
+	runGit(w.r, "worktree", "remove", "--force", w.Dir)
+	os.RemoveAll(w.Dir)
+	w.Dir = ""
+}
+
+func runGit(r runner.CommandRunner, args ...string) error {
+	proc, err := runner.Cmd("git", args...).WithQuiet().Run(r)
+	if err != nil {
+		return fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+	}
+	if err := proc.Wait(); err != nil {
+		return fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
@@ -3,18 +3,75 @@ package build
 import (
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/wow-look-at-my/go-containers/sortedmap"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
 type Target struct {
 	ImportPath string
 	OutputName string
+	Tags       []string
+	Trimpath   bool
+	Gcflags    string
+	Ldflags    string            // appended to the shared version ldflags
+	Vars       map[string]string // extra -X importpath.name=value
+	CGO        bool              // matrix builds use CGO_ENABLED=1
+	PGO        string            // -pgo profile, set by ApplyPGO
+}
+
+// BuildArgs returns the `go build` arguments for this target. The shared
+// ldflags (version info) come first so per-target -X values can override them.
+func (t Target) BuildArgs(ldflags, outPath string) []string {
+	args := []string{"build"}
+	if len(t.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(t.Tags, ","))
+	}
+	if t.Trimpath {
+		args = append(args, "-trimpath")
+	}
+	if t.Gcflags != "" {
+		args = append(args, "-gcflags", t.Gcflags)
+	}
+	if t.PGO != "" {
+		args = append(args, "-pgo", t.PGO)
+	}
+	if ld := t.ldflags(ldflags); ld != "" {
+		args = append(args, "-ldflags", ld)
+	}
+	return append(args, "-o", outPath, t.ImportPath)
+}
+
+func (t Target) ldflags(base string) string {
+	flags := []string{}
+	if base != "" {
+		flags = append(flags, base)
+	}
+	for _, name := range slices.Sorted(maps.Keys(t.Vars)) {
+		flags = append(flags, "-X "+quoteLdflag(name+"="+t.Vars[name]))
+	}
+	if t.Ldflags != "" {
+		flags = append(flags, t.Ldflags)
+	}
+	return strings.Join(flags, " ")
+}
+
+// quoteLdflag quotes an -ldflags argument containing whitespace. go splits
+// -ldflags on spaces, keeping 'single' or "double" quoted fields together.
+func quoteLdflag(arg string) string {
+	if !strings.ContainsAny(arg, " \t\n\r") {
+		return arg
+	}
+	if !strings.Contains(arg, "'") {
+		return "'" + arg + "'"
+	}
+	return `"` + arg + `"`
 }
 
 // findMainPackages uses go list to discover all main packages in the module.
@@ -63,11 +120,13 @@ func binaryNameFromImportPath(pkg, moduleName string) string {
 }
 
 // ResolveBuildTargets determines what to build and what to name the binaries.
-// Uses go list to find all main packages in the module. If no main packages
-// exist (library-only project), falls back to all packages found by walking
-// the filesystem.
-// Binary names are always auto-derived from the package/directory name.
-func ResolveBuildTargets(r runner.CommandRunner) ([]Target, error) {
+// Targets declared in cfg are built as configured; otherwise (or additionally,
+// with cfg.AutoDiscover) go list finds all main packages in the module and
+// names them after their package/directory. If no main packages exist
+// (library-only project), falls back to all packages found by walking the
+// filesystem.
+// Two targets resolving to the same output name is an error.
+func ResolveBuildTargets(r runner.CommandRunner, cfg config.Build) ([]Target, error) {
 	// Get module name for smart binary naming
 	proc, modErr := runner.Cmd("go", "list", "-m").WithQuiet().Run(r)
 	moduleName := ""
@@ -77,33 +136,104 @@ func ResolveBuildTargets(r runner.CommandRunner) ([]Target, error) {
 		moduleName = strings.TrimSpace(string(modOut))
 	}
 
+	byName := sortedmap.New[string, Target]()
+	declared := make(map[string]bool)
+	add := func(t Target) error {
+		if existing, ok := byName.Get(t.OutputName); ok {
+			return fmt.Errorf("build targets %s and %s both produce %q — set a distinct \"output\" or add one to \"exclude\" in %s",
+				existing.ImportPath, t.ImportPath, t.OutputName, config.FileName)
+		}
+		byName.Put(t.OutputName, t)
+		return nil
+	}
+
+	for _, tc := range cfg.Targets {
+		t := targetFromConfig(tc, moduleName)
+		if err := add(t); err != nil {
+			return nil, err
+		}
+		declared[t.ImportPath] = true
+	}
+	if len(cfg.Targets) > 0 && !cfg.AutoDiscover {
+		return slices.Collect(byName.Values()), nil
+	}
+
 	// Find all main packages in the module
 	pkgs, err := findMainPackages(r)
 	if err != nil {
 		return nil, err
 	}
 
+	excluded := make(map[string]bool)
+	for _, e := range cfg.Exclude {
+		excluded[resolveImportPath(e, moduleName)] = true
+	}
+
 	if len(pkgs) > 0 {
-		byName := sortedmap.New[string, Target]()
 		for _, pkg := range pkgs {
-			name := binaryNameFromImportPath(pkg, moduleName)
-			if !byName.Contains(name) {
-				byName.Put(name, Target{ImportPath: pkg, OutputName: name})
+			if declared[pkg] || excluded[pkg] {
+				continue
+			}
+			if err := add(Target{ImportPath: pkg, OutputName: binaryNameFromImportPath(pkg, moduleName)}); err != nil {
+				return nil, err
 			}
 		}
 		return slices.Collect(byName.Values()), nil
 	}
+	if len(cfg.Targets) > 0 {
+		return slices.Collect(byName.Values()), nil
+	}
 
 	// Library-only project: walk filesystem to find all packages
 	allPkgs, err := findAllPackagesByDir(moduleName)
 	if err != nil {
 		return nil, err
 	}
-	targets := make([]Target, len(allPkgs))
-	for i, pkg := range allPkgs {
-		targets[i] = Target{ImportPath: pkg, OutputName: filepath.Base(pkg)}
+	for _, pkg := range allPkgs {
+		if excluded[pkg] {
+			continue
+		}
+		if err := add(Target{ImportPath: pkg, OutputName: filepath.Base(pkg)}); err != nil {
+			return nil, err
+		}
+	}
+	return slices.Collect(byName.Values()), nil
+}
+
+// targetFromConfig converts a declared target into a Target, resolving
+// relative package dirs and defaulting the output name.
+func targetFromConfig(tc config.Target, moduleName string) Target {
+	importPath := resolveImportPath(tc.Package, moduleName)
+	name := tc.Output
+	if name == "" {
+		name = binaryNameFromImportPath(importPath, moduleName)
+	}
+	return Target{
+		ImportPath: importPath,
+		OutputName: name,
+		Tags:       tc.Tags,
+		Trimpath:   tc.Trimpath,
+		Gcflags:    tc.Gcflags,
+		Ldflags:    tc.Ldflags,
+		Vars:       tc.Vars,
+		CGO:        tc.CGO,
+	}
+}
+
+// resolveImportPath turns a ./relative package dir into an import path under
+// moduleName. Anything else is assumed to already be an import path.
+func resolveImportPath(pkg, moduleName string) string {
+	if pkg != "." && !strings.HasPrefix(pkg, "./") {
+		return pkg
+	}
+	rel := filepath.ToSlash(filepath.Clean(pkg))
+	if rel == "." {
+		return moduleName
+	}
+	if moduleName == "" {
+		return rel
 	}
-	return targets, nil
+	return moduleName + "/" + rel
 }
 
 // findAllPackagesByDir walks the filesystem from the current directory to find
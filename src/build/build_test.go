@@ -7,6 +7,7 @@ import (
 
 	"github.com/wow-look-at-my/testify/assert"
 	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
@@ -69,7 +70,7 @@ func TestResolveBuildTargetsGoFilesInRoot(t *testing.T) {
 	mock.SetResponse("go", []string{"list", "-m"},
 		[]byte("example.com\n"), nil)
 
-	targets, err := ResolveBuildTargets(mock)
+	targets, err := ResolveBuildTargets(mock, config.Build{})
 	require.Nil(t, err)
 	require.Equal(t, 1, len(targets))
 	assert.Equal(t, "example.com", targets[0].ImportPath)
@@ -88,7 +89,7 @@ func TestResolveBuildTargetsAutoDetectSingle(t *testing.T) {
 	mock.SetResponse("go", []string{"list", "-m"},
 		[]byte("example.com\n"), nil)
 
-	targets, err := ResolveBuildTargets(mock)
+	targets, err := ResolveBuildTargets(mock, config.Build{})
 	require.Nil(t, err)
 	assert.False(t, len(targets) != 1 || targets[0].ImportPath != "example.com/cmd/myapp" || targets[0].OutputName != "myapp")
 }
@@ -105,7 +106,7 @@ func TestResolveBuildTargetsAutoDetectMultiple(t *testing.T) {
 	mock.SetResponse("go", []string{"list", "-m"},
 		[]byte("example.com\n"), nil)
 
-	targets, err := ResolveBuildTargets(mock)
+	targets, err := ResolveBuildTargets(mock, config.Build{})
 	require.Nil(t, err)
 	require.Equal(t, 2, len(targets))
 	assert.Equal(t, "bar", targets[0].OutputName)
@@ -124,7 +125,7 @@ func TestResolveBuildTargetsAutoDetectSrcDir(t *testing.T) {
 	mock.SetResponse("go", []string{"list", "-m"},
 		[]byte("github.com/wow-look-at-my/go-toolchain\n"), nil)
 
-	targets, err := ResolveBuildTargets(mock)
+	targets, err := ResolveBuildTargets(mock, config.Build{})
 	require.Nil(t, err)
 	require.Equal(t, 1, len(targets))
 	// Binary should be named after the module, not "src"
@@ -186,7 +187,7 @@ func TestResolveBuildTargetsFallsBackToAllPackages(t *testing.T) {
 	mock.SetResponse("go", []string{"list", "-m"},
 		[]byte("example.com/mylib\n"), nil)
 
-	targets, err := ResolveBuildTargets(mock)
+	targets, err := ResolveBuildTargets(mock, config.Build{})
 	require.Nil(t, err)
 	require.Equal(t, 2, len(targets))
 
@@ -195,7 +196,36 @@ func TestResolveBuildTargetsFallsBackToAllPackages(t *testing.T) {
 	assert.Contains(t, names, "example.com/mylib/pkg")
 }
 
-func TestResolveBuildTargetsDeduplicates(t *testing.T) {
+func TestResolveBuildTargetsFallbackNameCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	// A library whose packages a/util and b/util share a base name
+	os.MkdirAll("a/util", 0755)
+	os.MkdirAll("b/util", 0755)
+	os.WriteFile("a/util/util.go", []byte("package util"), 0644)
+	os.WriteFile("b/util/util.go", []byte("package util"), 0644)
+
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-f", `{{if eq .Name "main"}}{{.ImportPath}}{{end}}`, "./..."},
+		[]byte("\n"), nil)
+	mock.SetResponse("go", []string{"list", "-m"},
+		[]byte("example.com/mylib\n"), nil)
+
+	_, err := ResolveBuildTargets(mock, config.Build{})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "example.com/mylib/a/util")
+	assert.Contains(t, err.Error(), "example.com/mylib/b/util")
+
+	targets, err := ResolveBuildTargets(mock, config.Build{Exclude: []string{"./b/util"}})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(targets))
+	assert.Equal(t, "example.com/mylib/a/util", targets[0].ImportPath)
+}
+
+func TestResolveBuildTargetsNameCollision(t *testing.T) {
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -208,9 +238,133 @@ func TestResolveBuildTargetsDeduplicates(t *testing.T) {
 	mock.SetResponse("go", []string{"list", "-m"},
 		[]byte("example.com/mymod\n"), nil)
 
-	targets, err := ResolveBuildTargets(mock)
+	_, err := ResolveBuildTargets(mock, config.Build{})
+	// Both resolve to "mymod" — must be reported rather than silently dropped
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "example.com/mymod")
+	assert.Contains(t, err.Error(), "example.com/mymod/src")
+	assert.Contains(t, err.Error(), `"mymod"`)
+}
+
+func TestResolveBuildTargetsExcludeResolvesCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-f", `{{if eq .Name "main"}}{{.ImportPath}}{{end}}`, "./..."},
+		[]byte("example.com/mymod\nexample.com/mymod/src\n"), nil)
+	mock.SetResponse("go", []string{"list", "-m"},
+		[]byte("example.com/mymod\n"), nil)
+
+	targets, err := ResolveBuildTargets(mock, config.Build{Exclude: []string{"."}})
 	require.Nil(t, err)
-	// Both resolve to "mymod" — should be deduplicated to 1
 	require.Equal(t, 1, len(targets))
-	assert.Equal(t, "mymod", targets[0].OutputName)
+	assert.Equal(t, "example.com/mymod/src", targets[0].ImportPath)
+}
+
+func TestResolveBuildTargetsDeclaredOnly(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-f", `{{if eq .Name "main"}}{{.ImportPath}}{{end}}`, "./..."},
+		[]byte("example.com/cmd/foo\nexample.com/cmd/bar\n"), nil)
+	mock.SetResponse("go", []string{"list", "-m"},
+		[]byte("example.com\n"), nil)
+
+	cfg := config.Build{Targets: []config.Target{
+		{Package: "./cmd/foo", Output: "foo-server", Tags: []string{"netgo"}},
+	}}
+	targets, err := ResolveBuildTargets(mock, cfg)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(targets))
+	assert.Equal(t, "example.com/cmd/foo", targets[0].ImportPath)
+	assert.Equal(t, "foo-server", targets[0].OutputName)
+	assert.Equal(t, []string{"netgo"}, targets[0].Tags)
+
+	// Declared-only mode never asks go list for main packages
+	for _, c := range mock.Calls() {
+		assert.False(t, c.HasArg("./..."))
+	}
+}
+
+func TestResolveBuildTargetsDeclaredWithAutoDiscover(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-f", `{{if eq .Name "main"}}{{.ImportPath}}{{end}}`, "./..."},
+		[]byte("example.com/cmd/foo\nexample.com/cmd/bar\n"), nil)
+	mock.SetResponse("go", []string{"list", "-m"},
+		[]byte("example.com\n"), nil)
+
+	cfg := config.Build{
+		AutoDiscover: true,
+		Targets:      []config.Target{{Package: "example.com/cmd/foo", Trimpath: true}},
+	}
+	targets, err := ResolveBuildTargets(mock, cfg)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(targets))
+	assert.Equal(t, "bar", targets[0].OutputName)
+	assert.False(t, targets[0].Trimpath)
+	// The declared settings win over the discovered duplicate
+	assert.Equal(t, "foo", targets[1].OutputName)
+	assert.True(t, targets[1].Trimpath)
+}
+
+func TestResolveBuildTargetsDeclaredCollision(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m"},
+		[]byte("example.com\n"), nil)
+
+	cfg := config.Build{Targets: []config.Target{
+		{Package: "./cmd/foo", Output: "app"},
+		{Package: "./cmd/bar", Output: "app"},
+	}}
+	_, err := ResolveBuildTargets(mock, cfg)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), `"app"`)
+}
+
+func TestResolveImportPath(t *testing.T) {
+	assert.Equal(t, "example.com", resolveImportPath(".", "example.com"))
+	assert.Equal(t, "example.com/cmd/foo", resolveImportPath("./cmd/foo", "example.com"))
+	assert.Equal(t, "example.com/cmd/foo", resolveImportPath("./cmd/foo/", "example.com"))
+	assert.Equal(t, "other.org/tool", resolveImportPath("other.org/tool", "example.com"))
+}
+
+func TestTargetBuildArgs(t *testing.T) {
+	plain := Target{ImportPath: "example.com/cmd/foo"}
+	assert.Equal(t, []string{"build", "-ldflags", "-X a.b=c", "-o", "out/foo", "example.com/cmd/foo"},
+		plain.BuildArgs("-X a.b=c", "out/foo"))
+
+	// No ldflags at all → flag omitted
+	assert.Equal(t, []string{"build", "-o", "out/foo", "example.com/cmd/foo"},
+		plain.BuildArgs("", "out/foo"))
+
+	full := Target{
+		ImportPath: "example.com/cmd/foo",
+		Tags:       []string{"netgo", "osusergo"},
+		Trimpath:   true,
+		Gcflags:    "all=-N -l",
+		Ldflags:    "-s -w",
+		Vars:       map[string]string{"main.z": "1", "main.a": "2"},
+		PGO:        "cmd/foo/default.pgo",
+	}
+	assert.Equal(t, []string{
+		"build",
+		"-tags", "netgo,osusergo",
+		"-trimpath",
+		"-gcflags", "all=-N -l",
+		"-pgo", "cmd/foo/default.pgo",
+		"-ldflags", "-X v.v=1 -X main.a=2 -X main.z=1 -s -w",
+		"-o", "out/foo", "example.com/cmd/foo",
+	}, full.BuildArgs("-X v.v=1", "out/foo"))
+
+	// Values with spaces are quoted so go keeps each -X together
+	spaced := Target{
+		ImportPath: "example.com/cmd/foo",
+		Vars:       map[string]string{"main.desc": "nightly build", "main.owner": "Bob's team", "main.plain": "x"},
+	}
+	assert.Equal(t, []string{
+		"build",
+		"-ldflags", `-X 'main.desc=nightly build' -X "main.owner=Bob's team" -X main.plain=x`,
+		"-o", "out/foo", "example.com/cmd/foo",
+	}, spaced.BuildArgs("", "out/foo"))
 }
@@ -0,0 +1,113 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// Zig target triple parts for each GOARCH and GOOS.
+var (
+	zigArch = map[string]string{
+		"amd64": "x86_64", "arm64": "aarch64", "386": "x86", "arm": "arm",
+		"riscv64": "riscv64", "ppc64le": "powerpc64le", "s390x": "s390x", "loong64": "loongarch64",
+	}
+	zigOS = map[string]string{
+		"linux": "linux-gnu", "windows": "windows-gnu", "darwin": "macos", "freebsd": "freebsd",
+	}
+)
+
+// zigTarget derives the `zig cc -target` triple for p, e.g. aarch64-linux-gnu.
+func zigTarget(p Platform) (string, error) {
+	arch, ok := zigArch[p.GOARCH]
+	if !ok {
+		return "", fmt.Errorf("no zig target for %s; set \"zig_target\"", p)
+	}
+	osName, ok := zigOS[p.GOOS]
+	if !ok {
+		return "", fmt.Errorf("no zig target for %s; set \"zig_target\"", p)
+	}
+	if p.GOOS == "linux" && p.GOARCH == "arm" {
+		osName = "linux-gnueabihf"
+	}
+	return arch + "-" + osName, nil
+}
+
+// CToolchainEnv returns the CC, CXX and CGO_*FLAGS environment for a cgo
+// build of p, taken from the first toolchain whose platform pattern matches.
+// ok is false when none matches.
+func CToolchainEnv(toolchains []config.CToolchain, p Platform) (env map[string]string, ok bool, err error) {
+	for _, tc := range toolchains {
+		if !p.matches(tc.Platform) {
+			continue
+		}
+		env = make(map[string]string)
+		cc, cxx := tc.CC, tc.CXX
+		if tc.Zig {
+			target := tc.ZigTarget
+			if target == "" {
+				if target, err = zigTarget(p); err != nil {
+					return nil, false, err
+				}
+			}
+			cc = "zig cc -target " + target
+			cxx = "zig c++ -target " + target
+		}
+		if cc != "" {
+			env["CC"] = cc
+		}
+		if cxx != "" {
+			env["CXX"] = cxx
+		}
+		if tc.Sysroot != "" {
+			sysroot := "--sysroot=" + tc.Sysroot
+			env["CGO_CFLAGS"] = withFlag("CGO_CFLAGS", sysroot)
+			env["CGO_CXXFLAGS"] = withFlag("CGO_CXXFLAGS", sysroot)
+			env["CGO_LDFLAGS"] = strings.TrimSpace(os.Getenv("CGO_LDFLAGS") + " " + sysroot)
+		}
+		return env, true, nil
+	}
+	return nil, false, nil
+}
+
+// withFlag appends flag to the compiler flags in the environment variable
+// name. Setting the variable replaces Go's default "-O2 -g", so that is kept
+// when it is unset.
+func withFlag(name, flag string) string {
+	flags := os.Getenv(name)
+	if flags == "" {
+		flags = "-O2 -g"
+	}
+	return flags + " " + flag
+}
+
+// CgoDependencies lists the non-standard packages in t's dependency graph
+// that contain cgo files. Building t with CGO_ENABLED=0 either fails or
+// silently swaps these for pure-Go fallbacks.
+func CgoDependencies(r runner.CommandRunner, t Target) ([]string, error) {
+	args := []string{"list", "-deps", "-f", "{{if and .CgoFiles (not .Standard)}}{{.ImportPath}}{{end}}"}
+	if len(t.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(t.Tags, ","))
+	}
+	args = append(args, t.ImportPath)
+	proc, err := runner.Cmd("go", args...).WithEnv("CGO_ENABLED", "1").WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+
+	var pkgs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			pkgs = append(pkgs, line)
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,83 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestZigTarget(t *testing.T) {
+	target, err := zigTarget(Platform{GOOS: "linux", GOARCH: "arm64"})
+	require.NoError(t, err)
+	assert.Equal(t, "aarch64-linux-gnu", target)
+	target, err = zigTarget(Platform{GOOS: "linux", GOARCH: "arm", Variant: "7"})
+	require.NoError(t, err)
+	assert.Equal(t, "arm-linux-gnueabihf", target)
+	target, err = zigTarget(Platform{GOOS: "windows", GOARCH: "amd64"})
+	require.NoError(t, err)
+	assert.Equal(t, "x86_64-windows-gnu", target)
+
+	_, err = zigTarget(Platform{GOOS: "plan9", GOARCH: "amd64"})
+	assert.NotNil(t, err)
+}
+
+func TestCToolchainEnv(t *testing.T) {
+	toolchains := []config.CToolchain{
+		{Platform: "linux/arm64", CC: "aarch64-linux-gnu-gcc", CXX: "aarch64-linux-gnu-g++", Sysroot: "/opt/sysroot"},
+		{Platform: "linux/*", Zig: true},
+		{Platform: "windows/amd64", Zig: true, ZigTarget: "x86_64-windows-msvc"},
+	}
+
+	t.Setenv("CGO_CFLAGS", "")
+	t.Setenv("CGO_CXXFLAGS", "")
+	t.Setenv("CGO_LDFLAGS", "")
+	env, ok, err := CToolchainEnv(toolchains, Platform{GOOS: "linux", GOARCH: "arm64"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "aarch64-linux-gnu-gcc", env["CC"])
+	assert.Equal(t, "aarch64-linux-gnu-g++", env["CXX"])
+	assert.Equal(t, "-O2 -g --sysroot=/opt/sysroot", env["CGO_CFLAGS"])
+	assert.Equal(t, "-O2 -g --sysroot=/opt/sysroot", env["CGO_CXXFLAGS"])
+	assert.Equal(t, "--sysroot=/opt/sysroot", env["CGO_LDFLAGS"])
+
+	// Flags already in the environment are kept
+	t.Setenv("CGO_CFLAGS", "-O3 -DNDEBUG")
+	t.Setenv("CGO_LDFLAGS", "-lm")
+	env, _, err = CToolchainEnv(toolchains, Platform{GOOS: "linux", GOARCH: "arm64"})
+	require.NoError(t, err)
+	assert.Equal(t, "-O3 -DNDEBUG --sysroot=/opt/sysroot", env["CGO_CFLAGS"])
+	assert.Equal(t, "-O2 -g --sysroot=/opt/sysroot", env["CGO_CXXFLAGS"])
+	assert.Equal(t, "-lm --sysroot=/opt/sysroot", env["CGO_LDFLAGS"])
+
+	env, ok, err = CToolchainEnv(toolchains, Platform{GOOS: "linux", GOARCH: "riscv64"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "zig cc -target riscv64-linux-gnu", env["CC"])
+	assert.Equal(t, "zig c++ -target riscv64-linux-gnu", env["CXX"])
+
+	env, ok, err = CToolchainEnv(toolchains, Platform{GOOS: "windows", GOARCH: "amd64"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "zig cc -target x86_64-windows-msvc", env["CC"])
+
+	_, ok, err = CToolchainEnv(toolchains, Platform{GOOS: "darwin", GOARCH: "arm64"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = CToolchainEnv([]config.CToolchain{{Platform: "*/*", Zig: true}}, Platform{GOOS: "plan9", GOARCH: "amd64"})
+	assert.NotNil(t, err)
+}
+
+func TestCgoDependencies(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-deps", "-f", "{{if and .CgoFiles (not .Standard)}}{{.ImportPath}}{{end}}", "-tags", "sqlite", "example.com/cmd/db"},
+		[]byte("\ngithub.com/mattn/go-sqlite3\n\n"), nil)
+
+	pkgs, err := CgoDependencies(mock, Target{ImportPath: "example.com/cmd/db", Tags: []string{"sqlite"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.com/mattn/go-sqlite3"}, pkgs)
+	assert.Equal(t, "1", mock.Calls()[0].Env["CGO_ENABLED"])
+}
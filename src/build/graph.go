@@ -0,0 +1,169 @@
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// ModGraph is the module requirement graph from go mod graph. Nodes are
+// "path@version", except the main module, which has no version.
+type ModGraph struct {
+	Main  string
+	Edges map[string][]string // requirer -> required, in go mod graph order
+}
+
+// Edge is one requirement in the module graph.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// LoadModGraph runs go mod graph.
+func LoadModGraph(r runner.CommandRunner) (*ModGraph, error) {
+	proc, err := runner.Cmd("go", "mod", "graph").WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go mod graph failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go mod graph failed: %w", err)
+	}
+	return parseModGraph(out)
+}
+
+func parseModGraph(data []byte) (*ModGraph, error) {
+	g := &ModGraph{Edges: make(map[string][]string)}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected go mod graph line %q", sc.Text())
+		}
+		if g.Main == "" && !strings.Contains(fields[0], "@") {
+			g.Main = fields[0]
+		}
+		g.Edges[fields[0]] = append(g.Edges[fields[0]], fields[1])
+	}
+	return g, sc.Err()
+}
+
+// Nodes returns every node in the graph, sorted, main module first.
+func (g *ModGraph) Nodes() []string {
+	seen := map[string]bool{g.Main: true}
+	var nodes []string
+	for from, tos := range g.Edges {
+		for _, n := range append([]string{from}, tos...) {
+			if !seen[n] {
+				seen[n] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	sort.Strings(nodes)
+	return append([]string{g.Main}, nodes...)
+}
+
+// EdgeList returns every edge, sorted by requirer then required module.
+func (g *ModGraph) EdgeList() []Edge {
+	var edges []Edge
+	for from, tos := range g.Edges {
+		for _, to := range tos {
+			edges = append(edges, Edge{From: from, To: to})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// RequirePath returns the shortest requirement chain from the main module
+// to any version of the module path, or nil if the graph doesn't reach it.
+func (g *ModGraph) RequirePath(path string) []string {
+	prev := map[string]string{g.Main: ""}
+	queue := []string{g.Main}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if nodePath(node) == path {
+			var chain []string
+			for n := node; n != ""; n = prev[n] {
+				chain = append([]string{n}, chain...)
+			}
+			return chain
+		}
+		for _, next := range g.Edges[node] {
+			if _, ok := prev[next]; !ok {
+				prev[next] = node
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteDOT writes the graph in Graphviz DOT format.
+func (g *ModGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph modules {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintf(bw, "\t%q [shape=box];\n", g.Main)
+	for _, e := range g.EdgeList() {
+		fmt.Fprintf(bw, "\t%q -> %q;\n", e.From, e.To)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// nodePath strips the version from a graph node.
+func nodePath(node string) string {
+	path, _, _ := strings.Cut(node, "@")
+	return path
+}
+
+// ModWhy runs go mod why -m for the modules and returns, per module, the
+// shortest import chain from a main module package, or nil if the main
+// module doesn't need it.
+func ModWhy(r runner.CommandRunner, modules ...string) (map[string][]string, error) {
+	proc, err := runner.Cmd("go", append([]string{"mod", "why", "-m"}, modules...)...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go mod why failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go mod why failed: %w", err)
+	}
+	return parseModWhy(out), nil
+}
+
+// parseModWhy parses go mod why output: a "# module" header per module
+// followed by the import chain, or a "(main module does not need ...)" note.
+func parseModWhy(data []byte) map[string][]string {
+	why := make(map[string][]string)
+	var current string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "# "):
+			current = strings.TrimPrefix(line, "# ")
+			why[current] = nil
+		case line == "" || current == "" || strings.HasPrefix(line, "("):
+		default:
+			why[current] = append(why[current], line)
+		}
+	}
+	return why
+}
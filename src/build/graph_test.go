@@ -0,0 +1,72 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+const testModGraph = `example.com/app example.com/a@v1.0.0
+example.com/app example.com/b@v1.2.0
+example.com/a@v1.0.0 example.com/c@v0.1.0
+example.com/b@v1.2.0 example.com/c@v0.2.0
+example.com/c@v0.2.0 example.com/d@v1.0.0
+`
+
+func TestLoadModGraph(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"mod", "graph"}, []byte(testModGraph), nil)
+	g, err := LoadModGraph(mock)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com/app", g.Main)
+	assert.Equal(t, []string{"example.com/app", "example.com/a@v1.0.0", "example.com/b@v1.2.0", "example.com/c@v0.1.0", "example.com/c@v0.2.0", "example.com/d@v1.0.0"}, g.Nodes())
+	edges := g.EdgeList()
+	require.Equal(t, 5, len(edges))
+	assert.Equal(t, Edge{From: "example.com/a@v1.0.0", To: "example.com/c@v0.1.0"}, edges[0])
+
+	assert.Equal(t, []string{"example.com/app", "example.com/a@v1.0.0", "example.com/c@v0.1.0"}, g.RequirePath("example.com/c"))
+	assert.Equal(t, []string{"example.com/app", "example.com/b@v1.2.0", "example.com/c@v0.2.0", "example.com/d@v1.0.0"}, g.RequirePath("example.com/d"))
+	assert.Equal(t, []string{"example.com/app"}, g.RequirePath("example.com/app"))
+	assert.Nil(t, g.RequirePath("example.com/missing"))
+
+	var sb strings.Builder
+	require.NoError(t, g.WriteDOT(&sb))
+	assert.True(t, strings.HasPrefix(sb.String(), "digraph modules {\n"))
+	assert.Contains(t, sb.String(), "\t\"example.com/app\" [shape=box];\n")
+	assert.Contains(t, sb.String(), "\t\"example.com/c@v0.2.0\" -> \"example.com/d@v1.0.0\";\n")
+}
+
+func TestLoadModGraphErrors(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"mod", "graph"}, nil, assert.AnError)
+	_, err := LoadModGraph(mock)
+	assert.NotNil(t, err)
+
+	_, err = parseModGraph([]byte("one two three\n"))
+	assert.NotNil(t, err)
+}
+
+func TestModWhy(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"mod", "why", "-m", "example.com/a", "example.com/unused"}, []byte(`# example.com/a
+example.com/app/cmd
+example.com/a/pkg
+
+# example.com/unused
+(main module does not need module example.com/unused)
+`), nil)
+	why, err := ModWhy(mock, "example.com/a", "example.com/unused")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com/app/cmd", "example.com/a/pkg"}, why["example.com/a"])
+	chain, ok := why["example.com/unused"]
+	assert.True(t, ok)
+	assert.Nil(t, chain)
+
+	mock.SetResponse("go", []string{"mod", "why", "-m", "x"}, nil, assert.AnError)
+	_, err = ModWhy(mock, "x")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,65 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// Module is one entry of the module graph, as reported by go list -m -json.
+type Module struct {
+	Path      string
+	Version   string
+	Time      string   `json:",omitempty"`
+	Replace   *Module  `json:",omitempty"`
+	Main      bool     `json:",omitempty"`
+	Indirect  bool     `json:",omitempty"`
+	Dir       string   `json:",omitempty"` // empty if the module isn't in the module cache
+	GoMod     string   `json:",omitempty"`
+	GoVersion string   `json:",omitempty"`
+	Sum       string   `json:",omitempty"`
+	Update    *Module  `json:",omitempty"` // with -u: the newest available version
+	Versions  []string `json:",omitempty"` // with -versions: every tagged version
+}
+
+// ListModules returns the main module followed by every module in the
+// build list (go list -m -json all).
+func ListModules(r runner.CommandRunner) ([]Module, error) {
+	return QueryModules(r, nil, "all")
+}
+
+// QueryModules runs go list -m -json with extra flags (-u, -versions) for
+// module queries such as a path or path@version.
+func QueryModules(r runner.CommandRunner, flags []string, queries ...string) ([]Module, error) {
+	args := append([]string{"list", "-m", "-json"}, flags...)
+	proc, err := runner.Cmd("go", append(args, queries...)...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go list -m failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go list -m failed: %w", err)
+	}
+	return parseModules(out)
+}
+
+// parseModules decodes the stream of JSON objects go list -m -json prints.
+func parseModules(data []byte) ([]Module, error) {
+	var mods []Module
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var m Module
+		err := dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			return mods, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse go list -m output: %w", err)
+		}
+		mods = append(mods, m)
+	}
+}
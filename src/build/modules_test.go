@@ -0,0 +1,77 @@
+package build
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+const testModulesJSON = `{
+	"Path": "example.com/app",
+	"Main": true,
+	"Dir": "/src/app",
+	"GoVersion": "1.24"
+}
+{
+	"Path": "golang.org/x/text",
+	"Version": "v0.14.0",
+	"Indirect": true,
+	"Sum": "h1:abc="
+}
+{
+	"Path": "example.com/forked",
+	"Version": "v1.0.0",
+	"Replace": {
+		"Path": "../forked",
+		"Dir": "/src/forked"
+	}
+}
+`
+
+func TestListModules(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "all"}, []byte(testModulesJSON), nil)
+
+	mods, err := ListModules(mock)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(mods))
+	assert.True(t, mods[0].Main)
+	assert.Equal(t, "/src/app", mods[0].Dir)
+	assert.Equal(t, "v0.14.0", mods[1].Version)
+	assert.True(t, mods[1].Indirect)
+	assert.Equal(t, "h1:abc=", mods[1].Sum)
+	require.NotNil(t, mods[2].Replace)
+	assert.Equal(t, "../forked", mods[2].Replace.Path)
+}
+
+func TestListModulesErrors(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "all"}, nil, errors.New("boom"))
+	_, err := ListModules(mock)
+	assert.NotNil(t, err)
+
+	mock = runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "all"}, []byte("{not json"), nil)
+	_, err = ListModules(mock)
+	assert.NotNil(t, err)
+}
+
+func TestQueryModules(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "-u", "-versions", "example.com/lib"}, []byte(`{
+	"Path": "example.com/lib",
+	"Version": "v1.2.0",
+	"Versions": ["v1.1.0", "v1.2.0", "v1.3.0"],
+	"Update": {"Path": "example.com/lib", "Version": "v1.3.0", "Time": "2026-01-02T03:04:05Z"}
+}`), nil)
+
+	mods, err := QueryModules(mock, []string{"-u", "-versions"}, "example.com/lib")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(mods))
+	assert.Equal(t, []string{"v1.1.0", "v1.2.0", "v1.3.0"}, mods[0].Versions)
+	require.NotNil(t, mods[0].Update)
+	assert.Equal(t, "2026-01-02T03:04:05Z", mods[0].Update.Time)
+}
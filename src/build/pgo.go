@@ -0,0 +1,91 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// PGOFile is the profile go build uses for profile-guided optimization
+// when it sits in a main package's directory.
+const PGOFile = "default.pgo"
+
+// MainPackageDirs maps the main packages among importPaths to their
+// directories, relative to the current directory when inside it.
+func MainPackageDirs(r runner.CommandRunner, importPaths []string) (map[string]string, error) {
+	dirs := make(map[string]string)
+	if len(importPaths) == 0 {
+		return dirs, nil
+	}
+	args := append([]string{"list", "-f", "{{if eq .Name \"main\"}}{{.ImportPath}}\t{{.Dir}}{{end}}"}, importPaths...)
+	proc, err := runner.Cmd("go", args...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+
+	wd, _ := os.Getwd()
+	for _, line := range strings.Split(string(out), "\n") {
+		pkg, dir, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		if rel, err := filepath.Rel(wd, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			dir = rel
+		}
+		dirs[pkg] = dir
+	}
+	return dirs, nil
+}
+
+// ApplyPGO points each target whose main package directory holds a
+// default.pgo at it. go build's default -pgo=auto only finds the profile
+// when building a single main package, and passing it explicitly records
+// it in the build arguments.
+func ApplyPGO(r runner.CommandRunner, targets []Target) ([]Target, error) {
+	paths := make([]string, len(targets))
+	for i, t := range targets {
+		paths[i] = t.ImportPath
+	}
+	dirs, err := MainPackageDirs(r, paths)
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range targets {
+		dir, ok := dirs[t.ImportPath]
+		if !ok {
+			continue
+		}
+		profile := filepath.Join(dir, PGOFile)
+		if info, err := os.Stat(profile); err == nil && info.Mode().IsRegular() {
+			targets[i].PGO = profile
+		}
+	}
+	return targets, nil
+}
+
+// ModuleDependencies lists the packages of the main module that t is
+// built from, t's own package included.
+func ModuleDependencies(r runner.CommandRunner, t Target) ([]string, error) {
+	args := []string{"list", "-deps", "-f", "{{if and .Module .Module.Main}}{{.ImportPath}}{{end}}"}
+	if len(t.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(t.Tags, ","))
+	}
+	args = append(args, t.ImportPath)
+	proc, err := runner.Cmd("go", args...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	return strings.Fields(string(out)), nil
+}
@@ -0,0 +1,64 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+const mainDirsFormat = "{{if eq .Name \"main\"}}{{.ImportPath}}\t{{.Dir}}{{end}}"
+
+func TestApplyPGO(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cmd", "foo"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cmd", "bar"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cmd", "foo", PGOFile), []byte("profile"), 0644))
+
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-f", mainDirsFormat, "ex/cmd/foo", "ex/cmd/bar", "ex/lib"},
+		[]byte("ex/cmd/foo\t"+filepath.Join(dir, "cmd", "foo")+"\nex/cmd/bar\t"+filepath.Join(dir, "cmd", "bar")+"\n\n"), nil)
+
+	targets, err := ApplyPGO(mock, []Target{
+		{ImportPath: "ex/cmd/foo", OutputName: "foo"},
+		{ImportPath: "ex/cmd/bar", OutputName: "bar"},
+		{ImportPath: "ex/lib", OutputName: "lib"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("cmd", "foo", PGOFile), targets[0].PGO)
+	assert.Empty(t, targets[1].PGO)
+	assert.Empty(t, targets[2].PGO)
+}
+
+func TestMainPackageDirsOutsideWorkingDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-f", mainDirsFormat, "other.org/tool"}, []byte("other.org/tool\t/mod/other.org/tool\n"), nil)
+
+	dirs, err := MainPackageDirs(mock, []string{"other.org/tool"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"other.org/tool": "/mod/other.org/tool"}, dirs)
+
+	dirs, err = MainPackageDirs(mock, nil)
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Len(t, mock.Calls(), 1)
+}
+
+func TestModuleDependencies(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-deps", "-f", "{{if and .Module .Module.Main}}{{.ImportPath}}{{end}}", "-tags", "netgo", "ex/cmd/foo"},
+		[]byte("\n\nex/internal/parse\n\nex/cmd/foo\n"), nil)
+
+	deps, err := ModuleDependencies(mock, Target{ImportPath: "ex/cmd/foo", Tags: []string{"netgo"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ex/internal/parse", "ex/cmd/foo"}, deps)
+
+	mock.SetResponse("go", []string{"list", "-deps", "-f", "{{if and .Module .Module.Main}}{{.ImportPath}}{{end}}", "ex/broken"}, nil, assert.AnError)
+	_, err = ModuleDependencies(mock, Target{ImportPath: "ex/broken"})
+	assert.ErrorContains(t, err, "go list failed")
+}
@@ -0,0 +1,199 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// Platform is a GOOS/GOARCH pair with an optional microarchitecture
+// variant, e.g. linux/arm/7 (GOARM=7) or linux/amd64/v3 (GOAMD64=v3).
+type Platform struct {
+	GOOS    string
+	GOARCH  string
+	Variant string
+}
+
+// variantEnv maps each GOARCH that has variants to its environment variable.
+var variantEnv = map[string]string{
+	"386":      "GO386",
+	"amd64":    "GOAMD64",
+	"arm":      "GOARM",
+	"arm64":    "GOARM64",
+	"mips":     "GOMIPS",
+	"mipsle":   "GOMIPS",
+	"mips64":   "GOMIPS64",
+	"mips64le": "GOMIPS64",
+	"ppc64":    "GOPPC64",
+	"ppc64le":  "GOPPC64",
+	"riscv64":  "GORISCV64",
+}
+
+// validVariants matches the variant values accepted for each GOARCH.
+var validVariants = map[string]*regexp.Regexp{
+	"386":      regexp.MustCompile(`^(sse2|softfloat)$`),
+	"amd64":    regexp.MustCompile(`^v[1-4]$`),
+	"arm":      regexp.MustCompile(`^[5-7]$`),
+	"arm64":    regexp.MustCompile(`^v(8\.[0-9]|9\.[0-5])$`),
+	"mips":     regexp.MustCompile(`^(hardfloat|softfloat)$`),
+	"mipsle":   regexp.MustCompile(`^(hardfloat|softfloat)$`),
+	"mips64":   regexp.MustCompile(`^(hardfloat|softfloat)$`),
+	"mips64le": regexp.MustCompile(`^(hardfloat|softfloat)$`),
+	"ppc64":    regexp.MustCompile(`^power(8|9|10)$`),
+	"ppc64le":  regexp.MustCompile(`^power(8|9|10)$`),
+	"riscv64":  regexp.MustCompile(`^rva2[02]u64$`),
+}
+
+// ParsePlatform parses "os/arch" or "os/arch/variant".
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("invalid platform %q (want os/arch or os/arch/variant)", s)
+	}
+	p := Platform{GOOS: parts[0], GOARCH: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+		re, ok := validVariants[p.GOARCH]
+		if !ok {
+			return Platform{}, fmt.Errorf("invalid platform %q: %s has no variants", s, p.GOARCH)
+		}
+		if !re.MatchString(p.Variant) {
+			return Platform{}, fmt.Errorf("invalid platform %q: unknown %s value %q", s, variantEnv[p.GOARCH], p.Variant)
+		}
+	}
+	return p, nil
+}
+
+// String returns the platform as os/arch[/variant].
+func (p Platform) String() string {
+	s := p.GOOS + "/" + p.GOARCH
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Suffix returns the platform as an output-name suffix: os_arch[_variant].
+func (p Platform) Suffix() string {
+	return strings.ReplaceAll(p.String(), "/", "_")
+}
+
+// Env returns the environment for building this platform.
+func (p Platform) Env() map[string]string {
+	env := map[string]string{"GOOS": p.GOOS, "GOARCH": p.GOARCH}
+	if p.Variant != "" {
+		env[variantEnv[p.GOARCH]] = p.Variant
+	}
+	return env
+}
+
+// matches reports whether p matches an exclusion pattern. Patterns use
+// path.Match syntax per element; a pattern without a variant matches every
+// variant, e.g. "windows/*", "*/386" or "linux/arm/5".
+func (p Platform) matches(pattern string) bool {
+	parts := strings.Split(pattern, "/")
+	fields := []string{p.GOOS, p.GOARCH, p.Variant}
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
+	}
+	for i, part := range parts {
+		if ok, _ := path.Match(part, fields[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// PlatformSelection describes which platforms to build.
+type PlatformSelection struct {
+	Platforms []string // explicit os/arch[/variant] entries; override OS x Arch
+	OS        []string
+	Arch      []string
+	Exclude   []string // patterns dropped from the selection
+}
+
+// ResolvePlatforms expands sel into a list of platforms, drops excluded ones
+// and checks the rest against `go tool dist list`, so unsupported pairs fail
+// before anything is built.
+func ResolvePlatforms(r runner.CommandRunner, sel PlatformSelection) ([]Platform, error) {
+	var candidates []Platform
+	if len(sel.Platforms) > 0 {
+		for _, s := range sel.Platforms {
+			p, err := ParsePlatform(s)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, p)
+		}
+	} else {
+		for _, goos := range sel.OS {
+			for _, goarch := range sel.Arch {
+				candidates = append(candidates, Platform{GOOS: goos, GOARCH: goarch})
+			}
+		}
+	}
+	for _, pattern := range sel.Exclude {
+		if n := strings.Count(pattern, "/"); n < 1 || n > 2 {
+			return nil, fmt.Errorf("invalid platform exclusion %q (want os/arch or os/arch/variant, * allowed)", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid platform exclusion %q: %w", pattern, err)
+		}
+	}
+
+	var platforms []Platform
+	seen := make(map[Platform]bool)
+	for _, p := range candidates {
+		if seen[p] || slices.ContainsFunc(sel.Exclude, p.matches) {
+			continue
+		}
+		seen[p] = true
+		platforms = append(platforms, p)
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no platforms to build (check --platforms, --os/--arch and --exclude)")
+	}
+
+	supported, err := SupportedPlatforms(r)
+	if err != nil {
+		return nil, err
+	}
+	var unsupported []string
+	for _, p := range platforms {
+		if !supported[p.GOOS+"/"+p.GOARCH] {
+			unsupported = append(unsupported, p.GOOS+"/"+p.GOARCH)
+		}
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("unsupported platforms: %s (see `go tool dist list`)", strings.Join(unsupported, ", "))
+	}
+	return platforms, nil
+}
+
+// SupportedPlatforms returns the os/arch pairs the Go toolchain can target.
+func SupportedPlatforms(r runner.CommandRunner) (map[string]bool, error) {
+	proc, err := runner.Cmd("go", "tool", "dist", "list").WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("go tool dist list failed: %w", err)
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("go tool dist list failed: %w", err)
+	}
+
+	supported := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			supported[line] = true
+		}
+	}
+	if len(supported) == 0 {
+		return nil, fmt.Errorf("go tool dist list returned no platforms")
+	}
+	return supported, nil
+}
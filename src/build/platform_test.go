@@ -0,0 +1,110 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func distListMock() *runner.Mock {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"tool", "dist", "list"},
+		[]byte("darwin/arm64\nlinux/amd64\nlinux/arm\nlinux/arm64\nlinux/riscv64\nwindows/amd64\nwindows/arm64\n"), nil)
+	return mock
+}
+
+func TestParsePlatform(t *testing.T) {
+	p, err := ParsePlatform("linux/arm/7")
+	require.NoError(t, err)
+	assert.Equal(t, Platform{GOOS: "linux", GOARCH: "arm", Variant: "7"}, p)
+	assert.Equal(t, "linux/arm/7", p.String())
+	assert.Equal(t, "linux_arm_7", p.Suffix())
+	assert.Equal(t, map[string]string{"GOOS": "linux", "GOARCH": "arm", "GOARM": "7"}, p.Env())
+
+	p, err = ParsePlatform("linux/amd64/v3")
+	require.NoError(t, err)
+	assert.Equal(t, "v3", p.Env()["GOAMD64"])
+
+	p, err = ParsePlatform("darwin/arm64")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"GOOS": "darwin", "GOARCH": "arm64"}, p.Env())
+
+	for _, bad := range []string{"linux", "linux/", "/amd64", "linux/amd64/v5", "linux/arm/8", "darwin/wasm/x", "a/b/c/d"} {
+		_, err := ParsePlatform(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	p := Platform{GOOS: "linux", GOARCH: "arm", Variant: "5"}
+	assert.True(t, p.matches("linux/arm"))
+	assert.True(t, p.matches("*/arm"))
+	assert.True(t, p.matches("linux/arm/5"))
+	assert.True(t, p.matches("linux/*/*"))
+	assert.False(t, p.matches("linux/arm/7"))
+	assert.False(t, p.matches("windows/*"))
+	assert.False(t, p.matches("linux"))
+}
+
+func TestResolvePlatformsCrossProduct(t *testing.T) {
+	platforms, err := ResolvePlatforms(distListMock(), PlatformSelection{
+		OS:      []string{"linux", "windows"},
+		Arch:    []string{"amd64", "arm64"},
+		Exclude: []string{"windows/arm64"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Platform{
+		{GOOS: "linux", GOARCH: "amd64"},
+		{GOOS: "linux", GOARCH: "arm64"},
+		{GOOS: "windows", GOARCH: "amd64"},
+	}, platforms)
+}
+
+func TestResolvePlatformsExplicit(t *testing.T) {
+	platforms, err := ResolvePlatforms(distListMock(), PlatformSelection{
+		Platforms: []string{"linux/amd64", "linux/arm/6", "linux/arm/7", "linux/riscv64", "darwin/arm64", "linux/amd64"},
+		OS:        []string{"windows"},
+		Arch:      []string{"amd64"},
+		Exclude:   []string{"linux/arm/6"},
+	})
+	require.NoError(t, err)
+	var names []string
+	for _, p := range platforms {
+		names = append(names, p.String())
+	}
+	assert.Equal(t, []string{"linux/amd64", "linux/arm/7", "linux/riscv64", "darwin/arm64"}, names)
+}
+
+func TestResolvePlatformsUnsupported(t *testing.T) {
+	_, err := ResolvePlatforms(distListMock(), PlatformSelection{
+		OS:   []string{"darwin", "plan10"},
+		Arch: []string{"arm64", "386"},
+	})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "darwin/386")
+	assert.Contains(t, err.Error(), "plan10/arm64")
+	assert.NotContains(t, err.Error(), "darwin/arm64")
+}
+
+func TestResolvePlatformsAllExcluded(t *testing.T) {
+	_, err := ResolvePlatforms(distListMock(), PlatformSelection{
+		OS:      []string{"windows"},
+		Arch:    []string{"amd64"},
+		Exclude: []string{"windows/*"},
+	})
+	assert.NotNil(t, err)
+
+	_, err = ResolvePlatforms(distListMock(), PlatformSelection{
+		OS:      []string{"windows"},
+		Arch:    []string{"amd64"},
+		Exclude: []string{"windows"},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestSupportedPlatformsEmpty(t *testing.T) {
+	_, err := SupportedPlatforms(runner.NewMock())
+	assert.NotNil(t, err)
+}
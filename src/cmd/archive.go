@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/release"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// bundledDocPrefixes are module-root files always shipped in release archives.
+var bundledDocPrefixes = []string{"README", "LICENSE", "LICENCE", "COPYING", "NOTICE"}
+
+// packageArchives bundles each platform's binaries, the module's README and
+// LICENSE files, the third-party notices (if written) and any configured
+// extra files into one archive per GOOS/GOARCH: .zip for windows, .tar.gz
+// everywhere else. Returns manifest entries for the archives.
+func packageArchives(r runner.CommandRunner, cfg config.Release, binaries []artifactEntry, info gitInfo) ([]artifactEntry, error) {
+	extras, err := releaseExtraFiles(cfg.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = path.Base(moduleName(r))
+	}
+	if info.version != "" {
+		name += "_" + info.version
+	}
+
+	mtime, err := info.fileDate()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range binaries {
+		if a.Kind == "notices" {
+			extras = append(extras, release.File{Name: a.Name, Source: filepath.Join(outputDir, filepath.FromSlash(a.Path)), Mode: 0644})
+		}
+	}
+
+	byPlatform := make(map[string][]artifactEntry)
+	for _, b := range binaries {
+		if b.Kind != "binary" {
+			continue
+		}
+		key := b.GOOS + "_" + b.GOARCH
+		if b.Variant != "" {
+			key += "_" + b.Variant
+		}
+		byPlatform[key] = append(byPlatform[key], b)
+	}
+	platforms := make([]string, 0, len(byPlatform))
+	for p := range byPlatform {
+		platforms = append(platforms, p)
+	}
+	sort.Strings(platforms)
+
+	var archives []artifactEntry
+	for _, platform := range platforms {
+		bins := byPlatform[platform]
+		ext := ".tar.gz"
+		exe := ""
+		if bins[0].GOOS == "windows" {
+			ext = ".zip"
+			exe = ".exe"
+		}
+
+		files := append([]release.File(nil), extras...)
+		for _, b := range bins {
+			files = append(files, release.File{
+				Name:   b.Name + exe,
+				Source: filepath.Join(outputDir, filepath.FromSlash(b.Path)),
+				Mode:   0755,
+			})
+		}
+
+		archivePath := filepath.Join(outputDir, fmt.Sprintf("%s_%s%s", name, platform, ext))
+		if err := release.WriteArchive(archivePath, files, mtime); err != nil {
+			return nil, err
+		}
+
+		entry := artifactEntry{Kind: "archive", Name: name, GOOS: bins[0].GOOS, GOARCH: bins[0].GOARCH, Variant: bins[0].Variant}
+		for _, f := range files {
+			entry.Files = append(entry.Files, f.Name)
+		}
+		sort.Strings(entry.Files)
+		if err := entry.record(outputDir, archivePath, info, bins[0].GoVersion); err != nil {
+			return nil, err
+		}
+		archives = append(archives, entry)
+	}
+	return archives, nil
+}
+
+// releaseExtraFiles returns the README/LICENSE files in the module root plus
+// every file matched by the configured globs. A glob matching nothing is an
+// error, since it was asked for explicitly.
+func releaseExtraFiles(globs []string) ([]release.File, error) {
+	seen := make(map[string]bool)
+	var files []release.File
+	add := func(p string) error {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if seen[p] {
+			return nil
+		}
+		st, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if st.IsDir() {
+			return nil
+		}
+		seen[p] = true
+		files = append(files, release.File{Name: p, Source: filepath.FromSlash(p), Mode: 0644})
+		return nil
+	}
+
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		upper := strings.ToUpper(e.Name())
+		for _, prefix := range bundledDocPrefixes {
+			if strings.HasPrefix(upper, prefix) {
+				if err := add(e.Name()); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("bad release file pattern %q: %w", glob, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("release file pattern %q matched nothing", glob)
+		}
+		for _, m := range matches {
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// moduleName returns the current module path from `go list -m`, or the
+// working directory's name if that fails.
+func moduleName(r runner.CommandRunner) string {
+	proc, err := runner.Cmd("go", "list", "-m").WithQuiet().Run(r)
+	if err == nil {
+		out, _ := io.ReadAll(proc.Stdout())
+		if proc.Wait() == nil && strings.TrimSpace(string(out)) != "" {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	wd, _ := os.Getwd()
+	return filepath.Base(wd)
+}
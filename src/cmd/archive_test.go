@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestReleaseExtraFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	os.WriteFile("README.md", []byte("readme"), 0644)
+	os.WriteFile("LICENSE", []byte("mit"), 0644)
+	os.WriteFile("main.go", []byte("package main"), 0644)
+	os.MkdirAll("docs", 0755)
+	os.WriteFile("docs/a.md", []byte("a"), 0644)
+	os.WriteFile("docs/b.md", []byte("b"), 0644)
+
+	files, err := releaseExtraFiles([]string{"docs/*.md", "README.md"})
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	assert.ElementsMatch(t, []string{"LICENSE", "README.md", "docs/a.md", "docs/b.md"}, names)
+}
+
+func TestReleaseExtraFilesNoMatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	_, err := releaseExtraFiles([]string{"CHANGELOG.md"})
+	assert.NotNil(t, err)
+}
+
+func TestModuleNameFallsBackToDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	assert.Equal(t, filepath.Base(tmpDir), moduleName(runner.NewMock()))
+}
+
+func TestPackageArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOutput := outputDir
+	outputDir = "dist"
+	defer func() { outputDir = oldOutput }()
+
+	os.MkdirAll("dist", 0755)
+	os.WriteFile("README.md", []byte("readme"), 0644)
+	os.WriteFile("dist/app_linux_amd64", []byte("linux"), 0755)
+	os.WriteFile("dist/app_windows_amd64.exe", []byte("windows"), 0755)
+	binaries := []artifactEntry{
+		{Kind: "binary", Name: "app", Path: "app_windows_amd64.exe", GOOS: "windows", GOARCH: "amd64"},
+		{Kind: "binary", Name: "app", Path: "app_linux_amd64", GOOS: "linux", GOARCH: "amd64"},
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	archives, err := packageArchives(runner.NewMock(), config.Release{Name: "tool"}, binaries, gitInfo{version: "v1.0.0"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(archives))
+
+	assert.Equal(t, "tool_v1.0.0_linux_amd64.tar.gz", archives[0].Path)
+	assert.Equal(t, []string{"README.md", "app"}, archives[0].Files)
+	assert.Equal(t, "archive", archives[0].Kind)
+	assert.Equal(t, "tool_v1.0.0_windows_amd64.zip", archives[1].Path)
+	assert.Equal(t, []string{"README.md", "app.exe"}, archives[1].Files)
+	assert.NotEqual(t, "", archives[1].SHA256)
+
+	_, err = os.Stat(filepath.Join("dist", "tool_v1.0.0_windows_amd64.zip"))
+	assert.NoError(t, err)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	_, err = packageArchives(runner.NewMock(), config.Release{Name: "tool"}, binaries, gitInfo{version: "v1.0.0"})
+	assert.NotNil(t, err)
+}
+
+func TestRunReleaseWithRunnerArchive(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	oldOutput := outputDir
+	oldArchive := matrixArchive
+	matrixOS = []string{"linux", "windows"}
+	matrixArch = []string{"arm64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	matrixArchive = true
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+		outputDir = oldOutput
+		matrixArchive = oldArchive
+	}()
+
+	mock := newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+
+	kinds := map[string]int{}
+	for _, a := range m.Artifacts {
+		kinds[a.Kind]++
+	}
+	assert.Equal(t, 2, kinds["binary"])
+	assert.Equal(t, 2, kinds["archive"])
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wow-look-at-my/go-toolchain/src/bench"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
@@ -15,23 +17,39 @@ var (
 	benchTime   string
 	benchCount  int
 	benchCPU    string
+
+	benchBaseline string
+	benchFilter   string
+	benchSkip     string
+	benchExclude  []string
+
+	benchStrictEnv bool
+	benchStabilize bool
+
+	historyFormat    string
+	historyUnit      string
+	historyMax       int
+	historyThreshold float64
+
+	bisectCount int
+	bisectUnit  string
 )
 
 var benchCmd = &cobra.Command{
 	Use:   "bench",
 	Short: "Run and manage benchmarks",
-	Long:  "Run benchmarks and compare against previous stored results.\n\nSubcommands: run, save, show, compare",
+	Long:  "Run benchmarks and compare against previous stored results.\n\nSubcommands: run, save, show, compare, history, bisect, push, pull",
 }
 
 var benchRunCmd = &cobra.Command{
-	Use:          "run",
+	Use:          "run [packages]",
 	Short:        "Run benchmarks and show deltas vs stored results",
 	SilenceUsage: true,
 	RunE:         runBenchRun,
 }
 
 var benchSaveCmd = &cobra.Command{
-	Use:          "save",
+	Use:          "save [packages]",
 	Short:        "Run benchmarks and store results in git notes",
 	SilenceUsage: true,
 	RunE:         runBenchSave,
@@ -52,33 +70,120 @@ var benchCompareCmd = &cobra.Command{
 	RunE:         runBenchCompare,
 }
 
+var benchHistoryCmd = &cobra.Command{
+	Use:          "history [range]",
+	Short:        "Show each benchmark's trend over a commit range (default: HEAD)",
+	Long:         "Show each benchmark's stored results over a git revision range (e.g. v1.0.0..HEAD) as a sparkline, and flag the commit where its largest significant step change happened.",
+	SilenceUsage: true,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runBenchHistory,
+}
+
+var benchBisectCmd = &cobra.Command{
+	Use:   "bisect <good> <bad> <benchmark> [packages]",
+	Short: "Find the commit where a benchmark changed",
+	Long: "Binary-search the commits between good and bad for the one where a benchmark's results changed. " +
+		"Each commit is checked out into a temporary worktree and only that benchmark is run there; " +
+		"a commit counts as bad when its median is closer to bad's than to good's. " +
+		"Results are stored in the benchmark notes, so commits measured before aren't run again.",
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(3),
+	RunE:         runBenchBisect,
+}
+
+var benchPushCmd = &cobra.Command{
+	Use:   "push [remote]",
+	Short: "Share stored benchmark results with a git remote (default: bench.remote or origin)",
+	Long: "Merge the remote's benchmark notes into the local ones and push the result. " +
+		"Each commit's note keeps one entry per environment, so results from different machines are combined rather than conflicting.",
+	SilenceUsage: true,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runBenchPush,
+}
+
+var benchPullCmd = &cobra.Command{
+	Use:          "pull [remote]",
+	Short:        "Merge benchmark results from a git remote (default: bench.remote or origin)",
+	SilenceUsage: true,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runBenchPull,
+}
+
 func init() {
 	// Flags for run and save subcommands
 	for _, cmd := range []*cobra.Command{benchRunCmd, benchSaveCmd} {
 		cmd.Flags().StringVar(&benchTime, "benchtime", "", "Duration or count for each benchmark (e.g. 5s, 1000x)")
 		cmd.Flags().IntVarP(&benchCount, "count", "n", 1, "Number of times to run each benchmark")
 		cmd.Flags().StringVar(&benchCPU, "cpu", "", "GOMAXPROCS values to test with (comma-separated)")
+		cmd.Flags().StringVar(&benchFilter, "bench", "", "Run only benchmarks matching this regexp (go test -bench; default .)")
+		cmd.Flags().StringVar(&benchSkip, "skip", "", "Skip benchmarks matching this regexp (go test -skip)")
+		cmd.Flags().StringArrayVar(&benchExclude, "exclude", nil, "Leave out packages matching this pattern (repeatable)")
 	}
 
-	benchCmd.AddCommand(benchRunCmd, benchSaveCmd, benchShowCmd, benchCompareCmd)
+	for _, cmd := range []*cobra.Command{benchRunCmd, benchSaveCmd} {
+		cmd.Flags().BoolVar(&benchStabilize, "stabilize", false, "Check the CPU governor and wait for the machine to be idle before running (default: bench.stabilize)")
+	}
+	for _, cmd := range []*cobra.Command{benchRunCmd, benchCompareCmd} {
+		cmd.Flags().BoolVar(&benchStrictEnv, "strict-env", false, "Refuse to compare results from incompatible environments instead of warning (default: bench.strict_env)")
+	}
+
+	benchRunCmd.Flags().StringVar(&benchBaseline, "baseline", "", "Compare against this commit's stored results (default: bench.baseline, the merge base with bench.base, or the most recent results)")
+
+	benchHistoryCmd.Flags().StringVar(&historyFormat, "format", "table", "Output format: table, csv or json")
+	benchHistoryCmd.Flags().StringVar(&historyUnit, "unit", "ns/op", "Metric to chart: ns/op, B/op, allocs/op or a custom unit such as MB/s")
+	benchHistoryCmd.Flags().IntVar(&historyMax, "max", 50, "Only look at the newest N commits in the range (0 for all)")
+	benchHistoryCmd.Flags().Float64Var(&historyThreshold, "threshold", bench.DefaultStepThreshold, "Smallest change, in percent, to flag as a step")
+
+	benchBisectCmd.Flags().IntVarP(&bisectCount, "count", "n", bench.DefaultBisectCount, "Number of times to run the benchmark at each commit")
+	benchBisectCmd.Flags().StringVar(&benchTime, "benchtime", "", "Duration or count for each run (e.g. 5s, 1000x)")
+	benchBisectCmd.Flags().StringVar(&bisectUnit, "unit", "ns/op", "Metric to bisect on: ns/op, B/op, allocs/op or a custom unit such as MB/s")
+
+	benchCmd.AddCommand(benchRunCmd, benchSaveCmd, benchShowCmd, benchCompareCmd, benchHistoryCmd, benchBisectCmd, benchPushCmd, benchPullCmd)
 }
 
 func runBenchRun(cmd *cobra.Command, args []string) error {
 	r := runner.New()
+	benchPackages = args
 	return runBenchRunWithRunner(r, jsonOutput)
 }
 
+// benchPackages holds the package patterns given to bench run or save.
+var benchPackages []string
+
+// benchOptions returns the options for bench run and save.
+func benchOptions() bench.Options {
+	return bench.Options{
+		Time:     benchTime,
+		Count:    benchCount,
+		CPU:      benchCPU,
+		Verbose:  verbose,
+		Packages: benchPackages,
+		Bench:    benchFilter,
+		Skip:     benchSkip,
+		Exclude:  benchExclude,
+	}
+}
+
+// benchFiltered reports whether bench run or save cover only some
+// benchmarks.
+func benchFiltered() bool {
+	return len(benchPackages) > 0 || benchFilter != "" || benchSkip != "" || len(benchExclude) > 0
+}
+
 func runBenchRunWithRunner(r runner.CommandRunner, quiet bool) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+
+	if err := stabilizeBench(cfg.Bench, quiet); err != nil {
+		return err
+	}
 	if !quiet {
 		fmt.Println("==> Running benchmarks")
 	}
 
-	opts := bench.Options{
-		Time:    benchTime,
-		Count:   benchCount,
-		CPU:     benchCPU,
-		Verbose: verbose,
-	}
+	opts := benchOptions()
 
 	report, err := bench.RunBenchmarks(r, opts)
 	if err != nil {
@@ -88,45 +193,62 @@ func runBenchRunWithRunner(r runner.CommandRunner, quiet bool) error {
 		return err
 	}
 
-	// Fetch previous results for comparison
-	prev, prevSHA, _ := bench.FetchPrevious(r)
+	// Fetch the baseline results for comparison
+	fetchBenchNotes(r, cfg.Bench, quiet)
+	prev, prevSHA, err := findBenchBaseline(r, cfg.Bench)
+	if err != nil {
+		return err
+	}
+	comp := bench.Compare(report, prev)
+	comp.PreviousCommit = prevSHA
+	if err := checkBenchEnv(cfg.Bench, comp); err != nil {
+		return err
+	}
 
 	if quiet {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "\t")
-		return enc.Encode(report)
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		return checkBenchGate(cfg.Bench, comp)
 	}
 
 	if prev != nil && prevSHA != "" {
 		fmt.Printf("\n==> Benchmark comparison vs %s\n", prevSHA)
-		comp := bench.Compare(report, prev)
-		comp.PreviousCommit = prevSHA
 		comp.Print()
 	} else {
 		fmt.Println("\n==> Benchmark results (no previous data for comparison)")
 		report.Print()
 	}
 
+	if err := checkBenchGate(cfg.Bench, comp); err != nil {
+		return err
+	}
 	fmt.Println("==> Benchmarks complete")
 	return nil
 }
 
 func runBenchSave(cmd *cobra.Command, args []string) error {
 	r := runner.New()
+	benchPackages = args
 	return runBenchSaveWithRunner(r, jsonOutput)
 }
 
 func runBenchSaveWithRunner(r runner.CommandRunner, quiet bool) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+
+	if err := stabilizeBench(cfg.Bench, quiet); err != nil {
+		return err
+	}
 	if !quiet {
 		fmt.Println("==> Running benchmarks")
 	}
 
-	opts := bench.Options{
-		Time:    benchTime,
-		Count:   benchCount,
-		CPU:     benchCPU,
-		Verbose: verbose,
-	}
+	opts := benchOptions()
 
 	report, err := bench.RunBenchmarks(r, opts)
 	if err != nil {
@@ -140,6 +262,14 @@ func runBenchSaveWithRunner(r runner.CommandRunner, quiet bool) error {
 		return fmt.Errorf("no benchmark results to save")
 	}
 
+	// A filtered run only replaces the benchmarks it ran
+	if benchFiltered() {
+		if stored, err := bench.FetchForCommit(r, "HEAD"); err == nil && stored.SameEnvironment(report.Env) {
+			stored.Merge(report)
+			report = stored
+		}
+	}
+
 	if err := bench.StoreNotes(r, report); err != nil {
 		return err
 	}
@@ -179,6 +309,12 @@ func runBenchShow(cmd *cobra.Command, args []string) error {
 func runBenchCompare(cmd *cobra.Command, args []string) error {
 	r := runner.New()
 
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	fetchBenchNotes(r, cfg.Bench, jsonOutput)
+
 	report1, err := bench.FetchForCommit(r, args[0])
 	if err != nil {
 		return fmt.Errorf("commit %s: %w", args[0], err)
@@ -191,6 +327,9 @@ func runBenchCompare(cmd *cobra.Command, args []string) error {
 
 	comp := bench.Compare(report2, report1)
 	comp.PreviousCommit = args[0]
+	if err := checkBenchEnv(cfg.Bench, comp); err != nil {
+		return err
+	}
 
 	if jsonOutput {
 		enc := json.NewEncoder(os.Stdout)
@@ -203,9 +342,161 @@ func runBenchCompare(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// runBenchmarkInBuild runs benchmarks as part of the default build
-// and shows comparison against previous stored results
-func runBenchmarkInBuild(r runner.CommandRunner) error {
+func runBenchHistory(cmd *cobra.Command, args []string) error {
+	revRange := "HEAD"
+	if len(args) > 0 {
+		revRange = args[0]
+	}
+	return runBenchHistoryWithRunner(runner.New(), revRange)
+}
+
+func runBenchHistoryWithRunner(r runner.CommandRunner, revRange string) error {
+	format := historyFormat
+	if jsonOutput {
+		format = "json"
+	}
+	if format != "table" && format != "csv" && format != "json" {
+		return fmt.Errorf("unknown history format %q (use: table, csv, json)", format)
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	fetchBenchNotes(r, cfg.Bench, format != "table")
+
+	commits, err := bench.CommitRange(r, revRange, historyMax)
+	if err != nil {
+		return err
+	}
+	notes, err := bench.LoadNotes(r, commits)
+	if err != nil {
+		return err
+	}
+	history := bench.BuildHistory(commits, notes, historyUnit, historyThreshold)
+
+	switch format {
+	case "csv":
+		return history.WriteCSV(os.Stdout)
+	case "json":
+		return printJSON(history)
+	}
+	fmt.Printf("==> Benchmark history: %s over %d commits (%d with results)\n", historyUnit, len(commits), len(notes))
+	history.Print()
+	return nil
+}
+
+func runBenchBisect(cmd *cobra.Command, args []string) error {
+	return runBenchBisectWithRunner(runner.New(), args)
+}
+
+func runBenchBisectWithRunner(r runner.CommandRunner, args []string) error {
+	opts := bench.BisectOptions{
+		Good:      args[0],
+		Bad:       args[1],
+		Benchmark: args[2],
+		Unit:      bisectUnit,
+		Run: bench.Options{
+			Time:     benchTime,
+			Count:    bisectCount,
+			Verbose:  verbose,
+			Packages: args[3:],
+		},
+	}
+	if !jsonOutput {
+		fmt.Printf("==> Bisecting %s between %s and %s\n", opts.Benchmark, opts.Good, opts.Bad)
+		opts.Progress = func(step bench.BisectStep) {
+			fmt.Println("  " + step.Describe(opts.Unit))
+		}
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	fetchBenchNotes(r, cfg.Bench, jsonOutput)
+
+	result, err := bench.Bisect(r, opts)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(result)
+	}
+	result.Print()
+	return nil
+}
+
+func runBenchPush(cmd *cobra.Command, args []string) error {
+	return runBenchSyncWithRunner(runner.New(), args, true)
+}
+
+func runBenchPull(cmd *cobra.Command, args []string) error {
+	return runBenchSyncWithRunner(runner.New(), args, false)
+}
+
+// runBenchSyncWithRunner pushes the benchmark notes to, or pulls them
+// from, the remote named in args or configured.
+func runBenchSyncWithRunner(r runner.CommandRunner, args []string, push bool) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	remote := benchRemote(cfg.Bench)
+	if len(args) > 0 {
+		remote = args[0]
+	}
+
+	if push {
+		if err := bench.PushNotes(r, remote); err != nil {
+			return err
+		}
+		if !jsonOutput {
+			fmt.Printf("==> Benchmark results pushed to %s\n", remote)
+		}
+		return nil
+	}
+	if err := bench.PullNotes(r, remote); err != nil {
+		return err
+	}
+	if !jsonOutput {
+		fmt.Printf("==> Benchmark results pulled from %s\n", remote)
+	}
+	return nil
+}
+
+// benchRemote is the remote benchmark results are shared through.
+func benchRemote(cfg config.Bench) string {
+	if cfg.Remote != "" {
+		return cfg.Remote
+	}
+	return "origin"
+}
+
+// fetchBenchNotes merges the remote's benchmark results in before a
+// comparison, so baselines measured elsewhere (e.g. in CI) are used. It's
+// skipped offline, with bench.no_fetch or without the remote; a failed
+// fetch only warns, since the local results are still usable.
+func fetchBenchNotes(r runner.CommandRunner, cfg config.Bench, quiet bool) {
+	remote := benchRemote(cfg)
+	if cfg.NoFetch || isOffline() || !bench.HasRemote(r, remote) {
+		return
+	}
+	if !quiet {
+		fmt.Printf("==> Fetching benchmark results from %s\n", remote)
+	}
+	if err := bench.PullNotes(r, remote); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// runBenchmarkInBuild runs benchmarks as part of the default build,
+// shows comparison against the baseline results and enforces the
+// regression gate
+func runBenchmarkInBuild(r runner.CommandRunner, cfg config.Bench) error {
+	if err := stabilizeBench(cfg, jsonOutput); err != nil {
+		return err
+	}
 	if !jsonOutput {
 		fmt.Println("==> Running benchmarks")
 	}
@@ -225,25 +516,105 @@ func runBenchmarkInBuild(r runner.CommandRunner) error {
 		return err
 	}
 
+	// Fetch the baseline results for comparison
+	fetchBenchNotes(r, cfg, jsonOutput)
+	prev, prevSHA, err := findBenchBaseline(r, cfg)
+	if err != nil {
+		return err
+	}
+	comp := bench.Compare(report, prev)
+	comp.PreviousCommit = prevSHA
+	if err := checkBenchEnv(cfg, comp); err != nil {
+		return err
+	}
+
 	if jsonOutput {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "\t")
-		return enc.Encode(report)
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		return checkBenchGate(cfg, comp)
 	}
 
-	// Fetch previous results for comparison
-	prev, prevSHA, _ := bench.FetchPrevious(r)
-
 	if prev != nil && prevSHA != "" {
 		fmt.Printf("\n==> Benchmark comparison vs %s\n", prevSHA)
-		comp := bench.Compare(report, prev)
-		comp.PreviousCommit = prevSHA
 		comp.Print()
 	} else {
 		fmt.Println()
 		report.Print()
 	}
 
+	if err := checkBenchGate(cfg, comp); err != nil {
+		return err
+	}
 	fmt.Println("==> Benchmarks complete")
 	return nil
 }
+
+// findBenchBaseline returns the results to compare against: --baseline,
+// then the configured baseline or base ref, then the most recent results.
+func findBenchBaseline(r runner.CommandRunner, cfg config.Bench) (*bench.BenchmarkReport, string, error) {
+	pinned := cfg.Baseline
+	if benchBaseline != "" {
+		pinned = benchBaseline
+	}
+	return bench.FindBaseline(r, pinned, cfg.Base)
+}
+
+// benchGate converts the configured thresholds into a regression gate.
+func benchGate(cfg config.Bench) bench.Gate {
+	var gate bench.Gate
+	for _, t := range cfg.Thresholds {
+		gate.Thresholds = append(gate.Thresholds, bench.Threshold{
+			Package:   t.Package,
+			Benchmark: t.Benchmark,
+			MaxTime:   t.MaxTimePercent,
+			MaxBytes:  t.MaxBytesPercent,
+			MaxAllocs: t.MaxAllocsPercent,
+		})
+	}
+	return gate
+}
+
+// checkBenchGate fails when a significant regression exceeds its
+// configured threshold. Results from an incompatible environment aren't
+// gated.
+func checkBenchGate(cfg config.Bench, comp *bench.Comparison) error {
+	if len(comp.EnvMismatch) > 0 {
+		if len(cfg.Thresholds) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: skipping the benchmark regression gate: the results vs %s come from a different environment (%s)\n",
+				comp.PreviousCommit, strings.Join(comp.EnvMismatch, "; "))
+		}
+		return nil
+	}
+	if violations := benchGate(cfg).Check(comp); len(violations) > 0 {
+		return fmt.Errorf("benchmark regressions vs %s:\n  %s", comp.PreviousCommit, strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+// checkBenchEnv refuses a comparison across incompatible environments
+// with --strict-env or bench.strict_env.
+func checkBenchEnv(cfg config.Bench, comp *bench.Comparison) error {
+	if len(comp.EnvMismatch) > 0 && (benchStrictEnv || cfg.StrictEnv) {
+		return fmt.Errorf("results vs %s come from a different environment: %s", comp.PreviousCommit, strings.Join(comp.EnvMismatch, "; "))
+	}
+	return nil
+}
+
+// stabilizeBench checks the machine is fit for benchmarking when
+// --stabilize or bench.stabilize asks for it.
+func stabilizeBench(cfg config.Bench, quiet bool) error {
+	if !benchStabilize && !cfg.Stabilize {
+		return nil
+	}
+	if !quiet {
+		fmt.Println("==> Waiting for the machine to be idle")
+	}
+	warnings, err := bench.Stabilize(bench.StabilizeOptions{MaxLoad: cfg.MaxLoad})
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	return err
+}
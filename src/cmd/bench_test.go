@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
@@ -39,7 +41,7 @@ func TestRunBenchmarkInBuild(t *testing.T) {
 	benchCPU = ""
 	verbose = false
 
-	err := runBenchmarkInBuild(mock)
+	err := runBenchmarkInBuild(mock, config.Bench{})
 	assert.Nil(t, err)
 }
 
@@ -66,7 +68,7 @@ func TestRunBenchmarkInBuildJSON(t *testing.T) {
 	benchCount = 1
 	benchCPU = ""
 
-	err := runBenchmarkInBuild(mock)
+	err := runBenchmarkInBuild(mock, config.Bench{})
 	assert.Nil(t, err)
 }
 
@@ -100,10 +102,88 @@ func TestRunBenchmarkInBuildWithPrevious(t *testing.T) {
 	benchCount = 1
 	benchCPU = ""
 
-	err := runBenchmarkInBuild(mock)
+	err := runBenchmarkInBuild(mock, config.Bench{})
 	assert.Nil(t, err)
 }
 
+func TestRunBenchmarkInBuildGate(t *testing.T) {
+	mock := runner.NewMock()
+
+	benchOutput := `{"Action":"output","Package":"pkg","Output":"BenchmarkFoo-8   \t 1000\t  1234 ns/op\t  64 B/op\t  2 allocs/op\n"}`
+	benchArgs := []string{"test", "-json", "-run", "^$", "-bench", ".", "-benchmem", "./..."}
+	mock.SetResponse("go", benchArgs, []byte(benchOutput), nil)
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/main"}, []byte("abc123\n"), nil)
+	prevData := `{"packages":{"pkg":[{"name":"BenchmarkFoo-8","ns_per_op":1000,"bytes_per_op":64,"allocs_per_op":1}]}}`
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "abc123"}, []byte(prevData), nil)
+
+	oldJSON := jsonOutput
+	oldCount := benchCount
+	defer func() {
+		jsonOutput = oldJSON
+		benchCount = oldCount
+	}()
+	jsonOutput = false
+	benchCount = 1
+
+	zero, ten := 0.0, 10.0
+	cfg := config.Bench{Base: "origin/main", Thresholds: []config.BenchThreshold{{MaxTimePercent: &ten, MaxBytesPercent: &zero}}}
+
+	// A single sample can't make the 23% time change significant, and B/op
+	// didn't change
+	assert.Nil(t, runBenchmarkInBuild(mock, cfg))
+
+	// Allocs are deterministic, so doubling them fails even with one run
+	cfg.Thresholds = append(cfg.Thresholds, config.BenchThreshold{Benchmark: "Foo", MaxAllocsPercent: &zero})
+	err := runBenchmarkInBuild(mock, cfg)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "pkg BenchmarkFoo: allocs/op +100.0% > 0.0%")
+
+	// --baseline overrides the base ref
+	oldBaseline := benchBaseline
+	defer func() { benchBaseline = oldBaseline }()
+	benchBaseline = "v1.0.0"
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "v1.0.0"}, nil, fmt.Errorf("no note"))
+	err = runBenchmarkInBuild(mock, cfg)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "baseline")
+}
+
+func TestRunBenchmarkInBuildEnvMismatch(t *testing.T) {
+	mock := runner.NewMock()
+
+	benchOutput := `{"Action":"output","Package":"pkg","Output":"BenchmarkFoo-8   \t 1000\t  1234 ns/op\t  64 B/op\t  2 allocs/op\n"}`
+	benchArgs := []string{"test", "-json", "-run", "^$", "-bench", ".", "-benchmem", "./..."}
+	mock.SetResponse("go", benchArgs, []byte(benchOutput), nil)
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/main"}, []byte("abc123\n"), nil)
+	prevData := `{"packages":{"pkg":[{"name":"BenchmarkFoo-8","ns_per_op":1000,"bytes_per_op":64,"allocs_per_op":1}]},"env":{"goos":"plan9","goarch":"mips"}}`
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "abc123"}, []byte(prevData), nil)
+
+	oldJSON, oldCount, oldStrict := jsonOutput, benchCount, benchStrictEnv
+	defer func() {
+		jsonOutput, benchCount, benchStrictEnv = oldJSON, oldCount, oldStrict
+	}()
+	jsonOutput = false
+	benchCount = 1
+
+	zero := 0.0
+	cfg := config.Bench{Base: "origin/main", Thresholds: []config.BenchThreshold{{MaxAllocsPercent: &zero}}}
+
+	// The doubled allocs would fail the gate, but results from another
+	// platform aren't gated
+	output := captureStdout(t, func() error { return runBenchmarkInBuild(mock, cfg) })
+	assert.Contains(t, output, "warning: different environment: platform")
+	assert.Contains(t, output, "(was plan9/mips)")
+
+	cfg.StrictEnv = true
+	err := runBenchmarkInBuild(mock, cfg)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "results vs abc123 come from a different environment: platform")
+
+	cfg.StrictEnv = false
+	benchStrictEnv = true
+	assert.NotNil(t, runBenchmarkInBuild(mock, cfg))
+}
+
 func TestRunBenchmarkInBuildFails(t *testing.T) {
 	mock := runner.NewMock()
 
@@ -126,7 +206,7 @@ func TestRunBenchmarkInBuildFails(t *testing.T) {
 	benchCount = 1
 	benchCPU = ""
 
-	err := runBenchmarkInBuild(mock)
+	err := runBenchmarkInBuild(mock, config.Bench{})
 	assert.NotNil(t, err)
 }
 
@@ -291,3 +371,151 @@ func TestRunBenchSave(t *testing.T) {
 	// This will fail but exercises the code path
 	_ = runBenchSave(benchSaveCmd, []string{})
 }
+
+func TestRunBenchSaveFilteredMerges(t *testing.T) {
+	mock := runner.NewMock()
+	benchOutput := `{"Action":"output","Package":"pkg","Output":"BenchmarkFoo/n=10-8   \t 1000\t  900 ns/op\n"}`
+	mock.SetResponse("go", []string{"test", "-json", "-run", "^$", "-bench", "Foo", "-benchmem", "./pkg"}, []byte(benchOutput), nil)
+	stored := `{"packages":{"pkg":[{"name":"BenchmarkFoo/n=10-8","ns_per_op":1000},{"name":"BenchmarkBar-8","ns_per_op":50}]}}`
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "HEAD"}, []byte(stored), nil)
+
+	oldPackages, oldFilter, oldCount := benchPackages, benchFilter, benchCount
+	defer func() {
+		benchPackages, benchFilter, benchCount = oldPackages, oldFilter, oldCount
+	}()
+	benchPackages = []string{"./pkg"}
+	benchFilter = "Foo"
+	benchCount = 1
+
+	assert.Nil(t, runBenchSaveWithRunner(mock, true))
+
+	var note string
+	for _, c := range mock.Calls() {
+		if c.IsCmd("git", "notes", "--ref=benchmarks", "add") {
+			note = c.Args[5]
+		}
+	}
+	assert.Contains(t, note, `"name":"BenchmarkBar-8"`)
+	assert.Contains(t, note, `"ns_per_op":900`)
+	assert.NotContains(t, note, `"ns_per_op":1000`)
+}
+
+func TestRunBenchHistoryWithRunner(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"rev-list", "--reverse", "--max-count=50", "v1.0.0..HEAD"}, []byte("aaa\nbbb\n"), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "list"}, []byte("n1 aaa\nn2 bbb\n"), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "aaa"}, []byte(`{"packages":{"pkg":[{"name":"BenchmarkFoo-8","ns_per_op":100}]}}`), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "bbb"}, []byte(`{"packages":{"pkg":[{"name":"BenchmarkFoo-8","ns_per_op":120}]}}`), nil)
+
+	oldFormat, oldUnit, oldMax, oldThreshold, oldJSON := historyFormat, historyUnit, historyMax, historyThreshold, jsonOutput
+	defer func() {
+		historyFormat, historyUnit, historyMax, historyThreshold, jsonOutput = oldFormat, oldUnit, oldMax, oldThreshold, oldJSON
+	}()
+	historyFormat, historyUnit, historyMax, historyThreshold = "csv", "ns/op", 50, 10
+
+	output := captureStdout(t, func() error { return runBenchHistoryWithRunner(mock, "v1.0.0..HEAD") })
+	assert.Contains(t, output, "aaa,pkg,BenchmarkFoo,ns/op,100,1,")
+	assert.Contains(t, output, "bbb,pkg,BenchmarkFoo,ns/op,120,1,")
+
+	jsonOutput = true
+	output = captureStdout(t, func() error { return runBenchHistoryWithRunner(mock, "v1.0.0..HEAD") })
+	assert.Contains(t, output, `"commits"`)
+	assert.Contains(t, output, `"benchmark": "BenchmarkFoo"`)
+
+	jsonOutput = false
+	historyFormat = "xml"
+	assert.NotNil(t, runBenchHistoryWithRunner(mock, "HEAD"))
+}
+
+func TestRunBenchBisectWithRunner(t *testing.T) {
+	note := func(ns float64) []byte {
+		var results []string
+		for i := range 10 {
+			results = append(results, fmt.Sprintf(`{"name":"BenchmarkFoo-8","ns_per_op":%.1f}`, ns+float64(i)/10))
+		}
+		return []byte(`{"packages":{"pkg":[` + strings.Join(results, ",") + `]}}`)
+	}
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"rev-parse", "--verify", "v1^{commit}"}, []byte("aaa\n"), nil)
+	mock.SetResponse("git", []string{"rev-parse", "--verify", "HEAD^{commit}"}, []byte("ccc\n"), nil)
+	mock.SetResponse("git", []string{"rev-list", "--reverse", "--ancestry-path", "aaa..ccc"}, []byte("bbb\nccc\n"), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "aaa"}, note(100), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "bbb"}, note(150), nil)
+	mock.SetResponse("git", []string{"notes", "--ref=benchmarks", "show", "ccc"}, note(150), nil)
+
+	oldCount, oldUnit, oldJSON := bisectCount, bisectUnit, jsonOutput
+	defer func() { bisectCount, bisectUnit, jsonOutput = oldCount, oldUnit, oldJSON }()
+	bisectCount, bisectUnit, jsonOutput = 10, "ns/op", false
+
+	output := captureStdout(t, func() error { return runBenchBisectWithRunner(mock, []string{"v1", "HEAD", "Foo"}) })
+	assert.Contains(t, output, "==> Bisecting Foo between v1 and HEAD")
+	assert.Contains(t, output, "bbb  bad")
+	assert.Contains(t, output, "(cached)")
+	assert.Contains(t, output, "Foo changed ns/op +49.8% (100.5 ns → 150.4 ns) at bbb")
+
+	jsonOutput = true
+	output = captureStdout(t, func() error { return runBenchBisectWithRunner(mock, []string{"v1", "HEAD", "Foo"}) })
+	assert.Contains(t, output, `"first_bad": "bbb"`)
+	assert.NotContains(t, output, "==> Bisecting")
+}
+
+func TestRunBenchSyncWithRunner(t *testing.T) {
+	mock := runner.NewMock()
+	oldJSON := jsonOutput
+	defer func() { jsonOutput = oldJSON }()
+	jsonOutput = false
+
+	output := captureStdout(t, func() error { return runBenchSyncWithRunner(mock, nil, true) })
+	assert.Contains(t, output, "==> Benchmark results pushed to origin")
+	output = captureStdout(t, func() error { return runBenchSyncWithRunner(mock, []string{"upstream"}, false) })
+	assert.Contains(t, output, "==> Benchmark results pulled from upstream")
+
+	var pushed, merged bool
+	for _, c := range mock.Calls() {
+		if c.IsCmd("git", "push", "--quiet", "origin", "refs/notes/benchmarks:refs/notes/benchmarks") {
+			pushed = true
+		}
+		if c.IsCmd("git", "notes", "--ref=benchmarks", "merge") && c.HasArg("refs/notes/remotes/upstream/benchmarks") {
+			merged = true
+		}
+	}
+	assert.True(t, pushed)
+	assert.True(t, merged)
+
+	mock.SetResponse("git", []string{"rev-parse", "--verify", "--quiet", "refs/notes/benchmarks"}, nil, fmt.Errorf("exit status 1"))
+	assert.ErrorContains(t, runBenchSyncWithRunner(mock, nil, true), "no benchmark notes to push")
+}
+
+func TestFetchBenchNotes(t *testing.T) {
+	fetched := func(mock *runner.Mock) bool {
+		for _, c := range mock.Calls() {
+			if c.IsCmd("git", "fetch") {
+				return true
+			}
+		}
+		return false
+	}
+	oldOffline := offline
+	defer func() { offline = oldOffline }()
+	offline = false
+	setUserGOPROXY(t, "")
+
+	mock := runner.NewMock()
+	fetchBenchNotes(mock, config.Bench{Remote: "ci"}, true)
+	assert.True(t, fetched(mock))
+
+	// Skipped without the remote, with bench.no_fetch and offline
+	mock = runner.NewMock()
+	mock.SetResponse("git", []string{"remote", "get-url", "origin"}, nil, fmt.Errorf("exit status 2"))
+	fetchBenchNotes(mock, config.Bench{}, true)
+	assert.False(t, fetched(mock))
+
+	mock = runner.NewMock()
+	fetchBenchNotes(mock, config.Bench{NoFetch: true}, true)
+	assert.False(t, fetched(mock))
+
+	offline = true
+	mock = runner.NewMock()
+	fetchBenchNotes(mock, config.Bench{}, true)
+	assert.False(t, fetched(mock))
+}
@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+)
+
+// matrixCacheSubdir holds cached matrix artifacts, next to deps.db.
+const matrixCacheSubdir = "matrix"
+
+// matrixCacheMaxBytes caps the matrix cache. Past it, the least recently
+// used artifacts are evicted after each matrix run.
+const matrixCacheMaxBytes = 2 << 30
+
+// buildCacheEnv lists host environment variables that change go build output
+// beyond GOOS, GOARCH and CGO_ENABLED, which every job sets explicitly.
+var buildCacheEnv = []string{
+	"GOFLAGS", "GOEXPERIMENT", "GOTOOLCHAIN",
+	"GOAMD64", "GOARM", "GOARM64", "GO386", "GOMIPS", "GOMIPS64", "GOPPC64", "GORISCV64", "GOWASM",
+	"CC", "CXX", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS",
+}
+
+// buildCache is a content-addressed store of matrix build outputs. A job's key
+// covers the module's sources (including go.mod and go.sum) and those of its
+// local replacements and workspace modules, the Go version, the build command
+// and its environment, so an unchanged job can be served by copying the
+// previous artifact instead of running go build. cgo jobs are never cached:
+// their output also depends on the C toolchain and sysroot. prune keeps the
+// cache under a size cap.
+type buildCache struct {
+	dir       string
+	sources   string // hash of the module's source files
+	goVersion string
+}
+
+// openBuildCache hashes the module rooted at the working directory, skipping
+// skipDir (the build output directory), along with every local module it
+// builds against.
+func openBuildCache(goVersion, skipDir string) (*buildCache, error) {
+	root, err := toolCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, matrixCacheSubdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	sources, err := hashBuildSources(".", skipDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash module sources: %w", err)
+	}
+	return &buildCache{dir: dir, sources: sources, goVersion: goVersion}, nil
+}
+
+// hashBuildSources hashes the module at root together with the local
+// directories it is built against: replace targets in go.mod, and the
+// go.work file with its modules and replace targets.
+func hashBuildSources(root, skipDir string) (string, error) {
+	h := sha256.New()
+	sum, err := hashModuleSources(root, skipDir)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "module %s\n", sum)
+
+	dirs, work, err := localModuleDirs(root)
+	if err != nil {
+		return "", err
+	}
+	if work != "" {
+		sum, err := fileHash(work)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "go.work %s\x00%s\n", filepath.ToSlash(work), sum)
+	}
+	for _, dir := range dirs {
+		sum, err := hashModuleSources(dir, skipDir)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "local %s\x00%s\n", filepath.ToSlash(dir), sum)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// localModuleDirs returns the absolute directories, other than root, that
+// the module at root is built from: its go.mod's directory replacements and,
+// in a workspace, the go.work modules and replacements. work is the go.work
+// file in effect, or "".
+func localModuleDirs(root string) (dirs []string, work string, err error) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, "", err
+	}
+	seen := map[string]bool{rootAbs: true}
+	add := func(base, path string) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		if !seen[path] {
+			seen[path] = true
+			dirs = append(dirs, path)
+		}
+	}
+	addReplaces := func(base string, replaces []*modfile.Replace) {
+		for _, r := range replaces {
+			if r.New.Version == "" && modfile.IsDirectoryPath(r.New.Path) {
+				add(base, r.New.Path)
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(rootAbs, "go.mod")); err == nil {
+		f, err := modfile.Parse("go.mod", data, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		addReplaces(rootAbs, f.Replace)
+	}
+
+	work = findGoWork(rootAbs)
+	if work == "" {
+		return dirs, "", nil
+	}
+	data, err := os.ReadFile(work)
+	if err != nil {
+		return nil, "", err
+	}
+	wf, err := modfile.ParseWork(work, data, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	base := filepath.Dir(work)
+	for _, u := range wf.Use {
+		add(base, u.Path)
+	}
+	addReplaces(base, wf.Replace)
+	return dirs, work, nil
+}
+
+// findGoWork returns the go.work file the go command uses for dir: $GOWORK,
+// or the nearest go.work in dir or its parents. Returns "" outside a
+// workspace.
+func findGoWork(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+	for {
+		path := filepath.Join(dir, "go.work")
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// hashModuleSources hashes every file under root that can affect a build:
+// hidden and _-prefixed directories (as ignored by go build), nested modules,
+// _test.go files and skipDir are left out.
+func hashModuleSources(root, skipDir string) (string, error) {
+	skipAbs, _ := filepath.Abs(skipDir)
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if abs, _ := filepath.Abs(path); abs == skipAbs {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && !strings.HasSuffix(path, "_test.go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		sum, err := fileHash(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(path), sum)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// key returns the cache key for job.
+func (c *buildCache) key(job buildJob) string {
+	cmd := job.command()
+	h := sha256.New()
+	fmt.Fprintf(h, "sources %s\ngo %s\n", c.sources, c.goVersion)
+	// Normalize the output path so the key survives a different --output dir
+	args := append([]string(nil), cmd.Args...)
+	for i := range args {
+		if args[i] == job.outputPath {
+			args[i] = filepath.Base(job.outputPath)
+		}
+	}
+	fmt.Fprintf(h, "args %q\n", args)
+
+	env := make(map[string]string, len(cmd.Env)+len(buildCacheEnv))
+	for _, k := range buildCacheEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env[k] = v
+		}
+	}
+	for k, v := range cmd.Env {
+		env[k] = v
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "env %s=%q\n", k, env[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (c *buildCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// restore copies the cached artifact for job to its output path. Returns
+// false if there is no cached artifact.
+func (c *buildCache) restore(job buildJob) (bool, error) {
+	if job.target.CGO {
+		return false, nil
+	}
+	cached := c.path(c.key(job))
+	if _, err := os.Stat(cached); err != nil {
+		return false, nil
+	}
+	os.Remove(job.outputPath)
+	if err := copyFile(cached, job.outputPath); err != nil {
+		return false, fmt.Errorf("failed to restore %s from build cache: %w", job.outputPath, err)
+	}
+	// The modification time records the last use, for prune
+	now := time.Now()
+	os.Chtimes(cached, now, now)
+	return true, nil
+}
+
+// store saves job's freshly built artifact. The copy is written to a
+// temporary file and renamed so concurrent runs never see a partial entry.
+func (c *buildCache) store(job buildJob) error {
+	if job.target.CGO {
+		return nil
+	}
+	dst := c.path(c.key(job))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	src, err := os.Open(job.outputPath)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	defer src.Close()
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// prune evicts the least recently used artifacts (by modification time,
+// which store sets and restore refreshes) until the cache fits in limit
+// bytes.
+func (c *buildCache) prune(limit int64) error {
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var entries []entry
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed by a concurrent run
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+	for _, e := range entries {
+		if total <= limit {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= e.size
+	}
+	return nil
+}
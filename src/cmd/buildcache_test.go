@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestHashModuleSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "go.sum"), []byte("sum"), 0644)
+
+	base, err := hashModuleSources(tmpDir, filepath.Join(tmpDir, "build"))
+	require.NoError(t, err)
+
+	// Test files, hidden dirs, nested modules and the output dir don't count
+	os.WriteFile(filepath.Join(tmpDir, "main_test.go"), []byte("package main"), 0644)
+	os.MkdirAll(filepath.Join(tmpDir, ".git"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, ".git", "HEAD"), []byte("ref"), 0644)
+	os.MkdirAll(filepath.Join(tmpDir, "build"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "build", "app"), []byte("binary"), 0755)
+	os.MkdirAll(filepath.Join(tmpDir, "tools"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "tools", "go.mod"), []byte("module tools"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "tools", "tools.go"), []byte("package tools"), 0644)
+	same, err := hashModuleSources(tmpDir, filepath.Join(tmpDir, "build"))
+	require.NoError(t, err)
+	assert.Equal(t, base, same)
+
+	os.WriteFile(filepath.Join(tmpDir, "go.sum"), []byte("sum2"), 0644)
+	changed, err := hashModuleSources(tmpDir, filepath.Join(tmpDir, "build"))
+	require.NoError(t, err)
+	assert.NotEqual(t, base, changed)
+}
+
+func TestHashBuildSourcesLocalModules(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmpDir := t.TempDir()
+	app, lib, tool := filepath.Join(tmpDir, "app"), filepath.Join(tmpDir, "lib"), filepath.Join(tmpDir, "tool")
+	for _, dir := range []string{app, lib, tool} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "x.go"), []byte("package x"), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(app, "go.mod"), []byte("module example.com/app\n\nrequire example.com/lib v1.0.0\n\nreplace example.com/lib => ../lib\n"), 0644))
+
+	dirs, work, err := localModuleDirs(app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{lib}, dirs)
+	assert.Empty(t, work)
+
+	base, err := hashBuildSources(app, filepath.Join(app, "build"))
+	require.NoError(t, err)
+
+	// Editing the replaced directory changes the key
+	require.NoError(t, os.WriteFile(filepath.Join(lib, "x.go"), []byte("package x // edited"), 0644))
+	edited, err := hashBuildSources(app, filepath.Join(app, "build"))
+	require.NoError(t, err)
+	assert.NotEqual(t, base, edited)
+
+	// So do workspace modules, and GOWORK=off leaves them out
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "go.work"), []byte("go 1.24\n\nuse (\n\t./app\n\t./tool\n)\n"), 0644))
+	dirs, work, err = localModuleDirs(app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{lib, tool}, dirs)
+	assert.Equal(t, filepath.Join(tmpDir, "go.work"), work)
+	inWork, err := hashBuildSources(app, filepath.Join(app, "build"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tool, "x.go"), []byte("package x // edited"), 0644))
+	toolEdited, err := hashBuildSources(app, filepath.Join(app, "build"))
+	require.NoError(t, err)
+	assert.NotEqual(t, inWork, toolEdited)
+
+	t.Setenv("GOWORK", "off")
+	offWork, err := hashBuildSources(app, filepath.Join(app, "build"))
+	require.NoError(t, err)
+	assert.Equal(t, edited, offWork)
+}
+
+func TestBuildCacheMissAfterReplacedDirEdit(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("GOWORK", "off")
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+	lib := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.MkdirAll(lib, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n"), 0644))
+	require.NoError(t, os.WriteFile("go.mod", []byte("module example.com/app\n\nrequire example.com/lib v1.0.0\n\nreplace example.com/lib => "+lib+"\n"), 0644))
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	job := buildJob{platform: build.Platform{GOOS: "linux", GOARCH: "amd64"}, target: build.Target{ImportPath: "example.com/app"}, outputPath: filepath.Join(tmpDir, "dist", "app")}
+	require.NoError(t, os.MkdirAll(filepath.Dir(job.outputPath), 0755))
+	require.NoError(t, os.WriteFile(job.outputPath, []byte("binary"), 0755))
+
+	c, err := openBuildCache("go1.24.0", "dist")
+	require.NoError(t, err)
+	require.NoError(t, c.store(job))
+	c, err = openBuildCache("go1.24.0", "dist")
+	require.NoError(t, err)
+	hit, err := c.restore(job)
+	require.NoError(t, err)
+	assert.True(t, hit)
+
+	require.NoError(t, os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n\nconst V = 2\n"), 0644))
+	c, err = openBuildCache("go1.24.0", "dist")
+	require.NoError(t, err)
+	hit, err = c.restore(job)
+	require.NoError(t, err)
+	assert.False(t, hit)
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	c := &buildCache{sources: "abc", goVersion: "go1.24.0"}
+	job := buildJob{platform: build.Platform{GOOS: "linux", GOARCH: "amd64"}, target: build.Target{ImportPath: "example.com/app"}, outputPath: "build/app_linux_amd64", ldflags: "-X main.version=v1"}
+
+	key := c.key(job)
+	moved := job
+	moved.outputPath = "dist/app_linux_amd64"
+	assert.Equal(t, key, c.key(moved))
+
+	other := job
+	other.platform.GOARCH = "arm64"
+	assert.NotEqual(t, key, c.key(other))
+	other = job
+	other.ldflags = "-X main.version=v2"
+	assert.NotEqual(t, key, c.key(other))
+	assert.NotEqual(t, key, (&buildCache{sources: "abc", goVersion: "go1.25.0"}).key(job))
+	assert.NotEqual(t, key, (&buildCache{sources: "abd", goVersion: "go1.24.0"}).key(job))
+
+	t.Setenv("GOAMD64", "v3")
+	assert.NotEqual(t, key, c.key(job))
+}
+
+func TestBuildCacheStoreRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	c := &buildCache{dir: filepath.Join(tmpDir, "cache"), sources: "abc", goVersion: "go1.24.0"}
+	job := buildJob{platform: build.Platform{GOOS: "linux", GOARCH: "amd64"}, target: build.Target{ImportPath: "."}, outputPath: filepath.Join(tmpDir, "app")}
+
+	hit, err := c.restore(job)
+	require.NoError(t, err)
+	assert.False(t, hit)
+
+	require.NoError(t, os.WriteFile(job.outputPath, []byte("binary"), 0755))
+	require.NoError(t, c.store(job))
+	os.Remove(job.outputPath)
+
+	hit, err = c.restore(job)
+	require.NoError(t, err)
+	assert.True(t, hit)
+	data, _ := os.ReadFile(job.outputPath)
+	assert.Equal(t, "binary", string(data))
+	st, _ := os.Stat(job.outputPath)
+	assert.Equal(t, os.FileMode(0755), st.Mode().Perm())
+
+	// cgo builds also depend on the C toolchain, so they're never cached
+	job.target.CGO = true
+	require.NoError(t, c.store(job))
+	hit, err = c.restore(job)
+	require.NoError(t, err)
+	assert.False(t, hit)
+}
+
+func TestBuildCachePrune(t *testing.T) {
+	c := &buildCache{dir: t.TempDir()}
+	base := time.Now().Add(-time.Hour)
+	for i, key := range []string{"aa01", "bb02", "cc03"} {
+		path := c.path(key)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, make([]byte, 10), 0755))
+		used := base.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(path, used, used))
+	}
+	// Restoring refreshes an entry, so the oldest one is used again
+	now := time.Now()
+	require.NoError(t, os.Chtimes(c.path("aa01"), now, now))
+
+	require.NoError(t, c.prune(30))
+	assert.FileExists(t, c.path("bb02"))
+
+	require.NoError(t, c.prune(20))
+	assert.NoFileExists(t, c.path("bb02"))
+	assert.FileExists(t, c.path("aa01"))
+	assert.FileExists(t, c.path("cc03"))
+
+	require.NoError(t, c.prune(0))
+	assert.NoFileExists(t, c.path("aa01"))
+	assert.NoFileExists(t, c.path("cc03"))
+}
+
+func TestBuildCacheRestoreMarksUse(t *testing.T) {
+	tmpDir := t.TempDir()
+	c := &buildCache{dir: filepath.Join(tmpDir, "cache"), sources: "abc", goVersion: "go1.24.0"}
+	job := buildJob{platform: build.Platform{GOOS: "linux", GOARCH: "amd64"}, target: build.Target{ImportPath: "."}, outputPath: filepath.Join(tmpDir, "app")}
+	require.NoError(t, os.WriteFile(job.outputPath, []byte("binary"), 0755))
+	require.NoError(t, c.store(job))
+
+	cached := c.path(c.key(job))
+	old := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(cached, old, old))
+	hit, err := c.restore(job)
+	require.NoError(t, err)
+	require.True(t, hit)
+	st, err := os.Stat(cached)
+	require.NoError(t, err)
+	assert.True(t, st.ModTime().After(old.Add(time.Hour)))
+}
+
+func countGoBuilds(mock *runner.Mock) int {
+	n := 0
+	for _, cfg := range mock.Calls() {
+		if cfg.IsCmd("go", "build") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRunReleaseWithRunnerIncremental(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	os.WriteFile("main.go", []byte("package main\nfunc main() {}\n"), 0644)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	oldOutput := outputDir
+	oldNoCache := matrixNoCache
+	matrixOS = []string{"linux", "darwin"}
+	matrixArch = []string{"amd64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+		outputDir = oldOutput
+		matrixNoCache = oldNoCache
+	}()
+
+	mock := newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+	assert.Equal(t, 2, countGoBuilds(mock))
+
+	// Nothing changed: every artifact comes from the cache
+	os.RemoveAll(outputDir)
+	mock = newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+	assert.Equal(t, 0, countGoBuilds(mock))
+	_, err := os.Stat(filepath.Join(outputDir, "example.com_linux_amd64"))
+	assert.NoError(t, err)
+
+	// A source change invalidates every job
+	os.WriteFile("main.go", []byte("package main\nfunc main() { println() }\n"), 0644)
+	mock = newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+	assert.Equal(t, 2, countGoBuilds(mock))
+
+	matrixNoCache = true
+	mock = newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+	assert.Equal(t, 2, countGoBuilds(mock))
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wow-look-at-my/go-toolchain/src/size"
+)
+
+var offline bool
+
+// errOffline reports a network call skipped in offline mode.
+var errOffline = errors.New("skipped in offline mode")
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect, clear or export the go-toolchain cache",
+	Long: `go-toolchain caches dependency update checks, resolved v0.0.0 requirements and
+the latest go-toolchain commit in deps.db, and matrix build outputs in matrix/,
+under the user cache directory (~/.cache/go-toolchain).
+
+With --offline (or GOPROXY=off), nothing is fetched: dependency checks and
+v0.0.0 resolution are served from deps.db or skipped, and go commands run
+with GOPROXY=off unless GOPROXY is a file:// mirror. Export the cache from a
+connected host and import it on air-gapped ones to seed it.`,
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Never touch the network; serve dependency and update checks from the cache or skip them")
+	cobra.OnInitialize(applyOffline)
+
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:          "stats",
+		Short:        "Show what the cache holds",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCacheStats()
+		},
+	}, &cobra.Command{
+		Use:          "clear [deps|matrix]...",
+		Short:        "Delete cached entries (everything by default)",
+		ValidArgs:    []string{"deps", "matrix"},
+		Args:         cobra.OnlyValidArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCacheClear(args)
+		},
+	}, &cobra.Command{
+		Use:          "export [file]",
+		Short:        "Write deps.db entries as JSON (to stdout by default)",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCacheExport(args)
+		},
+	}, &cobra.Command{
+		Use:          "import <file>",
+		Short:        "Merge entries written by cache export into deps.db",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCacheImport(args[0])
+		},
+	})
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// userGOPROXY is GOPROXY as the user set it, saved by UseDirectModules
+// before it points the go command at module origins.
+var userGOPROXY string
+
+// UseDirectModules turns off Go's phone-home behavior: the module proxy and
+// checksum database are bypassed. GOPROXY=off and file:// mirrors already
+// stay off the network, so they're kept. main calls it from init, before
+// any command runs.
+func UseDirectModules() {
+	userGOPROXY = os.Getenv("GOPROXY")
+	if userGOPROXY != "off" && !strings.HasPrefix(userGOPROXY, "file://") {
+		os.Setenv("GOPROXY", "direct")
+	}
+	os.Setenv("GOSUMDB", "off")
+	os.Setenv("GONOSUMCHECK", "*")
+}
+
+// isOffline reports whether network access is disabled, by --offline or
+// GOPROXY=off.
+func isOffline() bool {
+	return offline || userGOPROXY == "off"
+}
+
+// applyOffline points the go command away from the network in offline
+// mode, keeping file:// module mirrors.
+func applyOffline() {
+	if !offline {
+		return
+	}
+	if !strings.HasPrefix(userGOPROXY, "file://") {
+		os.Setenv("GOPROXY", "off")
+	}
+	os.Setenv("GOSUMDB", "off")
+}
+
+// cacheExport is the deps.db contents, as written by cache export.
+type cacheExport struct {
+	Deps     []cachedDep        `json:"deps"`
+	Resolved []cachedResolution `json:"resolved"`
+	Commits  []cachedCommit     `json:"commits"`
+}
+
+type cachedDep struct {
+	Path      string `json:"path"`
+	Version   string `json:"version"`
+	Update    string `json:"update,omitempty"` // empty if up to date
+	CheckedAt int64  `json:"checked_at"`
+}
+
+type cachedResolution struct {
+	Path       string `json:"path"`
+	Version    string `json:"version"`
+	ResolvedAt int64  `json:"resolved_at"`
+}
+
+type cachedCommit struct {
+	Repo      string `json:"repo"`
+	SHA       string `json:"sha"`
+	Timestamp int64  `json:"timestamp"`
+	CheckedAt int64  `json:"checked_at"`
+}
+
+// readCache loads every deps.db entry.
+func readCache(db *sql.DB) (*cacheExport, error) {
+	export := &cacheExport{Deps: []cachedDep{}, Resolved: []cachedResolution{}, Commits: []cachedCommit{}}
+
+	rows, err := db.Query(`SELECT path, version, update_version, checked_at FROM deps ORDER BY path, version`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d cachedDep
+		var update sql.NullString
+		if err := rows.Scan(&d.Path, &d.Version, &update, &d.CheckedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Update = update.String
+		export.Deps = append(export.Deps, d)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT path, version, resolved_at FROM resolved ORDER BY path`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var r cachedResolution
+		if err := rows.Scan(&r.Path, &r.Version, &r.ResolvedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.Resolved = append(export.Resolved, r)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT repo, sha, timestamp, checked_at FROM commits ORDER BY repo`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c cachedCommit
+		if err := rows.Scan(&c.Repo, &c.SHA, &c.Timestamp, &c.CheckedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.Commits = append(export.Commits, c)
+	}
+	rows.Close()
+	return export, rows.Err()
+}
+
+// cacheStats summarizes the cache directory.
+type cacheStats struct {
+	Dir           string `json:"dir"`
+	DBBytes       int64  `json:"db_bytes"`
+	Deps          int    `json:"deps"`
+	Outdated      int    `json:"outdated"`
+	Resolved      int    `json:"resolved"`
+	Commits       int    `json:"commits"`
+	MatrixBytes   int64  `json:"matrix_bytes"`
+	MatrixEntries int    `json:"matrix_entries"`
+}
+
+func runCacheStats() error {
+	dir, err := toolCacheDir()
+	if err != nil {
+		return err
+	}
+	stats := cacheStats{Dir: dir}
+
+	db, err := openCacheDB()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", cacheFile, err)
+	}
+	entries, err := readCache(db)
+	db.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", cacheFile, err)
+	}
+	stats.Deps = len(entries.Deps)
+	for _, d := range entries.Deps {
+		if d.Update != "" {
+			stats.Outdated++
+		}
+	}
+	stats.Resolved = len(entries.Resolved)
+	stats.Commits = len(entries.Commits)
+	if st, err := os.Stat(filepath.Join(dir, cacheFile)); err == nil {
+		stats.DBBytes = st.Size()
+	}
+
+	filepath.WalkDir(filepath.Join(dir, matrixCacheSubdir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stats.MatrixBytes += info.Size()
+			stats.MatrixEntries++
+		}
+		return nil
+	})
+
+	if jsonOutput {
+		return printJSON(stats)
+	}
+	fmt.Printf("==> Cache: %s\n", stats.Dir)
+	fmt.Printf("  %-8s %10s  %d dependency checks (%d outdated), %d resolved versions, %d commit checks\n",
+		cacheFile, size.FormatBytes(stats.DBBytes), stats.Deps, stats.Outdated, stats.Resolved, stats.Commits)
+	fmt.Printf("  %-8s %10s  %d build artifacts\n", matrixCacheSubdir+"/", size.FormatBytes(stats.MatrixBytes), stats.MatrixEntries)
+	return nil
+}
+
+func runCacheClear(what []string) error {
+	if len(what) == 0 {
+		what = []string{"deps", "matrix"}
+	}
+	dir, err := toolCacheDir()
+	if err != nil {
+		return err
+	}
+	for _, w := range what {
+		switch w {
+		case "deps":
+			db, err := openCacheDB()
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", cacheFile, err)
+			}
+			_, err = db.Exec(`DELETE FROM deps; DELETE FROM resolved; DELETE FROM commits; VACUUM`)
+			db.Close()
+			if err != nil {
+				return fmt.Errorf("failed to clear %s: %w", cacheFile, err)
+			}
+			fmt.Printf("==> Cleared %s\n", filepath.Join(dir, cacheFile))
+		case "matrix":
+			if err := os.RemoveAll(filepath.Join(dir, matrixCacheSubdir)); err != nil {
+				return fmt.Errorf("failed to clear build cache: %w", err)
+			}
+			fmt.Printf("==> Cleared %s\n", filepath.Join(dir, matrixCacheSubdir))
+		}
+	}
+	return nil
+}
+
+func runCacheExport(args []string) error {
+	db, err := openCacheDB()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", cacheFile, err)
+	}
+	defer db.Close()
+	entries, err := readCache(db)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", cacheFile, err)
+	}
+
+	var w io.Writer = os.Stdout
+	if len(args) == 1 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(entries)
+}
+
+// runCacheImport merges an export into deps.db. Entries already in the
+// cache are only replaced by newer ones.
+func runCacheImport(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	var entries cacheExport
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	db, err := openCacheDB()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", cacheFile, err)
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range entries.Deps {
+		var update sql.NullString
+		if d.Update != "" {
+			update = sql.NullString{String: d.Update, Valid: true}
+		}
+		if _, err := tx.Exec(`INSERT INTO deps (path, version, update_version, checked_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (path, version) DO UPDATE SET update_version = excluded.update_version, checked_at = excluded.checked_at
+			WHERE excluded.checked_at > deps.checked_at`, d.Path, d.Version, update, d.CheckedAt); err != nil {
+			return err
+		}
+	}
+	for _, r := range entries.Resolved {
+		if _, err := tx.Exec(`INSERT INTO resolved (path, version, resolved_at) VALUES (?, ?, ?)
+			ON CONFLICT (path) DO UPDATE SET version = excluded.version, resolved_at = excluded.resolved_at
+			WHERE excluded.resolved_at > resolved.resolved_at`, r.Path, r.Version, r.ResolvedAt); err != nil {
+			return err
+		}
+	}
+	for _, c := range entries.Commits {
+		if _, err := tx.Exec(`INSERT INTO commits (repo, sha, timestamp, checked_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (repo) DO UPDATE SET sha = excluded.sha, timestamp = excluded.timestamp, checked_at = excluded.checked_at
+			WHERE excluded.checked_at > commits.checked_at`, c.Repo, c.SHA, c.Timestamp, c.CheckedAt); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("==> Imported %d dependency checks, %d resolved versions, %d commit checks\n", len(entries.Deps), len(entries.Resolved), len(entries.Commits))
+	return nil
+}
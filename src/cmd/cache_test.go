@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// setOffline enables offline mode for the rest of the test, with a fresh
+// cache directory.
+func setOffline(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	old := offline
+	offline = true
+	t.Cleanup(func() { offline = old })
+}
+
+// setUserGOPROXY sets GOPROXY as UseDirectModules found it for the rest of
+// the test.
+func setUserGOPROXY(t *testing.T, proxy string) {
+	old := userGOPROXY
+	userGOPROXY = proxy
+	t.Cleanup(func() { userGOPROXY = old })
+}
+
+func TestIsOffline(t *testing.T) {
+	setUserGOPROXY(t, "")
+	old := offline
+	defer func() { offline = old }()
+
+	offline = false
+	assert.False(t, isOffline())
+	offline = true
+	assert.True(t, isOffline())
+	offline = false
+	setUserGOPROXY(t, "off")
+	assert.True(t, isOffline())
+}
+
+func TestApplyOffline(t *testing.T) {
+	old := offline
+	defer func() { offline = old }()
+
+	setUserGOPROXY(t, "https://proxy.golang.org,direct")
+	t.Setenv("GOPROXY", "direct")
+	t.Setenv("GOSUMDB", "")
+	offline = false
+	applyOffline()
+	assert.Equal(t, "direct", os.Getenv("GOPROXY"))
+
+	offline = true
+	applyOffline()
+	assert.Equal(t, "off", os.Getenv("GOPROXY"))
+	assert.Equal(t, "off", os.Getenv("GOSUMDB"))
+
+	setUserGOPROXY(t, "file:///srv/goproxy")
+	t.Setenv("GOPROXY", "file:///srv/goproxy")
+	applyOffline()
+	assert.Equal(t, "file:///srv/goproxy", os.Getenv("GOPROXY"))
+}
+
+func TestUseDirectModules(t *testing.T) {
+	old := offline
+	defer func() { offline = old }()
+	offline = false
+	setUserGOPROXY(t, "")
+	t.Setenv("GOSUMDB", "")
+	t.Setenv("GONOSUMCHECK", "")
+
+	for proxy, want := range map[string]string{
+		"":                                "direct",
+		"https://proxy.golang.org,direct": "direct",
+		"off":                             "off",
+		"file:///srv/goproxy":             "file:///srv/goproxy",
+	} {
+		t.Setenv("GOPROXY", proxy)
+		UseDirectModules()
+		assert.Equal(t, want, os.Getenv("GOPROXY"), proxy)
+		assert.Equal(t, proxy, userGOPROXY)
+		assert.Equal(t, proxy == "off", isOffline(), proxy)
+	}
+	assert.Equal(t, "off", os.Getenv("GOSUMDB"))
+	assert.Equal(t, "*", os.Getenv("GONOSUMCHECK"))
+}
+
+func TestCheckDepOffline(t *testing.T) {
+	setOffline(t)
+	db, err := openCacheDB()
+	require.NoError(t, err)
+	defer db.Close()
+	dc := &DepChecker{db: db}
+
+	// Miss: skipped rather than checked live
+	_, _, err = dc.checkDep("example.com/unknown", "v1.0.0")
+	assert.True(t, errors.Is(err, errOffline))
+
+	// Expired up-to-date entries are still served
+	_, err = db.Exec(`INSERT INTO deps (path, version, update_version, checked_at) VALUES (?, ?, ?, ?)`, "example.com/old", "v1.0.0", nil, 0)
+	require.NoError(t, err)
+	update, needsUpdate, err := dc.checkDep("example.com/old", "v1.0.0")
+	require.NoError(t, err)
+	assert.False(t, needsUpdate)
+	assert.Equal(t, "", update)
+}
+
+func TestWaitForOutdatedDepsOffline(t *testing.T) {
+	setOffline(t)
+	dc := &DepChecker{
+		doneCh:  make(chan struct{}),
+		done:    true,
+		results: []OutdatedDep{{Path: "example.com/lib", Version: "v1.0.0", Update: "v1.1.0"}},
+	}
+	close(dc.doneCh)
+	mock := runner.NewMock()
+	assert.False(t, WaitForOutdatedDeps(mock, dc))
+	assert.Equal(t, 0, len(mock.Calls()))
+
+	assert.True(t, errors.Is(runDepsUpdate(mock, nil, true), errOffline))
+	assert.True(t, errors.Is(runDepsOutdated(mock), errOffline))
+}
+
+func TestFixBogusDepsVersionsOffline(t *testing.T) {
+	setOffline(t)
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	gomod := "module test\n\ngo 1.21\n\nrequire git.internal/lib v0.0.0\n"
+	os.WriteFile("go.mod", []byte(gomod), 0644)
+	mock := runner.NewMock()
+
+	// Never resolved: left alone without touching the network
+	require.NoError(t, FixBogusDepsVersions(mock))
+	data, _ := os.ReadFile("go.mod")
+	assert.Equal(t, gomod, string(data))
+
+	db, err := openCacheDB()
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO resolved (path, version, resolved_at) VALUES (?, ?, ?)`, "git.internal/lib", "v0.0.0-20260101000000-abcdefabcdef", 1)
+	require.NoError(t, err)
+	db.Close()
+
+	require.NoError(t, FixBogusDepsVersions(mock))
+	data, _ = os.ReadFile("go.mod")
+	assert.Contains(t, string(data), "git.internal/lib v0.0.0-20260101000000-abcdefabcdef")
+	assert.Equal(t, 0, len(mock.Calls()))
+}
+
+func TestLatestCommitOffline(t *testing.T) {
+	setOffline(t)
+	_, err := latestCommit()
+	assert.True(t, errors.Is(err, errOffline))
+
+	db, err := openCacheDB()
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO commits (repo, sha, timestamp, checked_at) VALUES (?, ?, ?, ?)`, githubRepo, "abc123", 1700000000, 1700000100)
+	require.NoError(t, err)
+	db.Close()
+
+	c, err := latestCommit()
+	require.NoError(t, err)
+	assert.Equal(t, commitInfo{sha: "abc123", timestamp: 1700000000, checkedAt: 1700000100}, *c)
+}
+
+func TestCacheExportImportClear(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	db, err := openCacheDB()
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO deps (path, version, update_version, checked_at) VALUES ('example.com/a', 'v1.0.0', 'v1.1.0', 100), ('example.com/b', 'v2.0.0', NULL, 200);
+		INSERT INTO resolved (path, version, resolved_at) VALUES ('git.internal/lib', 'v0.0.0-20260101000000-abcdefabcdef', 300);
+		INSERT INTO commits (repo, sha, timestamp, checked_at) VALUES ('org/repo', 'abc', 1, 2)`)
+	require.NoError(t, err)
+	db.Close()
+
+	dir, err := toolCacheDir()
+	require.NoError(t, err)
+	matrixDir := filepath.Join(dir, matrixCacheSubdir, "ab")
+	require.NoError(t, os.MkdirAll(matrixDir, 0755))
+	os.WriteFile(filepath.Join(matrixDir, "abcdef"), []byte("binary"), 0755)
+
+	oldJSON := jsonOutput
+	jsonOutput = true
+	defer func() { jsonOutput = oldJSON }()
+	out := captureStdout(t, runCacheStats)
+	var stats cacheStats
+	require.NoError(t, json.Unmarshal([]byte(out), &stats))
+	assert.Equal(t, cacheStats{Dir: dir, DBBytes: stats.DBBytes, Deps: 2, Outdated: 1, Resolved: 1, Commits: 1, MatrixBytes: 6, MatrixEntries: 1}, stats)
+
+	export := filepath.Join(t.TempDir(), "cache.json")
+	require.NoError(t, runCacheExport([]string{export}))
+
+	require.NoError(t, runCacheClear(nil))
+	assert.NoDirExists(t, filepath.Join(dir, matrixCacheSubdir))
+	require.NoError(t, json.Unmarshal([]byte(captureStdout(t, runCacheStats)), &stats))
+	assert.Equal(t, 0, stats.Deps+stats.Resolved+stats.Commits)
+
+	// Importing restores everything; an older entry doesn't replace a newer one
+	db, err = openCacheDB()
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO deps (path, version, update_version, checked_at) VALUES ('example.com/b', 'v2.0.0', 'v2.1.0', 999)`)
+	require.NoError(t, err)
+	db.Close()
+	require.NoError(t, runCacheImport(export))
+
+	db, err = openCacheDB()
+	require.NoError(t, err)
+	defer db.Close()
+	entries, err := readCache(db)
+	require.NoError(t, err)
+	assert.Equal(t, []cachedDep{
+		{Path: "example.com/a", Version: "v1.0.0", Update: "v1.1.0", CheckedAt: 100},
+		{Path: "example.com/b", Version: "v2.0.0", Update: "v2.1.0", CheckedAt: 999},
+	}, entries.Deps)
+	assert.Equal(t, 1, len(entries.Resolved))
+	assert.Equal(t, []cachedCommit{{Repo: "org/repo", SHA: "abc", Timestamp: 1, CheckedAt: 2}}, entries.Commits)
+
+	assert.NotNil(t, runCacheImport(filepath.Join(t.TempDir(), "missing.json")))
+}
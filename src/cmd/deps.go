@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/update"
 	"golang.org/x/mod/modfile"
 	_ "modernc.org/sqlite"
 )
@@ -34,6 +35,7 @@ type OutdatedDep struct {
 	Path    string // module path
 	Version string // current version
 	Update  string // available update version
+	Note    string // why it wasn't updated automatically, if it matters
 }
 
 // DepChecker handles async dependency checking with caching
@@ -75,6 +77,15 @@ func (dc *DepChecker) run() {
 	dc.db = db
 	defer db.Close()
 
+	policy, err := loadUpdatePolicy()
+	if err != nil {
+		dc.mu.Lock()
+		dc.err = err
+		dc.done = true
+		dc.mu.Unlock()
+		return
+	}
+
 	// Get list of direct dependencies
 	deps, err := listDirectDeps()
 	if err != nil {
@@ -84,6 +95,7 @@ func (dc *DepChecker) run() {
 		dc.mu.Unlock()
 		return
 	}
+	deps = depsToCheck(policy, deps)
 
 	dc.mu.Lock()
 	dc.total = len(deps)
@@ -100,11 +112,6 @@ func (dc *DepChecker) run() {
 		dc.checked++
 		dc.mu.Unlock()
 
-		// Only check pseudo-versions
-		if !looksLikeGitVersion(dep.Version) {
-			continue
-		}
-
 		update, needsUpdate, err := dc.checkDep(dep.Path, dep.Version)
 		if err != nil {
 			continue // Skip on error, don't fail the whole check
@@ -125,6 +132,19 @@ func (dc *DepChecker) run() {
 	dc.mu.Unlock()
 }
 
+// depsToCheck drops the dependencies the policy pins, which are never
+// reported. Everything else is checked, tagged versions and pseudo-versions
+// alike.
+func depsToCheck(policy update.Policy, deps []depInfo) []depInfo {
+	var check []depInfo
+	for _, dep := range deps {
+		if policy.Rule(dep.Path).Level != update.Pinned {
+			check = append(check, dep)
+		}
+	}
+	return check
+}
+
 // checkDep checks if a dependency has an update, using cache when valid
 func (dc *DepChecker) checkDep(path, version string) (update string, needsUpdate bool, err error) {
 	now := time.Now().Unix()
@@ -143,11 +163,14 @@ func (dc *DepChecker) checkDep(path, version string) (update string, needsUpdate
 			// Cached as outdated - return immediately (no expiry for outdated)
 			return cachedUpdate.String, true, nil
 		}
-		// Cached as up-to-date - check if still fresh
-		if now-checkedAt < int64(upToDateCacheDuration.Seconds()) {
+		// Cached as up-to-date - check if still fresh (offline, any age will do)
+		if now-checkedAt < int64(upToDateCacheDuration.Seconds()) || isOffline() {
 			return "", false, nil
 		}
 	}
+	if isOffline() {
+		return "", false, errOffline
+	}
 
 	// Cache miss or expired - check live
 	update, needsUpdate, err = checkDepLive(path)
@@ -233,7 +256,9 @@ func listDirectDeps() ([]depInfo, error) {
 	return deps, nil
 }
 
-func openCacheDB() (*sql.DB, error) {
+// toolCacheDir returns ~/.cache/go-toolchain (or the platform equivalent),
+// creating it if needed.
+func toolCacheDir() (string, error) {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
 		cacheDir = filepath.Join(os.Getenv("HOME"), ".cache")
@@ -241,6 +266,14 @@ func openCacheDB() (*sql.DB, error) {
 
 	dir := filepath.Join(cacheDir, cacheSubdir)
 	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func openCacheDB() (*sql.DB, error) {
+	dir, err := toolCacheDir()
+	if err != nil {
 		return nil, err
 	}
 
@@ -250,7 +283,7 @@ func openCacheDB() (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Create table if not exists
+	// Create tables if not exists
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS deps (
 			path TEXT NOT NULL,
@@ -258,6 +291,17 @@ func openCacheDB() (*sql.DB, error) {
 			update_version TEXT,
 			checked_at INTEGER NOT NULL,
 			PRIMARY KEY (path, version)
+		);
+		CREATE TABLE IF NOT EXISTS resolved (
+			path TEXT PRIMARY KEY,
+			version TEXT NOT NULL,
+			resolved_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS commits (
+			repo TEXT PRIMARY KEY,
+			sha TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			checked_at INTEGER NOT NULL
 		)
 	`)
 	if err != nil {
@@ -268,27 +312,6 @@ func openCacheDB() (*sql.DB, error) {
 	return db, nil
 }
 
-// looksLikeGitVersion returns true if the version appears to be a pseudo-version
-func looksLikeGitVersion(version string) bool {
-	parts := strings.Split(version, "-")
-	if len(parts) < 3 {
-		return false
-	}
-
-	// Check if last part looks like a commit hash (12 hex chars)
-	lastPart := parts[len(parts)-1]
-	return len(lastPart) == 12 && isHex(lastPart)
-}
-
-func isHex(s string) bool {
-	for _, c := range s {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
-			return false
-		}
-	}
-	return true
-}
-
 // Progress returns current check progress (checked, total)
 func (dc *DepChecker) Progress() (checked, total int) {
 	dc.mu.Lock()
@@ -387,11 +410,15 @@ func PrintOutdatedDeps(deps []OutdatedDep) {
 	}
 
 	fmt.Println()
-	fmt.Println(warn("Outdated git dependencies:"))
+	fmt.Println(warn("Outdated dependencies:"))
 	for _, dep := range deps {
 		current := shortenVersion(dep.Version)
 		update := shortenVersion(dep.Update)
-		fmt.Printf("    %s: %s -> %s\n", dep.Path, current, update)
+		if dep.Note != "" {
+			fmt.Printf("    %s: %s -> %s (%s)\n", dep.Path, current, update, dep.Note)
+		} else {
+			fmt.Printf("    %s: %s -> %s\n", dep.Path, current, update)
+		}
 	}
 	fmt.Println("    Run 'go get -u' to update")
 }
@@ -409,78 +436,42 @@ func shortenVersion(v string) string {
 	return v
 }
 
-// WaitForOutdatedDeps waits for the dependency check to complete and prints results.
-// Dependencies from the same org/prefix as the current module are automatically updated.
-// Returns true if any dependencies were auto-updated (caller should rebuild).
-func WaitForOutdatedDeps(dc *DepChecker) bool {
+// WaitForOutdatedDeps waits for the dependency check to complete, applies
+// the update policy and prints the dependencies it left alone. Returns true
+// if any dependencies were updated (caller should rebuild).
+func WaitForOutdatedDeps(r runner.CommandRunner, dc *DepChecker) bool {
 	if dc == nil {
 		return false
 	}
 	deps := dc.WaitWithProgress()
-
-	// Get auto-update prefix from current module
-	autoUpdatePrefix := getAutoUpdatePrefix()
-
-	// Separate auto-update deps from manual deps
-	var toAutoUpdate, manual []OutdatedDep
-	for _, dep := range deps {
-		if autoUpdatePrefix != "" && strings.HasPrefix(dep.Path, autoUpdatePrefix) {
-			toAutoUpdate = append(toAutoUpdate, dep)
-		} else {
-			manual = append(manual, dep)
-		}
+	if len(deps) == 0 {
+		return false
 	}
-
-	// Auto-update trusted dependencies
-	if len(toAutoUpdate) > 0 {
-		autoUpdateDeps(toAutoUpdate)
+	// Updating needs the module proxy; just report what the cache knows
+	if isOffline() {
+		PrintOutdatedDeps(deps)
+		return false
 	}
 
-	// Print remaining manual deps
-	PrintOutdatedDeps(manual)
-
-	return len(toAutoUpdate) > 0
-}
-
-// getAutoUpdatePrefix returns the org prefix from the current module path.
-// e.g., "github.com/org/repo" -> "github.com/org/"
-// e.g., "gitlab.com/group/repo" -> "gitlab.com/group/"
-func getAutoUpdatePrefix() string {
-	data, err := os.ReadFile("go.mod")
+	policy, err := loadUpdatePolicy()
 	if err != nil {
-		return ""
-	}
-
-	f, err := modfile.Parse("go.mod", data, nil)
-	if err != nil || f.Module == nil {
-		return ""
-	}
-
-	// Extract host + org: "host.com/org/repo" -> "host.com/org/"
-	parts := strings.Split(f.Module.Mod.Path, "/")
-	if len(parts) >= 2 {
-		return parts[0] + "/" + parts[1] + "/"
+		fmt.Printf("%s dependency update policy: %v\n", warn("WARNING:"), err)
+		return false
 	}
-	return ""
-}
+	plans := planUpdates(r, policy, deps, time.Now())
 
-// autoUpdateDeps runs go get -u for each dependency
-func autoUpdateDeps(deps []OutdatedDep) {
-	fmt.Println()
-	fmt.Println("==> Auto-updating trusted dependencies:")
-	for _, dep := range deps {
-		current := shortenVersion(dep.Version)
-		update := shortenVersion(dep.Update)
-		fmt.Printf("    %s: %s -> %s\n", dep.Path, current, update)
+	updated := applyUpdates(r, plans, false)
 
-		cmd := exec.Command("go", "get", "-u", dep.Path+"@latest")
-		if err := cmd.Run(); err != nil {
-			fmt.Printf("    %s failed to update: %v\n", warn("WARNING:"), err)
+	// Print the deps that need a manual update
+	var manual []OutdatedDep
+	for _, p := range plans {
+		if p.Target == "" {
+			manual = append(manual, p.outdated())
 		}
 	}
-	// Run go mod tidy to clean up
-	cmd := exec.Command("go", "mod", "tidy")
-	cmd.Run()
+	PrintOutdatedDeps(manual)
+
+	return updated
 }
 
 // FixBogusDepsVersions detects dependencies with v0.0.0 versions in go.mod and
@@ -508,22 +499,47 @@ func FixBogusDepsVersions(r runner.CommandRunner) error {
 		return nil
 	}
 
+	// Resolutions are cached so offline runs can reuse them
+	db, _ := openCacheDB()
+	if db != nil {
+		defer db.Close()
+	}
+
 	// Resolve each module to its actual latest version
+	resolved := 0
 	for _, mod := range toFix {
 		if !jsonOutput {
 			fmt.Printf("==> Resolving %s (v0.0.0 is not a valid version)\n", mod)
 		}
 
-		version, err := resolveLatestVersionViaGit(r, mod)
-		if err != nil {
-			return fmt.Errorf("failed to resolve %s: %w", mod, err)
+		var version string
+		if isOffline() {
+			version = resolvedVersion(db, mod)
+			if version == "" {
+				if !jsonOutput {
+					fmt.Printf("    %s not resolved before, skipping (offline)\n", mod)
+				}
+				continue
+			}
+		} else {
+			version, err = resolveLatestVersionViaGit(r, mod)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", mod, err)
+			}
+			if db != nil {
+				_, _ = db.Exec(`INSERT OR REPLACE INTO resolved (path, version, resolved_at) VALUES (?, ?, ?)`, mod, version, time.Now().Unix())
+			}
 		}
+		resolved++
 
 		// Update the require in the parsed file
 		if err := f.AddRequire(mod, version); err != nil {
 			return fmt.Errorf("failed to update %s: %w", mod, err)
 		}
 	}
+	if resolved == 0 {
+		return nil
+	}
 
 	// Write the updated go.mod
 	newData, err := f.Format()
@@ -537,6 +553,18 @@ func FixBogusDepsVersions(r runner.CommandRunner) error {
 	return nil
 }
 
+// resolvedVersion returns the version mod last resolved to, or "".
+func resolvedVersion(db *sql.DB, mod string) string {
+	if db == nil {
+		return ""
+	}
+	var version string
+	if err := db.QueryRow(`SELECT version FROM resolved WHERE path = ?`, mod).Scan(&version); err != nil {
+		return ""
+	}
+	return version
+}
+
 // resolveLatestVersionViaGit fetches the latest commit from a git repo and
 // constructs a proper pseudo-version with the correct timestamp.
 func resolveLatestVersionViaGit(r runner.CommandRunner, mod string) (string, error) {
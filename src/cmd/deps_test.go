@@ -9,59 +9,6 @@ import (
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
-func TestLooksLikeGitVersion(t *testing.T) {
-	tests := []struct {
-		version string
-		want    bool
-	}{
-		// Pseudo-versions (should match)
-		{"v0.0.0-20240101120000-abc123def456", true},
-		{"v1.2.3-0.20240101120000-abc123def456", true},
-		{"v0.0.0-20230915123456-1234567890ab", true},
-
-		// Tagged versions (should not match)
-		{"v1.0.0", false},
-		{"v1.2.3", false},
-		{"v2.0.0-beta.1", false},
-		{"v1.0.0-rc1", false},
-
-		// Edge cases
-		{"", false},
-		{"v1", false},
-		{"v1.0", false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.version, func(t *testing.T) {
-			got := looksLikeGitVersion(tt.version)
-			assert.Equal(t, tt.want, got)
-		})
-	}
-}
-
-func TestIsHex(t *testing.T) {
-	tests := []struct {
-		s    string
-		want bool
-	}{
-		{"abc123def456", true},
-		{"0123456789ab", true},
-		{"000000000000", true},
-		{"ffffffffffff", true},
-		{"ABC123", false}, // uppercase not allowed
-		{"ghijkl", false}, // non-hex letters
-		{"12345", true},   // shorter is fine
-		{"", true},        // empty string has no non-hex chars
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.s, func(t *testing.T) {
-			got := isHex(tt.s)
-			assert.Equal(t, tt.want, got)
-		})
-	}
-}
-
 func TestShortenVersion(t *testing.T) {
 	tests := []struct {
 		version string
@@ -107,7 +54,7 @@ func TestPrintOutdatedDeps_WithDeps(t *testing.T) {
 
 func TestWaitForOutdatedDeps_Nil(t *testing.T) {
 	// Should not panic with nil DepChecker
-	WaitForOutdatedDeps(nil)
+	WaitForOutdatedDeps(runner.NewMock(), nil)
 }
 
 func TestDepChecker_Progress(t *testing.T) {
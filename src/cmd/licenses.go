@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/license"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+var licensesNotices string
+
+var licensesCmd = &cobra.Command{
+	Use:   "licenses",
+	Short: "List dependency licenses and check them against the license policy",
+	Long: `Classifies the license of every module in the build list from the license
+files in its module root, and reports each one as OK, DENIED or UNKNOWN
+against the allow/deny lists in the "licenses" section of go-toolchain.json.
+Licenses that aren't recognized can be set with "licenses.overrides".
+
+When an allow or deny list is configured, the same check runs before
+building in the default workflow and in matrix.`,
+	SilenceUsage: true,
+	RunE:         runLicenses,
+}
+
+func init() {
+	licensesCmd.Flags().StringVar(&licensesNotices, "notices", "", "Also write a third-party notices file (e.g. "+license.NoticesFile+")")
+	rootCmd.AddCommand(licensesCmd)
+}
+
+// licenseStatus is one row of the licenses report.
+type licenseStatus struct {
+	license.Module
+	Status string `json:"status"` // OK, DENIED or UNKNOWN
+	Reason string `json:"reason,omitempty"`
+}
+
+func runLicenses(cmd *cobra.Command, args []string) error {
+	return runLicensesWithRunner(runner.New())
+}
+
+func runLicensesWithRunner(r runner.CommandRunner) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	inv, err := licenseInventory(r, cfg.Licenses)
+	if err != nil {
+		return err
+	}
+	if licensesNotices != "" {
+		if err := writeNoticesFile(licensesNotices, inv); err != nil {
+			return err
+		}
+	}
+
+	policy := licensePolicy(cfg.Licenses)
+	statuses := checkLicensePolicy(policy, inv)
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(statuses); err != nil {
+			return err
+		}
+	} else {
+		for _, s := range statuses {
+			licenses := strings.Join(s.Licenses, ", ")
+			if licenses == "" {
+				licenses = "-"
+			}
+			if s.Override {
+				licenses += " (override)"
+			}
+			line := fmt.Sprintf("  %-7s %s %s: %s", s.Status, s.Path, s.Version, licenses)
+			if s.Reason != "" {
+				line += " (" + s.Reason + ")"
+			}
+			fmt.Println(line)
+		}
+		if licensesNotices != "" {
+			fmt.Printf("==> Wrote %s\n", licensesNotices)
+		}
+	}
+	return licenseError(statuses)
+}
+
+// licenseInventory classifies the licenses of every module in the build list.
+func licenseInventory(r runner.CommandRunner, cfg config.Licenses) ([]license.Module, error) {
+	mods, err := build.ListModules(r)
+	if err != nil {
+		return nil, err
+	}
+	return license.Inventory(mods, cfg.Overrides)
+}
+
+func licensePolicy(cfg config.Licenses) license.Policy {
+	return license.Policy{Allow: cfg.Allow, Deny: cfg.Deny}
+}
+
+// checkLicensePolicy reports the status of every module in inv.
+func checkLicensePolicy(policy license.Policy, inv []license.Module) []licenseStatus {
+	statuses := make([]licenseStatus, 0, len(inv))
+	for _, m := range inv {
+		s := licenseStatus{Module: m, Status: "OK"}
+		if reason := policy.Check(m); reason != "" {
+			s.Status = "DENIED"
+			s.Reason = reason
+		} else if len(m.Licenses) == 0 {
+			s.Status = "UNKNOWN"
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// licenseError fails if any module violates the policy.
+func licenseError(statuses []licenseStatus) error {
+	denied := 0
+	for _, s := range statuses {
+		if s.Status == "DENIED" {
+			denied++
+		}
+	}
+	if denied > 0 {
+		return fmt.Errorf("%d dependencies violate the license policy", denied)
+	}
+	return nil
+}
+
+// checkLicenses runs the license policy before a build, when one is
+// configured. Violations are always printed.
+func checkLicenses(r runner.CommandRunner, cfg config.Licenses, quiet bool) error {
+	policy := licensePolicy(cfg)
+	if !policy.Enabled() {
+		return nil
+	}
+	inv, err := licenseInventory(r, cfg)
+	if err != nil {
+		return err
+	}
+	statuses := checkLicensePolicy(policy, inv)
+	if !quiet {
+		fmt.Printf("==> Checking licenses of %d dependencies\n", len(statuses))
+	}
+	for _, s := range statuses {
+		if s.Status == "DENIED" {
+			fmt.Printf("  DENIED  %s %s: %s\n", s.Path, s.Version, s.Reason)
+		}
+	}
+	return licenseError(statuses)
+}
+
+// writeNoticesFile writes the third-party notices for inv to path.
+func writeNoticesFile(path string, inv []license.Module) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write notices: %w", err)
+	}
+	if err := license.WriteNotices(f, inv); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeNotices writes THIRD_PARTY_NOTICES.txt into the output dir and
+// returns its manifest entry, which archives and packages bundle.
+func writeNotices(r runner.CommandRunner, cfg config.Licenses, info gitInfo, goVersion string) (artifactEntry, error) {
+	inv, err := licenseInventory(r, cfg)
+	if err != nil {
+		return artifactEntry{}, err
+	}
+	path := filepath.Join(outputDir, license.NoticesFile)
+	if err := writeNoticesFile(path, inv); err != nil {
+		return artifactEntry{}, err
+	}
+	entry := artifactEntry{Kind: "notices", Name: license.NoticesFile}
+	if err := entry.record(outputDir, path, info, goVersion); err != nil {
+		return artifactEntry{}, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/license"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// newLicenseMock is a passing mock whose build list holds an MIT and a
+// GPL-3.0 module, with their sources in temp dirs.
+func newLicenseMock(t *testing.T) *runner.Mock {
+	mitDir := t.TempDir()
+	os.WriteFile(filepath.Join(mitDir, "LICENSE"), []byte("MIT License\n\nPermission is hereby granted, free of charge, to any person obtaining a copy\nof this software and associated documentation files.\n\nThe above copyright notice and this permission notice shall be included in all\ncopies or substantial portions of the Software.\n"), 0644)
+	gplDir := t.TempDir()
+	os.WriteFile(filepath.Join(gplDir, "COPYING"), []byte("GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n"), 0644)
+	list := fmt.Sprintf(`{"Path": "example.com", "Main": true}
+{"Path": "example.com/mit", "Version": "v1.0.0", "Dir": %q}
+{"Path": "example.com/gpl", "Version": "v2.0.0", "Dir": %q}
+{"Path": "example.com/unused", "Version": "v0.1.0"}
+`, mitDir, gplDir)
+
+	mock := newTestPassMock(0)
+	orig := mock.Handler
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		if cfg.IsCmd("go", "list", "-m", "-json", "all") {
+			return runner.MockProcess([]byte(list), nil), nil
+		}
+		return orig(cfg)
+	}
+	return mock
+}
+
+func TestRunLicensesWithRunner(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldNotices := licensesNotices
+	licensesNotices = "NOTICES.txt"
+	defer func() { licensesNotices = oldNotices }()
+
+	mock := newLicenseMock(t)
+	require.NoError(t, runLicensesWithRunner(mock))
+	data, err := os.ReadFile("NOTICES.txt")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "example.com/gpl v2.0.0\nLicense: GPL-3.0\n")
+
+	os.WriteFile(config.FileName, []byte(`{"licenses": {"deny": ["GPL-3.0"]}}`), 0644)
+	err = runLicensesWithRunner(mock)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "1 dependencies violate the license policy")
+}
+
+func TestCheckLicensePolicy(t *testing.T) {
+	inv := []license.Module{
+		{Path: "a", Licenses: []string{"MIT"}},
+		{Path: "b", Licenses: []string{}},
+		{Path: "c", Licenses: []string{"AGPL-3.0"}},
+	}
+	statuses := checkLicensePolicy(license.Policy{Deny: []string{"AGPL-3.0"}}, inv)
+	require.Equal(t, 3, len(statuses))
+	assert.Equal(t, "OK", statuses[0].Status)
+	assert.Equal(t, "UNKNOWN", statuses[1].Status)
+	assert.Equal(t, "DENIED", statuses[2].Status)
+	assert.Equal(t, "AGPL-3.0 is denied", statuses[2].Reason)
+
+	statuses = checkLicensePolicy(license.Policy{Allow: []string{"MIT", "AGPL-3.0"}}, inv)
+	assert.Equal(t, "DENIED", statuses[1].Status)
+	assert.Nil(t, licenseError(statuses[:1]))
+	assert.NotNil(t, licenseError(statuses))
+}
+
+func TestCheckLicensesWithoutPolicy(t *testing.T) {
+	mock := runner.NewMock()
+	require.NoError(t, checkLicenses(mock, config.Licenses{Notices: true}, true))
+	assert.Equal(t, 0, len(mock.Calls()))
+}
+
+func TestRunBuildPhaseLicenseDenied(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOutput := outputDir
+	outputDir = filepath.Join(tmpDir, "build")
+	defer func() { outputDir = oldOutput }()
+	os.WriteFile(config.FileName, []byte(`{"licenses": {"allow": ["MIT"]}}`), 0644)
+
+	mock := newLicenseMock(t)
+	err := runBuildPhase(mock, true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "license policy")
+	for _, call := range mock.Calls() {
+		assert.False(t, call.IsCmd("go", "build"))
+	}
+}
+
+func TestRunReleaseWithRunnerNotices(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	oldOutput := outputDir
+	oldArchive := matrixArchive
+	oldPackages := matrixPackages
+	matrixOS = []string{"linux"}
+	matrixArch = []string{"amd64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	matrixArchive = true
+	matrixPackages = []string{"deb"}
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+		outputDir = oldOutput
+		matrixArchive = oldArchive
+		matrixPackages = oldPackages
+	}()
+	os.WriteFile(config.FileName, []byte(`{"release": {"name": "tool"}, "licenses": {"notices": true}}`), 0644)
+
+	require.NoError(t, runReleaseWithRunner(newLicenseMock(t)))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+
+	kinds := map[string]artifactEntry{}
+	for _, a := range m.Artifacts {
+		kinds[a.Kind] = a
+	}
+	assert.Equal(t, license.NoticesFile, kinds["notices"].Path)
+	assert.True(t, slices.Contains(kinds["archive"].Files, license.NoticesFile))
+	assert.True(t, slices.Contains(kinds["package"].Files, "/usr/share/doc/tool/"+license.NoticesFile))
+
+	data, err = os.ReadFile(filepath.Join(outputDir, license.NoticesFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "example.com/mit v1.0.0\nLicense: MIT\n")
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+const (
+	manifestFile  = "manifest.json"
+	checksumsFile = "SHA256SUMS"
+)
+
+// buildManifest describes every artifact produced by a build, written to
+// <outputDir>/manifest.json for release and deploy tooling.
+type buildManifest struct {
+	Artifacts []artifactEntry `json:"artifacts"`
+}
+
+// artifactEntry records a single built file and how it was produced.
+type artifactEntry struct {
+	Kind      string            `json:"kind"` // "binary", "archive", "package", "sbom" or "notices"
+	Name      string            `json:"name"` // binary name, or archive name prefix
+	Path      string            `json:"path"` // relative to the output dir
+	Target    string            `json:"target,omitempty"`
+	GOOS      string            `json:"goos"`
+	GOARCH    string            `json:"goarch"`
+	Variant   string            `json:"variant,omitempty"` // GOARM, GOAMD64, ... value
+	Size      int64             `json:"size"`
+	SHA256    string            `json:"sha256"`
+	Version   string            `json:"version,omitempty"`
+	Commit    string            `json:"commit,omitempty"`
+	Timestamp string            `json:"commit_timestamp,omitempty"`
+	GoVersion string            `json:"go_version,omitempty"`
+	BuildArgs []string          `json:"build_args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Files     []string          `json:"files,omitempty"` // archive or package contents
+	SBOMs     []string          `json:"sboms,omitempty"` // paths of the binary's SBOMs
+}
+
+// goToolchainEnv holds the target platform and version of the go command
+// doing the builds (not the toolchain go-toolchain itself was built with).
+type goToolchainEnv struct {
+	goos      string
+	goarch    string
+	goVersion string
+}
+
+// queryGoEnv asks `go env` for GOOS, GOARCH and GOVERSION, falling back to
+// the runtime values if the query fails.
+func queryGoEnv(r runner.CommandRunner) goToolchainEnv {
+	env := goToolchainEnv{goos: runtime.GOOS, goarch: runtime.GOARCH}
+	proc, err := runner.Cmd("go", "env", "GOOS", "GOARCH", "GOVERSION").WithQuiet().Run(r)
+	if err != nil {
+		return env
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if proc.Wait() != nil {
+		return env
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) == 3 {
+		env.goos = strings.TrimSpace(lines[0])
+		env.goarch = strings.TrimSpace(lines[1])
+		env.goVersion = strings.TrimSpace(lines[2])
+	}
+	return env
+}
+
+// record fills in the size, hash and provenance of the file at path, which
+// must live under dir.
+func (e *artifactEntry) record(dir, path string, info gitInfo, goVersion string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("artifact %s: %w", path, err)
+	}
+	hash, err := fileHash(path)
+	if err != nil {
+		return fmt.Errorf("artifact %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+	e.Path = filepath.ToSlash(rel)
+	e.Size = st.Size()
+	e.SHA256 = hash
+	e.Version = info.version
+	e.Commit = info.commit
+	e.Timestamp = info.timestamp
+	e.GoVersion = goVersion
+	return nil
+}
+
+// writeManifest writes manifest.json and SHA256SUMS into dir. Entries are
+// sorted by path so the files are stable across parallel builds.
+func writeManifest(dir string, entries []artifactEntry) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	data, err := json.MarshalIndent(buildManifest{Artifacts: entries}, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	var sums strings.Builder
+	for _, e := range entries {
+		// Same layout as sha256sum, so `sha256sum -c SHA256SUMS` works
+		fmt.Fprintf(&sums, "%s  %s\n", e.SHA256, e.Path)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumsFile), []byte(sums.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", checksumsFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestQueryGoEnv(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"env", "GOOS", "GOARCH", "GOVERSION"},
+		[]byte("freebsd\nriscv64\ngo1.25.1\n"), nil)
+
+	env := queryGoEnv(mock)
+	assert.Equal(t, "freebsd", env.goos)
+	assert.Equal(t, "riscv64", env.goarch)
+	assert.Equal(t, "go1.25.1", env.goVersion)
+}
+
+func TestQueryGoEnvFallsBackToRuntime(t *testing.T) {
+	env := queryGoEnv(runner.NewMock())
+	assert.Equal(t, runtime.GOOS, env.goos)
+	assert.Equal(t, runtime.GOARCH, env.goarch)
+	assert.Equal(t, "", env.goVersion)
+}
+
+func TestArtifactEntryRecord(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0755))
+
+	e := artifactEntry{Target: "example.com/app"}
+	info := gitInfo{version: "v1.2.3", commit: "abc", timestamp: "1700000000"}
+	require.NoError(t, e.record(dir, path, info, "go1.25.1"))
+
+	assert.Equal(t, "app", e.Path)
+	assert.Equal(t, int64(5), e.Size)
+	// sha256("hello")
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", e.SHA256)
+	assert.Equal(t, "v1.2.3", e.Version)
+	assert.Equal(t, "abc", e.Commit)
+	assert.Equal(t, "1700000000", e.Timestamp)
+	assert.Equal(t, "go1.25.1", e.GoVersion)
+}
+
+func TestArtifactEntryRecordMissing(t *testing.T) {
+	e := artifactEntry{}
+	err := e.record(t.TempDir(), "/nonexistent/app", gitInfo{}, "")
+	assert.NotNil(t, err)
+}
+
+func TestWriteManifest(t *testing.T) {
+	dir := t.TempDir()
+	entries := []artifactEntry{
+		{Path: "b_linux_amd64", SHA256: "bbbb", Target: "example.com/b", GOOS: "linux", GOARCH: "amd64"},
+		{Path: "a_linux_amd64", SHA256: "aaaa", Target: "example.com/a", GOOS: "linux", GOARCH: "amd64"},
+	}
+	require.NoError(t, writeManifest(dir, entries))
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+	require.Equal(t, 2, len(m.Artifacts))
+	assert.Equal(t, "a_linux_amd64", m.Artifacts[0].Path)
+	assert.Equal(t, "b_linux_amd64", m.Artifacts[1].Path)
+
+	sums, err := os.ReadFile(filepath.Join(dir, checksumsFile))
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa  a_linux_amd64\nbbbb  b_linux_amd64\n", string(sums))
+}
+
+func TestRunReleaseWithRunnerWritesManifest(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	oldOutput := outputDir
+	matrixOS = []string{"linux", "windows"}
+	matrixArch = []string{"amd64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+		outputDir = oldOutput
+	}()
+
+	mock := newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+	require.Equal(t, 2, len(m.Artifacts))
+	assert.Equal(t, "example.com_linux_amd64", m.Artifacts[0].Path)
+	assert.Equal(t, "linux", m.Artifacts[0].GOOS)
+	assert.Equal(t, "example.com/pkg", m.Artifacts[0].Target)
+	assert.Equal(t, "0", m.Artifacts[0].Env["CGO_ENABLED"])
+	assert.Equal(t, "example.com_windows_amd64.exe", m.Artifacts[1].Path)
+	assert.Equal(t, "windows", m.Artifacts[1].GOOS)
+
+	sums, err := os.ReadFile(filepath.Join(outputDir, checksumsFile))
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(strings.Split(strings.TrimSpace(string(sums)), "\n")))
+}
+
+func TestRunBuildPhaseWritesManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOutput := outputDir
+	oldBench := noBenchmark
+	outputDir = filepath.Join(tmpDir, "build")
+	noBenchmark = true
+	defer func() {
+		outputDir = oldOutput
+		noBenchmark = oldBench
+	}()
+
+	mock := newTestPassMock(0)
+	mock.SetResponse("go", []string{"env", "GOOS", "GOARCH", "GOVERSION"},
+		[]byte("linux\narm64\ngo1.25.1\n"), nil)
+	require.NoError(t, runBuildPhase(mock, true))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+	require.Equal(t, 1, len(m.Artifacts))
+	assert.Equal(t, "example.com", m.Artifacts[0].Path)
+	assert.Equal(t, "linux", m.Artifacts[0].GOOS)
+	assert.Equal(t, "arm64", m.Artifacts[0].GOARCH)
+	assert.Equal(t, "go1.25.1", m.Artifacts[0].GoVersion)
+	assert.Equal(t, "build", m.Artifacts[0].BuildArgs[0])
+}
@@ -5,17 +5,25 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/spf13/cobra"
 	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
 var (
-	matrixOS      []string
-	matrixArch    []string
+	matrixOS        []string
+	matrixArch      []string
 	releaseParallel int
+	matrixArchive   bool
+	matrixPackages  []string
+	matrixNoCache   bool
+	matrixPlatforms []string
+	matrixExclude   []string
 )
 
 var (
@@ -27,27 +35,40 @@ func init() {
 	matrixCmd := &cobra.Command{
 		Use:          "matrix",
 		Short:        "Cross-compile for multiple platforms",
-		Long:         "Builds binaries for multiple GOOS/GOARCH combinations in parallel.",
+		Long: `Builds binaries for multiple GOOS/GOARCH combinations in parallel.
+
+Platforms are the --os x --arch product unless --platforms (or "matrix.platforms"
+in go-toolchain.json) lists explicit os/arch pairs. A third element selects a
+variant: linux/arm/7 sets GOARM=7, linux/amd64/v3 sets GOAMD64=v3. --exclude
+drops matching platforms (e.g. windows/arm64, */386). Every platform is checked
+against 'go tool dist list' before anything is built.`,
 		SilenceUsage: true,
 		RunE:         runRelease,
 	}
 	matrixCmd.Flags().StringSliceVar(&matrixOS, "os", DefaultOS, "Target operating systems")
 	matrixCmd.Flags().StringSliceVar(&matrixArch, "arch", DefaultArch, "Target architectures")
+	matrixCmd.Flags().StringSliceVar(&matrixPlatforms, "platforms", nil, "Explicit os/arch[/variant] platforms, instead of --os x --arch")
+	matrixCmd.Flags().StringSliceVar(&matrixExclude, "exclude", nil, "Platforms to skip, e.g. windows/arm64 or */386")
 	matrixCmd.Flags().IntVarP(&releaseParallel, "parallel", "p", runtime.NumCPU(), "Number of parallel builds")
+	matrixCmd.Flags().BoolVar(&matrixArchive, "archive", false, "Package each platform's binaries into a .tar.gz (.zip on windows)")
+	matrixCmd.Flags().StringSliceVar(&matrixPackages, "packages", nil, "Linux package formats to build from linux binaries (deb, rpm, apk)")
+	matrixCmd.Flags().BoolVar(&matrixNoCache, "no-cache", false, "Rebuild every binary instead of reusing unchanged ones from the build cache")
 	rootCmd.AddCommand(matrixCmd)
 }
 
 type buildJob struct {
-	goos       string
-	goarch     string
-	srcPath    string
+	platform   build.Platform
+	target     build.Target
 	outputPath string
 	ldflags    string
+	cgoEnv     map[string]string // CC, CXX, ... for cgo targets
 }
 
 type buildResult struct {
-	job buildJob
-	err error
+	job      buildJob
+	artifact artifactEntry
+	cached   bool
+	err      error
 }
 
 func runRelease(cmd *cobra.Command, args []string) error {
@@ -56,8 +77,27 @@ func runRelease(cmd *cobra.Command, args []string) error {
 }
 
 func runReleaseWithRunner(r runner.CommandRunner) error {
-	if len(matrixOS) == 0 || len(matrixArch) == 0 {
-		return fmt.Errorf("no platforms specified (need at least one --os and one --arch)")
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+
+	// Resolve platforms before testing so a bad pair fails immediately
+	sel := build.PlatformSelection{
+		Platforms: matrixPlatforms,
+		OS:        matrixOS,
+		Arch:      matrixArch,
+		Exclude:   append(slices.Clone(cfg.Matrix.Exclude), matrixExclude...),
+	}
+	if len(sel.Platforms) == 0 {
+		sel.Platforms = cfg.Matrix.Platforms
+	}
+	if len(sel.Platforms) == 0 && (len(matrixOS) == 0 || len(matrixArch) == 0) {
+		return fmt.Errorf("no platforms specified (need at least one --os and one --arch, or --platforms)")
+	}
+	platforms, err := build.ResolvePlatforms(r, sel)
+	if err != nil {
+		return err
 	}
 
 	// Run tests with coverage first (same as default command)
@@ -66,14 +106,29 @@ func runReleaseWithRunner(r runner.CommandRunner) error {
 	}
 
 	// Resolve what to build
-	targets, err := build.ResolveBuildTargets(r)
+	formats := matrixPackages
+	if len(formats) == 0 {
+		formats = cfg.Release.Packages.Formats
+	}
+	for _, f := range formats {
+		if !slices.Contains(config.PackageFormats, f) {
+			return fmt.Errorf("unknown package format %q (use: deb, rpm, apk)", f)
+		}
+	}
+	targets, err := build.ResolveBuildTargets(r, cfg.Build)
 	if err != nil {
 		return err
 	}
+	if targets, err = build.ApplyPGO(r, targets); err != nil {
+		return err
+	}
 
 	if len(targets) == 0 {
 		return fmt.Errorf("no main packages found to build")
 	}
+	if err := checkLicenses(r, cfg.Licenses, false); err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
@@ -82,29 +137,51 @@ func runReleaseWithRunner(r runner.CommandRunner) error {
 	// Collect git info once for all builds
 	info := collectGitInfo()
 	ldflags := info.ldflags()
+	goEnv := queryGoEnv(r)
+	goVersion := goEnv.goVersion
+	host := build.Platform{GOOS: goEnv.goos, GOARCH: goEnv.goarch}
+
+	warnDisabledCgo(r, targets)
 
-	// Build job queue - cartesian product of OS x Arch x Targets
+	// Build job queue - Platforms x Targets
 	var jobs []buildJob
-	for _, goos := range matrixOS {
-		for _, goarch := range matrixArch {
-			for _, target := range targets {
-				ext := ""
-				if goos == "windows" {
-					ext = ".exe"
+	for _, platform := range platforms {
+		for _, target := range targets {
+			var cgoEnv map[string]string
+			if target.CGO {
+				env, ok, err := build.CToolchainEnv(cfg.Matrix.CToolchains, platform)
+				if err != nil {
+					return err
 				}
-				outputName := fmt.Sprintf("%s_%s_%s%s", target.OutputName, goos, goarch, ext)
-				jobs = append(jobs, buildJob{
-					goos:       goos,
-					goarch:     goarch,
-					srcPath:    target.ImportPath,
-					outputPath: filepath.Join(outputDir, outputName),
-					ldflags:    ldflags,
-				})
+				if !ok && (platform.GOOS != host.GOOS || platform.GOARCH != host.GOARCH) {
+					return fmt.Errorf("%s needs cgo, but no C toolchain is configured for %s (add one to matrix.c_toolchains in %s)", target.OutputName, platform, config.FileName)
+				}
+				cgoEnv = env
+			}
+			ext := ""
+			if platform.GOOS == "windows" {
+				ext = ".exe"
 			}
+			outputName := fmt.Sprintf("%s_%s%s", target.OutputName, platform.Suffix(), ext)
+			jobs = append(jobs, buildJob{
+				platform:   platform,
+				target:     target,
+				outputPath: filepath.Join(outputDir, outputName),
+				ldflags:    ldflags,
+				cgoEnv:     cgoEnv,
+			})
 		}
 	}
 
-	fmt.Printf("==> Building %d binaries (%d OS x %d arch)\n", len(jobs), len(matrixOS), len(matrixArch))
+	fmt.Printf("==> Building %d binaries (%d platforms)\n", len(jobs), len(platforms))
+
+	var cache *buildCache
+	if !matrixNoCache {
+		cache, err = openBuildCache(goVersion, outputDir)
+		if err != nil {
+			fmt.Printf("warning: build cache disabled: %v\n", err)
+		}
+	}
 
 	// Run builds in parallel
 	results := make(chan buildResult, len(jobs))
@@ -121,8 +198,32 @@ func runReleaseWithRunner(r runner.CommandRunner) error {
 		go func() {
 			defer wg.Done()
 			for job := range jobChan {
-				err := runBuild(r, job)
-				results <- buildResult{job: job, err: err}
+				result := buildResult{job: job}
+				if cache != nil {
+					result.cached, result.err = cache.restore(job)
+				}
+				if !result.cached && result.err == nil {
+					result.err = runBuild(r, job)
+					if result.err == nil && cache != nil {
+						if err := cache.store(job); err != nil {
+							fmt.Printf("warning: failed to cache %s: %v\n", job.outputPath, err)
+						}
+					}
+				}
+				if result.err == nil {
+					result.artifact = artifactEntry{
+						Kind:      "binary",
+						Name:      job.target.OutputName,
+						Target:    job.target.ImportPath,
+						GOOS:      job.platform.GOOS,
+						GOARCH:    job.platform.GOARCH,
+						Variant:   job.platform.Variant,
+						BuildArgs: job.command().Args,
+						Env:       job.command().Env,
+					}
+					result.err = result.artifact.record(outputDir, job.outputPath, info, goVersion)
+				}
+				results <- result
 			}
 		}()
 	}
@@ -139,12 +240,25 @@ func runReleaseWithRunner(r runner.CommandRunner) error {
 
 	// Collect results
 	var failed []buildResult
+	var artifacts []artifactEntry
+	cachedCount := 0
 	for result := range results {
 		if result.err != nil {
-			fmt.Printf("  FAIL %s/%s: %v\n", result.job.goos, result.job.goarch, result.err)
+			fmt.Printf("  FAIL %s: %v\n", result.job.platform, result.err)
 			failed = append(failed, result)
+		} else if result.cached {
+			fmt.Printf("  HIT  %s\n", result.job.outputPath)
+			artifacts = append(artifacts, result.artifact)
+			cachedCount++
 		} else {
 			fmt.Printf("  OK   %s\n", result.job.outputPath)
+			artifacts = append(artifacts, result.artifact)
+		}
+	}
+
+	if cache != nil {
+		if err := cache.prune(matrixCacheMaxBytes); err != nil {
+			fmt.Printf("warning: failed to prune build cache: %v\n", err)
 		}
 	}
 
@@ -152,17 +266,101 @@ func runReleaseWithRunner(r runner.CommandRunner) error {
 		return fmt.Errorf("%d/%d builds failed", len(failed), len(jobs))
 	}
 
-	fmt.Printf("==> All %d binaries built successfully in %s/\n", len(jobs), outputDir)
+	if cfg.Licenses.Notices {
+		notices, err := writeNotices(r, cfg.Licenses, info, goVersion)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  DOC  %s\n", filepath.Join(outputDir, notices.Path))
+		artifacts = append(artifacts, notices)
+	}
+
+	if matrixArchive || cfg.Release.Archives {
+		archives, err := packageArchives(r, cfg.Release, artifacts, info)
+		if err != nil {
+			return err
+		}
+		for _, a := range archives {
+			fmt.Printf("  PKG  %s\n", filepath.Join(outputDir, a.Path))
+		}
+		artifacts = append(artifacts, archives...)
+	}
+
+	if len(formats) > 0 {
+		packages, err := packageLinux(r, cfg.Release, formats, artifacts, info)
+		if err != nil {
+			return err
+		}
+		for _, p := range packages {
+			fmt.Printf("  PKG  %s\n", filepath.Join(outputDir, p.Path))
+		}
+		artifacts = append(artifacts, packages...)
+	}
+
+	if formats := sbomFormats(cfg.SBOM); len(formats) > 0 {
+		sboms, err := writeSBOMs(r, formats, artifacts, info)
+		if err != nil {
+			return err
+		}
+		for _, s := range sboms {
+			fmt.Printf("  SBOM %s\n", filepath.Join(outputDir, s.Path))
+		}
+		artifacts = append(artifacts, sboms...)
+	}
+
+	if err := writeManifest(outputDir, artifacts); err != nil {
+		return err
+	}
+
+	if err := trackSizes(r, cfg.Size, artifacts, info, false); err != nil {
+		return err
+	}
+
+	if cachedCount > 0 {
+		fmt.Printf("==> All %d binaries built successfully in %s/ (%d unchanged, reused from cache)\n", len(jobs), outputDir, cachedCount)
+	} else {
+		fmt.Printf("==> All %d binaries built successfully in %s/\n", len(jobs), outputDir)
+	}
 	return nil
 }
 
+// command returns the go build invocation for this job.
+func (job buildJob) command() *runner.Config {
+	cgo := "0"
+	if job.target.CGO {
+		cgo = "1"
+	}
+	cmd := runner.Cmd("go", job.target.BuildArgs(job.ldflags, job.outputPath)...).
+		WithEnv("CGO_ENABLED", cgo).
+		WithQuiet()
+	for k, v := range job.platform.Env() {
+		cmd = cmd.WithEnv(k, v)
+	}
+	for k, v := range job.cgoEnv {
+		cmd = cmd.WithEnv(k, v)
+	}
+	return cmd
+}
+
+// warnDisabledCgo warns about targets built with CGO_ENABLED=0 whose
+// dependencies use cgo. Such builds fail, or quietly drop features, instead
+// of producing the binary the developer runs locally.
+func warnDisabledCgo(r runner.CommandRunner, targets []build.Target) {
+	for _, t := range targets {
+		if t.CGO {
+			continue
+		}
+		pkgs, err := build.CgoDependencies(r, t)
+		if err != nil || len(pkgs) == 0 {
+			continue
+		}
+		fmt.Printf("warning: %s depends on cgo packages (%s) but matrix builds it with CGO_ENABLED=0; set \"cgo\": true on its target in %s\n",
+			t.OutputName, strings.Join(pkgs, ", "), config.FileName)
+	}
+}
+
 func runBuild(r runner.CommandRunner, job buildJob) error {
-	proc, err := runner.Cmd("go", "build", "-ldflags", job.ldflags, "-o", job.outputPath, job.srcPath).
-		WithEnv("GOOS", job.goos).
-		WithEnv("GOARCH", job.goarch).
-		WithEnv("CGO_ENABLED", "0").
-		WithQuiet().
-		Run(r)
+	proc, err := job.command().Run(r)
 	if err != nil {
 		return err
 	}
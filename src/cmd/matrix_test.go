@@ -8,10 +8,14 @@ import (
 	"testing"
 
 	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
 func TestRunReleaseWithRunnerNoPlatforms(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	oldOS := matrixOS
 	oldArch := matrixArch
 	matrixOS = []string{}
@@ -27,6 +31,7 @@ func TestRunReleaseWithRunnerNoPlatforms(t *testing.T) {
 }
 
 func TestRunReleaseWithRunnerNoMainPackages(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -45,11 +50,13 @@ func TestRunReleaseWithRunnerNoMainPackages(t *testing.T) {
 	}()
 
 	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"tool", "dist", "list"}, []byte(testDistList), nil)
 	err := runReleaseWithRunner(mock)
 	assert.NotNil(t, err)
 }
 
 func TestRunReleaseWithRunnerSuccess(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -79,6 +86,7 @@ func TestRunReleaseWithRunnerSuccess(t *testing.T) {
 }
 
 func TestRunReleaseWithRunnerBuildFails(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -116,6 +124,7 @@ func TestRunReleaseWithRunnerBuildFails(t *testing.T) {
 }
 
 func TestRunReleaseWithRunnerWindowsExt(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -160,6 +169,7 @@ func TestRunReleaseWithRunnerWindowsExt(t *testing.T) {
 }
 
 func TestRunReleaseWithRunnerMoreJobsThanWorkers(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -189,6 +199,7 @@ func TestRunReleaseWithRunnerMoreJobsThanWorkers(t *testing.T) {
 }
 
 func TestRunReleaseWithRunnerMultipleOSArch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	tmpDir := t.TempDir()
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
@@ -229,9 +240,8 @@ func TestRunReleaseWithRunnerMultipleOSArch(t *testing.T) {
 func TestRunBuild(t *testing.T) {
 	mock := runner.NewMock()
 	job := buildJob{
-		goos:       "linux",
-		goarch:     "amd64",
-		srcPath:    ".",
+		platform:   build.Platform{GOOS: "linux", GOARCH: "amd64"},
+		target:     build.Target{ImportPath: "."},
 		outputPath: "/tmp/test",
 	}
 
@@ -257,3 +267,133 @@ func TestRunBuild(t *testing.T) {
 	}
 	assert.True(t, hasOutput)
 }
+
+func TestRunReleaseWithRunnerPlatforms(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	os.WriteFile(config.FileName, []byte(`{"matrix": {"exclude": ["linux/arm/6"]}}`), 0644)
+
+	oldPlatforms := matrixPlatforms
+	oldExclude := matrixExclude
+	oldOutput := outputDir
+	matrixPlatforms = []string{"linux/amd64/v3", "linux/arm/6", "linux/arm/7", "windows/amd64", "darwin/arm64"}
+	matrixExclude = []string{"windows/*"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	defer func() {
+		matrixPlatforms = oldPlatforms
+		matrixExclude = oldExclude
+		outputDir = oldOutput
+	}()
+
+	mock := newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+
+	envs := map[string]map[string]string{}
+	for _, cfg := range mock.Calls() {
+		if cfg.IsCmd("go", "build") {
+			envs[filepath.Base(cfg.Args[len(cfg.Args)-2])] = cfg.Env
+		}
+	}
+	require.Equal(t, 3, len(envs))
+	assert.Equal(t, "v3", envs["example.com_linux_amd64_v3"]["GOAMD64"])
+	assert.Equal(t, "7", envs["example.com_linux_arm_7"]["GOARM"])
+	assert.Equal(t, "arm64", envs["example.com_darwin_arm64"]["GOARCH"])
+}
+
+func TestRunReleaseWithRunnerUnsupportedPlatformFailsFirst(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	matrixOS = []string{"darwin"}
+	matrixArch = []string{"386"}
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+	}()
+
+	mock := newTestPassMock(0)
+	err := runReleaseWithRunner(mock)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "darwin/386")
+	for _, cfg := range mock.Calls() {
+		assert.False(t, cfg.IsCmd("go", "test"), "tests should not run for an unsupported platform")
+	}
+}
+
+func TestRunReleaseWithRunnerCgoTarget(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	os.WriteFile(config.FileName, []byte(`{
+	"build": {"targets": [{"package": "example.com/db", "output": "db", "cgo": true}, {"package": "example.com/cli", "output": "cli"}]},
+	"matrix": {"c_toolchains": [{"platform": "linux/arm64", "zig": true}]}
+}`), 0644)
+
+	oldPlatforms := matrixPlatforms
+	oldOutput := outputDir
+	matrixPlatforms = []string{"linux/arm64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	defer func() {
+		matrixPlatforms = oldPlatforms
+		outputDir = oldOutput
+	}()
+
+	mock := newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+
+	envs := map[string]map[string]string{}
+	preflight := map[string]bool{}
+	for _, cfg := range mock.Calls() {
+		if cfg.IsCmd("go", "build") {
+			envs[filepath.Base(cfg.Args[len(cfg.Args)-2])] = cfg.Env
+		}
+		if cfg.IsCmd("go", "list", "-deps") {
+			preflight[cfg.Args[len(cfg.Args)-1]] = true
+		}
+	}
+	assert.Equal(t, "1", envs["db_linux_arm64"]["CGO_ENABLED"])
+	assert.Equal(t, "zig cc -target aarch64-linux-gnu", envs["db_linux_arm64"]["CC"])
+	assert.Equal(t, "0", envs["cli_linux_arm64"]["CGO_ENABLED"])
+	assert.Equal(t, "", envs["cli_linux_arm64"]["CC"])
+	// Only targets with cgo disabled get the preflight
+	assert.Equal(t, map[string]bool{"example.com/cli": true}, preflight)
+}
+
+func TestRunReleaseWithRunnerCgoWithoutToolchain(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	os.WriteFile(config.FileName, []byte(`{"build": {"targets": [{"package": "example.com/db", "output": "db", "cgo": true}]}}`), 0644)
+
+	oldPlatforms := matrixPlatforms
+	oldOutput := outputDir
+	matrixPlatforms = []string{"windows/arm64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	defer func() {
+		matrixPlatforms = oldPlatforms
+		outputDir = oldOutput
+	}()
+
+	mock := newTestPassMock(0)
+	err := runReleaseWithRunner(mock)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "c_toolchains")
+	for _, cfg := range mock.Calls() {
+		assert.False(t, cfg.IsCmd("go", "build"))
+	}
+}
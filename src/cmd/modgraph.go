@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/modfile"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+var (
+	depsDirectOnly bool
+	depsFormat     string
+)
+
+func init() {
+	outdatedCmd := &cobra.Command{
+		Use:          "outdated",
+		Short:        "List every module in the build list with a newer version",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDepsOutdated(runner.New())
+		},
+	}
+	outdatedCmd.Flags().BoolVar(&depsDirectOnly, "direct", false, "Only list direct dependencies")
+
+	graphCmd := &cobra.Command{
+		Use:          "graph",
+		Short:        "Print the module requirement graph as DOT or JSON",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDepsGraph(runner.New())
+		},
+	}
+	graphCmd.Flags().StringVar(&depsFormat, "format", "dot", "Output format: dot or json")
+
+	whyCmd := &cobra.Command{
+		Use:          "why <module>",
+		Short:        "Show why a module is in the build: its requirement and import chains",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDepsWhy(runner.New(), args[0])
+		},
+	}
+
+	unusedCmd := &cobra.Command{
+		Use:          "unused",
+		Short:        "List go.mod requirements that no package in the build imports",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDepsUnused(runner.New())
+		},
+	}
+
+	depsCmd.AddCommand(outdatedCmd, graphCmd, whyCmd, unusedCmd)
+}
+
+// outdatedModule is one row of the deps outdated report.
+type outdatedModule struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Update   string `json:"update"`
+	Indirect bool   `json:"indirect,omitempty"`
+}
+
+func runDepsOutdated(r runner.CommandRunner) error {
+	if isOffline() {
+		return fmt.Errorf("deps outdated queries the module proxy: %w", errOffline)
+	}
+	mods, err := build.QueryModules(r, []string{"-u"}, "all")
+	if err != nil {
+		return err
+	}
+	outdated := []outdatedModule{}
+	for _, m := range mods {
+		if m.Main || m.Update == nil || m.Replace != nil || (depsDirectOnly && m.Indirect) {
+			continue
+		}
+		outdated = append(outdated, outdatedModule{Path: m.Path, Version: m.Version, Update: m.Update.Version, Indirect: m.Indirect})
+	}
+	if jsonOutput {
+		return printJSON(outdated)
+	}
+	if len(outdated) == 0 {
+		fmt.Println("==> All modules are up to date")
+		return nil
+	}
+	width := 0
+	for _, m := range outdated {
+		width = max(width, len(m.Path))
+	}
+	for _, m := range outdated {
+		line := fmt.Sprintf("  %-*s  %s -> %s", width, m.Path, shortenVersion(m.Version), shortenVersion(m.Update))
+		if m.Indirect {
+			line += " (indirect)"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func runDepsGraph(r runner.CommandRunner) error {
+	g, err := build.LoadModGraph(r)
+	if err != nil {
+		return err
+	}
+	format := depsFormat
+	if jsonOutput {
+		format = "json"
+	}
+	switch format {
+	case "dot":
+		return g.WriteDOT(os.Stdout)
+	case "json":
+		return printJSON(struct {
+			Main  string       `json:"main"`
+			Nodes []string     `json:"nodes"`
+			Edges []build.Edge `json:"edges"`
+		}{g.Main, g.Nodes(), g.EdgeList()})
+	}
+	return fmt.Errorf("unknown graph format %q (use: dot, json)", format)
+}
+
+func runDepsWhy(r runner.CommandRunner, module string) error {
+	module, _, _ = strings.Cut(module, "@")
+	g, err := build.LoadModGraph(r)
+	if err != nil {
+		return err
+	}
+	requires := g.RequirePath(module)
+	if requires == nil {
+		return fmt.Errorf("%s is not in the module graph", module)
+	}
+	why, err := build.ModWhy(r, module)
+	if err != nil {
+		return err
+	}
+	imports := why[module]
+
+	if jsonOutput {
+		if imports == nil {
+			imports = []string{}
+		}
+		return printJSON(struct {
+			Module   string   `json:"module"`
+			Requires []string `json:"requires"`
+			Imports  []string `json:"imports"`
+		}{module, requires, imports})
+	}
+	fmt.Println("==> Required by")
+	printChain(requires)
+	fmt.Println("==> Imported by")
+	if imports == nil {
+		fmt.Printf("  (no package in the build imports %s)\n", module)
+	} else {
+		printChain(imports)
+	}
+	return nil
+}
+
+// printChain prints a dependency chain, one step per line.
+func printChain(chain []string) {
+	for i, step := range chain {
+		if i == 0 {
+			fmt.Printf("  %s\n", step)
+		} else {
+			fmt.Printf("  %s-> %s\n", strings.Repeat("  ", i-1), step)
+		}
+	}
+}
+
+// unusedRequire is a go.mod requirement the build doesn't need.
+type unusedRequire struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect,omitempty"`
+}
+
+func runDepsUnused(r runner.CommandRunner) error {
+	data, err := os.ReadFile("go.mod")
+	if err != nil {
+		return fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+	var paths []string
+	for _, req := range f.Require {
+		paths = append(paths, req.Mod.Path)
+	}
+
+	unused := []unusedRequire{}
+	if len(paths) > 0 {
+		why, err := build.ModWhy(r, paths...)
+		if err != nil {
+			return err
+		}
+		for _, req := range f.Require {
+			if chain, ok := why[req.Mod.Path]; ok && chain == nil {
+				unused = append(unused, unusedRequire{Path: req.Mod.Path, Version: req.Mod.Version, Indirect: req.Indirect})
+			}
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(unused)
+	}
+	if len(unused) == 0 {
+		fmt.Println("==> Every requirement is imported")
+		return nil
+	}
+	for _, u := range unused {
+		line := fmt.Sprintf("  %s %s", u.Path, u.Version)
+		if u.Indirect {
+			line += " // indirect"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func newModGraphMock() *runner.Mock {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"mod", "graph"}, []byte(`example.com/app example.com/a@v1.0.0
+example.com/a@v1.0.0 example.com/b@v0.1.0
+`), nil)
+	mock.SetResponse("go", []string{"list", "-m", "-json", "-u", "all"}, []byte(`{"Path": "example.com/app", "Main": true}
+{"Path": "example.com/a", "Version": "v1.0.0", "Update": {"Path": "example.com/a", "Version": "v1.1.0"}}
+{"Path": "example.com/b", "Version": "v0.1.0", "Indirect": true, "Update": {"Path": "example.com/b", "Version": "v0.2.0"}}
+{"Path": "example.com/c", "Version": "v1.0.0", "Indirect": true}
+{"Path": "example.com/forked", "Version": "v1.0.0", "Replace": {"Path": "../forked"}, "Update": {"Path": "example.com/forked", "Version": "v1.1.0"}}
+`), nil)
+	mock.SetResponse("go", []string{"mod", "why", "-m", "example.com/b"}, []byte("# example.com/b\nexample.com/app\nexample.com/a/pkg\nexample.com/b\n"), nil)
+	mock.SetResponse("go", []string{"mod", "why", "-m", "example.com/a", "example.com/c"}, []byte(`# example.com/a
+example.com/app
+example.com/a
+
+# example.com/c
+(main module does not need module example.com/c)
+`), nil)
+	return mock
+}
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func() error) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	old := os.Stdout
+	os.Stdout = w
+	ferr := f()
+	os.Stdout = old
+	w.Close()
+	data, _ := io.ReadAll(r)
+	require.NoError(t, ferr)
+	return string(data)
+}
+
+func TestRunDepsOutdated(t *testing.T) {
+	out := captureStdout(t, func() error { return runDepsOutdated(newModGraphMock()) })
+	assert.Contains(t, out, "example.com/a  v1.0.0 -> v1.1.0\n")
+	assert.Contains(t, out, "example.com/b  v0.1.0 -> v0.2.0 (indirect)\n")
+	assert.NotContains(t, out, "example.com/forked")
+
+	oldDirect := depsDirectOnly
+	depsDirectOnly = true
+	defer func() { depsDirectOnly = oldDirect }()
+	out = captureStdout(t, func() error { return runDepsOutdated(newModGraphMock()) })
+	assert.NotContains(t, out, "example.com/b")
+}
+
+func TestRunDepsGraph(t *testing.T) {
+	oldFormat := depsFormat
+	defer func() { depsFormat = oldFormat }()
+
+	depsFormat = "dot"
+	out := captureStdout(t, func() error { return runDepsGraph(newModGraphMock()) })
+	assert.Contains(t, out, "\"example.com/a@v1.0.0\" -> \"example.com/b@v0.1.0\";")
+
+	depsFormat = "json"
+	out = captureStdout(t, func() error { return runDepsGraph(newModGraphMock()) })
+	assert.Contains(t, out, "\"main\": \"example.com/app\"")
+	assert.Contains(t, out, "\"from\": \"example.com/app\"")
+
+	depsFormat = "svg"
+	assert.NotNil(t, runDepsGraph(newModGraphMock()))
+}
+
+func TestRunDepsWhy(t *testing.T) {
+	out := captureStdout(t, func() error { return runDepsWhy(newModGraphMock(), "example.com/b@v0.1.0") })
+	assert.Contains(t, out, "==> Required by\n  example.com/app\n  -> example.com/a@v1.0.0\n    -> example.com/b@v0.1.0\n")
+	assert.Contains(t, out, "==> Imported by\n  example.com/app\n  -> example.com/a/pkg\n")
+
+	assert.NotNil(t, runDepsWhy(newModGraphMock(), "example.com/missing"))
+}
+
+func TestRunDepsUnused(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	os.WriteFile("go.mod", []byte(`module example.com/app
+
+go 1.24
+
+require example.com/a v1.0.0
+
+require example.com/c v1.0.0 // indirect
+`), 0644)
+	out := captureStdout(t, func() error { return runDepsUnused(newModGraphMock()) })
+	assert.Equal(t, "  example.com/c v1.0.0 // indirect\n", out)
+
+	oldJSON := jsonOutput
+	jsonOutput = true
+	defer func() { jsonOutput = oldJSON }()
+	out = captureStdout(t, func() error { return runDepsUnused(newModGraphMock()) })
+	assert.Contains(t, out, "\"path\": \"example.com/c\"")
+
+	os.Remove("go.mod")
+	assert.NotNil(t, runDepsUnused(newModGraphMock()))
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/release"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// packageLinux builds one package per format and linux GOARCH, installing
+// that platform's binaries into bin_dir plus any configured extra files.
+// Third-party notices, if written, go under /usr/share/doc/<name>/.
+// Returns manifest entries for the packages.
+func packageLinux(r runner.CommandRunner, cfg config.Release, formats []string, binaries []artifactEntry, info gitInfo) ([]artifactEntry, error) {
+	pc := cfg.Packages
+	name := pc.Name
+	if name == "" {
+		name = cfg.Name
+	}
+	if name == "" {
+		name = path.Base(moduleName(r))
+	}
+	binDir := pc.BinDir
+	if binDir == "" {
+		binDir = "/usr/bin"
+	}
+
+	var extras []release.PackageFile
+	for _, f := range pc.Files {
+		mode, err := f.FileMode()
+		if err != nil {
+			return nil, err
+		}
+		extras = append(extras, release.PackageFile{Dest: f.Dest, Source: filepath.FromSlash(f.Source), Mode: mode})
+	}
+
+	for _, a := range binaries {
+		if a.Kind == "notices" {
+			extras = append(extras, release.PackageFile{Dest: path.Join("/usr/share/doc", name, a.Name), Source: filepath.Join(outputDir, filepath.FromSlash(a.Path)), Mode: 0644})
+		}
+	}
+
+	mtime, err := info.fileDate()
+	if err != nil {
+		return nil, err
+	}
+
+	byArch := make(map[string][]artifactEntry)
+	for _, b := range binaries {
+		if b.Kind == "binary" && b.GOOS == "linux" {
+			byArch[b.GOARCH] = append(byArch[b.GOARCH], b)
+		}
+	}
+	// Package architectures have no notion of GOARM/GOAMD64 levels, so each
+	// GOARCH must come from a single variant
+	for goarch, bins := range byArch {
+		for _, b := range bins[1:] {
+			if b.Variant != bins[0].Variant {
+				return nil, fmt.Errorf("cannot package linux/%s: built for several variants (%q, %q); --exclude all but one", goarch, bins[0].Variant, b.Variant)
+			}
+		}
+	}
+	if len(byArch) == 0 {
+		return nil, fmt.Errorf("no linux binaries to package (add linux to --os)")
+	}
+	arches := make([]string, 0, len(byArch))
+	for a := range byArch {
+		arches = append(arches, a)
+	}
+	sort.Strings(arches)
+
+	var packages []artifactEntry
+	for _, goarch := range arches {
+		bins := byArch[goarch]
+		files := append([]release.PackageFile(nil), extras...)
+		for _, b := range bins {
+			files = append(files, release.PackageFile{
+				Dest:   path.Join(binDir, b.Name),
+				Source: filepath.Join(outputDir, filepath.FromSlash(b.Path)),
+				Mode:   0755,
+			})
+		}
+		pkgInfo := release.PackageInfo{
+			Name:        name,
+			Version:     info.version,
+			GOARCH:      goarch,
+			Variant:     bins[0].Variant,
+			Maintainer:  pc.Maintainer,
+			Description: pc.Description,
+			Homepage:    pc.Homepage,
+			License:     pc.License,
+			Depends:     pc.Depends,
+		}
+
+		for _, format := range formats {
+			fileName, err := release.PackageFileName(format, pkgInfo)
+			if err != nil {
+				return nil, err
+			}
+			pkgPath := filepath.Join(outputDir, fileName)
+			if err := release.WritePackage(pkgPath, format, pkgInfo, files, mtime); err != nil {
+				return nil, err
+			}
+
+			entry := artifactEntry{Kind: "package", Name: name, GOOS: "linux", GOARCH: goarch, Variant: bins[0].Variant}
+			for _, f := range files {
+				entry.Files = append(entry.Files, f.Dest)
+			}
+			sort.Strings(entry.Files)
+			if err := entry.record(outputDir, pkgPath, info, bins[0].GoVersion); err != nil {
+				return nil, err
+			}
+			packages = append(packages, entry)
+		}
+	}
+	return packages, nil
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestPackageLinux(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOutput := outputDir
+	outputDir = "dist"
+	defer func() { outputDir = oldOutput }()
+
+	os.MkdirAll("dist", 0755)
+	os.WriteFile("tool.conf", []byte("key=value"), 0644)
+	os.WriteFile("dist/app_linux_amd64", []byte("linux"), 0755)
+	os.WriteFile("dist/app_darwin_amd64", []byte("darwin"), 0755)
+	binaries := []artifactEntry{
+		{Kind: "binary", Name: "app", Path: "app_linux_amd64", GOOS: "linux", GOARCH: "amd64"},
+		{Kind: "binary", Name: "app", Path: "app_darwin_amd64", GOOS: "darwin", GOARCH: "amd64"},
+		{Kind: "archive", Name: "app", Path: "app_linux_amd64.tar.gz", GOOS: "linux", GOARCH: "amd64"},
+	}
+	cfg := config.Release{Packages: config.Packages{
+		Name:  "tool",
+		Files: []config.PackageFile{{Source: "tool.conf", Dest: "/etc/tool.conf"}},
+	}}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	pkgs, err := packageLinux(runner.NewMock(), cfg, []string{"deb", "rpm", "apk"}, binaries, gitInfo{version: "v1.0.0"})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(pkgs))
+
+	assert.Equal(t, "tool_1.0.0_amd64.deb", pkgs[0].Path)
+	assert.Equal(t, "tool-1.0.0-1.x86_64.rpm", pkgs[1].Path)
+	assert.Equal(t, "tool-1.0.0-r0.x86_64.apk", pkgs[2].Path)
+	for _, p := range pkgs {
+		assert.Equal(t, "package", p.Kind)
+		assert.Equal(t, []string{"/etc/tool.conf", "/usr/bin/app"}, p.Files)
+		assert.NotEqual(t, "", p.SHA256)
+	}
+}
+
+func TestPackageLinuxInvalidSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	binaries := []artifactEntry{{Kind: "binary", Name: "app", GOOS: "linux", GOARCH: "amd64"}}
+	_, err := packageLinux(runner.NewMock(), config.Release{Name: "tool"}, []string{"deb"}, binaries, gitInfo{})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "SOURCE_DATE_EPOCH")
+}
+
+func TestPackageLinuxNoLinuxBinaries(t *testing.T) {
+	binaries := []artifactEntry{{Kind: "binary", Name: "app", GOOS: "darwin", GOARCH: "arm64"}}
+	_, err := packageLinux(runner.NewMock(), config.Release{Name: "tool"}, []string{"deb"}, binaries, gitInfo{})
+	assert.NotNil(t, err)
+}
+
+func TestRunReleaseWithRunnerPackages(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	oldOutput := outputDir
+	oldPackages := matrixPackages
+	matrixOS = []string{"linux", "darwin"}
+	matrixArch = []string{"amd64", "arm64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	matrixPackages = []string{"deb", "rpm"}
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+		outputDir = oldOutput
+		matrixPackages = oldPackages
+	}()
+
+	mock := newTestPassMock(0)
+	require.NoError(t, runReleaseWithRunner(mock))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+
+	kinds := map[string]int{}
+	for _, a := range m.Artifacts {
+		kinds[a.Kind]++
+	}
+	assert.Equal(t, 4, kinds["binary"])
+	assert.Equal(t, 4, kinds["package"])
+}
+
+func TestRunReleaseWithRunnerUnknownPackageFormat(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldPackages := matrixPackages
+	matrixPackages = []string{"msi"}
+	defer func() { matrixPackages = oldPackages }()
+
+	err := runReleaseWithRunner(newTestPassMock(0))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "msi")
+}
+
+func TestPackageLinuxRejectsMixedVariants(t *testing.T) {
+	binaries := []artifactEntry{
+		{Kind: "binary", Name: "app", GOOS: "linux", GOARCH: "arm", Variant: "6"},
+		{Kind: "binary", Name: "app", GOOS: "linux", GOARCH: "arm", Variant: "7"},
+	}
+	_, err := packageLinux(runner.NewMock(), config.Release{Name: "tool"}, []string{"deb"}, binaries, gitInfo{})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "linux/arm")
+}
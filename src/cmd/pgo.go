@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	pprof "github.com/google/pprof/profile"
+	"github.com/spf13/cobra"
+	"github.com/wow-look-at-my/go-toolchain/src/bench"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/profile"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+var (
+	pgoBench     string
+	pgoBenchTime string
+	pgoCount     int
+	pgoTargets   []string
+	pgoKeep      bool
+)
+
+var pgoCmd = &cobra.Command{
+	Use:   "pgo",
+	Short: "Manage profile-guided optimization profiles",
+	Long: `Maintain a default.pgo CPU profile in each main package directory. The
+default build, matrix and verify-reproducible pass it to go build with -pgo.
+
+Subcommands: collect, merge, compare`,
+}
+
+var pgoCollectCmd = &cobra.Command{
+	Use:   "collect [packages]",
+	Short: "Write each binary's default.pgo from benchmark CPU profiles",
+	Long: `Run the matching benchmarks with CPU profiling and write each binary's
+default.pgo from the profiles of the benchmarks in the packages it is built
+from. Binaries none of whose packages have matching benchmarks are left alone.`,
+	SilenceUsage: true,
+	RunE:         runPGOCollect,
+}
+
+var pgoMergeCmd = &cobra.Command{
+	Use:   "merge <profile>...",
+	Short: "Merge CPU profiles, e.g. from production, into a binary's default.pgo",
+	Long: `Merge CPU profiles, such as ones downloaded from a service's
+/debug/pprof/profile endpoint, into a binary's default.pgo. With several
+binaries, pick them with --target. The merged profile replaces the existing
+default.pgo unless --keep is given.`,
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runPGOMerge,
+}
+
+var pgoCompareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Show the benchmark delta with and without each binary's default.pgo",
+	Long: `Run the benchmarks in the packages each binary with a default.pgo is
+built from twice, with -pgo=off and with its profile, and compare the results.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runPGOCompare,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pgoCollectCmd, pgoCompareCmd} {
+		cmd.Flags().StringVar(&pgoBench, "bench", "", "Run only benchmarks matching this regexp (go test -bench; default .)")
+		cmd.Flags().StringVar(&pgoBenchTime, "benchtime", "", "Duration or count for each benchmark (e.g. 5s, 1000x)")
+	}
+	pgoCollectCmd.Flags().IntVarP(&pgoCount, "count", "n", 1, "Number of times to run each benchmark")
+	pgoCompareCmd.Flags().IntVarP(&pgoCount, "count", "n", 6, "Number of times to run each benchmark with and without PGO")
+	for _, cmd := range []*cobra.Command{pgoCollectCmd, pgoMergeCmd, pgoCompareCmd} {
+		cmd.Flags().StringArrayVar(&pgoTargets, "target", nil, "Only this binary, by output name (repeatable; default: all)")
+	}
+	pgoMergeCmd.Flags().BoolVar(&pgoKeep, "keep", false, "Merge into the existing default.pgo instead of replacing it")
+
+	pgoCmd.AddCommand(pgoCollectCmd, pgoMergeCmd, pgoCompareCmd)
+	rootCmd.AddCommand(pgoCmd)
+}
+
+func runPGOCollect(cmd *cobra.Command, args []string) error {
+	return runPGOCollectWithRunner(runner.New(), args)
+}
+
+func runPGOCollectWithRunner(r runner.CommandRunner, args []string) error {
+	targets, dirs, err := pgoBuildTargets(r)
+	if err != nil {
+		return err
+	}
+
+	if !jsonOutput {
+		fmt.Println("==> Collecting CPU profiles from benchmarks")
+	}
+	byPackage, err := profile.CollectPackages(r, profile.Options{
+		Bench:    pgoBench,
+		Time:     pgoBenchTime,
+		Count:    pgoCount,
+		Packages: args,
+		Quiet:    true,
+	})
+	if err != nil {
+		return fmt.Errorf("profiling failed: %w", err)
+	}
+
+	written := make(map[string]string)
+	for _, t := range targets {
+		deps, err := build.ModuleDependencies(r, t)
+		if err != nil {
+			return err
+		}
+		var parts []*pprof.Profile
+		var used []string
+		for _, pkg := range deps {
+			if p, ok := byPackage[pkg]["cpu"]; ok {
+				parts = append(parts, p)
+				used = append(used, pkg)
+			}
+		}
+		if len(parts) == 0 {
+			if !jsonOutput {
+				fmt.Printf("==> %s: none of its packages have matching benchmarks, skipped\n", t.OutputName)
+			}
+			continue
+		}
+		merged, err := pprof.Merge(parts)
+		if err != nil {
+			return fmt.Errorf("failed to merge profiles for %s: %w", t.OutputName, err)
+		}
+		path := filepath.Join(dirs[t.ImportPath], build.PGOFile)
+		if err := profile.Save(merged, path); err != nil {
+			return err
+		}
+		written[t.OutputName] = path
+		if !jsonOutput {
+			fmt.Printf("==> %s: wrote %s from the benchmarks of %s\n", t.OutputName, path, strings.Join(used, ", "))
+		}
+	}
+	if len(written) == 0 {
+		return fmt.Errorf("no binary is built from a package with matching benchmarks")
+	}
+	if jsonOutput {
+		return printJSON(written)
+	}
+	return nil
+}
+
+func runPGOMerge(cmd *cobra.Command, args []string) error {
+	return runPGOMergeWithRunner(runner.New(), args)
+}
+
+func runPGOMergeWithRunner(r runner.CommandRunner, args []string) error {
+	var profiles []*pprof.Profile
+	for _, path := range args {
+		p, err := profile.Load(path)
+		if err != nil {
+			return err
+		}
+		if !isCPUProfile(p) {
+			return fmt.Errorf("%s is not a CPU profile; PGO needs one", path)
+		}
+		profiles = append(profiles, p)
+	}
+
+	targets, dirs, err := pgoBuildTargets(r)
+	if err != nil {
+		return err
+	}
+	if len(targets) > 1 && len(pgoTargets) == 0 {
+		var names []string
+		for _, t := range targets {
+			names = append(names, t.OutputName)
+		}
+		return fmt.Errorf("there are several binaries (%s); pick the one the profiles come from with --target", strings.Join(names, ", "))
+	}
+
+	written := make(map[string]string)
+	for _, t := range targets {
+		path := filepath.Join(dirs[t.ImportPath], build.PGOFile)
+		parts := profiles
+		if pgoKeep {
+			if existing, err := profile.Load(path); err == nil {
+				parts = append([]*pprof.Profile{existing}, profiles...)
+			}
+		}
+		merged, err := pprof.Merge(parts)
+		if err != nil {
+			return fmt.Errorf("failed to merge profiles for %s: %w", t.OutputName, err)
+		}
+		if err := profile.Save(merged, path); err != nil {
+			return err
+		}
+		written[t.OutputName] = path
+		if !jsonOutput {
+			fmt.Printf("==> %s: wrote %s from %d profile(s)\n", t.OutputName, path, len(parts))
+		}
+	}
+	if jsonOutput {
+		return printJSON(written)
+	}
+	return nil
+}
+
+// isCPUProfile reports whether a profile has the cpu sample type go build
+// -pgo needs.
+func isCPUProfile(p *pprof.Profile) bool {
+	return slices.ContainsFunc(p.SampleType, func(st *pprof.ValueType) bool {
+		return st.Type == "cpu" && st.Unit == "nanoseconds"
+	})
+}
+
+func runPGOCompare(cmd *cobra.Command, args []string) error {
+	return runPGOCompareWithRunner(runner.New())
+}
+
+func runPGOCompareWithRunner(r runner.CommandRunner) error {
+	targets, _, err := pgoBuildTargets(r)
+	if err != nil {
+		return err
+	}
+	if targets, err = build.ApplyPGO(r, targets); err != nil {
+		return err
+	}
+
+	comparisons := make(map[string]*bench.Comparison)
+	for _, t := range targets {
+		if t.PGO == "" {
+			continue
+		}
+		deps, err := build.ModuleDependencies(r, t)
+		if err != nil {
+			return err
+		}
+		if !jsonOutput {
+			fmt.Printf("==> %s: running benchmarks without and with %s\n", t.OutputName, t.PGO)
+		}
+		opts := bench.Options{
+			Time:     pgoBenchTime,
+			Count:    pgoCount,
+			Verbose:  verbose,
+			Packages: deps,
+			Bench:    pgoBench,
+			PGO:      "off",
+		}
+		without, err := bench.RunBenchmarks(r, opts)
+		if err != nil {
+			return err
+		}
+		opts.PGO = t.PGO
+		with, err := bench.RunBenchmarks(r, opts)
+		if err != nil {
+			return err
+		}
+		if !with.HasResults() {
+			if !jsonOutput {
+				fmt.Printf("==> %s: none of its packages have matching benchmarks\n", t.OutputName)
+			}
+			continue
+		}
+
+		comp := bench.Compare(with, without)
+		comp.PreviousCommit = "-pgo=off"
+		comparisons[t.OutputName] = comp
+		if !jsonOutput {
+			fmt.Printf("\n==> %s: with PGO vs without\n", t.OutputName)
+			comp.Print()
+		}
+	}
+	if len(comparisons) == 0 && !jsonOutput {
+		fmt.Printf("==> No binary has a %s with benchmarks to compare (run: go-toolchain pgo collect)\n", build.PGOFile)
+	}
+	if jsonOutput {
+		return printJSON(comparisons)
+	}
+	return nil
+}
+
+// pgoBuildTargets returns the main packages to manage profiles for, as
+// built by the default build, and their directories; --target limits them.
+func pgoBuildTargets(r runner.CommandRunner) ([]build.Target, map[string]string, error) {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := build.ResolveBuildTargets(r, cfg.Build)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range pgoTargets {
+		if !slices.ContainsFunc(all, func(t build.Target) bool { return t.OutputName == name }) {
+			return nil, nil, fmt.Errorf("no binary named %q", name)
+		}
+	}
+
+	paths := make([]string, len(all))
+	for i, t := range all {
+		paths[i] = t.ImportPath
+	}
+	dirs, err := build.MainPackageDirs(r, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	var targets []build.Target
+	for _, t := range all {
+		if _, ok := dirs[t.ImportPath]; !ok {
+			continue
+		}
+		if len(pgoTargets) > 0 && !slices.Contains(pgoTargets, t.OutputName) {
+			continue
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("no main packages found to optimize")
+	}
+	return targets, dirs, nil
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/profile"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// pgoMock simulates a module ex with binaries app (built from ex/pkg) and
+// tool (built from nothing with benchmarks) in dir. go test writes a CPU
+// profile in ex/pkg.Work and reports BenchmarkWork 25% faster with -pgo.
+func pgoMock(t *testing.T, dir string) *runner.Mock {
+	mock := runner.NewMock()
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		switch {
+		case cfg.IsCmd("go", "list", "-m"):
+			return runner.MockProcess([]byte("ex\n"), nil), nil
+		case cfg.IsCmd("go", "list", "-deps"):
+			if cfg.HasArg("ex/cmd/app") {
+				return runner.MockProcess([]byte("ex/pkg\nex/cmd/app\n"), nil), nil
+			}
+			return runner.MockProcess([]byte("ex/cmd/tool\n"), nil), nil
+		case cfg.IsCmd("go", "list", "-f") && cfg.HasArg("./..."):
+			return runner.MockProcess([]byte("ex/cmd/app\nex/cmd/tool\n"), nil), nil
+		case cfg.IsCmd("go", "list", "-f"):
+			return runner.MockProcess([]byte(fmt.Sprintf("ex/cmd/app\t%s\nex/cmd/tool\t%s\n",
+				filepath.Join(dir, "cmd", "app"), filepath.Join(dir, "cmd", "tool"))), nil), nil
+		case cfg.HasArg("-list"):
+			return runner.MockProcess([]byte(`{"Action":"output","Package":"ex/pkg","Output":"BenchmarkWork\n"}`+"\n"), nil), nil
+		case cfg.HasArg("-cpuprofile"):
+			for i, arg := range cfg.Args {
+				if arg == "-cpuprofile" {
+					writeTestProfile(t, cfg.Args[i+1], "cpu", "nanoseconds", "ex/pkg.Work", 1e9)
+				}
+			}
+		case cfg.HasArg("-pgo"):
+			ns := 750
+			if cfg.HasArg("off") {
+				ns = 1000
+			}
+			var out strings.Builder
+			for i := range 6 {
+				fmt.Fprintf(&out, `{"Action":"output","Package":"ex/pkg","Output":"BenchmarkWork-8   \t 1000\t  %d ns/op\t  0 B/op\t  0 allocs/op\n"}`+"\n", ns+i)
+			}
+			return runner.MockProcess([]byte(out.String()), nil), nil
+		}
+		return nil, nil
+	}
+	return mock
+}
+
+// setupPGOTest creates the mock module's main package dirs and resets the
+// pgo flags.
+func setupPGOTest(t *testing.T) string {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, name := range []string{"app", "tool"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "cmd", name), 0755))
+	}
+	oldBench, oldTime, oldCount, oldTargets, oldKeep, oldJSON := pgoBench, pgoBenchTime, pgoCount, pgoTargets, pgoKeep, jsonOutput
+	t.Cleanup(func() {
+		pgoBench, pgoBenchTime, pgoCount, pgoTargets, pgoKeep, jsonOutput = oldBench, oldTime, oldCount, oldTargets, oldKeep, oldJSON
+	})
+	pgoBench, pgoBenchTime, pgoCount, pgoTargets, pgoKeep, jsonOutput = "", "", 1, nil, false, false
+	return dir
+}
+
+func TestRunPGOCollect(t *testing.T) {
+	dir := setupPGOTest(t)
+	pgoBench = "Work"
+	mock := pgoMock(t, dir)
+
+	output := captureStdout(t, func() error { return runPGOCollectWithRunner(mock, []string{}) })
+	assert.Contains(t, output, "==> app: wrote "+filepath.Join("cmd", "app", "default.pgo")+" from the benchmarks of ex/pkg")
+	assert.Contains(t, output, "==> tool: none of its packages have matching benchmarks, skipped")
+	assert.NoFileExists(t, filepath.Join(dir, "cmd", "tool", "default.pgo"))
+
+	p, err := profile.Load(filepath.Join(dir, "cmd", "app", "default.pgo"))
+	require.NoError(t, err)
+	assert.Equal(t, "ex/pkg.Work", profile.Top(p, 1).Functions[0].Name)
+
+	for _, c := range benchRuns(mock) {
+		assert.True(t, c.HasArg("Work"))
+	}
+
+	pgoTargets = []string{"tool"}
+	err = runPGOCollectWithRunner(mock, []string{})
+	assert.ErrorContains(t, err, "no binary is built from a package with matching benchmarks")
+
+	pgoTargets = []string{"missing"}
+	assert.ErrorContains(t, runPGOCollectWithRunner(mock, []string{}), `no binary named "missing"`)
+}
+
+func TestRunPGOMerge(t *testing.T) {
+	dir := setupPGOTest(t)
+	mock := pgoMock(t, dir)
+	prod1, prod2 := filepath.Join(dir, "prod1.pprof"), filepath.Join(dir, "prod2.pprof")
+	writeTestProfile(t, prod1, "cpu", "nanoseconds", "ex/pkg.Handle", 1e9)
+	writeTestProfile(t, prod2, "cpu", "nanoseconds", "ex/pkg.Handle", 2e9)
+
+	assert.ErrorContains(t, runPGOMergeWithRunner(mock, []string{prod1}), "several binaries (app, tool)")
+
+	pgoTargets = []string{"app"}
+	output := captureStdout(t, func() error { return runPGOMergeWithRunner(mock, []string{prod1, prod2}) })
+	pgoFile := filepath.Join(dir, "cmd", "app", "default.pgo")
+	assert.Contains(t, output, "==> app: wrote "+filepath.Join("cmd", "app", "default.pgo")+" from 2 profile(s)")
+	p, err := profile.Load(pgoFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3e9), profile.Top(p, 1).Total)
+
+	// --keep merges into the existing profile
+	pgoKeep = true
+	captureStdout(t, func() error { return runPGOMergeWithRunner(mock, []string{prod1}) })
+	p, err = profile.Load(pgoFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4e9), profile.Top(p, 1).Total)
+
+	mem := filepath.Join(dir, "mem.pprof")
+	writeTestProfile(t, mem, "alloc_space", "bytes", "ex/pkg.Handle", 1024)
+	assert.ErrorContains(t, runPGOMergeWithRunner(mock, []string{mem}), "is not a CPU profile")
+	assert.Error(t, runPGOMergeWithRunner(mock, []string{filepath.Join(dir, "missing.pprof")}))
+}
+
+func TestRunPGOCompare(t *testing.T) {
+	dir := setupPGOTest(t)
+	pgoCount = 6
+	mock := pgoMock(t, dir)
+
+	output := captureStdout(t, func() error { return runPGOCompareWithRunner(mock) })
+	assert.Contains(t, output, "No binary has a default.pgo")
+
+	writeTestProfile(t, filepath.Join(dir, "cmd", "app", "default.pgo"), "cpu", "nanoseconds", "ex/pkg.Work", 1e9)
+	output = captureStdout(t, func() error { return runPGOCompareWithRunner(mock) })
+	assert.Contains(t, output, "==> app: with PGO vs without")
+	assert.Contains(t, output, "-24.9% (p=0.002 n=6)")
+	assert.NotContains(t, output, "tool")
+
+	var pgoArgs []string
+	for _, c := range benchRuns(mock) {
+		assert.True(t, c.HasArg("ex/pkg"))
+		assert.True(t, c.HasArg("ex/cmd/app"))
+		for i, arg := range c.Args {
+			if arg == "-pgo" {
+				pgoArgs = append(pgoArgs, c.Args[i+1])
+			}
+		}
+	}
+	assert.Equal(t, []string{"off", filepath.Join("cmd", "app", "default.pgo")}, pgoArgs)
+
+	jsonOutput = true
+	output = captureStdout(t, func() error { return runPGOCompareWithRunner(mock) })
+	assert.Contains(t, output, `"app"`)
+	assert.NotContains(t, output, "==>")
+}
@@ -5,8 +5,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
+	pprof "github.com/google/pprof/profile"
 	"github.com/spf13/cobra"
+	"github.com/wow-look-at-my/go-toolchain/src/bench"
+	"github.com/wow-look-at-my/go-toolchain/src/profile"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
@@ -14,34 +18,64 @@ var (
 	profileOutput  string
 	profileWeb     bool
 	profileNoPprof bool
+	profilePprof   bool
 	profileType    string
+	profileTop     int
 )
 
 var profileCmd = &cobra.Command{
-	Use:   "profile [package]",
-	Short: "Run benchmarks with CPU profiling",
-	Long: `Run benchmarks with pprof CPU profiling enabled.
+	Use:   "profile [packages]",
+	Short: "Run benchmarks with profiling and show the hottest functions",
+	Long: `Run benchmarks with pprof profiling enabled and print the hottest
+functions of each profile.
 
-The profiler samples the call stack at regular intervals to identify
-hot code paths. Output is written to a .pprof file that can be analyzed
-with 'go tool pprof'.
+Several profile types can be collected in one run. Each package with
+matching benchmarks is profiled on its own and the profiles are merged,
+then written to .pprof files that can be analyzed with 'go tool pprof'.
 
 Examples:
-  go-toolchain profile                  # Profile and open pprof
-  go-toolchain profile ./pkg/...        # Profile specific package
-  go-toolchain profile --web            # Open pprof web UI instead
-  go-toolchain profile --no-pprof       # Just write profile, don't open pprof`,
+  go-toolchain profile                          # CPU profile, top 10 functions
+  go-toolchain profile --type cpu,mem ./pkg/... # CPU and memory profiles
+  go-toolchain profile --bench Parse --top 20   # Only BenchmarkParse*
+  go-toolchain profile --pprof                  # Open interactive pprof afterwards
+  go-toolchain profile --web                    # Open the pprof web UI afterwards
+  go-toolchain profile diff v1.2.0 HEAD         # Which functions got hotter`,
 	SilenceUsage: true,
 	RunE:         runProfile,
 }
 
+var profileDiffCmd = &cobra.Command{
+	Use:   "diff <base> <new> [packages]",
+	Short: "Show which functions got hotter between two profiles or commits",
+	Long: `Compare two profiles and list the functions whose share of the total
+grew or shrank the most. Each side is a .pprof file or a commit; commits
+are checked out into a temporary worktree and profiled there.
+
+Shares are compared rather than raw values because a benchmark run for a
+fixed time does more iterations as it gets faster. Use a fixed
+--benchtime count (e.g. 1000x) to profile both commits for the same work.`,
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(2),
+	RunE:         runProfileDiff,
+}
+
 func init() {
-	profileCmd.Flags().StringVarP(&profileOutput, "output", "o", "", "Output file (default: profile_cpu.pprof)")
-	profileCmd.Flags().BoolVar(&profileWeb, "web", false, "Open pprof web UI instead of interactive mode")
+	for _, cmd := range []*cobra.Command{profileCmd, profileDiffCmd} {
+		cmd.Flags().StringVar(&benchFilter, "bench", "", "Run only benchmarks matching this regexp (go test -bench; default .)")
+		cmd.Flags().StringVar(&benchTime, "benchtime", "3s", "Duration or count for each benchmark")
+		cmd.Flags().IntVarP(&benchCount, "count", "n", 1, "Number of times to run each benchmark")
+		cmd.Flags().IntVar(&profileTop, "top", 10, "Number of functions to show (0 for all)")
+	}
+	profileCmd.Flags().StringVar(&profileType, "type", "cpu", "Profile types, comma-separated: cpu, mem, mutex, block")
+	profileDiffCmd.Flags().StringVar(&profileType, "type", "cpu", "Profile type to compare when profiling commits: cpu, mem, mutex or block")
+
+	profileCmd.Flags().StringVarP(&profileOutput, "output", "o", "", "Output file (default: profile_<type>.pprof; with several types, _<type> is added to the name)")
+	profileCmd.Flags().BoolVar(&profilePprof, "pprof", false, "Open interactive pprof afterwards (one profile type only)")
+	profileCmd.Flags().BoolVar(&profileWeb, "web", false, "Open the pprof web UI afterwards")
 	profileCmd.Flags().BoolVar(&profileNoPprof, "no-pprof", false, "Don't open pprof after profiling")
-	profileCmd.Flags().StringVar(&profileType, "type", "cpu", "Profile type: cpu, mem, mutex, block")
-	profileCmd.Flags().StringVar(&benchTime, "benchtime", "3s", "Duration for each benchmark")
-	profileCmd.Flags().IntVarP(&benchCount, "count", "n", 1, "Number of times to run each benchmark")
+	profileCmd.Flags().MarkDeprecated("no-pprof", "pprof is only opened with --pprof or --web")
+
+	profileCmd.AddCommand(profileDiffCmd)
 	rootCmd.AddCommand(profileCmd)
 }
 
@@ -51,91 +85,158 @@ func runProfile(cmd *cobra.Command, args []string) error {
 }
 
 func runProfileWithRunner(r runner.CommandRunner, args []string) error {
-	// Determine output file
-	outFile := profileOutput
-	if outFile == "" {
-		outFile = fmt.Sprintf("profile_%s.pprof", profileType)
-	}
-
-	// Ensure absolute path for profile output
-	absOut, err := filepath.Abs(outFile)
+	types, err := profile.ParseTypes(profileType)
 	if err != nil {
-		return fmt.Errorf("failed to resolve output path: %w", err)
+		return err
 	}
-
-	// Build go test args for profiling
-	goTestArgs := []string{"test", "-run", "^$", "-bench", "."}
-
-	// Add profile flag based on type
-	switch profileType {
-	case "cpu":
-		goTestArgs = append(goTestArgs, "-cpuprofile", absOut)
-	case "mem":
-		goTestArgs = append(goTestArgs, "-memprofile", absOut)
-	case "mutex":
-		goTestArgs = append(goTestArgs, "-mutexprofile", absOut)
-	case "block":
-		goTestArgs = append(goTestArgs, "-blockprofile", absOut)
-	default:
-		return fmt.Errorf("unknown profile type %q (use: cpu, mem, mutex, block)", profileType)
+	if profilePprof && len(types) > 1 {
+		return fmt.Errorf("--pprof opens one profile; pick one type or use --web")
 	}
 
-	if benchTime != "" {
-		goTestArgs = append(goTestArgs, "-benchtime", benchTime)
-	}
-	if benchCount > 1 {
-		goTestArgs = append(goTestArgs, "-count", fmt.Sprintf("%d", benchCount))
+	// Resolve the output paths up front so a bad one fails before running
+	outputs := make(map[string]string)
+	for _, t := range types {
+		abs, err := filepath.Abs(profileOutputPath(t, len(types) > 1))
+		if err != nil {
+			return fmt.Errorf("failed to resolve output path: %w", err)
+		}
+		outputs[t] = abs
 	}
 
-	// Package target
 	target := "./..."
 	if len(args) > 0 {
-		target = args[0]
+		target = strings.Join(args, " ")
+	}
+	if !jsonOutput {
+		fmt.Printf("==> Running %s profiling on %s\n", strings.Join(types, ", "), target)
 	}
-	goTestArgs = append(goTestArgs, target)
-
-	fmt.Printf("==> Running %s profiling on %s\n", profileType, target)
-	fmt.Printf("==> Output: %s\n", absOut)
 
-	proc, err := runner.Cmd("go", goTestArgs...).Run(r)
+	profiles, err := profile.Collect(r, profile.Options{
+		Types:    types,
+		Bench:    benchFilter,
+		Time:     benchTime,
+		Count:    benchCount,
+		Packages: args,
+		Quiet:    jsonOutput,
+	})
 	if err != nil {
 		return fmt.Errorf("profiling failed: %w", err)
 	}
-	if err := proc.Wait(); err != nil {
-		return fmt.Errorf("profiling failed: %w", err)
-	}
 
-	// Verify output was written
-	if _, statErr := os.Stat(absOut); statErr != nil {
-		return fmt.Errorf("profile output not created (no benchmarks found?)")
+	reports := make(map[string]*profile.Report)
+	for _, t := range types {
+		if err := profile.Save(profiles[t], outputs[t]); err != nil {
+			return err
+		}
+		reports[t] = profile.Top(profiles[t], profileTop)
+	}
+	if jsonOutput {
+		return printJSON(reports)
 	}
 
-	fmt.Printf("==> Profile written to %s\n", absOut)
-
-	if profileNoPprof {
-		fmt.Printf("\nAnalyze with:\n")
-		fmt.Printf("  go tool pprof %s\n", absOut)
-		fmt.Printf("  go tool pprof -http=: %s\n", absOut)
-		return nil
+	for _, t := range types {
+		report := reports[t]
+		fmt.Printf("\n==> %s profile: %s %s, written to %s\n", t, profile.FormatValue(report.Total, report.Unit), report.SampleType, outputs[t])
+		report.Print()
 	}
 
 	if profileWeb {
-		fmt.Println("==> Opening pprof web UI...")
-		pprofCmd := exec.Command("go", "tool", "pprof", "-http=:", absOut)
-		pprofCmd.Stdout = os.Stdout
-		pprofCmd.Stderr = os.Stderr
-		if err := pprofCmd.Start(); err != nil {
-			return fmt.Errorf("failed to start pprof: %w", err)
+		for _, t := range types {
+			fmt.Printf("==> Opening pprof web UI for the %s profile...\n", t)
+			pprofCmd := exec.Command("go", "tool", "pprof", "-http=:", outputs[t])
+			pprofCmd.Stdout = os.Stdout
+			pprofCmd.Stderr = os.Stderr
+			if err := pprofCmd.Start(); err != nil {
+				return fmt.Errorf("failed to start pprof: %w", err)
+			}
+			fmt.Printf("==> pprof running (PID %d)\n", pprofCmd.Process.Pid)
 		}
-		fmt.Printf("==> pprof running (PID %d)\n", pprofCmd.Process.Pid)
 		return nil
 	}
+	if profilePprof {
+		fmt.Println("==> Opening pprof...")
+		pprofCmd := exec.Command("go", "tool", "pprof", outputs[types[0]])
+		pprofCmd.Stdin = os.Stdin
+		pprofCmd.Stdout = os.Stdout
+		pprofCmd.Stderr = os.Stderr
+		return pprofCmd.Run()
+	}
+	return nil
+}
 
-	// Default: run pprof interactively
-	fmt.Println("==> Opening pprof...")
-	pprofCmd := exec.Command("go", "tool", "pprof", absOut)
-	pprofCmd.Stdin = os.Stdin
-	pprofCmd.Stdout = os.Stdout
-	pprofCmd.Stderr = os.Stderr
-	return pprofCmd.Run()
+// profileOutputPath returns where to write a profile type: --output, with
+// the type added before the extension when several types are written.
+func profileOutputPath(profileType string, several bool) string {
+	if profileOutput == "" {
+		return fmt.Sprintf("profile_%s.pprof", profileType)
+	}
+	if !several {
+		return profileOutput
+	}
+	ext := filepath.Ext(profileOutput)
+	return strings.TrimSuffix(profileOutput, ext) + "_" + profileType + ext
+}
+
+func runProfileDiff(cmd *cobra.Command, args []string) error {
+	return runProfileDiffWithRunner(runner.New(), args)
+}
+
+func runProfileDiffWithRunner(r runner.CommandRunner, args []string) error {
+	types, err := profile.ParseTypes(profileType)
+	if err != nil {
+		return err
+	}
+	if len(types) != 1 {
+		return fmt.Errorf("profile diff compares one profile type at a time")
+	}
+
+	wt := bench.NewWorktree(r)
+	defer wt.Close()
+	opts := profile.Options{
+		Types:    types,
+		Bench:    benchFilter,
+		Time:     benchTime,
+		Count:    benchCount,
+		Packages: args[2:],
+		Quiet:    true,
+	}
+	base, err := loadProfileForDiff(r, wt, args[0], opts)
+	if err != nil {
+		return err
+	}
+	current, err := loadProfileForDiff(r, wt, args[1], opts)
+	if err != nil {
+		return err
+	}
+
+	comp, err := profile.Diff(base, current, profileTop)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(comp)
+	}
+	fmt.Printf("==> Profile diff (%s): %s → %s\n", comp.SampleType, args[0], args[1])
+	comp.Print()
+	return nil
+}
+
+// loadProfileForDiff reads arg as a profile file, or else checks it out
+// as a commit and profiles it.
+func loadProfileForDiff(r runner.CommandRunner, wt *bench.Worktree, arg string, opts profile.Options) (*pprof.Profile, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		return profile.Load(arg)
+	}
+	if !jsonOutput {
+		fmt.Printf("==> Profiling %s\n", arg)
+	}
+	if err := wt.Checkout(arg); err != nil {
+		return nil, fmt.Errorf("%s is neither a profile nor a commit: %w", arg, err)
+	}
+	opts.Dir = wt.Dir
+	profiles, err := profile.Collect(r, opts)
+	if err != nil {
+		return nil, fmt.Errorf("profiling %s failed: %w", arg, err)
+	}
+	return profiles[opts.Types[0]], nil
 }
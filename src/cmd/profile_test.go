@@ -3,315 +3,212 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	pprof "github.com/google/pprof/profile"
 	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 )
 
-func TestRunProfileUnknownType(t *testing.T) {
+// profileFlagUnits maps the go test profile flags to a sample type.
+var profileFlagUnits = map[string][2]string{
+	"-cpuprofile":   {"cpu", "nanoseconds"},
+	"-memprofile":   {"alloc_space", "bytes"},
+	"-mutexprofile": {"delay", "nanoseconds"},
+	"-blockprofile": {"delay", "nanoseconds"},
+}
+
+// writeTestProfile writes a profile with a single sample in fn.
+func writeTestProfile(t *testing.T, path, sampleType, unit, fn string, v int64) {
+	t.Helper()
+	f := &pprof.Function{ID: 1, Name: fn}
+	loc := &pprof.Location{ID: 1, Line: []pprof.Line{{Function: f}}}
+	p := &pprof.Profile{
+		SampleType: []*pprof.ValueType{{Type: sampleType, Unit: unit}},
+		Sample:     []*pprof.Sample{{Location: []*pprof.Location{loc}, Value: []int64{v}}},
+		Location:   []*pprof.Location{loc},
+		Function:   []*pprof.Function{f},
+	}
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, p.Write(out))
+	require.NoError(t, out.Close())
+}
+
+// profileMock simulates a module whose package ex/pkg has benchmarks: go
+// test writes every profile it's asked for, with the hot function named
+// after the checked out worktree (or "main").
+func profileMock(t *testing.T) *runner.Mock {
 	mock := runner.NewMock()
+	checkout := "main"
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		switch {
+		case cfg.IsCmd("git", "worktree", "add"), cfg.IsCmd("git", "-C") && cfg.HasArg("checkout"):
+			checkout = cfg.Args[len(cfg.Args)-1]
+		case cfg.HasArg("-list"):
+			return runner.MockProcess([]byte(`{"Action":"output","Package":"ex/pkg","Output":"BenchmarkFoo\n"}`+"\n"), nil), nil
+		case cfg.HasArg("-bench"):
+			for i, arg := range cfg.Args {
+				if st, ok := profileFlagUnits[arg]; ok {
+					writeTestProfile(t, cfg.Args[i+1], st[0], st[1], "ex/pkg."+checkout, 1e9)
+				}
+			}
+		}
+		return nil, nil
+	}
+	return mock
+}
+
+// saveProfileFlags restores the profile flags after the test.
+func saveProfileFlags(t *testing.T) {
+	oldType, oldOutput, oldPprof, oldWeb, oldTop := profileType, profileOutput, profilePprof, profileWeb, profileTop
+	oldTime, oldCount, oldFilter, oldJSON := benchTime, benchCount, benchFilter, jsonOutput
+	t.Cleanup(func() {
+		profileType, profileOutput, profilePprof, profileWeb, profileTop = oldType, oldOutput, oldPprof, oldWeb, oldTop
+		benchTime, benchCount, benchFilter, jsonOutput = oldTime, oldCount, oldFilter, oldJSON
+	})
+	profileType, profileOutput, profilePprof, profileWeb, profileTop = "cpu", "", false, false, 10
+	benchTime, benchCount, benchFilter, jsonOutput = "", 1, "", false
+}
 
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-	}()
+// benchRuns returns the go test calls that ran benchmarks.
+func benchRuns(mock *runner.Mock) []runner.Config {
+	var runs []runner.Config
+	for _, c := range mock.Calls() {
+		if c.IsCmd("go") && c.HasArg("-bench") {
+			runs = append(runs, c)
+		}
+	}
+	return runs
+}
 
+func TestRunProfileUnknownType(t *testing.T) {
+	saveProfileFlags(t)
 	profileType = "invalid"
-	profileOutput = "test.pprof"
-	profileNoPprof = true
 
-	err := runProfileWithRunner(mock, []string{})
+	err := runProfileWithRunner(runner.NewMock(), []string{})
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "unknown profile type")
 }
 
 func TestRunProfileCPU(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "cpu.pprof")
-
-	mock := runner.NewMock()
-	// Mock will return success, we create a fake profile file
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "cpu"
+	saveProfileFlags(t)
+	outFile := filepath.Join(t.TempDir(), "cpu.pprof")
 	profileOutput = outFile
-	profileNoPprof = true
 	benchTime = "1s"
-	benchCount = 1
-
-	// Create the profile file so the os.Stat check passes
-	os.WriteFile(outFile, []byte("fake profile"), 0644)
-
-	err := runProfileWithRunner(mock, []string{})
-	assert.Nil(t, err)
-
-	// Verify correct args were passed
-	calls := mock.Calls()
-	assert.GreaterOrEqual(t, len(calls), 1)
-	call := calls[0]
-	assert.True(t, call.IsCmd("go", "test"))
-	assert.True(t, call.HasArg("-cpuprofile"))
-	assert.True(t, call.HasArg("-bench"))
+	mock := profileMock(t)
+
+	output := captureStdout(t, func() error { return runProfileWithRunner(mock, []string{}) })
+	assert.Contains(t, output, "==> Running cpu profiling on ./...")
+	assert.Contains(t, output, "==> cpu profile: 1s cpu, written to "+outFile)
+	assert.Contains(t, output, "ex/pkg.main")
+	assert.FileExists(t, outFile)
+
+	runs := benchRuns(mock)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].HasArg("-cpuprofile"))
+	assert.True(t, runs[0].HasArg("1s"))
+	assert.Equal(t, "ex/pkg", runs[0].Args[len(runs[0].Args)-1])
 }
 
-func TestRunProfileMem(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "mem.pprof")
-
-	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "mem"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
-
-	os.WriteFile(outFile, []byte("fake profile"), 0644)
-
-	err := runProfileWithRunner(mock, []string{})
-	assert.Nil(t, err)
-
-	calls := mock.Calls()
-	assert.GreaterOrEqual(t, len(calls), 1)
-	assert.True(t, calls[0].HasArg("-memprofile"))
+func TestRunProfileTypes(t *testing.T) {
+	for _, tc := range []struct{ typ, flag string }{
+		{"mem", "-memprofile"},
+		{"mutex", "-mutexprofile"},
+		{"block", "-blockprofile"},
+	} {
+		t.Run(tc.typ, func(t *testing.T) {
+			saveProfileFlags(t)
+			profileType = tc.typ
+			profileOutput = filepath.Join(t.TempDir(), tc.typ+".pprof")
+			mock := profileMock(t)
+
+			captureStdout(t, func() error { return runProfileWithRunner(mock, []string{}) })
+			runs := benchRuns(mock)
+			require.Len(t, runs, 1)
+			assert.True(t, runs[0].HasArg(tc.flag))
+			assert.FileExists(t, profileOutput)
+		})
+	}
 }
 
-func TestRunProfileMutex(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "mutex.pprof")
-
-	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "mutex"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
-
-	os.WriteFile(outFile, []byte("fake profile"), 0644)
-
-	err := runProfileWithRunner(mock, []string{})
-	assert.Nil(t, err)
-
-	calls := mock.Calls()
-	assert.GreaterOrEqual(t, len(calls), 1)
-	assert.True(t, calls[0].HasArg("-mutexprofile"))
+func TestRunProfileMultipleTypes(t *testing.T) {
+	saveProfileFlags(t)
+	dir := t.TempDir()
+	profileType = "mem,cpu"
+	profileOutput = filepath.Join(dir, "out.pprof")
+	profileTop = 1
+	mock := profileMock(t)
+
+	output := captureStdout(t, func() error { return runProfileWithRunner(mock, []string{}) })
+	assert.Contains(t, output, "==> Running cpu, mem profiling")
+	assert.Less(t, strings.Index(output, "==> cpu profile"), strings.Index(output, "==> mem profile: 953.67MB alloc_space"))
+	assert.FileExists(t, filepath.Join(dir, "out_cpu.pprof"))
+	assert.FileExists(t, filepath.Join(dir, "out_mem.pprof"))
+
+	// Both profiles come from one run
+	runs := benchRuns(mock)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].HasArg("-cpuprofile"))
+	assert.True(t, runs[0].HasArg("-memprofile"))
+
+	profilePprof = true
+	assert.ErrorContains(t, runProfileWithRunner(mock, []string{}), "--pprof opens one profile")
 }
 
-func TestRunProfileBlock(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "block.pprof")
+func TestRunProfileJSON(t *testing.T) {
+	saveProfileFlags(t)
+	profileOutput = filepath.Join(t.TempDir(), "cpu.pprof")
+	jsonOutput = true
+	mock := profileMock(t)
 
-	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "block"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
-
-	os.WriteFile(outFile, []byte("fake profile"), 0644)
-
-	err := runProfileWithRunner(mock, []string{})
-	assert.Nil(t, err)
-
-	calls := mock.Calls()
-	assert.GreaterOrEqual(t, len(calls), 1)
-	assert.True(t, calls[0].HasArg("-blockprofile"))
-}
-
-func TestRunProfileWithPackageArg(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "cpu.pprof")
-
-	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "cpu"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
-
-	os.WriteFile(outFile, []byte("fake profile"), 0644)
-
-	err := runProfileWithRunner(mock, []string{"./pkg/..."})
-	assert.Nil(t, err)
-
-	calls := mock.Calls()
-	assert.GreaterOrEqual(t, len(calls), 1)
-	assert.True(t, calls[0].HasArg("./pkg/..."))
+	output := captureStdout(t, func() error { return runProfileWithRunner(mock, []string{}) })
+	assert.Contains(t, output, `"sample_type": "cpu"`)
+	assert.Contains(t, output, `"name": "ex/pkg.main"`)
+	assert.NotContains(t, output, "==>")
 }
 
-func TestRunProfileWithCount(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "cpu.pprof")
-
-	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "cpu"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
+func TestRunProfileWithPackageArgAndFilter(t *testing.T) {
+	saveProfileFlags(t)
+	profileOutput = filepath.Join(t.TempDir(), "cpu.pprof")
+	benchFilter = "Foo"
 	benchCount = 3
-
-	os.WriteFile(outFile, []byte("fake profile"), 0644)
-
-	err := runProfileWithRunner(mock, []string{})
-	assert.Nil(t, err)
-
-	calls := mock.Calls()
-	assert.GreaterOrEqual(t, len(calls), 1)
-	assert.True(t, calls[0].HasArg("-count"))
+	mock := profileMock(t)
+
+	captureStdout(t, func() error { return runProfileWithRunner(mock, []string{"./pkg/..."}) })
+	var listed bool
+	for _, c := range mock.Calls() {
+		if c.HasArg("-list") {
+			listed = c.HasArg("./pkg/...") && c.HasArg("Foo")
+		}
+	}
+	assert.True(t, listed)
+	runs := benchRuns(mock)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].HasArg("Foo"))
+	assert.True(t, runs[0].HasArg("-count"))
 }
 
 func TestRunProfileGoTestFails(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "cpu.pprof")
-
+	saveProfileFlags(t)
 	mock := runner.NewMock()
 	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
 		return runner.MockProcess(nil, assert.AnError), nil
 	}
 
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "cpu"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
-
 	err := runProfileWithRunner(mock, []string{})
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "profiling failed")
 }
 
 func TestRunProfileNoOutputFile(t *testing.T) {
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "nonexistent", "cpu.pprof")
-
+	saveProfileFlags(t)
 	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "cpu"
-	profileOutput = outFile
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
+	mock.SetResponse("go", []string{"test", "-json", "-list", ".", "./..."}, []byte(`{"Action":"output","Package":"ex/pkg","Output":"BenchmarkFoo\n"}`), nil)
 
-	// Don't create the file - simulates no benchmarks found
+	// go test succeeds without writing the profile
 	err := runProfileWithRunner(mock, []string{})
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "profile output not created")
@@ -319,54 +216,57 @@ func TestRunProfileNoOutputFile(t *testing.T) {
 
 func TestRunProfile(t *testing.T) {
 	// Exercise the entry point (will fail but covers the code path)
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-	}()
-
+	saveProfileFlags(t)
 	profileType = "invalid"
-	profileOutput = "test.pprof"
-	profileNoPprof = true
 
 	err := runProfile(profileCmd, []string{})
 	assert.NotNil(t, err)
 }
 
 func TestRunProfileDefaultOutput(t *testing.T) {
+	saveProfileFlags(t)
 	tmpDir := t.TempDir()
-	oldWd, _ := os.Getwd()
-	os.Chdir(tmpDir)
-	defer os.Chdir(oldWd)
+	t.Chdir(tmpDir)
 
-	mock := runner.NewMock()
-	// Mock returns success by default
-
-	oldType := profileType
-	oldOutput := profileOutput
-	oldNoPprof := profileNoPprof
-	oldTime := benchTime
-	oldCount := benchCount
-	defer func() {
-		profileType = oldType
-		profileOutput = oldOutput
-		profileNoPprof = oldNoPprof
-		benchTime = oldTime
-		benchCount = oldCount
-	}()
-
-	profileType = "cpu"
-	profileOutput = "" // Use default
-	profileNoPprof = true
-	benchTime = ""
-	benchCount = 1
-
-	// Create the default output file
-	os.WriteFile(filepath.Join(tmpDir, "profile_cpu.pprof"), []byte("fake"), 0644)
+	captureStdout(t, func() error { return runProfileWithRunner(profileMock(t), []string{}) })
+	assert.FileExists(t, filepath.Join(tmpDir, "profile_cpu.pprof"))
+}
 
-	err := runProfileWithRunner(mock, []string{})
-	assert.Nil(t, err)
+func TestRunProfileDiff(t *testing.T) {
+	saveProfileFlags(t)
+	dir := t.TempDir()
+	base, current := filepath.Join(dir, "base.pprof"), filepath.Join(dir, "new.pprof")
+	writeTestProfile(t, base, "cpu", "nanoseconds", "ex/pkg.parse", 1e9)
+	writeTestProfile(t, current, "cpu", "nanoseconds", "ex/pkg.scan", 1e9)
+
+	output := captureStdout(t, func() error { return runProfileDiffWithRunner(runner.NewMock(), []string{base, current}) })
+	assert.Contains(t, output, "==> Profile diff (cpu): "+base+" → "+current)
+	assert.Contains(t, output, "+100.0  ex/pkg.scan")
+	assert.Contains(t, output, "-100.0  ex/pkg.parse")
+
+	profileType = "cpu,mem"
+	assert.ErrorContains(t, runProfileDiffWithRunner(runner.NewMock(), []string{base, current}), "one profile type")
+}
+
+func TestRunProfileDiffCommits(t *testing.T) {
+	saveProfileFlags(t)
+	mock := profileMock(t)
+
+	output := captureStdout(t, func() error { return runProfileDiffWithRunner(mock, []string{"v1.0.0", "HEAD", "./pkg"}) })
+	assert.Contains(t, output, "==> Profiling v1.0.0")
+	assert.Contains(t, output, "==> Profiling HEAD")
+	assert.Contains(t, output, "+100.0  ex/pkg.HEAD")
+	assert.Contains(t, output, "-100.0  ex/pkg.v1.0.0")
+
+	var removed bool
+	for _, c := range mock.Calls() {
+		if c.HasArg("-bench") {
+			assert.True(t, c.IsCmd("go", "-C"))
+			assert.True(t, c.HasArg("ex/pkg"))
+		}
+		if c.IsCmd("git", "worktree", "remove") {
+			removed = true
+		}
+	}
+	assert.True(t, removed)
 }
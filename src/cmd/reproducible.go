@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/release"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+var reproPlatforms []string
+
+func init() {
+	reproCmd := &cobra.Command{
+		Use:   "verify-reproducible",
+		Short: "Build each target twice and check the binaries are identical",
+		Long: `Builds every target twice, each time with its own empty GOPATH and GOCACHE,
+-trimpath, and the same SOURCE_DATE_EPOCH (the commit time unless already
+set), then byte-compares the results. When two builds differ, the sections
+that differ are listed.
+
+The module cache is shared between the builds: its contents are verified
+against go.sum, so it cannot make builds diverge, and no network is needed.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerifyReproducibleWithRunner(runner.New())
+		},
+	}
+	reproCmd.Flags().StringSliceVar(&reproPlatforms, "platforms", nil, "os/arch[/variant] platforms to check (default: the host)")
+	rootCmd.AddCommand(reproCmd)
+}
+
+func runVerifyReproducibleWithRunner(r runner.CommandRunner) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	goEnv := queryGoEnv(r)
+	platforms := []build.Platform{{GOOS: goEnv.goos, GOARCH: goEnv.goarch}}
+	if len(reproPlatforms) > 0 {
+		if platforms, err = build.ResolvePlatforms(r, build.PlatformSelection{Platforms: reproPlatforms}); err != nil {
+			return err
+		}
+	}
+	targets, err := build.ResolveBuildTargets(r, cfg.Build)
+	if err != nil {
+		return err
+	}
+	if targets, err = build.ApplyPGO(r, targets); err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no main packages found to build")
+	}
+
+	info := collectGitInfo()
+	ldflags := info.ldflags()
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		if t, ok := info.sourceDate(); ok {
+			epoch = strconv.FormatInt(t.Unix(), 10)
+		}
+	}
+	rb := reproBuild{ldflags: ldflags, epoch: epoch, modCache: goEnvVar(r, "GOMODCACHE"), matrix: cfg.Matrix}
+
+	checked, failed := 0, 0
+	for _, platform := range platforms {
+		for _, target := range targets {
+			target.Trimpath = true
+			fmt.Printf("==> Building %s (%s) twice\n", target.OutputName, platform)
+			same, err := rb.check(r, target, platform)
+			if err != nil {
+				return err
+			}
+			checked++
+			if !same {
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d builds are not reproducible", failed, checked)
+	}
+	fmt.Printf("==> All %d builds are reproducible\n", checked)
+	return nil
+}
+
+// reproBuild is what every build verify-reproducible runs shares.
+type reproBuild struct {
+	ldflags  string
+	epoch    string
+	modCache string
+	matrix   config.Matrix
+}
+
+// check builds target for platform twice, each in a fresh temp dir with its
+// own GOPATH and GOCACHE, and reports whether the binaries are identical.
+// The dirs are removed once they are compared.
+func (rb reproBuild) check(r runner.CommandRunner, target build.Target, platform build.Platform) (bool, error) {
+	name := target.OutputName + "_" + platform.Suffix()
+	var outputs [2]string
+	for i := range outputs {
+		dir, err := os.MkdirTemp("", "go-toolchain-repro-")
+		if err != nil {
+			return false, err
+		}
+		defer os.RemoveAll(dir)
+		outputs[i] = filepath.Join(dir, name)
+		cmd := runner.Cmd("go", target.BuildArgs(rb.ldflags, outputs[i])...).
+			WithEnv("GOPATH", filepath.Join(dir, "gopath")).
+			WithEnv("GOCACHE", filepath.Join(dir, "gocache")).
+			WithQuiet()
+		if rb.modCache != "" {
+			cmd = cmd.WithEnv("GOMODCACHE", rb.modCache)
+		}
+		if rb.epoch != "" {
+			cmd = cmd.WithEnv("SOURCE_DATE_EPOCH", rb.epoch)
+		}
+		for k, v := range reproBuildEnv(rb.matrix, target, platform) {
+			cmd = cmd.WithEnv(k, v)
+		}
+		proc, err := cmd.Run(r)
+		if err == nil {
+			err = proc.Wait()
+		}
+		if err != nil {
+			return false, fmt.Errorf("go build of %s (%s) failed: %w", target.ImportPath, platform, err)
+		}
+	}
+	return reportReproducibility(outputs[0], outputs[1])
+}
+
+// reproBuildEnv returns the platform and cgo environment for a target, the
+// same way matrix builds it.
+func reproBuildEnv(mc config.Matrix, target build.Target, platform build.Platform) map[string]string {
+	env := platform.Env()
+	env["CGO_ENABLED"] = "0"
+	if target.CGO {
+		env["CGO_ENABLED"] = "1"
+		if cc, ok, err := build.CToolchainEnv(mc.CToolchains, platform); err == nil && ok {
+			for k, v := range cc {
+				env[k] = v
+			}
+		}
+	}
+	return env
+}
+
+// reportReproducibility compares two builds of the same binary and prints
+// the result, listing differing sections when they don't match.
+func reportReproducibility(a, b string) (bool, error) {
+	hashA, err := fileHash(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := fileHash(b)
+	if err != nil {
+		return false, err
+	}
+	if hashA == hashB {
+		fmt.Printf("  OK   identical, sha256 %s\n", hashA)
+		return true, nil
+	}
+
+	fmt.Printf("  DIFF sha256 %s vs %s\n", hashA, hashB)
+	diffs, err := release.DiffSections(a, b)
+	if err != nil {
+		// Not an executable we can parse; fall back to the first differing byte
+		dataA, _ := os.ReadFile(a)
+		dataB, _ := os.ReadFile(b)
+		fmt.Printf("       files differ at byte %d (%s)\n", release.FirstDifference(dataA, dataB), err)
+		return false, nil
+	}
+	if len(diffs) == 0 {
+		fmt.Println("       all sections match; the difference is in headers or padding")
+	}
+	for _, d := range diffs {
+		switch {
+		case d.OnlyIn != 0:
+			fmt.Printf("       section %s: only in build %d\n", d.Name, d.OnlyIn)
+		case d.SizeA != d.SizeB:
+			fmt.Printf("       section %s: differs at +%#x, size %d vs %d\n", d.Name, d.FirstOffset, d.SizeA, d.SizeB)
+		default:
+			fmt.Printf("       section %s: differs at +%#x\n", d.Name, d.FirstOffset)
+		}
+	}
+	return false, nil
+}
+
+// goEnvVar returns a single `go env` value, or "" if the query fails.
+func goEnvVar(r runner.CommandRunner, name string) string {
+	proc, err := runner.Cmd("go", "env", name).WithQuiet().Run(r)
+	if err != nil {
+		return ""
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if proc.Wait() != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
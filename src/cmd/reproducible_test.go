@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestVerifyReproducibleIdentical(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	mock := newTestPassMock(0)
+	mock.SetResponse("go", []string{"env", "GOMODCACHE"}, []byte("/shared/mod\n"), nil)
+	require.NoError(t, runVerifyReproducibleWithRunner(mock))
+
+	var builds []runner.Config
+	for _, cfg := range mock.Calls() {
+		if cfg.IsCmd("go", "build") {
+			builds = append(builds, cfg)
+		}
+	}
+	require.Equal(t, 2, len(builds))
+	assert.True(t, builds[0].HasArg("-trimpath"))
+	assert.NotEqual(t, builds[0].Env["GOCACHE"], builds[1].Env["GOCACHE"])
+	assert.NotEqual(t, builds[0].Env["GOPATH"], builds[1].Env["GOPATH"])
+	assert.Equal(t, "/shared/mod", builds[0].Env["GOMODCACHE"])
+	assert.Equal(t, "1700000000", builds[0].Env["SOURCE_DATE_EPOCH"])
+	assert.Equal(t, "0", builds[0].Env["CGO_ENABLED"])
+}
+
+func TestVerifyReproducibleDiffers(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldPlatforms := reproPlatforms
+	reproPlatforms = []string{"linux/amd64", "windows/amd64"}
+	defer func() { reproPlatforms = oldPlatforms }()
+
+	// Every build writes a different binary
+	n := 0
+	caches := make(map[string]bool)
+	mock := newTestPassMock(0)
+	orig := mock.Handler
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		if cfg.IsCmd("go", "build") {
+			n++
+			caches[cfg.Env["GOCACHE"]] = true
+			for i, arg := range cfg.Args {
+				if arg == "-o" {
+					os.WriteFile(cfg.Args[i+1], []byte(strings.Repeat("x", n)), 0755)
+				}
+			}
+			return runner.MockProcess(nil, nil), nil
+		}
+		return orig(cfg)
+	}
+
+	err := runVerifyReproducibleWithRunner(mock)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "2 of 2 builds are not reproducible")
+
+	// Each of the four builds starts from its own cache, removed afterwards
+	assert.Equal(t, 4, len(caches))
+	for cache := range caches {
+		assert.NoDirExists(t, filepath.Dir(cache))
+	}
+}
+
+func TestVerifyReproducibleUnsupportedPlatform(t *testing.T) {
+	oldPlatforms := reproPlatforms
+	reproPlatforms = []string{"darwin/386"}
+	defer func() { reproPlatforms = oldPlatforms }()
+
+	assert.NotNil(t, runVerifyReproducibleWithRunner(newTestPassMock(0)))
+}
+
+func TestReproBuildEnv(t *testing.T) {
+	mc := config.Matrix{CToolchains: []config.CToolchain{{Platform: "linux/arm64", CC: "aarch64-gcc"}}}
+	platform := build.Platform{GOOS: "linux", GOARCH: "arm64"}
+
+	env := reproBuildEnv(mc, build.Target{}, platform)
+	assert.Equal(t, "0", env["CGO_ENABLED"])
+	assert.Equal(t, "arm64", env["GOARCH"])
+
+	env = reproBuildEnv(mc, build.Target{CGO: true}, platform)
+	assert.Equal(t, "1", env["CGO_ENABLED"])
+	assert.Equal(t, "aarch64-gcc", env["CC"])
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
 	"github.com/wow-look-at-my/go-toolchain/src/lint"
 	"github.com/wow-look-at-my/go-toolchain/src/runner"
 	gotest "github.com/wow-look-at-my/go-toolchain/src/test"
@@ -151,7 +152,7 @@ func runWithRunnerOnce(r runner.CommandRunner, isRetry bool) error {
 	}
 
 	// Check for dep updates after tests (runs in parallel)
-	depsUpdated := WaitForOutdatedDeps(depChecker)
+	depsUpdated := WaitForOutdatedDeps(r, depChecker)
 
 	// If anything changed, rebuild
 	if !isRetry && (filesChanged || depsUpdated) {
@@ -167,39 +168,80 @@ func runWithRunnerOnce(r runner.CommandRunner, isRetry bool) error {
 }
 
 func runBuildPhase(r runner.CommandRunner, quiet bool) error {
-	targets, err := build.ResolveBuildTargets(r)
+	cfg, err := config.Load(".")
 	if err != nil {
 		return err
 	}
+	targets, err := build.ResolveBuildTargets(r, cfg.Build)
+	if err != nil {
+		return err
+	}
+	if targets, err = build.ApplyPGO(r, targets); err != nil {
+		return err
+	}
+	if err := checkLicenses(r, cfg.Licenses, quiet); err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
 	}
 	info := collectGitInfo()
 	ldflags := info.ldflags()
+	goEnv := queryGoEnv(r)
 	if !quiet {
 		fmt.Printf("==> Embedding version: %s\n", info)
 	}
+	var artifacts []artifactEntry
 	for _, t := range targets {
 		outPath := filepath.Join(outputDir, t.OutputName)
 		if !quiet {
 			fmt.Printf("==> go build -o %s %s\n", outPath, t.ImportPath)
 		}
-		proc, err := runner.Cmd("go", "build", "-ldflags", ldflags, "-o", outPath, t.ImportPath).Run(r)
+		buildCmd := runner.Cmd("go", t.BuildArgs(ldflags, outPath)...)
+		proc, err := buildCmd.Run(r)
 		if err != nil {
 			return fmt.Errorf("go build failed: %w", err)
 		}
 		if err := proc.Wait(); err != nil {
 			return fmt.Errorf("go build failed: %w", err)
 		}
+		entry := artifactEntry{
+			Kind:      "binary",
+			Name:      t.OutputName,
+			Target:    t.ImportPath,
+			GOOS:      goEnv.goos,
+			GOARCH:    goEnv.goarch,
+			BuildArgs: buildCmd.Args,
+		}
+		if err := entry.record(outputDir, outPath, info, goEnv.goVersion); err != nil {
+			return err
+		}
+		artifacts = append(artifacts, entry)
+	}
+
+	if formats := sbomFormats(cfg.SBOM); len(formats) > 0 {
+		sboms, err := writeSBOMs(r, formats, artifacts, info)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, sboms...)
+	}
+
+	if err := writeManifest(outputDir, artifacts); err != nil {
+		return err
 	}
 
 	if !quiet {
 		fmt.Println("==> Build successful")
 	}
 
+	if err := trackSizes(r, cfg.Size, artifacts, info, quiet); err != nil {
+		return err
+	}
+
 	if !noBenchmark {
-		if err := runBenchmarkInBuild(r); err != nil {
+		if err := runBenchmarkInBuild(r, cfg.Bench); err != nil {
 			return err
 		}
 	}
@@ -254,10 +296,15 @@ func RunTestsWithCoverage(r runner.CommandRunner, quiet bool) (bool, error) {
 	if !quiet {
 		fmt.Println("==> go vet ./...")
 	}
-	filesChanged, err := vet.Run(fix)
+	vetResult, err := vet.Run(fix)
 	if err != nil {
 		return false, fmt.Errorf("vet failed: %w", err)
 	}
+	filesChanged := vetResult.FilesChanged
+
+	if err := runVulnPhase(r, vetResult.Packages, quiet); err != nil {
+		return false, err
+	}
 
 	if dupcode {
 		runDuplicateCheck()
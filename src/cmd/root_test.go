@@ -37,6 +37,31 @@ func writeMockCoverProfile(args []string, pct float32) {
 	}
 }
 
+// handleGoBuild handles go build commands for mocks, writing a small
+// placeholder binary at the -o path so manifests can be hashed.
+func handleGoBuild(cfg runner.Config) (runner.IProcess, bool) {
+	if !cfg.IsCmd("go", "build") {
+		return nil, false
+	}
+	for i, arg := range cfg.Args {
+		if arg == "-o" && i+1 < len(cfg.Args) {
+			os.WriteFile(cfg.Args[i+1], []byte("binary:"+cfg.Args[len(cfg.Args)-1]), 0755)
+		}
+	}
+	return runner.MockProcess(nil, nil), true
+}
+
+// testDistList is a subset of `go tool dist list` output for mocks.
+const testDistList = "darwin/amd64\ndarwin/arm64\nlinux/386\nlinux/amd64\nlinux/arm\nlinux/arm64\nlinux/riscv64\nwindows/386\nwindows/amd64\nwindows/arm64\n"
+
+// handleGoToolDist answers `go tool dist list` for mocks.
+func handleGoToolDist(cfg runner.Config) (runner.IProcess, bool) {
+	if !cfg.IsCmd("go", "tool", "dist", "list") {
+		return nil, false
+	}
+	return runner.MockProcess([]byte(testDistList), nil), true
+}
+
 // handleGoList handles go list commands for mocks, returning fake main package info.
 func handleGoList(cfg runner.Config) (runner.IProcess, bool) {
 	if !cfg.IsCmd("go", "list") {
@@ -70,9 +95,15 @@ func newTestPassMock(pct float32) *runner.Mock {
 `, covPct)
 			return runner.MockProcess([]byte(output), nil), nil
 		}
+		if proc, ok := handleGoBuild(cfg); ok {
+			return proc, nil
+		}
 		if proc, ok := handleGoList(cfg); ok {
 			return proc, nil
 		}
+		if proc, ok := handleGoToolDist(cfg); ok {
+			return proc, nil
+		}
 		return nil, nil // fall through to default
 	}
 	return mock
@@ -88,6 +119,9 @@ func newTestPipesFailMock() *runner.Mock {
 		if proc, ok := handleGoList(cfg); ok {
 			return proc, nil
 		}
+		if proc, ok := handleGoToolDist(cfg); ok {
+			return proc, nil
+		}
 		return nil, nil
 	}
 	return mock
@@ -103,6 +137,9 @@ func newModTidyFailMock() *runner.Mock {
 		if proc, ok := handleGoList(cfg); ok {
 			return proc, nil
 		}
+		if proc, ok := handleGoToolDist(cfg); ok {
+			return proc, nil
+		}
 		return nil, nil
 	}
 	return mock
@@ -126,6 +163,9 @@ func newBuildFailMock() *runner.Mock {
 		if proc, ok := handleGoList(cfg); ok {
 			return proc, nil
 		}
+		if proc, ok := handleGoToolDist(cfg); ok {
+			return proc, nil
+		}
 		return nil, nil
 	}
 	return mock
@@ -143,9 +183,15 @@ func newTestFailMock() *runner.Mock {
 `
 			return runner.MockProcess([]byte(output), nil), nil
 		}
+		if proc, ok := handleGoBuild(cfg); ok {
+			return proc, nil
+		}
 		if proc, ok := handleGoList(cfg); ok {
 			return proc, nil
 		}
+		if proc, ok := handleGoToolDist(cfg); ok {
+			return proc, nil
+		}
 		return nil, nil
 	}
 	return mock
@@ -169,6 +215,9 @@ func newTestFailWithErrorMock() *runner.Mock {
 		if proc, ok := handleGoList(cfg); ok {
 			return proc, nil
 		}
+		if proc, ok := handleGoToolDist(cfg); ok {
+			return proc, nil
+		}
 		return nil, nil
 	}
 	return mock
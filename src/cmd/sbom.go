@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/sbom"
+)
+
+var writeSBOM bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&writeSBOM, "sbom", false, "Write CycloneDX and SPDX SBOMs next to each binary")
+}
+
+// sbomFormats returns the SBOM formats to write: the configured ones, or
+// all of them when --sbom is passed without config.
+func sbomFormats(cfg config.SBOM) []string {
+	if len(cfg.Formats) > 0 {
+		return cfg.Formats
+	}
+	if writeSBOM {
+		return config.SBOMFormats
+	}
+	return nil
+}
+
+// writeSBOMs writes an SBOM per format next to every binary artifact, from
+// the binary's embedded build info and the module graph. The SBOM paths are
+// recorded on the binary's manifest entry, and manifest entries for the SBOM
+// files themselves are returned.
+func writeSBOMs(r runner.CommandRunner, formats []string, artifacts []artifactEntry, info gitInfo) ([]artifactEntry, error) {
+	mods, err := build.ListModules(r)
+	if err != nil {
+		return nil, err
+	}
+	created, ok := info.sourceDate()
+	if !ok {
+		created = time.Now()
+	}
+
+	var entries []artifactEntry
+	for i := range artifacts {
+		a := &artifacts[i]
+		if a.Kind != "binary" {
+			continue
+		}
+		binPath := filepath.Join(outputDir, filepath.FromSlash(a.Path))
+		bin, err := sbom.ReadBinary(binPath)
+		if err != nil {
+			return nil, err
+		}
+		bin.Name = filepath.Base(binPath)
+		bin.SHA256 = a.SHA256
+		if bin.Main.Version == "" || bin.Main.Version == "(devel)" {
+			bin.Main.Version = info.version
+		}
+		bin.Annotate(mods)
+
+		for _, format := range formats {
+			data, err := sbom.Generate(format, bin, created)
+			if err != nil {
+				return nil, err
+			}
+			path := binPath + sbom.FileSuffix(format)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write SBOM: %w", err)
+			}
+			entry := artifactEntry{Kind: "sbom", Name: a.Name, Target: a.Target, GOOS: a.GOOS, GOARCH: a.GOARCH, Variant: a.Variant}
+			if err := entry.record(outputDir, path, info, a.GoVersion); err != nil {
+				return nil, err
+			}
+			a.SBOMs = append(a.SBOMs, entry.Path)
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
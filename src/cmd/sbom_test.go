@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// newSBOMMock is a passing mock whose go build copies the test binary, which
+// carries real build info, to the -o path.
+func newSBOMMock(t *testing.T) *runner.Mock {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+
+	mock := newTestPassMock(0)
+	orig := mock.Handler
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		if cfg.IsCmd("go", "build") {
+			for i, arg := range cfg.Args {
+				if arg == "-o" {
+					os.WriteFile(cfg.Args[i+1], data, 0755)
+				}
+			}
+			return runner.MockProcess(nil, nil), nil
+		}
+		if cfg.IsCmd("go", "list", "-m", "-json", "all") {
+			return runner.MockProcess([]byte(`{"Path": "example.com", "Main": true}
+{"Path": "github.com/wow-look-at-my/testify", "Version": "v1.0.0", "Indirect": true}
+`), nil), nil
+		}
+		return orig(cfg)
+	}
+	return mock
+}
+
+func TestSBOMFormats(t *testing.T) {
+	oldSBOM := writeSBOM
+	defer func() { writeSBOM = oldSBOM }()
+
+	writeSBOM = false
+	assert.Equal(t, 0, len(sbomFormats(config.SBOM{})))
+	assert.Equal(t, []string{"spdx"}, sbomFormats(config.SBOM{Formats: []string{"spdx"}}))
+	writeSBOM = true
+	assert.Equal(t, []string{"cyclonedx", "spdx"}, sbomFormats(config.SBOM{}))
+}
+
+func TestRunReleaseWithRunnerSBOM(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOS := matrixOS
+	oldArch := matrixArch
+	oldOutput := outputDir
+	oldSBOM := writeSBOM
+	matrixOS = []string{"linux", "windows"}
+	matrixArch = []string{"amd64"}
+	outputDir = filepath.Join(tmpDir, "dist")
+	writeSBOM = true
+	defer func() {
+		matrixOS = oldOS
+		matrixArch = oldArch
+		outputDir = oldOutput
+		writeSBOM = oldSBOM
+	}()
+
+	require.NoError(t, runReleaseWithRunner(newSBOMMock(t)))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	require.NoError(t, err)
+	var m buildManifest
+	require.NoError(t, json.Unmarshal(data, &m))
+	require.Equal(t, 6, len(m.Artifacts))
+
+	byPath := make(map[string]artifactEntry)
+	for _, a := range m.Artifacts {
+		byPath[a.Path] = a
+	}
+	bin := byPath["example.com_linux_amd64"]
+	assert.Equal(t, []string{"example.com_linux_amd64.cdx.json", "example.com_linux_amd64.spdx.json"}, bin.SBOMs)
+	cdx := byPath["example.com_windows_amd64.exe.cdx.json"]
+	assert.Equal(t, "sbom", cdx.Kind)
+	assert.Equal(t, "windows", cdx.GOOS)
+	assert.NotEqual(t, "", cdx.SHA256)
+
+	data, err = os.ReadFile(filepath.Join(outputDir, "example.com_linux_amd64.cdx.json"))
+	require.NoError(t, err)
+	var doc struct {
+		BOMFormat  string
+		Components []struct {
+			Name       string
+			Properties []struct{ Name, Value string }
+		}
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "CycloneDX", doc.BOMFormat)
+	indirect := false
+	for _, c := range doc.Components {
+		if c.Name == "github.com/wow-look-at-my/testify" {
+			for _, p := range c.Properties {
+				indirect = indirect || p.Name == "go:indirect"
+			}
+		}
+	}
+	assert.True(t, indirect)
+}
+
+func TestRunBuildPhaseSBOMFromConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldOutput := outputDir
+	oldBench := noBenchmark
+	outputDir = filepath.Join(tmpDir, "build")
+	noBenchmark = true
+	defer func() {
+		outputDir = oldOutput
+		noBenchmark = oldBench
+	}()
+	os.WriteFile(config.FileName, []byte(`{"sbom": {"formats": ["spdx"]}}`), 0644)
+
+	require.NoError(t, runBuildPhase(newSBOMMock(t), true))
+	assert.FileExists(t, filepath.Join(outputDir, "example.com.spdx.json"))
+	assert.NoFileExists(t, filepath.Join(outputDir, "example.com.cdx.json"))
+}
+
+func TestWriteSBOMsUnreadableBinary(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldOutput := outputDir
+	outputDir = tmpDir
+	defer func() { outputDir = oldOutput }()
+
+	os.WriteFile(filepath.Join(tmpDir, "app"), []byte("not a binary"), 0755)
+	artifacts := []artifactEntry{{Kind: "binary", Name: "app", Path: "app"}}
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "all"}, []byte(`{"Path": "example.com", "Main": true}`), nil)
+	_, err := writeSBOMs(mock, []string{"cyclonedx"}, artifacts, gitInfo{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/size"
+)
+
+var sizeTop int
+
+var sizeCmd = &cobra.Command{
+	Use:   "size [binary...]",
+	Short: "Break down binary size by package and symbol",
+	Long: `Reads the symbol table of each binary (ELF, Mach-O or PE) and shows which
+packages and symbols take up the most space.
+
+With no arguments, analyzes the binaries listed in the build manifest.
+Binaries built with -ldflags=-s have no symbol table; only their section
+sizes are shown.
+
+Binary sizes are also recorded in git notes (refs/notes/sizes) after every
+build and compared against the base commit. Set "size.max_growth_percent" or
+"size.max_growth_bytes" in go-toolchain.json to fail builds that grow too much.`,
+	SilenceUsage: true,
+	RunE:         runSize,
+}
+
+func init() {
+	sizeCmd.Flags().IntVar(&sizeTop, "top", 20, "Number of packages and symbols to show")
+	rootCmd.AddCommand(sizeCmd)
+}
+
+func runSize(cmd *cobra.Command, args []string) error {
+	paths := args
+	if len(paths) == 0 {
+		var err error
+		if paths, err = manifestBinaries(outputDir); err != nil {
+			return err
+		}
+	}
+
+	var breakdowns []*size.Breakdown
+	for _, p := range paths {
+		b, err := size.Analyze(p)
+		if err != nil {
+			return err
+		}
+		breakdowns = append(breakdowns, b)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(breakdowns)
+	}
+	for _, b := range breakdowns {
+		printBreakdown(b, sizeTop)
+	}
+	return nil
+}
+
+// manifestBinaries returns the paths of the binaries in dir's manifest.
+func manifestBinaries(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("no binaries given and no %s in %s (run a build first): %w", manifestFile, dir, err)
+	}
+	var m buildManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+	var paths []string
+	for _, a := range m.Artifacts {
+		if a.Kind == "binary" {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(a.Path)))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no binaries in %s", filepath.Join(dir, manifestFile))
+	}
+	return paths, nil
+}
+
+func printBreakdown(b *size.Breakdown, top int) {
+	fmt.Printf("==> %s (%s, %s)\n", b.Path, b.Format, size.FormatBytes(b.FileSize))
+
+	fmt.Println("  sections:")
+	for _, s := range b.Sections {
+		fmt.Printf("    %10s  %s\n", size.FormatBytes(int64(s.Size)), s.Name)
+	}
+	if len(b.Symbols) == 0 {
+		fmt.Println("  (no symbol table; binary was probably linked with -s)")
+		return
+	}
+
+	var total uint64
+	for _, p := range b.Packages {
+		total += p.Size
+	}
+	fmt.Printf("  packages (top %d of %d):\n", min(top, len(b.Packages)), len(b.Packages))
+	for _, p := range b.Packages[:min(top, len(b.Packages))] {
+		fmt.Printf("    %10s  %5.1f%%  %s\n", size.FormatBytes(int64(p.Size)), float64(p.Size)/float64(total)*100, p.Name)
+	}
+	fmt.Printf("  symbols (top %d of %d):\n", min(top, len(b.Symbols)), len(b.Symbols))
+	for _, s := range b.Symbols[:min(top, len(b.Symbols))] {
+		fmt.Printf("    %10s  %s\n", size.FormatBytes(int64(s.Size)), s.Name)
+	}
+}
+
+// trackSizes records the built binaries' sizes in git notes for HEAD,
+// prints them against the base commit, and fails if any binary grew beyond
+// the configured budget. Dirty trees are compared but not recorded, since
+// the sizes don't belong to HEAD.
+func trackSizes(r runner.CommandRunner, cfg config.Size, artifacts []artifactEntry, info gitInfo, quiet bool) error {
+	report := &size.Report{}
+	for _, a := range artifacts {
+		if a.Kind == "binary" {
+			report.Binaries = append(report.Binaries, size.Binary{Name: a.Name, GOOS: a.GOOS, GOARCH: a.GOARCH, Variant: a.Variant, Size: a.Size})
+		}
+	}
+	if len(report.Binaries) == 0 {
+		return nil
+	}
+
+	base, baseSHA, err := size.FindBase(r, cfg.Base)
+	if err != nil {
+		return err
+	}
+	deltas := size.Compare(report, base)
+	if !quiet {
+		if base == nil {
+			baseSHA = ""
+		}
+		size.PrintDeltas(deltas, baseSHA)
+	}
+
+	if info.commit != "" && !strings.HasSuffix(info.version, "-dirty") {
+		if err := size.StoreNotes(r, report); err != nil && !quiet {
+			fmt.Printf("warning: failed to record binary sizes: %v\n", err)
+		}
+	}
+
+	budget := size.Budget{MaxGrowthPercent: cfg.MaxGrowthPercent, MaxGrowthBytes: cfg.MaxGrowthBytes}
+	if violations := budget.Check(deltas); len(violations) > 0 {
+		return fmt.Errorf("binary size budget exceeded vs %s:\n  %s", baseSHA, strings.Join(violations, "\n  "))
+	}
+	return nil
+}
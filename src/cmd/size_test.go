@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/size"
+)
+
+// sizeBaseMock serves a size note for commit "base" as HEAD's nearest ancestor.
+func sizeBaseMock(t *testing.T, binaries ...size.Binary) *runner.Mock {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"notes", "--ref=sizes", "list"}, []byte("note base\n"), nil)
+	mock.SetResponse("git", []string{"rev-list", "--max-count=200", "HEAD^"}, []byte("base\n"), nil)
+	note, err := json.Marshal(&size.Report{Binaries: binaries})
+	require.NoError(t, err)
+	mock.SetResponse("git", []string{"notes", "--ref=sizes", "show", "base"}, note, nil)
+	return mock
+}
+
+func TestTrackSizesBudgetExceeded(t *testing.T) {
+	mock := sizeBaseMock(t, size.Binary{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 10 << 20})
+	artifacts := []artifactEntry{
+		{Kind: "binary", Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 30 << 20},
+		{Kind: "archive", Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 1},
+	}
+
+	err := trackSizes(mock, config.Size{MaxGrowthPercent: 10}, artifacts, gitInfo{commit: "head", version: "v1.0.0"}, true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "app linux/amd64")
+	assert.Contains(t, err.Error(), "base")
+
+	// Sizes are still recorded so the history shows the jump
+	var stored bool
+	for _, cfg := range mock.Calls() {
+		stored = stored || cfg.IsCmd("git", "notes", "--ref=sizes", "add")
+	}
+	assert.True(t, stored)
+}
+
+func TestTrackSizesWithinBudget(t *testing.T) {
+	mock := sizeBaseMock(t, size.Binary{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 100})
+	artifacts := []artifactEntry{{Kind: "binary", Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 104}}
+	assert.NoError(t, trackSizes(mock, config.Size{MaxGrowthPercent: 5}, artifacts, gitInfo{commit: "head"}, true))
+}
+
+func TestTrackSizesDirtyTreeNotRecorded(t *testing.T) {
+	mock := runner.NewMock()
+	artifacts := []artifactEntry{{Kind: "binary", Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 100}}
+	require.NoError(t, trackSizes(mock, config.Size{}, artifacts, gitInfo{commit: "head", version: "v1.0.0-dirty"}, true))
+	for _, cfg := range mock.Calls() {
+		assert.False(t, cfg.IsCmd("git", "notes", "--ref=sizes", "add"))
+	}
+}
+
+func TestManifestBinaries(t *testing.T) {
+	dir := t.TempDir()
+	_, err := manifestBinaries(dir)
+	assert.NotNil(t, err)
+
+	require.NoError(t, writeManifest(dir, []artifactEntry{
+		{Kind: "binary", Path: "app_linux_amd64"},
+		{Kind: "archive", Path: "app_linux_amd64.tar.gz"},
+	}))
+	paths, err := manifestBinaries(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "app_linux_amd64")}, paths)
+}
+
+func TestRunSizeOnTestBinary(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	oldJSON := jsonOutput
+	jsonOutput = true
+	defer func() { jsonOutput = oldJSON }()
+
+	assert.NoError(t, runSize(sizeCmd, []string{exe}))
+	jsonOutput = false
+	assert.NoError(t, runSize(sizeCmd, []string{exe}))
+	assert.NotNil(t, runSize(sizeCmd, []string{filepath.Join(t.TempDir(), "missing")}))
+}
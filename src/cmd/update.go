@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/update"
+)
+
+var depsDryRun bool
+
+var depsCmd = &cobra.Command{
+	Use:   "deps",
+	Short: "Inspect and update dependencies",
+}
+
+var depsUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update dependencies as allowed by the update policy",
+	Long: `Checks the direct dependencies for newer versions and updates each one as far
+as its rule in "deps.update" of go-toolchain.json allows: never (only
+report), patch, minor, latest, or pinned, optionally holding back versions
+younger than min_age. The default workflow applies the same policy after
+tests. Use --dry-run to only report what would change.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dc := CheckOutdatedDeps()
+		deps := dc.WaitWithProgress()
+		if err := dc.err; err != nil {
+			return err
+		}
+		return runDepsUpdate(runner.New(), deps, depsDryRun)
+	},
+}
+
+func init() {
+	depsUpdateCmd.Flags().BoolVar(&depsDryRun, "dry-run", false, "Report the updates the policy allows without applying them")
+	depsCmd.AddCommand(depsUpdateCmd)
+	rootCmd.AddCommand(depsCmd)
+}
+
+// plannedUpdate is what the update policy decided for one outdated dependency.
+type plannedUpdate struct {
+	Path    string       `json:"path"`
+	Version string       `json:"version"`
+	Latest  string       `json:"latest"`
+	Policy  update.Level `json:"policy"`
+	Target  string       `json:"target,omitempty"` // empty if not updated
+	Reason  string       `json:"reason,omitempty"`
+}
+
+// outdated converts the plan back into a report entry.
+func (p plannedUpdate) outdated() OutdatedDep {
+	dep := OutdatedDep{Path: p.Path, Version: p.Version, Update: p.Latest}
+	if p.Policy != update.Never {
+		dep.Note = p.Reason
+	}
+	return dep
+}
+
+func runDepsUpdate(r runner.CommandRunner, deps []OutdatedDep, dryRun bool) error {
+	if isOffline() {
+		return fmt.Errorf("deps update queries the module proxy: %w", errOffline)
+	}
+	policy, err := loadUpdatePolicy()
+	if err != nil {
+		return err
+	}
+	plans := planUpdates(r, policy, deps, time.Now())
+	if jsonOutput {
+		if plans == nil {
+			plans = []plannedUpdate{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(plans); err != nil {
+			return err
+		}
+	} else if len(plans) == 0 {
+		fmt.Println("==> All dependencies are up to date")
+	} else if dryRun {
+		fmt.Println("==> Dependency updates (dry run)")
+		for _, p := range plans {
+			if p.Target != "" {
+				fmt.Printf("  UPDATE  %s: %s -> %s (%s)", p.Path, p.Version, p.Target, p.Policy)
+			} else {
+				fmt.Printf("  HOLD    %s: %s, latest %s (%s)", p.Path, p.Version, p.Latest, p.Policy)
+			}
+			if p.Reason != "" {
+				fmt.Printf(": %s", p.Reason)
+			}
+			fmt.Println()
+		}
+	}
+	if dryRun {
+		return nil
+	}
+	applyUpdates(r, plans, jsonOutput)
+	if !jsonOutput {
+		var manual []OutdatedDep
+		for _, p := range plans {
+			if p.Target == "" {
+				manual = append(manual, p.outdated())
+			}
+		}
+		PrintOutdatedDeps(manual)
+	}
+	return nil
+}
+
+// loadUpdatePolicy builds the update policy from go-toolchain.json. Without
+// rules, every dependency is "never": updates are reported, not applied.
+func loadUpdatePolicy() (update.Policy, error) {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return update.Policy{}, err
+	}
+	var policy update.Policy
+	for i, r := range cfg.Deps.Update {
+		rule, err := update.ParseRule(r.Match, r.Policy, r.MinAge)
+		if err != nil {
+			return update.Policy{}, fmt.Errorf("%s: deps.update[%d]: %w", config.FileName, i, err)
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+// planUpdates decides the target version of every outdated dependency.
+// Pinned dependencies are dropped.
+func planUpdates(r runner.CommandRunner, policy update.Policy, deps []OutdatedDep, now time.Time) []plannedUpdate {
+	var plans []plannedUpdate
+	for _, dep := range deps {
+		rule := policy.Rule(dep.Path)
+		if rule.Level == update.Pinned {
+			continue
+		}
+		plan := plannedUpdate{Path: dep.Path, Version: dep.Version, Latest: dep.Update, Policy: rule.Level}
+
+		// The newest version is all latest needs; patch, minor and min_age
+		// may have to fall back to an older tag
+		available := []string{dep.Update}
+		if rule.Level == update.Patch || rule.Level == update.Minor || rule.MinAge > 0 {
+			mods, err := build.QueryModules(r, []string{"-versions"}, dep.Path)
+			if err != nil || len(mods) != 1 {
+				plan.Reason = fmt.Sprintf("failed to list versions: %v", err)
+				plans = append(plans, plan)
+				continue
+			}
+			available = append(available, mods[0].Versions...)
+		}
+		timeOf := func(v string) (time.Time, error) {
+			return moduleVersionTime(r, dep.Path, v)
+		}
+		d := update.Select(rule, dep.Version, available, timeOf, now)
+		plan.Target = d.Version
+		plan.Reason = d.Reason
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// moduleVersionTime returns when a module version was published.
+func moduleVersionTime(r runner.CommandRunner, path, version string) (time.Time, error) {
+	mods, err := build.QueryModules(r, nil, path+"@"+version)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(mods) != 1 || mods[0].Time == "" {
+		return time.Time{}, fmt.Errorf("no time for %s@%s", path, version)
+	}
+	return time.Parse(time.RFC3339, mods[0].Time)
+}
+
+// applyUpdates runs go get for every planned update, then go mod tidy.
+// Returns true if any update was attempted.
+func applyUpdates(r runner.CommandRunner, plans []plannedUpdate, quiet bool) bool {
+	updated := false
+	for _, p := range plans {
+		if p.Target == "" {
+			continue
+		}
+		if !updated && !quiet {
+			fmt.Println()
+			fmt.Println("==> Updating dependencies:")
+		}
+		updated = true
+		if !quiet {
+			fmt.Printf("    %s: %s -> %s (%s)\n", p.Path, shortenVersion(p.Version), shortenVersion(p.Target), p.Policy)
+		}
+		proc, err := runner.Cmd("go", "get", p.Path+"@"+p.Target).WithQuiet().Run(r)
+		if err == nil {
+			err = proc.Wait()
+		}
+		if err != nil {
+			fmt.Printf("    %s failed to update: %v\n", warn("WARNING:"), err)
+		}
+	}
+	if updated {
+		if proc, err := runner.Cmd("go", "mod", "tidy").WithQuiet().Run(r); err == nil {
+			proc.Wait()
+		}
+	}
+	return updated
+}
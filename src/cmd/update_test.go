@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/update"
+)
+
+// newUpdateMock answers go list -m -versions and path@version queries for
+// example.com/lib, whose v1.2.1 is old and v1.2.2 and v1.3.0 are new.
+func newUpdateMock(now time.Time) *runner.Mock {
+	published := map[string]time.Time{
+		"v1.2.1": now.Add(-30 * 24 * time.Hour),
+		"v1.2.2": now.Add(-24 * time.Hour),
+		"v1.3.0": now.Add(-48 * time.Hour),
+	}
+	mock := runner.NewMock()
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		if cfg.IsCmd("go", "list", "-m", "-json", "-versions", "example.com/lib") {
+			return runner.MockProcess([]byte(`{"Path": "example.com/lib", "Version": "v1.2.0", "Versions": ["v1.1.0", "v1.2.0", "v1.2.1", "v1.2.2", "v1.3.0"]}`), nil), nil
+		}
+		if cfg.IsCmd("go", "list", "-m", "-json") && len(cfg.Args) == 4 {
+			if v, ok := strings.CutPrefix(cfg.Args[3], "example.com/lib@"); ok {
+				return runner.MockProcess([]byte(`{"Path": "example.com/lib", "Version": "`+v+`", "Time": "`+published[v].Format(time.RFC3339)+`"}`), nil), nil
+			}
+		}
+		return runner.MockProcess(nil, nil), nil
+	}
+	return mock
+}
+
+func TestLoadUpdatePolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	// Without rules, nothing is updated, not even the main module's org
+	os.WriteFile("go.mod", []byte("module github.com/org/app\n\ngo 1.24\n"), 0644)
+	policy, err := loadUpdatePolicy()
+	require.NoError(t, err)
+	assert.Equal(t, update.Never, policy.Rule("github.com/org/lib").Level)
+	assert.Equal(t, update.Never, policy.Rule("github.com/other/lib").Level)
+
+	os.WriteFile(config.FileName, []byte(`{"deps": {"update": [{"match": "github.com/org/*", "policy": "patch", "min_age": "3d"}]}}`), 0644)
+	policy, err = loadUpdatePolicy()
+	require.NoError(t, err)
+	assert.Equal(t, update.Rule{Pattern: "github.com/org/*", Level: update.Patch, MinAge: 72 * time.Hour}, policy.Rule("github.com/org/lib"))
+
+	os.WriteFile(config.FileName, []byte(`{"deps": {"update": [{"match": "*", "policy": "latest", "min_age": "soon"}]}}`), 0644)
+	_, err = loadUpdatePolicy()
+	assert.NotNil(t, err)
+}
+
+func TestDepsToCheck(t *testing.T) {
+	deps := []depInfo{
+		{Path: "github.com/org/lib", Version: "v0.0.0-20240101120000-abc123def456"},
+		{Path: "github.com/org/tagged", Version: "v1.4.2"},
+		{Path: "example.com/pinned", Version: "v1.0.0"},
+	}
+	// The default policy checks tagged versions as well as pseudo-versions
+	assert.Equal(t, deps, depsToCheck(update.Policy{}, deps))
+
+	policy := update.Policy{Rules: []update.Rule{{Pattern: "example.com/pinned", Level: update.Pinned}}}
+	assert.Equal(t, deps[:2], depsToCheck(policy, deps))
+}
+
+func TestPlanUpdates(t *testing.T) {
+	now := time.Now()
+	deps := []OutdatedDep{
+		{Path: "example.com/lib", Version: "v1.2.0", Update: "v1.3.0"},
+		{Path: "example.com/pinned", Version: "v1.0.0", Update: "v2.0.0"},
+		{Path: "example.com/other", Version: "v0.1.0", Update: "v0.2.0"},
+	}
+	rules := []update.Rule{
+		{Pattern: "example.com/pinned", Level: update.Pinned},
+		{Pattern: "example.com/other", Level: update.Never},
+	}
+
+	tests := []struct {
+		rule   update.Rule
+		target string
+		reason string
+	}{
+		{update.Rule{Level: update.Latest}, "v1.3.0", ""},
+		{update.Rule{Level: update.Patch}, "v1.2.2", ""},
+		{update.Rule{Level: update.Patch, MinAge: 7 * 24 * time.Hour}, "v1.2.1", "newer versions younger than 7d: v1.2.2 (1d old)"},
+		{update.Rule{Level: update.Latest, MinAge: 7 * 24 * time.Hour}, "v1.2.1", "newer versions younger than 7d: v1.3.0 (2d old)"},
+	}
+	for _, tt := range tests {
+		tt.rule.Pattern = "example.com/lib"
+		policy := update.Policy{Rules: append([]update.Rule{tt.rule}, rules...)}
+		plans := planUpdates(newUpdateMock(now), policy, deps, now)
+		require.Equal(t, 2, len(plans))
+		assert.Equal(t, tt.target, plans[0].Target, tt.rule.Level.String())
+		assert.Equal(t, tt.reason, plans[0].Reason, tt.rule.Level.String())
+
+		assert.Equal(t, "example.com/other", plans[1].Path)
+		assert.Equal(t, "", plans[1].Target)
+		assert.Equal(t, OutdatedDep{Path: "example.com/other", Version: "v0.1.0", Update: "v0.2.0"}, plans[1].outdated())
+	}
+}
+
+func TestPlanUpdatesVersionListFails(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "-versions", "example.com/lib"}, nil, os.ErrNotExist)
+	policy := update.Policy{Rules: []update.Rule{{Pattern: "*", Level: update.Minor}}}
+	plans := planUpdates(mock, policy, []OutdatedDep{{Path: "example.com/lib", Version: "v1.0.0", Update: "v1.1.0"}}, time.Now())
+	require.Equal(t, 1, len(plans))
+	assert.Equal(t, "", plans[0].Target)
+	assert.Contains(t, plans[0].Reason, "failed to list versions")
+	assert.Equal(t, plans[0].Reason, plans[0].outdated().Note)
+}
+
+func TestApplyUpdates(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"get", "example.com/bad@v2.0.0"}, nil, os.ErrNotExist)
+	plans := []plannedUpdate{
+		{Path: "example.com/lib", Version: "v1.0.0", Target: "v1.0.1", Policy: update.Patch},
+		{Path: "example.com/other", Version: "v1.0.0", Latest: "v2.0.0", Policy: update.Never},
+		{Path: "example.com/bad", Version: "v1.0.0", Target: "v2.0.0", Policy: update.Latest},
+	}
+	assert.True(t, applyUpdates(mock, plans, false))
+
+	var cmds []string
+	for _, c := range mock.Calls() {
+		cmds = append(cmds, c.Name+" "+strings.Join(c.Args, " "))
+	}
+	assert.Equal(t, []string{"go get example.com/lib@v1.0.1", "go get example.com/bad@v2.0.0", "go mod tidy"}, cmds)
+
+	mock = runner.NewMock()
+	assert.False(t, applyUpdates(mock, plans[1:2], false))
+	assert.Equal(t, 0, len(mock.Calls()))
+}
+
+func TestRunDepsUpdate(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+	os.WriteFile(config.FileName, []byte(`{"deps": {"update": [{"match": "example.com/lib", "policy": "patch"}]}}`), 0644)
+
+	deps := []OutdatedDep{{Path: "example.com/lib", Version: "v1.2.0", Update: "v1.3.0"}}
+
+	mock := newUpdateMock(time.Now())
+	require.NoError(t, runDepsUpdate(mock, deps, true))
+	for _, c := range mock.Calls() {
+		assert.False(t, c.IsCmd("go", "get"))
+	}
+
+	mock = newUpdateMock(time.Now())
+	require.NoError(t, runDepsUpdate(mock, deps, false))
+	found := false
+	for _, c := range mock.Calls() {
+		found = found || c.IsCmd("go", "get", "example.com/lib@v1.2.2")
+	}
+	assert.True(t, found)
+
+	oldJSON := jsonOutput
+	jsonOutput = true
+	defer func() { jsonOutput = oldJSON }()
+	require.NoError(t, runDepsUpdate(newUpdateMock(time.Now()), nil, true))
+}
+
+func TestWaitForOutdatedDepsAppliesPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+	os.WriteFile("go.mod", []byte("module example.com/app\n\ngo 1.24\n"), 0644)
+	os.WriteFile(config.FileName, []byte(`{"deps": {"update": [{"match": "example.com/lib", "policy": "minor"}]}}`), 0644)
+
+	dc := &DepChecker{
+		doneCh: make(chan struct{}),
+		done:   true,
+		results: []OutdatedDep{
+			{Path: "example.com/lib", Version: "v1.2.0", Update: "v1.3.0"},
+			{Path: "golang.org/x/mod", Version: "v0.29.0", Update: "v0.30.0"},
+		},
+	}
+	close(dc.doneCh)
+
+	mock := newUpdateMock(time.Now())
+	assert.True(t, WaitForOutdatedDeps(mock, dc))
+	var gets []string
+	for _, c := range mock.Calls() {
+		if c.IsCmd("go", "get") {
+			gets = append(gets, c.Args[1])
+		}
+	}
+	assert.Equal(t, []string{"example.com/lib@v1.3.0"}, gets)
+}
+
+func TestWaitForOutdatedDepsWithoutPolicyOnlyReports(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+	os.WriteFile("go.mod", []byte("module github.com/org/app\n\ngo 1.24\n"), 0644)
+
+	dc := &DepChecker{
+		doneCh: make(chan struct{}),
+		done:   true,
+		results: []OutdatedDep{
+			{Path: "github.com/org/lib", Version: "v0.0.0-20240101120000-abc123def456", Update: "v0.0.0-20250101120000-def456abc123"},
+			{Path: "github.com/org/tagged", Version: "v1.2.0", Update: "v1.3.0"},
+		},
+	}
+	close(dc.doneCh)
+
+	mock := newUpdateMock(time.Now())
+	var updated bool
+	output := captureStdout(t, func() error {
+		updated = WaitForOutdatedDeps(mock, dc)
+		return nil
+	})
+	assert.False(t, updated)
+	assert.Contains(t, output, "github.com/org/lib")
+	assert.Contains(t, output, "github.com/org/tagged")
+	for _, c := range mock.Calls() {
+		assert.False(t, c.IsCmd("go", "get"), "unexpected %v", c.Args)
+		assert.False(t, c.IsCmd("go", "mod", "tidy"), "unexpected %v", c.Args)
+	}
+}
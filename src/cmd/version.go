@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -73,6 +74,38 @@ var httpClient = &http.Client{Timeout: 10 * time.Second}
 type commitInfo struct {
 	sha       string
 	timestamp int64
+	checkedAt int64 // when it was fetched, if served from the cache
+}
+
+// latestCommit fetches the latest commit from GitHub and caches it. Offline,
+// the cached commit is returned instead, or errOffline if there is none.
+func latestCommit() (*commitInfo, error) {
+	db, _ := openCacheDB() // caching is best-effort
+	if db != nil {
+		defer db.Close()
+	}
+
+	if isOffline() {
+		if db == nil {
+			return nil, errOffline
+		}
+		c := &commitInfo{}
+		err := db.QueryRow(`SELECT sha, timestamp, checked_at FROM commits WHERE repo = ?`, githubRepo).Scan(&c.sha, &c.timestamp, &c.checkedAt)
+		if err != nil {
+			return nil, errOffline
+		}
+		return c, nil
+	}
+
+	c, err := fetchLatestCommitFromGitHub()
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		_, _ = db.Exec(`INSERT OR REPLACE INTO commits (repo, sha, timestamp, checked_at) VALUES (?, ?, ?, ?)`,
+			githubRepo, c.sha, c.timestamp, time.Now().Unix())
+	}
+	return c, nil
 }
 
 func printStaleness() {
@@ -86,7 +119,11 @@ func printStaleness() {
 		return
 	}
 
-	latest, err := fetchLatestCommitFromGitHub()
+	latest, err := latestCommit()
+	if errors.Is(err, errOffline) {
+		fmt.Println("\nSkipped update check (offline).")
+		return
+	}
 	if err != nil {
 		fmt.Printf("\nCould not check for updates: %v\n", err)
 		return
@@ -100,7 +137,9 @@ func printStaleness() {
 	diff := time.Duration(latest.timestamp-builtTs) * time.Second
 	msg := fmt.Sprintf("\nBuild is %s behind latest commit.", formatDuration(diff))
 
-	if count, err := fetchCommitsBehind(buildCommit, latest.sha); err == nil && count > 0 {
+	if latest.checkedAt != 0 {
+		msg += fmt.Sprintf(" (as of %s ago, offline)", formatDuration(time.Since(time.Unix(latest.checkedAt, 0))))
+	} else if count, err := fetchCommitsBehind(buildCommit, latest.sha); err == nil && count > 0 {
 		msg += fmt.Sprintf(" (%d commits)", count)
 	}
 
@@ -217,19 +256,41 @@ func (g gitInfo) ldflags() string {
 	if g.timestamp != "" {
 		flags = append(flags, fmt.Sprintf("-X %s.buildTimestamp=%s", ldflagsPrefix, g.timestamp))
 	}
-	// Build date: SOURCE_DATE_EPOCH > git commit timestamp
-	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
-		if ts, err := strconv.ParseInt(epoch, 10, 64); err == nil {
-			flags = append(flags, fmt.Sprintf("-X %s.buildDate=%s", ldflagsPrefix, time.Unix(ts, 0).UTC().Format(time.RFC3339)))
-		}
-	} else if g.timestamp != "" {
-		if ts, err := strconv.ParseInt(g.timestamp, 10, 64); err == nil {
-			flags = append(flags, fmt.Sprintf("-X %s.buildDate=%s", ldflagsPrefix, time.Unix(ts, 0).UTC().Format(time.RFC3339)))
-		}
+	if date, ok := g.sourceDate(); ok {
+		flags = append(flags, fmt.Sprintf("-X %s.buildDate=%s", ldflagsPrefix, date.Format(time.RFC3339)))
 	}
 	return strings.Join(flags, " ")
 }
 
+// sourceDate returns the reproducible build date: SOURCE_DATE_EPOCH if set,
+// otherwise the git commit timestamp. ok is false if neither is usable.
+func (g gitInfo) sourceDate() (date time.Time, ok bool) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		ts, err := strconv.ParseInt(epoch, 10, 64)
+		return time.Unix(ts, 0).UTC(), err == nil
+	}
+	if g.timestamp != "" {
+		ts, err := strconv.ParseInt(g.timestamp, 10, 64)
+		return time.Unix(ts, 0).UTC(), err == nil
+	}
+	return time.Time{}, false
+}
+
+// fileDate returns the modification time for files in archives and
+// packages: the source date, or the zero time if there is none, which still
+// beats wall-clock time. An unparseable SOURCE_DATE_EPOCH is an error rather
+// than a silent fallback.
+func (g gitInfo) fileDate() (time.Time, error) {
+	date, ok := g.sourceDate()
+	if ok {
+		return date, nil
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q (want seconds since 1970-01-01 UTC)", epoch)
+	}
+	return time.Time{}, nil
+}
+
 func (g gitInfo) String() string {
 	if g.version != "" {
 		return g.version
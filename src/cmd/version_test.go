@@ -112,6 +112,22 @@ func TestGitInfoLdflagsNoTimestamp(t *testing.T) {
 	assert.NotContains(t, ldflags, "buildDate")
 }
 
+func TestGitInfoFileDate(t *testing.T) {
+	info := gitInfo{timestamp: "1600000000"}
+	date, err := info.fileDate()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1600000000), date.Unix())
+
+	date, err = gitInfo{}.fileDate()
+	require.NoError(t, err)
+	assert.True(t, date.IsZero())
+
+	t.Setenv("SOURCE_DATE_EPOCH", "2023-11-14")
+	_, err = info.fileDate()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "SOURCE_DATE_EPOCH")
+}
+
 func TestGitInfoString(t *testing.T) {
 	tests := []struct {
 		info gitInfo
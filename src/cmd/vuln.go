@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/vuln"
+)
+
+var vulnDB string
+
+var vulnCmd = &cobra.Command{
+	Use:   "vuln",
+	Short: "Check dependencies against an offline OSV vulnerability database",
+	Long: `Matches every module in the build list, and the Go standard library, against
+an OSV vulnerability database directory on disk (e.g. a mirror of
+vuln.go.dev). Nothing is fetched over the network.
+
+Each finding is classified by how far it reaches into the module's (non-test)
+code: "module" (a vulnerable version is required), "imported" (a vulnerable
+package is imported) or "called" (a vulnerable function is reachable in the
+call graph). Only called findings at or above vuln.fail_on (default high)
+fail; the rest are warnings.
+
+The database comes from --vuln-db, vuln.db in go-toolchain.json, or a local
+GOVULNDB (a path or file:// URL). When one is configured, the scan also runs
+after vet in the default workflow.`,
+	SilenceUsage: true,
+	RunE:         runVuln,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&vulnDB, "vuln-db", "", "OSV vulnerability database directory (enables the vulnerability scan)")
+	rootCmd.AddCommand(vulnCmd)
+}
+
+func runVuln(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	dir := vulnDBDir(cfg.Vuln)
+	if dir == "" {
+		return fmt.Errorf("no vulnerability database: pass --vuln-db, set \"vuln.db\" in %s, or set GOVULNDB to a local directory", config.FileName)
+	}
+	pkgs, err := loadVulnPackages()
+	if err != nil {
+		return err
+	}
+	scan, err := scanVulns(runner.New(), cfg.Vuln, dir, pkgs)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(scan.findings); err != nil {
+			return err
+		}
+	} else {
+		scan.print()
+	}
+	return scan.err()
+}
+
+// runVulnPhase scans for vulnerabilities after vet when a database is
+// configured, using the packages vet loaded for reachability, or loading
+// them if vet didn't.
+func runVulnPhase(r runner.CommandRunner, pkgs []*packages.Package, quiet bool) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	dir := vulnDBDir(cfg.Vuln)
+	if dir == "" {
+		return nil
+	}
+	if pkgs == nil {
+		if pkgs, err = loadVulnPackages(); err != nil {
+			return err
+		}
+	}
+	scan, err := scanVulns(r, cfg.Vuln, dir, pkgs)
+	if err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Printf("==> Vulnerability scan (%s)\n", dir)
+		scan.print()
+	}
+	return scan.err()
+}
+
+// vulnDBDir returns the database directory from --vuln-db, the config, or
+// GOVULNDB. Remote GOVULNDB URLs are ignored since the scan is offline.
+func vulnDBDir(cfg config.Vuln) string {
+	if vulnDB != "" {
+		return vulnDB
+	}
+	if cfg.DB != "" {
+		return cfg.DB
+	}
+	env := os.Getenv("GOVULNDB")
+	if path, ok := strings.CutPrefix(env, "file://"); ok {
+		return path
+	}
+	if filepath.IsAbs(env) {
+		return env
+	}
+	return ""
+}
+
+// loadVulnPackages loads the module's packages for reachability analysis.
+func loadVulnPackages() ([]*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: vuln.LoadMode}, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+	return pkgs, nil
+}
+
+// vulnScan is the outcome of a vulnerability scan.
+type vulnScan struct {
+	findings []vuln.Finding
+	policy   vuln.Policy
+	entries  int // database size
+}
+
+// scanVulns checks the build list against the database at dir, dropping
+// ignored findings.
+func scanVulns(r runner.CommandRunner, cfg config.Vuln, dir string, pkgs []*packages.Package) (*vulnScan, error) {
+	policy, err := vuln.ParsePolicy(cfg.FailOn, cfg.UnknownSeverity, cfg.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	db, err := vuln.LoadDB(dir)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := build.ListModules(r)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := vuln.Scan(db, mods, queryGoEnv(r).goVersion, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	findings = policy.Filter(findings)
+	if findings == nil {
+		findings = []vuln.Finding{}
+	}
+	return &vulnScan{findings: findings, policy: policy, entries: db.Entries}, nil
+}
+
+// err fails the scan if any finding fails the policy.
+func (s *vulnScan) err() error {
+	failing := 0
+	for _, f := range s.findings {
+		if s.policy.Fails(f) {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return fmt.Errorf("%d reachable vulnerabilities at or above %s severity", failing, s.policy.FailOn)
+	}
+	return nil
+}
+
+func (s *vulnScan) print() {
+	if len(s.findings) == 0 {
+		fmt.Printf("  No known vulnerabilities (%d database entries)\n", s.entries)
+		return
+	}
+	for _, f := range s.findings {
+		status := "WARN"
+		if s.policy.Fails(f) {
+			status = "FAIL"
+		}
+		fixed := "no fix available"
+		if f.Fixed != "" {
+			fixed = "fixed in " + f.Fixed
+		}
+		fmt.Printf("  %s %-15s %-8s %s@%s (%s): %s\n", status, f.ID, s.policy.Severity(f), f.Module, f.Version, fixed, f.Summary)
+		switch f.Level {
+		case vuln.LevelCalled:
+			for i, step := range f.Trace {
+				arrow := "  "
+				if i > 0 {
+					arrow = "→ "
+				}
+				fmt.Printf("       %s%s\n", arrow, step)
+			}
+			if len(f.Trace) == 0 {
+				fmt.Printf("       uses %s\n", strings.Join(f.Symbols, ", "))
+			}
+		case vuln.LevelImported:
+			if len(f.Unanalyzed) > 0 {
+				fmt.Printf("       imported; no call to a vulnerable function found, but %s couldn't be analyzed\n", strings.Join(f.Unanalyzed, ", "))
+			} else {
+				fmt.Println("       imported, but no vulnerable function is called")
+			}
+		default:
+			fmt.Println("       required, but no vulnerable package is imported")
+		}
+	}
+}
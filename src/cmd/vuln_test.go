@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/wow-look-at-my/go-toolchain/src/config"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+	"github.com/wow-look-at-my/go-toolchain/src/vuln"
+)
+
+// The vuln package's fixtures: an app requiring example.com/dep v1.1.0,
+// and a database with entries for it and the standard library
+var (
+	vulnTestApp, _ = filepath.Abs("../vuln/testdata/app")
+	vulnTestDB, _  = filepath.Abs("../vuln/testdata/db")
+)
+
+func newVulnMock() *runner.Mock {
+	mock := runner.NewMock()
+	mock.SetResponse("go", []string{"list", "-m", "-json", "all"},
+		[]byte(`{"Path": "example.com/app", "Main": true}
+{"Path": "example.com/dep", "Version": "v1.1.0"}
+`), nil)
+	mock.SetResponse("go", []string{"env", "GOOS", "GOARCH", "GOVERSION"}, []byte("linux\namd64\ngo1.22.1\n"), nil)
+	return mock
+}
+
+func TestVulnDBDir(t *testing.T) {
+	oldDB := vulnDB
+	defer func() { vulnDB = oldDB }()
+
+	vulnDB = ""
+	t.Setenv("GOVULNDB", "")
+	assert.Equal(t, "", vulnDBDir(config.Vuln{}))
+
+	t.Setenv("GOVULNDB", "https://vuln.go.dev")
+	assert.Equal(t, "", vulnDBDir(config.Vuln{}))
+	t.Setenv("GOVULNDB", "file:///srv/vulndb")
+	assert.Equal(t, "/srv/vulndb", vulnDBDir(config.Vuln{}))
+	t.Setenv("GOVULNDB", "/mnt/vulndb")
+	assert.Equal(t, "/mnt/vulndb", vulnDBDir(config.Vuln{}))
+
+	assert.Equal(t, "osv", vulnDBDir(config.Vuln{DB: "osv"}))
+	vulnDB = "flag"
+	assert.Equal(t, "flag", vulnDBDir(config.Vuln{DB: "osv"}))
+}
+
+func TestRunVulnPhaseSkipsWithoutDB(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+	t.Setenv("GOVULNDB", "")
+
+	mock := runner.NewMock()
+	require.NoError(t, runVulnPhase(mock, nil, false))
+	assert.Equal(t, 0, len(mock.Calls()))
+}
+
+func TestScanVulns(t *testing.T) {
+	pkgs, err := packages.Load(&packages.Config{Mode: vuln.LoadMode, Dir: vulnTestApp}, "./...")
+	require.NoError(t, err)
+
+	scan, err := scanVulns(newVulnMock(), config.Vuln{}, vulnTestDB, pkgs)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(scan.findings))
+	assert.Equal(t, vuln.LevelCalled, scan.findings[0].Level)
+	// Parser.Parse (high) and fmt.Println (critical) are called
+	assert.Equal(t, "2 reachable vulnerabilities at or above HIGH severity", scan.err().Error())
+
+	scan, err = scanVulns(newVulnMock(), config.Vuln{FailOn: "critical", Ignore: []string{"GO-2099-0005"}}, vulnTestDB, pkgs)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(scan.findings))
+	assert.Nil(t, scan.err())
+
+	_, err = scanVulns(newVulnMock(), config.Vuln{FailOn: "severe"}, vulnTestDB, pkgs)
+	assert.NotNil(t, err)
+	_, err = scanVulns(newVulnMock(), config.Vuln{}, filepath.Join(vulnTestDB, "missing"), pkgs)
+	assert.NotNil(t, err)
+}
+
+func TestRunVulnPhase(t *testing.T) {
+	oldWd, _ := os.Getwd()
+	os.Chdir(vulnTestApp)
+	defer os.Chdir(oldWd)
+
+	oldDB := vulnDB
+	vulnDB = vulnTestDB
+	defer func() { vulnDB = oldDB }()
+
+	err := runVulnPhase(newVulnMock(), nil, false)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "reachable vulnerabilities")
+}
+
+func TestVulnScanPrintUnanalyzed(t *testing.T) {
+	policy, err := vuln.ParsePolicy("", "", nil)
+	require.NoError(t, err)
+	scan := &vulnScan{policy: policy, findings: []vuln.Finding{
+		{ID: "GO-2099-0002", Module: "example.com/dep", Version: "v1.1.0", Level: vuln.LevelImported, Unanalyzed: []string{"internal/poll"}},
+		{ID: "GO-2099-0003", Module: "example.com/dep", Version: "v1.1.0", Level: vuln.LevelImported},
+	}}
+	output := captureStdout(t, func() error { scan.print(); return nil })
+	assert.Contains(t, output, "no call to a vulnerable function found, but internal/poll couldn't be analyzed")
+	assert.Contains(t, output, "imported, but no vulnerable function is called")
+}
@@ -0,0 +1,344 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FileName is the optional per-module project config, read from the module root.
+const FileName = "go-toolchain.json"
+
+// Config holds project-level settings from go-toolchain.json.
+// Every section is optional; a missing file yields the zero Config.
+type Config struct {
+	Build    Build    `json:"build"`
+	Matrix   Matrix   `json:"matrix"`
+	Release  Release  `json:"release"`
+	Size     Size     `json:"size"`
+	SBOM     SBOM     `json:"sbom"`
+	Vuln     Vuln     `json:"vuln"`
+	Licenses Licenses `json:"licenses"`
+	Deps     Deps     `json:"deps"`
+	Bench    Bench    `json:"bench"`
+}
+
+// Bench configures the benchmark regression gate: bench run and the
+// post-build benchmark step fail when a statistically significant
+// regression against the baseline exceeds a threshold.
+type Bench struct {
+	// Base compares against the merge base of HEAD and this ref (e.g.
+	// origin/main) instead of the most recent stored results.
+	Base string `json:"base,omitempty"`
+	// Baseline pins the comparison to a commit or tag with stored results.
+	// Overrides Base.
+	Baseline string `json:"baseline,omitempty"`
+	// Thresholds limit regressions per package or benchmark. For each
+	// benchmark and metric the first matching threshold that sets a limit
+	// applies, so put a catch-all entry last.
+	Thresholds []BenchThreshold `json:"thresholds,omitempty"`
+	// StrictEnv refuses to compare results measured in incompatible
+	// environments (platform, CPU model, GOMAXPROCS) instead of warning
+	// and skipping the regression gate.
+	StrictEnv bool `json:"strict_env,omitempty"`
+	// Stabilize checks the CPU frequency governor and waits for the load
+	// average to drop to MaxLoad before running benchmarks.
+	Stabilize bool    `json:"stabilize,omitempty"`
+	MaxLoad   float64 `json:"max_load,omitempty"` // default: a quarter of the CPUs, at least 1
+	// Remote is the git remote bench push and bench pull share results
+	// through. Comparisons fetch its results first unless NoFetch is set.
+	Remote  string `json:"remote,omitempty"` // default: origin
+	NoFetch bool   `json:"no_fetch,omitempty"`
+}
+
+// BenchThreshold limits regressions of the matching benchmarks, in percent
+// over the baseline. An unset limit isn't checked; 0 fails any significant
+// regression.
+type BenchThreshold struct {
+	// Package is a comma-separated list of package path globs matched
+	// against path prefixes, as in deps.update. Empty matches every package.
+	Package string `json:"package,omitempty"`
+	// Benchmark is a name glob, with or without the "Benchmark" prefix,
+	// e.g. "Parse*". A top-level name also matches its sub-benchmarks.
+	// Empty matches every benchmark.
+	Benchmark string `json:"benchmark,omitempty"`
+
+	MaxTimePercent   *float64 `json:"max_time_percent,omitempty"`
+	MaxBytesPercent  *float64 `json:"max_bytes_percent,omitempty"`
+	MaxAllocsPercent *float64 `json:"max_allocs_percent,omitempty"`
+}
+
+// Deps configures dependency updates.
+type Deps struct {
+	// Update lists update rules; the first rule whose pattern matches a
+	// module applies. Without rules, modules from the main module's org
+	// (github.com/org/*) update to latest and the rest are only reported.
+	Update []UpdateRule `json:"update,omitempty"`
+}
+
+// UpdateRule sets how far the modules matching Match may be updated.
+type UpdateRule struct {
+	// Match is a comma-separated list of module path globs matched against
+	// path prefixes, as in GOPRIVATE: "github.com/org/*", "*".
+	Match string `json:"match"`
+	// Policy is never (only report), patch, minor, latest, or pinned (keep
+	// the current version and stop reporting updates).
+	Policy string `json:"policy"`
+	// MinAge skips versions published less than this long ago, e.g. "72h"
+	// or "7d".
+	MinAge string `json:"min_age,omitempty"`
+}
+
+// UpdatePolicies are the supported deps.update[].policy values.
+var UpdatePolicies = []string{"never", "patch", "minor", "latest", "pinned"}
+
+// Licenses configures the dependency license inventory. The policy is
+// checked before building whenever Allow or Deny is set.
+type Licenses struct {
+	// Allow lists the SPDX IDs every dependency license must match. Modules
+	// whose license is not recognized fail when this is set.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists SPDX IDs that fail the build, e.g. AGPL-3.0.
+	Deny []string `json:"deny,omitempty"`
+	// Overrides maps module paths to an SPDX expression, for modules whose
+	// license file is missing or not recognized.
+	Overrides map[string]string `json:"overrides,omitempty"`
+	// Notices writes THIRD_PARTY_NOTICES.txt into the matrix output and
+	// bundles it into every archive and package.
+	Notices bool `json:"notices,omitempty"`
+}
+
+// Vuln configures the offline vulnerability scan, which runs after vet.
+type Vuln struct {
+	// DB is an OSV database directory, e.g. a mirror of vuln.go.dev. The
+	// scan is skipped when neither this, --vuln-db nor GOVULNDB is set.
+	DB string `json:"db,omitempty"`
+	// FailOn fails the build for reachable vulnerabilities of at least this
+	// severity: low, medium, high, critical, or none to only warn (default high).
+	FailOn string `json:"fail_on,omitempty"`
+	// UnknownSeverity is assumed for unrated entries, which includes every
+	// entry in the Go vulnerability database (default high).
+	UnknownSeverity string `json:"unknown_severity,omitempty"`
+	// Ignore lists vulnerability IDs or aliases (CVE-..., GHSA-...) to skip.
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// VulnSeverities are the supported vuln.fail_on and vuln.unknown_severity
+// values; fail_on also accepts "none".
+var VulnSeverities = []string{"low", "medium", "high", "critical"}
+
+// SBOM configures software bills of materials written next to every built
+// binary.
+type SBOM struct {
+	// Formats lists the SBOM formats to write: cyclonedx, spdx. Passing
+	// --sbom writes both.
+	Formats []string `json:"formats,omitempty"`
+}
+
+// SBOMFormats are the supported sbom.formats values.
+var SBOMFormats = []string{"cyclonedx", "spdx"}
+
+// Size configures binary size tracking. Sizes are recorded in git notes
+// after every build and compared against a base commit.
+type Size struct {
+	// MaxGrowthPercent fails the build when a binary grows by more than this
+	// percentage over the base commit. Zero disables the check.
+	MaxGrowthPercent float64 `json:"max_growth_percent,omitempty"`
+	// MaxGrowthBytes fails the build when a binary grows by more than this
+	// many bytes over the base commit. Zero disables the check.
+	MaxGrowthBytes int64 `json:"max_growth_bytes,omitempty"`
+	// Base compares against the merge base of HEAD and this ref (e.g.
+	// origin/main) instead of the nearest ancestor with recorded sizes.
+	Base string `json:"base,omitempty"`
+}
+
+// Matrix configures which platforms the matrix command builds.
+type Matrix struct {
+	// Platforms lists os/arch or os/arch/variant entries to build, e.g.
+	// "linux/arm/7" or "linux/amd64/v3". Overrides the --os x --arch product.
+	Platforms []string `json:"platforms,omitempty"`
+	// Exclude drops matching platforms; * matches any element, e.g. "windows/*".
+	Exclude []string `json:"exclude,omitempty"`
+	// CToolchains picks the C compiler for cgo targets; the first entry whose
+	// platform pattern matches is used.
+	CToolchains []CToolchain `json:"c_toolchains,omitempty"`
+}
+
+// CToolchain configures cross C compilation for matching platforms.
+type CToolchain struct {
+	Platform  string `json:"platform"`             // os/arch pattern, e.g. "linux/arm64" or "windows/*"
+	CC        string `json:"cc,omitempty"`         // C compiler command
+	CXX       string `json:"cxx,omitempty"`        // C++ compiler command
+	Sysroot   string `json:"sysroot,omitempty"`    // passed as --sysroot to the compiler and linker
+	Zig       bool   `json:"zig,omitempty"`        // use `zig cc` / `zig c++` as CC and CXX
+	ZigTarget string `json:"zig_target,omitempty"` // zig -target triple (default: derived from the platform)
+}
+
+// Build configures which packages are built and how.
+type Build struct {
+	// Targets declares build targets explicitly. When empty, all main
+	// packages are auto-discovered.
+	Targets []Target `json:"targets,omitempty"`
+	// AutoDiscover adds auto-discovered main packages alongside the declared
+	// Targets. Ignored when Targets is empty (discovery is then implied).
+	AutoDiscover bool `json:"auto_discover,omitempty"`
+	// Exclude lists packages (import paths or ./relative dirs) to drop from
+	// auto-discovery.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Release configures packaging of matrix build outputs.
+type Release struct {
+	// Archives packs each platform's binaries into a .tar.gz (.zip on
+	// windows), same as passing --archive to matrix.
+	Archives bool `json:"archives,omitempty"`
+	// Name is the archive name prefix (default: last element of the module path).
+	Name string `json:"name,omitempty"`
+	// Files lists extra files (globs, relative to the module root) to bundle
+	// alongside README and LICENSE files.
+	Files []string `json:"files,omitempty"`
+	// Packages configures .deb/.rpm/.apk generation from linux builds.
+	Packages Packages `json:"packages"`
+}
+
+// Packages configures Linux packages built from the matrix's linux outputs.
+type Packages struct {
+	// Formats lists the package formats to build: deb, rpm, apk. Same as
+	// passing --packages to matrix.
+	Formats     []string `json:"formats,omitempty"`
+	Name        string   `json:"name,omitempty"` // package name (default: release name, then module name)
+	Maintainer  string   `json:"maintainer,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Homepage    string   `json:"homepage,omitempty"`
+	License     string   `json:"license,omitempty"`
+	Depends     []string `json:"depends,omitempty"`
+	// BinDir is where binaries are installed (default /usr/bin).
+	BinDir string `json:"bin_dir,omitempty"`
+	// Files lists extra files to install, e.g. configs and man pages.
+	Files []PackageFile `json:"files,omitempty"`
+}
+
+// PackageFile installs a file from the module into the package.
+type PackageFile struct {
+	Source string `json:"source"`         // path relative to the module root
+	Dest   string `json:"dest"`           // absolute install path
+	Mode   string `json:"mode,omitempty"` // octal permissions (default "0644")
+}
+
+// PackageFormats are the supported release.packages.formats values.
+var PackageFormats = []string{"deb", "rpm", "apk"}
+
+// Target declares a single main package to build.
+type Target struct {
+	Package  string            `json:"package"`            // import path or ./relative dir
+	Output   string            `json:"output,omitempty"`   // binary name (default: derived from package)
+	Tags     []string          `json:"tags,omitempty"`     // -tags
+	Trimpath bool              `json:"trimpath,omitempty"` // -trimpath
+	Gcflags  string            `json:"gcflags,omitempty"`  // -gcflags
+	Ldflags  string            `json:"ldflags,omitempty"`  // appended to the version ldflags
+	Vars     map[string]string `json:"vars,omitempty"`     // extra -X importpath.name=value
+	CGO      bool              `json:"cgo,omitempty"`      // matrix builds use CGO_ENABLED=1
+}
+
+// Load reads go-toolchain.json from dir. A missing file is not an error.
+func Load(dir string) (*Config, error) {
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	for i, t := range cfg.Build.Targets {
+		if t.Package == "" {
+			return nil, fmt.Errorf("%s: build.targets[%d] is missing \"package\"", FileName, i)
+		}
+		for name, v := range t.Vars {
+			// go splits -ldflags on spaces; such a value needs quoting in one kind of quote
+			if strings.ContainsAny(v, " \t\n\r") && strings.Contains(v, "'") && strings.Contains(v, `"`) {
+				return nil, fmt.Errorf("%s: build.targets[%d].vars[%q]: a value with spaces can't contain both ' and \"", FileName, i, name)
+			}
+		}
+	}
+	for i, tc := range cfg.Matrix.CToolchains {
+		if tc.Platform == "" {
+			return nil, fmt.Errorf("%s: matrix.c_toolchains[%d] is missing \"platform\"", FileName, i)
+		}
+		if tc.Zig && (tc.CC != "" || tc.CXX != "") {
+			return nil, fmt.Errorf("%s: matrix.c_toolchains[%d] sets both \"zig\" and \"cc\"/\"cxx\"", FileName, i)
+		}
+	}
+	for _, f := range cfg.Release.Packages.Formats {
+		if !slices.Contains(PackageFormats, f) {
+			return nil, fmt.Errorf("%s: unknown package format %q (use: deb, rpm, apk)", FileName, f)
+		}
+	}
+	for _, f := range cfg.SBOM.Formats {
+		if !slices.Contains(SBOMFormats, f) {
+			return nil, fmt.Errorf("%s: unknown sbom format %q (use: cyclonedx, spdx)", FileName, f)
+		}
+	}
+	if v := cfg.Vuln.FailOn; v != "" && v != "none" && !slices.Contains(VulnSeverities, v) {
+		return nil, fmt.Errorf("%s: unknown vuln.fail_on %q (use: low, medium, high, critical, none)", FileName, v)
+	}
+	if v := cfg.Vuln.UnknownSeverity; v != "" && !slices.Contains(VulnSeverities, v) {
+		return nil, fmt.Errorf("%s: unknown vuln.unknown_severity %q (use: low, medium, high, critical)", FileName, v)
+	}
+	for i, rule := range cfg.Deps.Update {
+		if rule.Match == "" {
+			return nil, fmt.Errorf("%s: deps.update[%d] is missing \"match\"", FileName, i)
+		}
+		if !slices.Contains(UpdatePolicies, rule.Policy) {
+			return nil, fmt.Errorf("%s: deps.update[%d]: unknown policy %q (use: never, patch, minor, latest, pinned)", FileName, i, rule.Policy)
+		}
+	}
+	for i, t := range cfg.Bench.Thresholds {
+		if _, err := filepath.Match(t.Benchmark, ""); err != nil {
+			return nil, fmt.Errorf("%s: bench.thresholds[%d]: invalid benchmark pattern %q", FileName, i, t.Benchmark)
+		}
+		for _, max := range []*float64{t.MaxTimePercent, t.MaxBytesPercent, t.MaxAllocsPercent} {
+			if max != nil && *max <= 0 {
+				return nil, fmt.Errorf("%s: bench.thresholds[%d]: limits must be positive", FileName, i)
+			}
+		}
+	}
+	if cfg.Bench.MaxLoad < 0 {
+		return nil, fmt.Errorf("%s: bench.max_load can't be negative", FileName)
+	}
+	for i, f := range cfg.Release.Packages.Files {
+		if f.Source == "" || !strings.HasPrefix(f.Dest, "/") {
+			return nil, fmt.Errorf("%s: release.packages.files[%d] needs a \"source\" and an absolute \"dest\"", FileName, i)
+		}
+		if _, err := f.FileMode(); err != nil {
+			return nil, fmt.Errorf("%s: release.packages.files[%d]: %w", FileName, i, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// FileMode parses Mode, defaulting to 0644.
+func (f PackageFile) FileMode() (fs.FileMode, error) {
+	if f.Mode == "" {
+		return 0644, nil
+	}
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q (want octal, e.g. \"0755\")", f.Mode)
+	}
+	return fs.FileMode(mode), nil
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(cfg.Build.Targets))
+}
+
+func TestLoadTargets(t *testing.T) {
+	dir := t.TempDir()
+	data := `{
+	"build": {
+		"auto_discover": true,
+		"exclude": ["./tools/gen"],
+		"targets": [
+			{"package": "./cmd/server", "output": "srv", "tags": ["netgo"], "trimpath": true,
+			 "gcflags": "all=-l", "ldflags": "-s -w", "vars": {"main.edition": "pro"}}
+		]
+	}
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.True(t, cfg.Build.AutoDiscover)
+	assert.Equal(t, []string{"./tools/gen"}, cfg.Build.Exclude)
+	require.Equal(t, 1, len(cfg.Build.Targets))
+	tgt := cfg.Build.Targets[0]
+	assert.Equal(t, "./cmd/server", tgt.Package)
+	assert.Equal(t, "srv", tgt.Output)
+	assert.Equal(t, []string{"netgo"}, tgt.Tags)
+	assert.True(t, tgt.Trimpath)
+	assert.Equal(t, "all=-l", tgt.Gcflags)
+	assert.Equal(t, "-s -w", tgt.Ldflags)
+	assert.Equal(t, "pro", tgt.Vars["main.edition"])
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`{"build": {"tragets": []}}`), 0644))
+
+	_, err := Load(dir)
+	assert.NotNil(t, err)
+}
+
+func TestLoadRequiresTargetPackage(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`{"build": {"targets": [{"output": "x"}]}}`), 0644))
+
+	_, err := Load(dir)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "package")
+}
+
+func TestLoadTargetVarsQuoting(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"build": {"targets": [{"package": ".", "vars": {"main.desc": "it's a \"build\""}}]}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+	_, err := Load(dir)
+	assert.ErrorContains(t, err, "can't contain both")
+
+	// Without spaces, the value needs no quoting
+	data = `{"build": {"targets": [{"package": ".", "vars": {"main.desc": "it's\"ok\""}}]}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+	_, err = Load(dir)
+	assert.NoError(t, err)
+}
+
+func TestLoadPackages(t *testing.T) {
+	dir := t.TempDir()
+	data := `{
+	"release": {
+		"packages": {
+			"formats": ["deb", "rpm"],
+			"maintainer": "Ops <ops@example.com>",
+			"depends": ["ca-certificates"],
+			"files": [{"source": "etc/tool.conf", "dest": "/etc/tool.conf"}, {"source": "x", "dest": "/usr/lib/x", "mode": "0755"}]
+		}
+	}
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	pkgs := cfg.Release.Packages
+	assert.Equal(t, []string{"deb", "rpm"}, pkgs.Formats)
+	assert.Equal(t, []string{"ca-certificates"}, pkgs.Depends)
+	require.Equal(t, 2, len(pkgs.Files))
+	mode, err := pkgs.Files[0].FileMode()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), mode)
+	mode, err = pkgs.Files[1].FileMode()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), mode)
+}
+
+func TestLoadPackagesValidation(t *testing.T) {
+	for _, data := range []string{
+		`{"release": {"packages": {"formats": ["msi"]}}}`,
+		`{"release": {"packages": {"files": [{"source": "a", "dest": "etc/a"}]}}}`,
+		`{"release": {"packages": {"files": [{"source": "a", "dest": "/etc/a", "mode": "rwx"}]}}}`,
+	} {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+		_, err := Load(dir)
+		assert.NotNil(t, err, data)
+	}
+}
+
+func TestLoadMatrix(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"matrix": {"platforms": ["linux/amd64", "linux/arm/7"], "exclude": ["windows/*"]}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm/7"}, cfg.Matrix.Platforms)
+	assert.Equal(t, []string{"windows/*"}, cfg.Matrix.Exclude)
+}
+
+func TestLoadCToolchains(t *testing.T) {
+	dir := t.TempDir()
+	data := `{
+	"build": {"targets": [{"package": "./cmd/db", "cgo": true}]},
+	"matrix": {"c_toolchains": [{"platform": "linux/arm64", "cc": "aarch64-linux-gnu-gcc", "sysroot": "/opt/arm64"}, {"platform": "windows/*", "zig": true}]}
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.True(t, cfg.Build.Targets[0].CGO)
+	require.Equal(t, 2, len(cfg.Matrix.CToolchains))
+	assert.Equal(t, "/opt/arm64", cfg.Matrix.CToolchains[0].Sysroot)
+	assert.True(t, cfg.Matrix.CToolchains[1].Zig)
+
+	for _, bad := range []string{
+		`{"matrix": {"c_toolchains": [{"cc": "gcc"}]}}`,
+		`{"matrix": {"c_toolchains": [{"platform": "linux/*", "zig": true, "cc": "gcc"}]}}`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(bad), 0644))
+		_, err := Load(dir)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestLoadSize(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"size": {"max_growth_percent": 5, "max_growth_bytes": 1048576, "base": "origin/main"}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, 5.0, cfg.Size.MaxGrowthPercent)
+	assert.Equal(t, int64(1048576), cfg.Size.MaxGrowthBytes)
+	assert.Equal(t, "origin/main", cfg.Size.Base)
+}
+
+func TestLoadSBOM(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`{"sbom": {"formats": ["spdx"]}}`), 0644))
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"spdx"}, cfg.SBOM.Formats)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`{"sbom": {"formats": ["swid"]}}`), 0644))
+	_, err = Load(dir)
+	assert.NotNil(t, err)
+}
+
+func TestLoadVuln(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"vuln": {"db": "osv", "fail_on": "critical", "unknown_severity": "low", "ignore": ["GO-2024-0001"]}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, "osv", cfg.Vuln.DB)
+	assert.Equal(t, "critical", cfg.Vuln.FailOn)
+	assert.Equal(t, "low", cfg.Vuln.UnknownSeverity)
+	assert.Equal(t, []string{"GO-2024-0001"}, cfg.Vuln.Ignore)
+
+	for _, bad := range []string{`{"vuln": {"fail_on": "severe"}}`, `{"vuln": {"unknown_severity": "none"}}`} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(bad), 0644))
+		_, err = Load(dir)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestLoadLicenses(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"licenses": {"allow": ["MIT", "BSD-3-Clause"], "deny": ["AGPL-3.0"], "overrides": {"example.com/x": "MIT"}, "notices": true}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"MIT", "BSD-3-Clause"}, cfg.Licenses.Allow)
+	assert.Equal(t, []string{"AGPL-3.0"}, cfg.Licenses.Deny)
+	assert.Equal(t, map[string]string{"example.com/x": "MIT"}, cfg.Licenses.Overrides)
+	assert.True(t, cfg.Licenses.Notices)
+}
+
+func TestLoadDeps(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"deps": {"update": [{"match": "github.com/org/pinned", "policy": "pinned"}, {"match": "*", "policy": "patch", "min_age": "7d"}]}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(cfg.Deps.Update))
+	assert.Equal(t, UpdateRule{Match: "*", Policy: "patch", MinAge: "7d"}, cfg.Deps.Update[1])
+
+	for _, bad := range []string{`{"deps": {"update": [{"policy": "latest"}]}}`, `{"deps": {"update": [{"match": "*", "policy": "major"}]}}`} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(bad), 0644))
+		_, err = Load(dir)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestLoadBench(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"bench": {"base": "origin/main", "thresholds": [{"benchmark": "Parse*", "max_time_percent": 5}], "strict_env": true, "stabilize": true, "max_load": 0.5, "remote": "upstream", "no_fetch": true}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644))
+	cfg, err := Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, "origin/main", cfg.Bench.Base)
+	require.Equal(t, 1, len(cfg.Bench.Thresholds))
+	assert.Equal(t, 5.0, *cfg.Bench.Thresholds[0].MaxTimePercent)
+	assert.True(t, cfg.Bench.StrictEnv)
+	assert.True(t, cfg.Bench.Stabilize)
+	assert.Equal(t, 0.5, cfg.Bench.MaxLoad)
+	assert.Equal(t, "upstream", cfg.Bench.Remote)
+	assert.True(t, cfg.Bench.NoFetch)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`{"bench": {"max_load": -1}}`), 0644))
+	_, err = Load(dir)
+	assert.NotNil(t, err)
+}
+
+func TestLoadBenchThresholdErrors(t *testing.T) {
+	cases := []struct {
+		data string
+		want string
+	}{
+		{`{"bench": {"thresholds": [{"benchmark": "["}]}}`, `invalid benchmark pattern "["`},
+		{`{"bench": {"thresholds": [{"benchmark": "Parse[a-"}]}}`, `invalid benchmark pattern "Parse[a-"`},
+		{`{"bench": {"thresholds": [{"max_time_percent": -1}]}}`, "limits must be positive"},
+		{`{"bench": {"thresholds": [{"max_time_percent": 0}]}}`, "limits must be positive"},
+		{`{"bench": {"thresholds": [{"max_bytes_percent": -5}]}}`, "limits must be positive"},
+		{`{"bench": {"thresholds": [{"max_bytes_percent": 0}]}}`, "limits must be positive"},
+		{`{"bench": {"thresholds": [{"max_allocs_percent": -0.5}]}}`, "limits must be positive"},
+		{`{"bench": {"thresholds": [{"max_allocs_percent": 0}]}}`, "limits must be positive"},
+		{`{"bench": {"thresholds": [{"max_time_percent": 5}, {"max_time_percent": 0}]}}`, "bench.thresholds[1]"},
+	}
+	for _, tc := range cases {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(tc.data), 0644))
+		_, err := Load(dir)
+		require.NotNil(t, err, tc.data)
+		assert.Contains(t, err.Error(), tc.want, tc.data)
+	}
+}
@@ -0,0 +1,151 @@
+// Package license identifies the licenses of Go modules from the license
+// files in their source, checks them against an allow/deny policy, and
+// writes third-party notices.
+package license
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// signature identifies a license by phrases from its text, normalized to
+// lowercase words separated by single spaces.
+type signature struct {
+	id      string
+	all     []string // every phrase must appear
+	none    []string // no phrase may appear
+	heading bool     // the first phrase must appear near the start (license title)
+}
+
+// headingWindow is how far into the normalized text a license title may
+// appear, allowing for a copyright line or two before it.
+const headingWindow = 600
+
+// signatures are checked in order. The GPL family requires its title at the
+// top, since other licenses (MPL-2.0, LGPL) mention the GPL in their text.
+var signatures = []signature{
+	{id: "AGPL-3.0", all: []string{"gnu affero general public license version 3"}, heading: true},
+	{id: "LGPL-3.0", all: []string{"gnu lesser general public license version 3"}, heading: true},
+	{id: "LGPL-2.1", all: []string{"gnu lesser general public license version 2 1"}, heading: true},
+	{id: "LGPL-2.0", all: []string{"gnu library general public license version 2"}, heading: true},
+	{id: "GPL-3.0", all: []string{"gnu general public license version 3"}, heading: true},
+	{id: "GPL-2.0", all: []string{"gnu general public license version 2"}, heading: true},
+	{id: "MPL-2.0", all: []string{"mozilla public license version 2 0"}},
+	{id: "EPL-2.0", all: []string{"eclipse public license v 2 0"}},
+	{id: "EPL-1.0", all: []string{"eclipse public license v 1 0"}},
+	{id: "Apache-2.0", all: []string{"apache license version 2 0"}},
+	{id: "MIT", all: []string{
+		"permission is hereby granted free of charge to any person obtaining a copy of this software",
+		"the above copyright notice and this permission notice shall be included",
+	}},
+	{id: "ISC", all: []string{
+		"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted provided that the above copyright notice and this permission notice appear in all copies",
+	}},
+	{id: "0BSD", all: []string{
+		"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+	}, none: []string{"provided that the above copyright notice"}},
+	{id: "BSD-4-Clause", all: []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that",
+		"all advertising materials mentioning features or use of this software must display the following acknowledgement",
+	}},
+	{id: "BSD-3-Clause", all: []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that",
+		"may be used to endorse or promote products derived from this software without specific prior written permission",
+	}, none: []string{"all advertising materials mentioning features"}},
+	{id: "BSD-2-Clause", all: []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that",
+	}, none: []string{"endorse or promote products derived", "all advertising materials mentioning features"}},
+	{id: "BSL-1.0", all: []string{"boost software license version 1 0"}},
+	{id: "Zlib", all: []string{
+		"this software is provided as is without any express or implied warranty",
+		"altered source versions must be plainly marked as such",
+	}},
+	{id: "Unlicense", all: []string{"this is free and unencumbered software released into the public domain"}},
+	{id: "CC0-1.0", all: []string{"cc0 1 0 universal"}},
+	{id: "BlueOak-1.0.0", all: []string{"blue oak model license"}},
+}
+
+// Licenses that embed another license's title in their own text.
+var subsumes = map[string][]string{
+	"AGPL-3.0": {"GPL-3.0"},
+	"LGPL-3.0": {"GPL-3.0"},
+	"LGPL-2.1": {"GPL-2.0"},
+	"LGPL-2.0": {"GPL-2.0"},
+	"ISC":      {"0BSD"},
+}
+
+var (
+	nonWord    = regexp.MustCompile(`[^a-z0-9]+`)
+	spdxHeader = regexp.MustCompile(`(?m)SPDX-License-Identifier:\s*([A-Za-z0-9.+()\- ]+?)\s*(?:\*/|-->)?\s*$`)
+)
+
+// normalize lowercases text and reduces it to words separated by single
+// spaces, so line wrapping, punctuation and markup don't matter.
+func normalize(text string) string {
+	return strings.TrimSpace(nonWord.ReplaceAllString(strings.ToLower(text), " "))
+}
+
+// Classify returns the SPDX identifiers of the licenses whose text appears
+// in a license file, sorted. SPDX-License-Identifier lines are honored too.
+func Classify(text []byte) []string {
+	found := make(map[string]bool)
+	for _, m := range spdxHeader.FindAllStringSubmatch(string(text), -1) {
+		for _, id := range splitExpression(m[1]) {
+			found[id] = true
+		}
+	}
+
+	norm := " " + normalize(string(text)) + " "
+	for _, sig := range signatures {
+		if sig.matches(norm) {
+			found[sig.id] = true
+		}
+	}
+	for id, dropped := range subsumes {
+		if found[id] {
+			for _, d := range dropped {
+				delete(found, d)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (sig signature) matches(norm string) bool {
+	for i, phrase := range sig.all {
+		idx := strings.Index(norm, " "+phrase+" ")
+		if idx < 0 || (i == 0 && sig.heading && idx > headingWindow) {
+			return false
+		}
+	}
+	for _, phrase := range sig.none {
+		if strings.Contains(norm, " "+phrase+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitExpression splits a simple SPDX expression ("MIT OR Apache-2.0")
+// into its license identifiers.
+func splitExpression(expr string) []string {
+	var ids []string
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ", ",", " ").Replace(expr))
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "OR", "AND":
+		case "WITH":
+			i++ // skip the exception, e.g. Classpath-exception-2.0
+		default:
+			ids = append(ids, fields[i])
+		}
+	}
+	return ids
+}
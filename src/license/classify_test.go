@@ -0,0 +1,94 @@
+package license
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+)
+
+const mitText = `MIT License
+
+Copyright (c) 2020 Example
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+`
+
+const bsd3Text = `Copyright (c) 2009 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+`
+
+const bsd2Text = `Copyright (c) 2015, Example
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+`
+
+const iscText = `Copyright (c) 2015 Example
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+`
+
+const zeroBSDText = `Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+`
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"mit", mitText, []string{"MIT"}},
+		{"bsd3", bsd3Text, []string{"BSD-3-Clause"}},
+		{"bsd2", bsd2Text, []string{"BSD-2-Clause"}},
+		{"isc", iscText, []string{"ISC"}},
+		{"0bsd", zeroBSDText, []string{"0BSD"}},
+		{"apache", "\n                                 Apache License\n                           Version 2.0, January 2004\n", []string{"Apache-2.0"}},
+		{"gpl3", "                    GNU GENERAL PUBLIC LICENSE\n                       Version 3, 29 June 2007\n", []string{"GPL-3.0"}},
+		{"lgpl21", "GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999\n\n[This is the first released version of the Lesser GPL.  It also counts\n as the successor of the GNU Library Public License, version 2, hence\n the version number 2.1.]\n\nthe GNU General Public License, version 2", []string{"LGPL-2.1"}},
+		{"agpl", "GNU AFFERO GENERAL PUBLIC LICENSE\nVersion 3, 19 November 2007\n... GNU General Public License version 3 ...", []string{"AGPL-3.0"}},
+		{"mpl mentions gpl", "Mozilla Public License Version 2.0\n==================================\n" + padding + "either the GNU General Public License, Version 2.0, the GNU Lesser General Public License, Version 2.1", []string{"MPL-2.0"}},
+		{"unlicense", "This is free and unencumbered software released into the public domain.", []string{"Unlicense"}},
+		{"dual", mitText + "\n\n" + "Apache License\nVersion 2.0, January 2004", []string{"Apache-2.0", "MIT"}},
+		{"spdx", "// SPDX-License-Identifier: MIT OR Apache-2.0\n", []string{"Apache-2.0", "MIT"}},
+		{"spdx with exception", "/* SPDX-License-Identifier: GPL-2.0-only WITH Classpath-exception-2.0 */\n", []string{"GPL-2.0-only"}},
+		{"unknown", "All rights reserved. Do not copy.", []string{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Classify([]byte(tt.text)), tt.name)
+	}
+}
+
+// padding pushes text past the heading window.
+var padding = func() string {
+	s := ""
+	for len(s) < 2*headingWindow {
+		s += "lorem ipsum dolor sit amet "
+	}
+	return s
+}()
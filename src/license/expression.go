@@ -0,0 +1,94 @@
+package license
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expression is a parsed SPDX license expression: a single license ID, or
+// operands joined by OR or AND.
+type expression struct {
+	id       string
+	op       string // "OR" or "AND"; empty for an ID
+	operands []*expression
+}
+
+// parseExpression parses an SPDX expression such as
+// "MIT OR (Apache-2.0 AND BSD-3-Clause)". AND binds tighter than OR, and
+// WITH exceptions are dropped, since policies match license IDs only.
+func parseExpression(s string) (*expression, error) {
+	p := &exprParser{tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s))}
+	e, err := p.parse("OR")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parse reads operands joined by op: AND groups for OR, and IDs or
+// parenthesized expressions for AND.
+func (p *exprParser) parse(op string) (*expression, error) {
+	next := p.operand
+	if op == "OR" {
+		next = func() (*expression, error) { return p.parse("AND") }
+	}
+	e, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), op) {
+		p.pos++
+		rhs, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if e.op != op {
+			e = &expression{op: op, operands: []*expression{e}}
+		}
+		e.operands = append(e.operands, rhs)
+	}
+	return e, nil
+}
+
+func (p *exprParser) operand() (*expression, error) {
+	tok := p.peek()
+	p.pos++
+	switch strings.ToUpper(tok) {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "(":
+		e, err := p.parse("OR")
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return e, nil
+	case ")", "OR", "AND", "WITH":
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.pos += 2 // skip the exception, e.g. Classpath-exception-2.0
+		if p.pos > len(p.tokens) {
+			return nil, fmt.Errorf("WITH needs an exception")
+		}
+	}
+	return &expression{id: tok}, nil
+}
@@ -0,0 +1,100 @@
+package license
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+)
+
+var (
+	// licenseFile matches license file names in a module root: LICENSE,
+	// LICENSE.md, LICENSE-APACHE, COPYING, UNLICENSE, ...
+	licenseFile = regexp.MustCompile(`(?i)^(licen[cs]e|copying|unlicense|copyright)([.\-_].*)?$`)
+	// noticeFile matches attribution files that must ship alongside
+	// Apache-2.0 licensed code.
+	noticeFile = regexp.MustCompile(`(?i)^notice([.\-_].*)?$`)
+)
+
+// Module is the license information of one module.
+type Module struct {
+	Path     string   `json:"path"`
+	Version  string   `json:"version,omitempty"`
+	Licenses []string `json:"licenses"`          // SPDX IDs, sorted; empty if unrecognized
+	Files    []string `json:"files,omitempty"`   // license files in the module root
+	Notices  []string `json:"notices,omitempty"` // NOTICE files in the module root
+	Override bool     `json:"override,omitempty"`
+	Dir      string   `json:"-"`
+
+	expr *expression // the override's expression, if any
+}
+
+// Inventory classifies the license of every module in mods that has source
+// on disk. Modules without a directory (in the module graph but never
+// downloaded) contribute no code to builds and are skipped; the main module
+// is skipped too. overrides maps module paths to SPDX expressions ("MIT",
+// "MIT OR Apache-2.0") that replace detection, for licenses the classifier
+// doesn't recognize.
+func Inventory(mods []build.Module, overrides map[string]string) ([]Module, error) {
+	var inv []Module
+	for _, bm := range mods {
+		if bm.Main || bm.Dir == "" {
+			continue
+		}
+		m := Module{Path: bm.Path, Version: bm.Version, Dir: bm.Dir}
+		if bm.Replace != nil && bm.Replace.Version != "" {
+			m.Version = bm.Replace.Version
+		}
+		if err := m.scan(); err != nil {
+			return nil, err
+		}
+		if expr, ok := overrides[bm.Path]; ok {
+			parsed, err := parseExpression(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid license override for %s %q: %w", bm.Path, expr, err)
+			}
+			m.expr = parsed
+			m.Licenses = splitExpression(expr)
+			m.Override = true
+		}
+		inv = append(inv, m)
+	}
+	sort.Slice(inv, func(i, j int) bool { return inv[i].Path < inv[j].Path })
+	return inv, nil
+}
+
+// scan finds and classifies the license files in the module root.
+func (m *Module) scan() error {
+	entries, err := os.ReadDir(m.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", m.Path, err)
+	}
+	found := make(map[string]bool)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch {
+		case licenseFile.MatchString(e.Name()):
+			m.Files = append(m.Files, e.Name())
+			data, err := os.ReadFile(filepath.Join(m.Dir, e.Name()))
+			if err != nil {
+				return fmt.Errorf("failed to read %s license: %w", m.Path, err)
+			}
+			for _, id := range Classify(data) {
+				found[id] = true
+			}
+		case noticeFile.MatchString(e.Name()):
+			m.Notices = append(m.Notices, e.Name())
+		}
+	}
+	m.Licenses = make([]string, 0, len(found))
+	for id := range found {
+		m.Licenses = append(m.Licenses, id)
+	}
+	sort.Strings(m.Licenses)
+	return nil
+}
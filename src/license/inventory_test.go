@@ -0,0 +1,94 @@
+package license
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+)
+
+// writeModule creates a module directory with the given files.
+func writeModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func testInventory(t *testing.T) []Module {
+	mods := []build.Module{
+		{Path: "example.com/app", Main: true, Dir: writeModule(t, map[string]string{"LICENSE": mitText})},
+		{Path: "example.com/mit", Version: "v1.0.0", Dir: writeModule(t, map[string]string{"LICENSE.md": mitText, "main.go": "package mit"})},
+		{Path: "example.com/dual", Version: "v2.0.0", Dir: writeModule(t, map[string]string{
+			"LICENSE-MIT":    mitText,
+			"LICENSE-APACHE": "Apache License\nVersion 2.0, January 2004",
+			"NOTICE":         "Dual Project\nCopyright 2020 Example",
+		})},
+		{Path: "example.com/none", Version: "v0.1.0", Dir: writeModule(t, map[string]string{"README": "hi"})},
+		{Path: "example.com/custom", Version: "v0.2.0", Dir: writeModule(t, map[string]string{"COPYING": "custom terms"})},
+		{Path: "example.com/notdownloaded", Version: "v1.0.0"},
+		{Path: "example.com/forked", Version: "v1.0.0", Replace: &build.Module{Path: "example.com/fork", Version: "v1.0.1"},
+			Dir: writeModule(t, map[string]string{"LICENSE": bsd3Text})},
+	}
+	inv, err := Inventory(mods, map[string]string{"example.com/custom": "MIT OR Apache-2.0"})
+	require.NoError(t, err)
+	return inv
+}
+
+func TestInventory(t *testing.T) {
+	inv := testInventory(t)
+	require.Equal(t, 5, len(inv))
+
+	assert.Equal(t, "example.com/custom", inv[0].Path)
+	assert.Equal(t, []string{"MIT", "Apache-2.0"}, inv[0].Licenses)
+	assert.True(t, inv[0].Override)
+	assert.Equal(t, "", Policy{Allow: []string{"Apache-2.0"}}.Check(inv[0]))
+
+	assert.Equal(t, "example.com/dual", inv[1].Path)
+	assert.Equal(t, []string{"Apache-2.0", "MIT"}, inv[1].Licenses)
+	assert.Equal(t, []string{"LICENSE-APACHE", "LICENSE-MIT"}, inv[1].Files)
+	assert.Equal(t, []string{"NOTICE"}, inv[1].Notices)
+
+	assert.Equal(t, "example.com/forked", inv[2].Path)
+	assert.Equal(t, "v1.0.1", inv[2].Version)
+	assert.Equal(t, []string{"BSD-3-Clause"}, inv[2].Licenses)
+
+	assert.Equal(t, []string{"MIT"}, inv[3].Licenses)
+	assert.Equal(t, []string{"LICENSE.md"}, inv[3].Files)
+
+	assert.Equal(t, "example.com/none", inv[4].Path)
+	assert.Equal(t, []string{}, inv[4].Licenses)
+}
+
+func TestInventoryBadOverride(t *testing.T) {
+	mods := []build.Module{{Path: "example.com/custom", Version: "v0.2.0", Dir: writeModule(t, map[string]string{"COPYING": "custom terms"})}}
+	_, err := Inventory(mods, map[string]string{"example.com/custom": "MIT OR"})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "example.com/custom")
+}
+
+func TestInventoryMissingDir(t *testing.T) {
+	_, err := Inventory([]build.Module{{Path: "example.com/gone", Dir: filepath.Join(t.TempDir(), "gone")}}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestWriteNotices(t *testing.T) {
+	inv := testInventory(t)
+	var sb strings.Builder
+	require.NoError(t, WriteNotices(&sb, inv))
+	out := sb.String()
+
+	assert.True(t, strings.HasPrefix(out, "THIRD-PARTY SOFTWARE NOTICES\n"))
+	assert.Contains(t, out, "example.com/dual v2.0.0\nLicense: Apache-2.0, MIT\n")
+	assert.Contains(t, out, "NOTICE:\n\nDual Project\nCopyright 2020 Example\n")
+	assert.Contains(t, out, "example.com/none v0.1.0\nLicense: unknown\n")
+	assert.Equal(t, 1, strings.Count(out, "LICENSE.md:\n\nMIT License"))
+
+	inv[0].Files = []string{"missing"}
+	assert.NotNil(t, WriteNotices(&sb, inv))
+}
@@ -0,0 +1,43 @@
+package license
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NoticesFile is the default name of the third-party notices file.
+const NoticesFile = "THIRD_PARTY_NOTICES.txt"
+
+var (
+	noticesRule     = strings.Repeat("=", 80)
+	noticesSubRule  = strings.Repeat("-", 80)
+	noticesPreamble = "This software includes the third-party Go modules listed below, each\nfollowed by its license and notice files.\n"
+)
+
+// WriteNotices writes a third-party notices document for inv: each
+// module's path, version and licenses, followed by its license and NOTICE
+// files verbatim.
+func WriteNotices(w io.Writer, inv []Module) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "THIRD-PARTY SOFTWARE NOTICES\n\n%s", noticesPreamble)
+	for _, m := range inv {
+		licenses := strings.Join(m.Licenses, ", ")
+		if licenses == "" {
+			licenses = "unknown"
+		}
+		fmt.Fprintf(bw, "\n%s\n%s %s\nLicense: %s\n", noticesRule, m.Path, m.Version, licenses)
+		for _, name := range append(append([]string(nil), m.Files...), m.Notices...) {
+			data, err := os.ReadFile(filepath.Join(m.Dir, name))
+			if err != nil {
+				return fmt.Errorf("failed to read %s %s: %w", m.Path, name, err)
+			}
+			text := strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+			fmt.Fprintf(bw, "%s\n%s:\n\n%s\n", noticesSubRule, name, text)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,93 @@
+package license
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Policy allows or denies licenses by SPDX ID. IDs compare
+// case-insensitively, and -only / -or-later / + variants match their base
+// ID, so denying GPL-2.0 also denies GPL-2.0-or-later.
+type Policy struct {
+	Allow []string // when set, every license must be on this list
+	Deny  []string
+}
+
+// Enabled reports whether the policy checks anything.
+func (p Policy) Enabled() bool {
+	return len(p.Allow) > 0 || len(p.Deny) > 0
+}
+
+// Check returns why m violates the policy, or "" if it complies. All of a
+// module's licenses apply: a module shipping MIT and Apache-2.0 license
+// files needs both to be acceptable (use an override for dual licenses that
+// let you choose). An override is evaluated as an SPDX expression, so
+// "MIT OR GPL-3.0" complies when either license does. Unrecognized licenses
+// violate an allow list.
+func (p Policy) Check(m Module) string {
+	if m.expr != nil {
+		return p.checkExpression(m.expr)
+	}
+	if len(m.Licenses) == 0 {
+		if len(p.Allow) > 0 {
+			return "license not recognized"
+		}
+		return ""
+	}
+	for _, id := range m.Licenses {
+		if reason := p.checkID(id); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// checkExpression returns why e violates the policy: an OR needs one
+// acceptable operand, an AND needs all of them.
+func (p Policy) checkExpression(e *expression) string {
+	switch e.op {
+	case "":
+		return p.checkID(e.id)
+	case "OR":
+		var reasons []string
+		for _, o := range e.operands {
+			reason := p.checkExpression(o)
+			if reason == "" {
+				return ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return strings.Join(reasons, " and ")
+	}
+	for _, o := range e.operands {
+		if reason := p.checkExpression(o); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func (p Policy) checkID(id string) string {
+	if containsID(p.Deny, id) {
+		return fmt.Sprintf("%s is denied", id)
+	}
+	if len(p.Allow) > 0 && !containsID(p.Allow, id) {
+		return fmt.Sprintf("%s is not allowed", id)
+	}
+	return ""
+}
+
+func containsID(list []string, id string) bool {
+	id = baseID(id)
+	return slices.ContainsFunc(list, func(l string) bool { return baseID(l) == id })
+}
+
+// baseID strips GPL-style version qualifiers and case.
+func baseID(id string) string {
+	id = strings.ToLower(id)
+	id = strings.TrimSuffix(id, "+")
+	id = strings.TrimSuffix(id, "-only")
+	id = strings.TrimSuffix(id, "-or-later")
+	return id
+}
@@ -0,0 +1,61 @@
+package license
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	mit := Module{Licenses: []string{"MIT"}}
+	gpl := Module{Licenses: []string{"GPL-2.0-or-later"}}
+	dual := Module{Licenses: []string{"Apache-2.0", "MIT"}}
+	unknown := Module{Licenses: []string{}}
+
+	deny := Policy{Deny: []string{"GPL-2.0"}}
+	assert.True(t, deny.Enabled())
+	assert.Equal(t, "", deny.Check(mit))
+	assert.Equal(t, "GPL-2.0-or-later is denied", deny.Check(gpl))
+	assert.Equal(t, "", deny.Check(unknown))
+
+	allow := Policy{Allow: []string{"mit", "BSD-3-Clause"}}
+	assert.Equal(t, "", allow.Check(mit))
+	assert.Equal(t, "Apache-2.0 is not allowed", allow.Check(dual))
+	assert.Equal(t, "license not recognized", allow.Check(unknown))
+
+	assert.False(t, Policy{}.Enabled())
+	assert.Equal(t, "", Policy{}.Check(gpl))
+}
+
+func TestPolicyCheckOverride(t *testing.T) {
+	override := func(expr string) Module {
+		e, err := parseExpression(expr)
+		require.NoError(t, err, expr)
+		return Module{Licenses: splitExpression(expr), Override: true, expr: e}
+	}
+	dual := override("MIT OR GPL-3.0")
+	both := override("MIT AND Apache-2.0")
+	nested := override("(MIT AND BSD-3-Clause) OR GPL-2.0-only WITH Classpath-exception-2.0")
+
+	allow := Policy{Allow: []string{"MIT", "BSD-3-Clause"}}
+	assert.Equal(t, "", allow.Check(dual))
+	assert.Equal(t, "Apache-2.0 is not allowed", allow.Check(both))
+	assert.Equal(t, "", allow.Check(nested))
+
+	deny := Policy{Deny: []string{"MIT"}}
+	assert.Equal(t, "", deny.Check(dual))
+	assert.Equal(t, "MIT is denied", deny.Check(both))
+	assert.Equal(t, "", deny.Check(nested))
+
+	strict := Policy{Allow: []string{"MIT"}, Deny: []string{"GPL-2.0"}}
+	assert.Equal(t, "BSD-3-Clause is not allowed and GPL-2.0-only is denied", strict.Check(nested))
+	assert.Equal(t, "MIT is not allowed and GPL-3.0 is not allowed", Policy{Allow: []string{"Apache-2.0"}}.Check(override("MIT or GPL-3.0")))
+}
+
+func TestParseExpression(t *testing.T) {
+	for _, bad := range []string{"", "MIT OR", "(MIT", "MIT)", "AND MIT", "MIT Apache-2.0", "GPL-2.0 WITH"} {
+		_, err := parseExpression(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
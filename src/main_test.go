@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+// TestInitKeepsOfflineProxies runs the test binary with each GOPROXY so
+// init sees it the way a real invocation would.
+func TestInitKeepsOfflineProxies(t *testing.T) {
+	if os.Getenv("GO_TOOLCHAIN_INIT_CHILD") == "1" {
+		fmt.Printf("%s %s\n", os.Getenv("GOPROXY"), os.Getenv("GOSUMDB"))
+		return
+	}
+	for proxy, want := range map[string]string{
+		"":                                "direct",
+		"https://proxy.golang.org,direct": "direct",
+		"off":                             "off",
+		"file:///srv/goproxy":             "file:///srv/goproxy",
+	} {
+		child := exec.Command(os.Args[0], "-test.run=^TestInitKeepsOfflineProxies$")
+		child.Env = append(os.Environ(), "GO_TOOLCHAIN_INIT_CHILD=1", "GOPROXY="+proxy)
+		out, err := child.Output()
+		require.NoError(t, err, proxy)
+		fields := strings.Fields(string(out))
+		require.GreaterOrEqual(t, len(fields), 2, string(out))
+		assert.Equal(t, []string{want, "off"}, fields[:2], proxy)
+	}
+}
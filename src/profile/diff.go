@@ -0,0 +1,104 @@
+package profile
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/google/pprof/profile"
+)
+
+// Change is how a function's share of a profile changed.
+type Change struct {
+	Name    string  `json:"name"`
+	BasePct float64 `json:"base_pct"` // flat, in percent of the base profile's total
+	NewPct  float64 `json:"new_pct"`
+	Delta   float64 `json:"delta"` // NewPct - BasePct, in percentage points
+}
+
+// Comparison lists the functions that got hotter or cooler between two
+// profiles.
+type Comparison struct {
+	SampleType string   `json:"sample_type"`
+	Unit       string   `json:"unit"`
+	BaseTotal  int64    `json:"base_total"`
+	NewTotal   int64    `json:"new_total"`
+	Hotter     []Change `json:"hotter"` // by Delta, descending
+	Cooler     []Change `json:"cooler"` // by Delta, ascending
+}
+
+// Diff compares two profiles of the same type, keeping the n functions
+// (all if n <= 0) that got hotter and cooler the most. Functions are
+// compared by their share of each profile's total rather than by raw
+// values, since a benchmark run for a fixed time does more iterations as
+// it gets faster.
+func Diff(base, current *profile.Profile, n int) (*Comparison, error) {
+	baseReport, baseFuncs := summarize(base)
+	newReport, newFuncs := summarize(current)
+	if baseReport.SampleType != newReport.SampleType || baseReport.Unit != newReport.Unit {
+		return nil, fmt.Errorf("can't compare profiles of different types: %s vs %s", describe(baseReport), describe(newReport))
+	}
+
+	comp := &Comparison{
+		SampleType: newReport.SampleType,
+		Unit:       newReport.Unit,
+		BaseTotal:  baseReport.Total,
+		NewTotal:   newReport.Total,
+	}
+	changes := make(map[string]*Change)
+	for _, f := range baseFuncs {
+		changes[f.Name] = &Change{Name: f.Name, BasePct: percent(f.Flat, baseReport.Total)}
+	}
+	for _, f := range newFuncs {
+		c, ok := changes[f.Name]
+		if !ok {
+			c = &Change{Name: f.Name}
+			changes[f.Name] = c
+		}
+		c.NewPct = percent(f.Flat, newReport.Total)
+	}
+	for _, c := range changes {
+		c.Delta = c.NewPct - c.BasePct
+		switch {
+		case c.Delta > 0:
+			comp.Hotter = append(comp.Hotter, *c)
+		case c.Delta < 0:
+			comp.Cooler = append(comp.Cooler, *c)
+		}
+	}
+
+	slices.SortFunc(comp.Hotter, func(a, b Change) int {
+		return cmp.Or(cmp.Compare(b.Delta, a.Delta), cmp.Compare(a.Name, b.Name))
+	})
+	slices.SortFunc(comp.Cooler, func(a, b Change) int {
+		return cmp.Or(cmp.Compare(a.Delta, b.Delta), cmp.Compare(a.Name, b.Name))
+	})
+	if n > 0 {
+		comp.Hotter = comp.Hotter[:min(n, len(comp.Hotter))]
+		comp.Cooler = comp.Cooler[:min(n, len(comp.Cooler))]
+	}
+	return comp, nil
+}
+
+func describe(r *Report) string {
+	return fmt.Sprintf("%s (%s)", r.SampleType, r.Unit)
+}
+
+// Print displays the hotter and cooler functions.
+func (c *Comparison) Print() {
+	fmt.Printf("  total %s → %s\n", FormatValue(c.BaseTotal, c.Unit), FormatValue(c.NewTotal, c.Unit))
+	printChanges := func(title string, changes []Change) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Printf("\n  %s (flat, %% of total):\n", title)
+		for _, ch := range changes {
+			fmt.Printf("  %6.1f%% → %5.1f%%  %+6.1f  %s\n", ch.BasePct, ch.NewPct, ch.Delta, ch.Name)
+		}
+	}
+	printChanges("hotter", c.Hotter)
+	printChanges("cooler", c.Cooler)
+	if len(c.Hotter) == 0 && len(c.Cooler) == 0 {
+		fmt.Println("  no function's share changed")
+	}
+}
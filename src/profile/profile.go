@@ -0,0 +1,233 @@
+// Package profile collects pprof profiles from benchmarks and summarizes
+// them as text: the hottest functions of a profile and the functions
+// that got hotter or cooler between two.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/pprof/profile"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// Types lists the profile types in the order they are reported.
+var Types = []string{"cpu", "mem", "mutex", "block"}
+
+// typeFlags maps each profile type to the go test flag that writes it.
+var typeFlags = map[string]string{
+	"cpu":   "-cpuprofile",
+	"mem":   "-memprofile",
+	"mutex": "-mutexprofile",
+	"block": "-blockprofile",
+}
+
+// ParseTypes parses a comma-separated list of profile types, returning
+// them in the order of Types without duplicates.
+func ParseTypes(list string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if _, ok := typeFlags[t]; !ok {
+			return nil, fmt.Errorf("unknown profile type %q (use: %s)", t, strings.Join(Types, ", "))
+		}
+		seen[t] = true
+	}
+	var types []string
+	for _, t := range Types {
+		if seen[t] {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// Options configures profile collection.
+type Options struct {
+	Types    []string // profile types to collect (default cpu)
+	Bench    string   // -bench regexp (default .)
+	Time     string   // -benchtime
+	Count    int      // -count
+	Packages []string // package patterns (default ./...)
+	Dir      string   // run in this directory instead of the current one
+	Quiet    bool     // don't show go test's output
+}
+
+// Collect runs the matching benchmarks with profiling enabled and returns
+// one profile per type. go test only profiles one package at a time, so
+// each package with matching benchmarks is run on its own and their
+// profiles are merged.
+func Collect(r runner.CommandRunner, opts Options) (map[string]*profile.Profile, error) {
+	byPackage, err := CollectPackages(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Types) == 0 {
+		opts.Types = []string{"cpu"}
+	}
+
+	// Merge in package order, so the result doesn't depend on map order
+	pkgs := slices.Sorted(maps.Keys(byPackage))
+	profiles := make(map[string]*profile.Profile)
+	for _, t := range opts.Types {
+		var parts []*profile.Profile
+		for _, pkg := range pkgs {
+			if p, ok := byPackage[pkg][t]; ok {
+				parts = append(parts, p)
+			}
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("profile output not created for %s (no benchmarks found?)", t)
+		}
+		p, err := profile.Merge(parts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge %s profiles: %w", t, err)
+		}
+		profiles[t] = p
+	}
+	return profiles, nil
+}
+
+// CollectPackages is Collect without the merge: it returns the profiles
+// of each package with matching benchmarks, by import path and type.
+func CollectPackages(r runner.CommandRunner, opts Options) (map[string]map[string]*profile.Profile, error) {
+	if len(opts.Types) == 0 {
+		opts.Types = []string{"cpu"}
+	}
+	if opts.Bench == "" {
+		opts.Bench = "."
+	}
+	if len(opts.Packages) == 0 {
+		opts.Packages = []string{"./..."}
+	}
+
+	pkgs, err := benchmarkPackages(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no benchmarks match %q in %s", opts.Bench, strings.Join(opts.Packages, " "))
+	}
+
+	tmp, err := os.MkdirTemp("", "go-toolchain-profile-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	byPackage := make(map[string]map[string]*profile.Profile)
+	for i, pkg := range pkgs {
+		args := []string{"test", "-run", "^$", "-bench", opts.Bench}
+		if opts.Time != "" {
+			args = append(args, "-benchtime", opts.Time)
+		}
+		if opts.Count > 1 {
+			args = append(args, "-count", fmt.Sprintf("%d", opts.Count))
+		}
+		// Keep the test binary out of the working directory
+		args = append(args, "-o", filepath.Join(tmp, fmt.Sprintf("%d.test", i)))
+		for _, t := range opts.Types {
+			args = append(args, typeFlags[t], filepath.Join(tmp, fmt.Sprintf("%d_%s.pprof", i, t)))
+		}
+		args = append(args, pkg)
+		if opts.Dir != "" {
+			args = append([]string{"-C", opts.Dir}, args...)
+		}
+
+		cmd := runner.Cmd("go", args...)
+		if opts.Quiet {
+			cmd = cmd.WithQuiet()
+		}
+		proc, err := cmd.Run(r)
+		if err != nil {
+			return nil, fmt.Errorf("profiling %s failed: %w", pkg, err)
+		}
+		if err := proc.Wait(); err != nil {
+			return nil, fmt.Errorf("profiling %s failed: %w", pkg, err)
+		}
+
+		for _, t := range opts.Types {
+			p, err := Load(filepath.Join(tmp, fmt.Sprintf("%d_%s.pprof", i, t)))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if byPackage[pkg] == nil {
+				byPackage[pkg] = make(map[string]*profile.Profile)
+			}
+			byPackage[pkg][t] = p
+		}
+	}
+	return byPackage, nil
+}
+
+// benchmarkPackages lists the packages with benchmarks matching the
+// top-level part of opts.Bench, so that packages without any aren't
+// profiled.
+func benchmarkPackages(r runner.CommandRunner, opts Options) ([]string, error) {
+	top, _, _ := strings.Cut(opts.Bench, "/")
+	args := append([]string{"test", "-json", "-list", top}, opts.Packages...)
+	if opts.Dir != "" {
+		args = append([]string{"-C", opts.Dir}, args...)
+	}
+	proc, err := runner.Cmd("go", args...).WithQuiet().Run(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list benchmarks: %w", err)
+	}
+	output, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to list benchmarks: %w", err)
+	}
+
+	var pkgs []string
+	dec := json.NewDecoder(strings.NewReader(string(output)))
+	for dec.More() {
+		var event struct {
+			Action  string
+			Package string
+			Output  string
+		}
+		if err := dec.Decode(&event); err != nil {
+			return nil, fmt.Errorf("failed to parse benchmark list: %w", err)
+		}
+		if event.Action == "output" && strings.HasPrefix(event.Output, "Benchmark") && !slices.Contains(pkgs, event.Package) {
+			pkgs = append(pkgs, event.Package)
+		}
+	}
+	return pkgs, nil
+}
+
+// Load reads a profile written by go test or go tool pprof.
+func Load(path string) (*profile.Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Save writes a profile in the gzipped format go tool pprof reads.
+func Save(p *profile.Profile, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write profile: %w", err)
+	}
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write profile: %w", err)
+	}
+	return f.Close()
+}
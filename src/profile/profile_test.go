@@ -0,0 +1,193 @@
+package profile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// cpuProfile builds a CPU profile from stacks, leaf first, with their
+// sample values in nanoseconds. A frame "a+b" is b inlined into a.
+func cpuProfile(stacks map[string]int64) *profile.Profile {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+	}
+	functions := make(map[string]*profile.Function)
+	for stack, v := range stacks {
+		s := &profile.Sample{Value: []int64{v / p.Period, v}}
+		for _, frame := range strings.Split(stack, ";") {
+			loc := &profile.Location{ID: uint64(len(p.Location) + 1)}
+			for _, name := range strings.Split(frame, "+") {
+				fn, ok := functions[name]
+				if !ok {
+					fn = &profile.Function{ID: uint64(len(functions) + 1), Name: name}
+					functions[name] = fn
+					p.Function = append(p.Function, fn)
+				}
+				loc.Line = append(loc.Line, profile.Line{Function: fn})
+			}
+			p.Location = append(p.Location, loc)
+			s.Location = append(s.Location, loc)
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	return p
+}
+
+func TestTop(t *testing.T) {
+	p := cpuProfile(map[string]int64{
+		"parse;run;main":      600e6,
+		"scan+parse;run;main": 300e6, // scan inlined into parse
+		"run;main":            100e6,
+		"main;run;main":       0,
+	})
+	report := Top(p, 0)
+	assert.Equal(t, "cpu", report.SampleType)
+	assert.Equal(t, "nanoseconds", report.Unit)
+	assert.Equal(t, int64(1000e6), report.Total)
+	assert.Equal(t, []Function{
+		{Name: "parse", Flat: 600e6, Cum: 900e6},
+		{Name: "scan", Flat: 300e6, Cum: 300e6},
+		{Name: "run", Flat: 100e6, Cum: 1000e6},
+		{Name: "main", Flat: 0, Cum: 1000e6},
+	}, report.Functions)
+
+	assert.Len(t, Top(p, 2).Functions, 2)
+	assert.Empty(t, Top(&profile.Profile{}, 10).Functions)
+}
+
+func TestDiff(t *testing.T) {
+	base := cpuProfile(map[string]int64{"parse;main": 500e6, "scan;main": 500e6})
+	current := cpuProfile(map[string]int64{"parse;main": 800e6, "scan;main": 100e6, "alloc;main": 100e6})
+
+	comp, err := Diff(base, current, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000e6), comp.BaseTotal)
+	require.Len(t, comp.Hotter, 2)
+	assert.Equal(t, "parse", comp.Hotter[0].Name)
+	assert.InDelta(t, 30.0, comp.Hotter[0].Delta, 0.01)
+	assert.Equal(t, "alloc", comp.Hotter[1].Name)
+	assert.Equal(t, 0.0, comp.Hotter[1].BasePct)
+	require.Len(t, comp.Cooler, 1)
+	assert.Equal(t, Change{Name: "scan", BasePct: 50, NewPct: 10, Delta: -40}, comp.Cooler[0])
+
+	comp, err = Diff(base, current, 1)
+	require.NoError(t, err)
+	assert.Len(t, comp.Hotter, 1)
+
+	mem := &profile.Profile{SampleType: []*profile.ValueType{{Type: "alloc_space", Unit: "bytes"}}}
+	_, err = Diff(base, mem, 0)
+	assert.ErrorContains(t, err, "cpu (nanoseconds) vs alloc_space (bytes)")
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("mem, cpu,mem")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "mem"}, types)
+	_, err = ParseTypes("cpu,heap")
+	assert.ErrorContains(t, err, `unknown profile type "heap"`)
+}
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "440ms", FormatValue(440e6, "nanoseconds"))
+	assert.Equal(t, "1.25s", FormatValue(1250e6, "nanoseconds"))
+	assert.Equal(t, "512B", FormatValue(512, "bytes"))
+	assert.Equal(t, "5.87MB", FormatValue(6155141, "bytes"))
+	assert.Equal(t, "-2kB", FormatValue(-2048, "bytes"))
+	assert.Equal(t, "42", FormatValue(42, "count"))
+}
+
+func TestCollect(t *testing.T) {
+	mock := runner.NewMock()
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		switch {
+		case cfg.IsCmd("go", "test", "-json", "-list", "Parse"):
+			return runner.MockProcess([]byte(
+				`{"Action":"output","Package":"ex/a","Output":"BenchmarkParse\n"}`+"\n"+
+					`{"Action":"output","Package":"ex/a","Output":"ok  \tex/a\t0.01s\n"}`+"\n"+
+					`{"Action":"output","Package":"ex/b","Output":"TestParse\n"}`+"\n"+
+					`{"Action":"output","Package":"ex/c","Output":"BenchmarkParseFast\n"}`+"\n"), nil), nil
+		case cfg.IsCmd("go", "test", "-run"):
+			pkg := cfg.Args[len(cfg.Args)-1]
+			for i, arg := range cfg.Args {
+				if arg == "-cpuprofile" {
+					p := cpuProfile(map[string]int64{pkg + ".parse;main": 100e6})
+					if err := Save(p, cfg.Args[i+1]); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	profiles, err := Collect(mock, Options{Bench: "Parse/size=1", Time: "1x", Count: 2, Packages: []string{"./..."}})
+	require.NoError(t, err)
+	report := Top(profiles["cpu"], 0)
+	assert.Equal(t, int64(200e6), report.Total)
+	assert.Equal(t, "ex/a.parse", report.Functions[0].Name)
+	assert.Equal(t, "ex/c.parse", report.Functions[1].Name)
+
+	var runs []string
+	for _, c := range mock.Calls() {
+		if c.IsCmd("go", "test", "-run") {
+			runs = append(runs, c.Args[len(c.Args)-1])
+			assert.True(t, c.HasArg("Parse/size=1"))
+			assert.True(t, c.HasArg("-count"))
+			assert.True(t, c.HasArg("-o"))
+		}
+	}
+	assert.Equal(t, []string{"ex/a", "ex/c"}, runs)
+
+	// Packages are merged in order, whatever the map order
+	for range 10 {
+		profiles, err := Collect(mock, Options{Bench: "Parse", Packages: []string{"./..."}})
+		require.NoError(t, err)
+		var first []string
+		for _, s := range profiles["cpu"].Sample {
+			first = append(first, s.Location[0].Line[0].Function.Name)
+		}
+		assert.Equal(t, []string{"ex/a.parse", "ex/c.parse"}, first)
+	}
+
+	// Other types weren't written
+	_, err = Collect(mock, Options{Types: []string{"cpu", "mem"}, Bench: "Parse"})
+	assert.ErrorContains(t, err, "profile output not created for mem")
+
+	_, err = Collect(mock, Options{Bench: "Nothing"})
+	assert.ErrorContains(t, err, `no benchmarks match "Nothing" in ./...`)
+}
+
+func TestCollectFails(t *testing.T) {
+	mock := runner.NewMock()
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		if cfg.IsCmd("go", "-C", "/src", "test", "-json", "-list", ".", "./...") {
+			return runner.MockProcess([]byte(`{"Action":"output","Package":"ex/a","Output":"BenchmarkA\n"}`), nil), nil
+		}
+		if cfg.IsCmd("go", "-C", "/src", "test", "-run") {
+			return runner.MockProcess(nil, fmt.Errorf("exit status 1")), nil
+		}
+		return nil, nil
+	}
+	_, err := Collect(mock, Options{Dir: "/src", Packages: []string{"./..."}})
+	assert.ErrorContains(t, err, "profiling ex/a failed")
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpu.pprof")
+	require.NoError(t, Save(cpuProfile(map[string]int64{"f": 10}), path))
+	p, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), Top(p, 1).Total)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.pprof"))
+	assert.Error(t, err)
+}
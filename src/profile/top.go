@@ -0,0 +1,163 @@
+package profile
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// Function is one function's share of a profile.
+type Function struct {
+	Name string `json:"name"`
+	Flat int64  `json:"flat"` // in the function itself
+	Cum  int64  `json:"cum"`  // in the function and everything it calls
+}
+
+// Report lists the hottest functions of a profile.
+type Report struct {
+	SampleType string     `json:"sample_type"` // e.g. cpu, alloc_space, delay
+	Unit       string     `json:"unit"`        // e.g. nanoseconds, bytes, count
+	Total      int64      `json:"total"`
+	Functions  []Function `json:"functions"` // by Flat, descending
+}
+
+// Top returns the n functions (all if n <= 0) with the highest flat
+// values of the profile's default sample type.
+func Top(p *profile.Profile, n int) *Report {
+	report, functions := summarize(p)
+	report.Functions = functions
+	if n > 0 && len(report.Functions) > n {
+		report.Functions = report.Functions[:n]
+	}
+	return report
+}
+
+// summarize adds up the default sample type's values by function.
+// Inlined calls count as the function they were inlined from, and a
+// function appearing more than once in a stack is counted once in Cum.
+func summarize(p *profile.Profile) (*Report, []Function) {
+	report := &Report{}
+	index := sampleIndex(p)
+	if index < 0 {
+		return report, nil
+	}
+	report.SampleType = p.SampleType[index].Type
+	report.Unit = p.SampleType[index].Unit
+
+	byName := make(map[string]*Function)
+	get := func(name string) *Function {
+		f, ok := byName[name]
+		if !ok {
+			f = &Function{Name: name}
+			byName[name] = f
+		}
+		return f
+	}
+	for _, s := range p.Sample {
+		v := s.Value[index]
+		if v == 0 {
+			continue
+		}
+		report.Total += v
+		seen := make(map[string]bool)
+		for i, loc := range s.Location {
+			for j, name := range frames(loc) {
+				if i == 0 && j == 0 {
+					get(name).Flat += v
+				}
+				if !seen[name] {
+					seen[name] = true
+					get(name).Cum += v
+				}
+			}
+		}
+	}
+
+	functions := make([]Function, 0, len(byName))
+	for _, f := range byName {
+		functions = append(functions, *f)
+	}
+	slices.SortFunc(functions, func(a, b Function) int {
+		return cmp.Or(cmp.Compare(b.Flat, a.Flat), cmp.Compare(b.Cum, a.Cum), cmp.Compare(a.Name, b.Name))
+	})
+	return report, functions
+}
+
+// sampleIndex picks the sample type go tool pprof shows by default: the
+// profile's declared default, or else the last one.
+func sampleIndex(p *profile.Profile) int {
+	for i, st := range p.SampleType {
+		if st.Type == p.DefaultSampleType {
+			return i
+		}
+	}
+	return len(p.SampleType) - 1
+}
+
+// frames returns the functions of a location, innermost inlined call
+// first, or its address if it isn't symbolized.
+func frames(loc *profile.Location) []string {
+	var names []string
+	for _, line := range loc.Line {
+		if line.Function != nil && line.Function.Name != "" {
+			names = append(names, line.Function.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, fmt.Sprintf("0x%x", loc.Address))
+	}
+	return names
+}
+
+// percent returns v as a percentage of total.
+func percent(v, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(v) / float64(total) * 100
+}
+
+// Print displays the report as a table.
+func (r *Report) Print() {
+	if len(r.Functions) == 0 {
+		fmt.Println("  (no samples)")
+		return
+	}
+	fmt.Printf("  %10s %6s %6s %10s %6s  %s\n", "flat", "flat%", "sum%", "cum", "cum%", "function")
+	var sum int64
+	for _, f := range r.Functions {
+		sum += f.Flat
+		fmt.Printf("  %10s %5.1f%% %5.1f%% %10s %5.1f%%  %s\n",
+			FormatValue(f.Flat, r.Unit), percent(f.Flat, r.Total), percent(sum, r.Total),
+			FormatValue(f.Cum, r.Unit), percent(f.Cum, r.Total), f.Name)
+	}
+}
+
+// FormatValue formats a sample value in its unit.
+func FormatValue(v int64, unit string) string {
+	abs := float64(v)
+	if abs < 0 {
+		abs = -abs
+	}
+	scale := func(units []string, step float64) string {
+		x, i := float64(v), 0
+		for ; i < len(units)-1 && abs >= step; i++ {
+			x /= step
+			abs /= step
+		}
+		if i == 0 {
+			return fmt.Sprintf("%d%s", v, units[0])
+		}
+		return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", x), "0"), ".") + units[i]
+	}
+	switch unit {
+	case "nanoseconds":
+		return scale([]string{"ns", "µs", "ms", "s"}, 1000)
+	case "bytes":
+		return scale([]string{"B", "kB", "MB", "GB"}, 1024)
+	}
+	return fmt.Sprintf("%d", v)
+}
@@ -0,0 +1,141 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeAPK writes an unsigned Alpine v2 package: a gzipped control tarball
+// holding .PKGINFO, immediately followed by the gzipped data tarball.
+// Install with `apk add --allow-untrusted`, or sign it with abuild-sign.
+func writeAPK(w io.Writer, info PackageInfo, contents []packageContent, mtime time.Time) error {
+	arch, err := mapArch(apkArch, "apk", info)
+	if err != nil {
+		return err
+	}
+
+	data, err := apkDataTar(contents, mtime)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, c := range contents {
+		size += int64(len(c.data))
+	}
+	var pkginfo strings.Builder
+	fmt.Fprintf(&pkginfo, "pkgname = %s\n", info.Name)
+	fmt.Fprintf(&pkginfo, "pkgver = %s\n", apkVersion(info.Version))
+	desc, _, _ := strings.Cut(strings.TrimSpace(info.Description), "\n")
+	if desc == "" {
+		desc = info.Name
+	}
+	fmt.Fprintf(&pkginfo, "pkgdesc = %s\n", desc)
+	if info.Homepage != "" {
+		fmt.Fprintf(&pkginfo, "url = %s\n", info.Homepage)
+	}
+	fmt.Fprintf(&pkginfo, "builddate = %d\n", mtime.Unix())
+	if info.Maintainer != "" {
+		fmt.Fprintf(&pkginfo, "packager = %s\n", info.Maintainer)
+		fmt.Fprintf(&pkginfo, "maintainer = %s\n", info.Maintainer)
+	}
+	fmt.Fprintf(&pkginfo, "size = %d\n", size)
+	fmt.Fprintf(&pkginfo, "arch = %s\n", arch)
+	if info.License != "" {
+		fmt.Fprintf(&pkginfo, "license = %s\n", info.License)
+	}
+	for _, dep := range info.Depends {
+		fmt.Fprintf(&pkginfo, "depend = %s\n", dep)
+	}
+	fmt.Fprintf(&pkginfo, "datahash = %x\n", sha256.Sum256(data))
+
+	control, err := apkControlTar(pkginfo.String(), mtime)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(control); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// apkControlTar gzips a tarball containing .PKGINFO. The tar end-of-archive
+// blocks are omitted so the data stream reads as a continuation of it.
+func apkControlTar(pkginfo string, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     ".PKGINFO",
+		Mode:     0644,
+		Size:     int64(len(pkginfo)),
+		ModTime:  mtime,
+		Uname:    "root",
+		Gname:    "root",
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(tw, pkginfo); err != nil {
+		return nil, err
+	}
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// apkDataTar gzips the installed files. apk-tools verifies each file against
+// the SHA1 stored in its APK-TOOLS.checksum.SHA1 PAX record.
+func apkDataTar(contents []packageContent, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, dir := range parentDirs(contents) {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     strings.TrimPrefix(dir, "/") + "/",
+			Mode:     0755,
+			ModTime:  mtime,
+			Uname:    "root",
+			Gname:    "root",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range contents {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeReg,
+			Name:       strings.TrimPrefix(c.Dest, "/"),
+			Mode:       int64(c.Mode.Perm()),
+			Size:       int64(len(c.data)),
+			ModTime:    mtime,
+			Uname:      "root",
+			Gname:      "root",
+			PAXRecords: map[string]string{"APK-TOOLS.checksum.SHA1": fmt.Sprintf("%x", sha1.Sum(c.data))},
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(c.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
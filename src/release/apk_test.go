@@ -0,0 +1,48 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestWriteAPK(t *testing.T) {
+	info, files := testPackage(t)
+	contents, err := loadPackageFiles(files)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeAPK(&buf, info, contents, time.Unix(1700000000, 0)))
+
+	// Two concatenated gzip streams: control, then data
+	br := bytes.NewReader(buf.Bytes())
+	gz, err := gzip.NewReader(br)
+	require.NoError(t, err)
+	gz.Multistream(false)
+	control, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	data := buf.Bytes()[len(buf.Bytes())-br.Len():]
+
+	tr := tar.NewReader(bytes.NewReader(control))
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, ".PKGINFO", hdr.Name)
+	pkginfo, _ := io.ReadAll(tr)
+	assert.Contains(t, string(pkginfo), "pkgname = tool\n")
+	assert.Contains(t, string(pkginfo), "pkgver = 1.2.3-r0\n")
+	assert.Contains(t, string(pkginfo), "pkgdesc = A tool\n")
+	assert.Contains(t, string(pkginfo), "arch = x86_64\n")
+	assert.Contains(t, string(pkginfo), "depend = ca-certificates\n")
+	assert.Contains(t, string(pkginfo), fmt.Sprintf("datahash = %x\n", sha256.Sum256(data)))
+
+	entries := readTarGz(t, data)
+	assert.Equal(t, "#!/bin/sh\necho hi\n", entries["usr/bin/tool"])
+}
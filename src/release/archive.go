@@ -0,0 +1,118 @@
+package release
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File is a single entry to place in an archive.
+type File struct {
+	Name   string      // slash-separated path inside the archive
+	Source string      // path on disk
+	Mode   fs.FileMode // permission bits stored in the archive
+}
+
+// WriteArchive writes files into a new archive at path. The format is chosen
+// from the extension: ".zip" or ".tar.gz". Entries are sorted by name and
+// stamped with mtime, with owner and other host details stripped, so the
+// same inputs always produce byte-identical archives.
+func WriteArchive(path string, files []File, mtime time.Time) error {
+	if mtime.IsZero() {
+		mtime = time.Unix(0, 0)
+	}
+	mtime = mtime.UTC().Truncate(time.Second)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		err = WriteZip(f, files, mtime)
+	case strings.HasSuffix(path, ".tar.gz"):
+		err = WriteTarGz(f, files, mtime)
+	default:
+		err = fmt.Errorf("unsupported archive extension: %s", path)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteTarGz writes a reproducible gzipped tarball of files to w.
+func WriteTarGz(w io.Writer, files []File, mtime time.Time) error {
+	gz := gzip.NewWriter(w) // zero header ModTime/Name keeps the gzip header stable
+	tw := tar.NewWriter(gz)
+	for _, file := range sortedFiles(files) {
+		data, err := os.ReadFile(file.Source)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     int64(file.Mode.Perm()),
+			Size:     int64(len(data)),
+			ModTime:  mtime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// WriteZip writes a reproducible zip archive of files to w.
+func WriteZip(w io.Writer, files []File, mtime time.Time) error {
+	// MS-DOS timestamps cannot represent anything before 1980
+	if minDOS := time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC); mtime.Before(minDOS) {
+		mtime = minDOS
+	}
+	zw := zip.NewWriter(w)
+	for _, file := range sortedFiles(files) {
+		data, err := os.ReadFile(file.Source)
+		if err != nil {
+			return err
+		}
+		hdr := &zip.FileHeader{
+			Name:     file.Name,
+			Method:   zip.Deflate,
+			Modified: mtime,
+		}
+		hdr.SetMode(file.Mode.Perm())
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func sortedFiles(files []File) []File {
+	sorted := make([]File, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
@@ -0,0 +1,119 @@
+package release
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func writeTestFiles(t *testing.T) []File {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app"), []byte("binary"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0644))
+	return []File{
+		{Name: "app", Source: filepath.Join(dir, "app"), Mode: 0755},
+		{Name: "README.md", Source: filepath.Join(dir, "README.md"), Mode: 0644},
+	}
+}
+
+func TestWriteArchiveTarGz(t *testing.T) {
+	files := writeTestFiles(t)
+	mtime := time.Unix(1700000000, 0)
+	out := filepath.Join(t.TempDir(), "app_linux_amd64.tar.gz")
+	require.NoError(t, WriteArchive(out, files, mtime))
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		assert.Equal(t, mtime.Unix(), hdr.ModTime.Unix())
+		assert.Equal(t, 0, hdr.Uid)
+		assert.Equal(t, "", hdr.Uname)
+		if hdr.Name == "app" {
+			assert.Equal(t, int64(0755), hdr.Mode)
+			data, _ := io.ReadAll(tr)
+			assert.Equal(t, "binary", string(data))
+		}
+	}
+	// Sorted by name regardless of input order
+	assert.Equal(t, []string{"README.md", "app"}, names)
+}
+
+func TestWriteArchiveZip(t *testing.T) {
+	files := writeTestFiles(t)
+	mtime := time.Unix(1700000000, 0)
+	out := filepath.Join(t.TempDir(), "app_windows_amd64.zip")
+	require.NoError(t, WriteArchive(out, files, mtime))
+
+	zr, err := zip.OpenReader(out)
+	require.NoError(t, err)
+	defer zr.Close()
+	require.Equal(t, 2, len(zr.File))
+	assert.Equal(t, "README.md", zr.File[0].Name)
+	assert.Equal(t, "app", zr.File[1].Name)
+	assert.Equal(t, mtime.Unix(), zr.File[1].Modified.Unix())
+	assert.Equal(t, os.FileMode(0755), zr.File[1].Mode().Perm())
+}
+
+func TestWriteArchiveReproducible(t *testing.T) {
+	files := writeTestFiles(t)
+	mtime := time.Unix(1700000000, 0)
+	for _, ext := range []string{".tar.gz", ".zip"} {
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a"+ext)
+		b := filepath.Join(dir, "b"+ext)
+		require.NoError(t, WriteArchive(a, files, mtime))
+		// Reversed input order must not change the output
+		require.NoError(t, WriteArchive(b, []File{files[1], files[0]}, mtime))
+
+		dataA, _ := os.ReadFile(a)
+		dataB, _ := os.ReadFile(b)
+		assert.True(t, bytes.Equal(dataA, dataB), ext)
+	}
+}
+
+func TestWriteArchiveZeroTime(t *testing.T) {
+	files := writeTestFiles(t)
+	dir := t.TempDir()
+	require.NoError(t, WriteArchive(filepath.Join(dir, "a.zip"), files, time.Time{}))
+	require.NoError(t, WriteArchive(filepath.Join(dir, "a.tar.gz"), files, time.Time{}))
+
+	zr, err := zip.OpenReader(filepath.Join(dir, "a.zip"))
+	require.NoError(t, err)
+	defer zr.Close()
+	assert.Equal(t, 1980, zr.File[0].Modified.Year())
+}
+
+func TestWriteArchiveUnsupported(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "app.rar")
+	err := WriteArchive(out, writeTestFiles(t), time.Unix(0, 0))
+	assert.NotNil(t, err)
+	_, statErr := os.Stat(out)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestWriteArchiveMissingSource(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "app.tar.gz")
+	err := WriteArchive(out, []File{{Name: "x", Source: "/nonexistent/x", Mode: 0644}}, time.Unix(0, 0))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,183 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeDeb writes a Debian binary package: an ar archive holding
+// debian-binary, control.tar.gz and data.tar.gz, in that order.
+func writeDeb(w io.Writer, info PackageInfo, contents []packageContent, mtime time.Time) error {
+	arch, err := mapArch(debArch, "deb", info)
+	if err != nil {
+		return err
+	}
+
+	data, err := debDataTar(contents, mtime)
+	if err != nil {
+		return err
+	}
+	control, err := debControlTar(info, arch, contents, mtime)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	} {
+		if err := writeArMember(w, member.name, member.data, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArMember writes one member of a common-format ar archive.
+func writeArMember(w io.Writer, name string, data []byte, mtime time.Time) error {
+	hdr := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", name, mtime.Unix(), 0, 0, 0644, len(data))
+	if _, err := io.WriteString(w, hdr); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)%2 != 0 {
+		_, err := w.Write([]byte{'\n'})
+		return err
+	}
+	return nil
+}
+
+func debDataTar(contents []packageContent, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, dir := range parentDirs(contents) {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     "." + dir + "/",
+			Mode:     0755,
+			ModTime:  mtime,
+			Uname:    "root",
+			Gname:    "root",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range contents {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     "." + c.Dest,
+			Mode:     int64(c.Mode.Perm()),
+			Size:     int64(len(c.data)),
+			ModTime:  mtime,
+			Uname:    "root",
+			Gname:    "root",
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(c.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func debControlTar(info PackageInfo, arch string, contents []packageContent, mtime time.Time) ([]byte, error) {
+	var installedSize int64
+	var md5sums strings.Builder
+	for _, c := range contents {
+		installedSize += int64(len(c.data))
+		fmt.Fprintf(&md5sums, "%x  %s\n", md5.Sum(c.data), strings.TrimPrefix(c.Dest, "/"))
+	}
+
+	var control strings.Builder
+	fmt.Fprintf(&control, "Package: %s\n", info.Name)
+	fmt.Fprintf(&control, "Version: %s\n", debVersion(info.Version))
+	fmt.Fprintf(&control, "Architecture: %s\n", arch)
+	maintainer := info.Maintainer
+	if maintainer == "" {
+		maintainer = "unknown"
+	}
+	fmt.Fprintf(&control, "Maintainer: %s\n", maintainer)
+	fmt.Fprintf(&control, "Installed-Size: %d\n", (installedSize+1023)/1024)
+	if len(info.Depends) > 0 {
+		fmt.Fprintf(&control, "Depends: %s\n", strings.Join(info.Depends, ", "))
+	}
+	if info.Homepage != "" {
+		fmt.Fprintf(&control, "Homepage: %s\n", info.Homepage)
+	}
+	fmt.Fprintf(&control, "Description: %s\n", debDescription(info.Description, info.Name))
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		data string
+	}{
+		{"./control", control.String()},
+		{"./md5sums", md5sums.String()},
+	} {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			ModTime:  mtime,
+			Uname:    "root",
+			Gname:    "root",
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(tw, f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// debDescription formats a (possibly multi-line) description as a control
+// field: synopsis on the first line, then indented continuation lines with
+// blank lines written as " .".
+func debDescription(desc, fallback string) string {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return fallback
+	}
+	lines := strings.Split(desc, "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			lines[i] = " ."
+		} else {
+			lines[i] = " " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
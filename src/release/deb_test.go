@@ -0,0 +1,83 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+// readAr splits an ar archive into its member names and contents.
+func readAr(t *testing.T, data []byte) ([]string, map[string][]byte) {
+	require.True(t, bytes.HasPrefix(data, []byte("!<arch>\n")))
+	data = data[8:]
+	var names []string
+	members := make(map[string][]byte)
+	for len(data) > 0 {
+		require.True(t, len(data) >= 60)
+		name := strings.TrimSpace(string(data[0:16]))
+		size, err := strconv.Atoi(strings.TrimSpace(string(data[48:58])))
+		require.NoError(t, err)
+		assert.Equal(t, "`\n", string(data[58:60]))
+		names = append(names, name)
+		members[name] = data[60 : 60+size]
+		data = data[60+size+size%2:]
+	}
+	return names, members
+}
+
+// readTarGz returns the entries of a gzipped tarball keyed by name.
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	entries := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		body, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(body)
+	}
+}
+
+func TestWriteDeb(t *testing.T) {
+	info, files := testPackage(t)
+	contents, err := loadPackageFiles(files)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDeb(&buf, info, contents, time.Unix(1700000000, 0)))
+
+	names, members := readAr(t, buf.Bytes())
+	assert.Equal(t, []string{"debian-binary", "control.tar.gz", "data.tar.gz"}, names)
+	assert.Equal(t, "2.0\n", string(members["debian-binary"]))
+
+	control := readTarGz(t, members["control.tar.gz"])
+	assert.Contains(t, control["./control"], "Package: tool\n")
+	assert.Contains(t, control["./control"], "Version: 1.2.3\n")
+	assert.Contains(t, control["./control"], "Architecture: amd64\n")
+	assert.Contains(t, control["./control"], "Depends: ca-certificates\n")
+	assert.Contains(t, control["./control"], "Description: A tool\n .\n Longer text.\n")
+	assert.Contains(t, control["./md5sums"], "  usr/bin/tool\n")
+
+	data := readTarGz(t, members["data.tar.gz"])
+	assert.Equal(t, "#!/bin/sh\necho hi\n", data["./usr/bin/tool"])
+	_, hasDir := data["./usr/bin/"]
+	assert.True(t, hasDir)
+}
+
+func TestDebDescription(t *testing.T) {
+	assert.Equal(t, "tool", debDescription("", "tool"))
+	assert.Equal(t, "One line", debDescription("One line\n", "tool"))
+	assert.Equal(t, "Title\n .\n Body", debDescription("Title\n\nBody", "tool"))
+}
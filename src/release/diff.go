@@ -0,0 +1,123 @@
+package release
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// SectionDiff describes a section whose contents differ between two builds.
+type SectionDiff struct {
+	Name        string
+	SizeA       uint64
+	SizeB       uint64
+	FirstOffset int64 // first differing byte within the section
+	OnlyIn      int   // 1 or 2 if the section exists in only one file, else 0
+}
+
+// FirstDifference returns the offset of the first differing byte of a and b,
+// or -1 if they are identical.
+func FirstDifference(a, b []byte) int64 {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return int64(i)
+		}
+	}
+	if len(a) != len(b) {
+		return int64(n)
+	}
+	return -1
+}
+
+// DiffSections compares the sections of two executables (ELF, Mach-O or PE)
+// and returns those whose contents differ, in file order of the first.
+func DiffSections(pathA, pathB string) ([]SectionDiff, error) {
+	a, order, err := readSections(pathA)
+	if err != nil {
+		return nil, err
+	}
+	b, _, err := readSections(pathB)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []SectionDiff
+	for _, name := range order {
+		dataB, ok := b[name]
+		if !ok {
+			diffs = append(diffs, SectionDiff{Name: name, SizeA: uint64(len(a[name])), OnlyIn: 1})
+			continue
+		}
+		if off := FirstDifference(a[name], dataB); off >= 0 {
+			diffs = append(diffs, SectionDiff{Name: name, SizeA: uint64(len(a[name])), SizeB: uint64(len(dataB)), FirstOffset: off})
+		}
+	}
+	var onlyB []string
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			onlyB = append(onlyB, name)
+		}
+	}
+	sort.Strings(onlyB)
+	for _, name := range onlyB {
+		diffs = append(diffs, SectionDiff{Name: name, SizeB: uint64(len(b[name])), OnlyIn: 2})
+	}
+	return diffs, nil
+}
+
+// readSections returns each section's contents by name, plus the names in
+// file order. Sections without file data (e.g. .bss) are skipped.
+func readSections(path string) (map[string][]byte, []string, error) {
+	type section struct {
+		name string
+		r    io.Reader
+	}
+	var sections []section
+
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			if s.Type != elf.SHT_NOBITS && s.Type != elf.SHT_NULL {
+				sections = append(sections, section{s.Name, s.Open()})
+			}
+		}
+	} else if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			// Zero-fill sections have no file data; Open would read from offset 0
+			switch s.Flags & 0xff {
+			case 0x1, 0xc, 0x12: // S_ZEROFILL, S_GB_ZEROFILL, S_THREAD_LOCAL_ZEROFILL
+				continue
+			}
+			sections = append(sections, section{s.Seg + "," + s.Name, s.Open()})
+		}
+	} else if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			sections = append(sections, section{s.Name, s.Open()})
+		}
+	} else {
+		if _, statErr := os.Stat(path); statErr != nil {
+			return nil, nil, statErr
+		}
+		return nil, nil, fmt.Errorf("%s: not an ELF, Mach-O or PE executable", path)
+	}
+
+	data := make(map[string][]byte, len(sections))
+	var order []string
+	for _, s := range sections {
+		var buf bytes.Buffer
+		io.Copy(&buf, s.r)
+		if _, dup := data[s.name]; !dup {
+			order = append(order, s.name)
+		}
+		data[s.name] = append(data[s.name], buf.Bytes()...)
+	}
+	return data, order, nil
+}
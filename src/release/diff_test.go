@@ -0,0 +1,52 @@
+package release
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestFirstDifference(t *testing.T) {
+	assert.Equal(t, int64(-1), FirstDifference([]byte("abc"), []byte("abc")))
+	assert.Equal(t, int64(1), FirstDifference([]byte("abc"), []byte("axc")))
+	assert.Equal(t, int64(3), FirstDifference([]byte("abc"), []byte("abcd")))
+}
+
+func TestDiffSections(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds binaries")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module difftest\n"), 0644))
+	build := func(msg, out string) {
+		src := "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"" + msg + "\") }\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644))
+		cmd := exec.Command("go", "build", "-trimpath", "-o", out, ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+		require.NoError(t, cmd.Run())
+	}
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	build("hello", a)
+	build("world", b)
+
+	diffs, err := DiffSections(a, a)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(diffs))
+
+	diffs, err = DiffSections(a, b)
+	require.NoError(t, err)
+	var names []string
+	for _, d := range diffs {
+		names = append(names, d.Name)
+	}
+	assert.Contains(t, names, ".rodata")
+
+	_, err = DiffSections(filepath.Join(dir, "main.go"), b)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,204 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PackageInfo is the metadata shared by the deb, rpm and apk writers.
+type PackageInfo struct {
+	Name        string
+	Version     string // git-style version, e.g. v1.2.3 or v1.2.3-4-gabcdef0
+	GOARCH      string
+	Variant     string // e.g. "6" for linux/arm/6; only arm's changes the package architecture
+	Maintainer  string
+	Description string
+	Homepage    string
+	License     string
+	Depends     []string
+}
+
+// PackageFile is a file installed by a package.
+type PackageFile struct {
+	Dest   string      // absolute install path, e.g. /usr/bin/tool
+	Source string      // path on disk
+	Mode   fs.FileMode // permission bits
+}
+
+// packageContent is a PackageFile with its data loaded, shared by all
+// formats so each file is read once.
+type packageContent struct {
+	PackageFile
+	data []byte
+}
+
+func loadPackageFiles(files []PackageFile) ([]packageContent, error) {
+	contents := make([]packageContent, 0, len(files))
+	for _, f := range files {
+		if !strings.HasPrefix(f.Dest, "/") {
+			return nil, fmt.Errorf("package file destination must be absolute: %s", f.Dest)
+		}
+		data, err := os.ReadFile(f.Source)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, packageContent{PackageFile: f, data: data})
+	}
+	sort.Slice(contents, func(i, j int) bool { return contents[i].Dest < contents[j].Dest })
+	return contents, nil
+}
+
+// parentDirs returns every directory above the given absolute paths, sorted
+// so parents precede children (e.g. /usr, /usr/bin).
+func parentDirs(contents []packageContent) []string {
+	seen := make(map[string]bool)
+	for _, c := range contents {
+		dir := c.Dest
+		for {
+			dir = dir[:strings.LastIndex(dir, "/")]
+			if dir == "" || seen[dir] {
+				break
+			}
+			seen[dir] = true
+		}
+	}
+	dirs := make([]string, 0, len(seen))
+	for d := range seen {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Per-format architecture names for each GOARCH. 32-bit arm is ARMv7
+// unless its GOARM variant is 5 or 6; Alpine has no ARMv5 architecture.
+var (
+	debArch = map[string]string{
+		"amd64": "amd64", "arm64": "arm64", "386": "i386", "arm": "armhf", "arm/6": "armel", "arm/5": "armel",
+		"riscv64": "riscv64", "ppc64le": "ppc64el", "s390x": "s390x", "loong64": "loong64",
+	}
+	rpmArch = map[string]string{
+		"amd64": "x86_64", "arm64": "aarch64", "386": "i686", "arm": "armv7hl", "arm/6": "armv6hl", "arm/5": "armv5tel",
+		"riscv64": "riscv64", "ppc64le": "ppc64le", "s390x": "s390x", "loong64": "loongarch64",
+	}
+	apkArch = map[string]string{
+		"amd64": "x86_64", "arm64": "aarch64", "386": "x86", "arm": "armv7", "arm/6": "armhf",
+		"riscv64": "riscv64", "ppc64le": "ppc64le", "s390x": "s390x", "loong64": "loongarch64",
+	}
+)
+
+func mapArch(table map[string]string, format string, info PackageInfo) (string, error) {
+	key := info.GOARCH
+	if key == "arm" && info.Variant != "" && info.Variant != "7" {
+		key += "/" + info.Variant
+	}
+	if a, ok := table[key]; ok {
+		return a, nil
+	}
+	return "", fmt.Errorf("%s packages: unsupported platform linux/%s", format, key)
+}
+
+var numericVersion = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// splitVersion turns a `git describe` version into a numeric base version and
+// the remainder after it, e.g. "v1.2.3-4-gabcdef0" -> ("1.2.3", "4-gabcdef0").
+// Versions without a numeric base (untagged repos) become "0.0.0".
+func splitVersion(v string) (base, rest string) {
+	v = strings.TrimPrefix(v, "v")
+	base, rest, _ = strings.Cut(v, "-")
+	if !numericVersion.MatchString(base) {
+		return "0.0.0", v
+	}
+	return base, rest
+}
+
+// debVersion formats v for a native Debian package: 1.2.3+4.gabcdef0
+func debVersion(v string) string {
+	base, rest := splitVersion(v)
+	if rest == "" {
+		return base
+	}
+	return base + "+" + strings.ReplaceAll(rest, "-", ".")
+}
+
+// rpmVersion splits v into RPM Version and Release fields: 1.2.3 and 1 for a
+// tag, or 1.2.3 and 0.4.gabcdef0 for commits after it (sorting before 1).
+func rpmVersion(v string) (version, rel string) {
+	base, rest := splitVersion(v)
+	if rest == "" {
+		return base, "1"
+	}
+	return base, "0." + strings.ReplaceAll(rest, "-", ".")
+}
+
+// apkVersion formats v for Alpine: 1.2.3-r0, or 1.2.3_p4-r0 for commits
+// after a tag (apk versions cannot carry commit hashes).
+func apkVersion(v string) string {
+	base, rest := splitVersion(v)
+	if n, _, _ := strings.Cut(rest, "-"); n != "" && strings.Trim(n, "0123456789") == "" {
+		return base + "_p" + n + "-r0"
+	}
+	return base + "-r0"
+}
+
+// WritePackage writes a deb, rpm or apk package to path. Like archives,
+// packages are reproducible: sorted entries, mtime on every file, and no
+// host-specific owner information.
+func WritePackage(path, format string, info PackageInfo, files []PackageFile, mtime time.Time) error {
+	contents, err := loadPackageFiles(files)
+	if err != nil {
+		return err
+	}
+	if mtime.IsZero() {
+		mtime = time.Unix(0, 0)
+	}
+	mtime = mtime.UTC().Truncate(time.Second)
+
+	var buf bytes.Buffer
+	switch format {
+	case "deb":
+		err = writeDeb(&buf, info, contents, mtime)
+	case "rpm":
+		err = writeRPM(&buf, info, contents, mtime)
+	case "apk":
+		err = writeAPK(&buf, info, contents, mtime)
+	default:
+		err = fmt.Errorf("unknown package format %q (use: deb, rpm, apk)", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build %s: %w", path, err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// PackageFileName returns the conventional file name for a package.
+func PackageFileName(format string, info PackageInfo) (string, error) {
+	switch format {
+	case "deb":
+		arch, err := mapArch(debArch, format, info)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s_%s_%s.deb", info.Name, debVersion(info.Version), arch), nil
+	case "rpm":
+		arch, err := mapArch(rpmArch, format, info)
+		if err != nil {
+			return "", err
+		}
+		version, rel := rpmVersion(info.Version)
+		return fmt.Sprintf("%s-%s-%s.%s.rpm", info.Name, version, rel, arch), nil
+	case "apk":
+		arch, err := mapArch(apkArch, format, info)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s-%s.%s.apk", info.Name, apkVersion(info.Version), arch), nil
+	}
+	return "", fmt.Errorf("unknown package format %q (use: deb, rpm, apk)", format)
+}
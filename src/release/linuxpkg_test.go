@@ -0,0 +1,131 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+// testPackage writes a small executable and returns package metadata and
+// files installing it as /usr/bin/tool.
+func testPackage(t *testing.T) (PackageInfo, []PackageFile) {
+	src := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(src, []byte("#!/bin/sh\necho hi\n"), 0755))
+	info := PackageInfo{
+		Name:        "tool",
+		Version:     "v1.2.3",
+		GOARCH:      "amd64",
+		Maintainer:  "Dev <dev@example.com>",
+		Description: "A tool\n\nLonger text.",
+		Homepage:    "https://example.com",
+		License:     "MIT",
+		Depends:     []string{"ca-certificates"},
+	}
+	return info, []PackageFile{{Dest: "/usr/bin/tool", Source: src, Mode: 0755}}
+}
+
+func TestPackageVersions(t *testing.T) {
+	tests := []struct {
+		in, deb, rpmVer, rpmRel, apk string
+	}{
+		{"v1.2.3", "1.2.3", "1.2.3", "1", "1.2.3-r0"},
+		{"1.2.3", "1.2.3", "1.2.3", "1", "1.2.3-r0"},
+		{"v1.2.3-4-gabcdef0", "1.2.3+4.gabcdef0", "1.2.3", "0.4.gabcdef0", "1.2.3_p4-r0"},
+		{"v1.2.3-dirty", "1.2.3+dirty", "1.2.3", "0.dirty", "1.2.3-r0"},
+		{"abcdef0", "0.0.0+abcdef0", "0.0.0", "0.abcdef0", "0.0.0-r0"},
+		{"", "0.0.0", "0.0.0", "1", "0.0.0-r0"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.deb, debVersion(tt.in), tt.in)
+		v, rel := rpmVersion(tt.in)
+		assert.Equal(t, tt.rpmVer, v, tt.in)
+		assert.Equal(t, tt.rpmRel, rel, tt.in)
+		assert.Equal(t, tt.apk, apkVersion(tt.in), tt.in)
+	}
+}
+
+func TestPackageFileName(t *testing.T) {
+	info := PackageInfo{Name: "tool", Version: "v1.2.3", GOARCH: "arm64"}
+	name, err := PackageFileName("deb", info)
+	require.NoError(t, err)
+	assert.Equal(t, "tool_1.2.3_arm64.deb", name)
+	name, err = PackageFileName("rpm", info)
+	require.NoError(t, err)
+	assert.Equal(t, "tool-1.2.3-1.aarch64.rpm", name)
+	name, err = PackageFileName("apk", info)
+	require.NoError(t, err)
+	assert.Equal(t, "tool-1.2.3-r0.aarch64.apk", name)
+
+	_, err = PackageFileName("msi", info)
+	assert.NotNil(t, err)
+	_, err = PackageFileName("deb", PackageInfo{Name: "tool", GOARCH: "wasm"})
+	assert.NotNil(t, err)
+}
+
+func TestPackageFileNameArmVariants(t *testing.T) {
+	tests := []struct {
+		variant       string
+		deb, rpm, apk string
+	}{
+		{"", "armhf", "armv7hl", "armv7"},
+		{"7", "armhf", "armv7hl", "armv7"},
+		{"6", "armel", "armv6hl", "armhf"},
+		{"5", "armel", "armv5tel", ""},
+	}
+	for _, tt := range tests {
+		info := PackageInfo{Name: "tool", Version: "v1.2.3", GOARCH: "arm", Variant: tt.variant}
+		name, err := PackageFileName("deb", info)
+		require.NoError(t, err)
+		assert.Equal(t, "tool_1.2.3_"+tt.deb+".deb", name, tt.variant)
+		name, err = PackageFileName("rpm", info)
+		require.NoError(t, err)
+		assert.Equal(t, "tool-1.2.3-1."+tt.rpm+".rpm", name, tt.variant)
+		name, err = PackageFileName("apk", info)
+		if tt.apk == "" {
+			require.NotNil(t, err, tt.variant)
+			assert.Contains(t, err.Error(), "linux/arm/5")
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "tool-1.2.3-r0."+tt.apk+".apk", name, tt.variant)
+	}
+
+	// amd64 levels don't change the architecture
+	name, err := PackageFileName("deb", PackageInfo{Name: "tool", Version: "v1.2.3", GOARCH: "amd64", Variant: "v3"})
+	require.NoError(t, err)
+	assert.Equal(t, "tool_1.2.3_amd64.deb", name)
+}
+
+func TestParentDirs(t *testing.T) {
+	contents := []packageContent{
+		{PackageFile: PackageFile{Dest: "/usr/bin/tool"}},
+		{PackageFile: PackageFile{Dest: "/usr/share/doc/tool/README"}},
+	}
+	assert.Equal(t, []string{"/usr", "/usr/bin", "/usr/share", "/usr/share/doc", "/usr/share/doc/tool"}, parentDirs(contents))
+}
+
+func TestWritePackageReproducible(t *testing.T) {
+	info, files := testPackage(t)
+	dir := t.TempDir()
+	for _, format := range []string{"deb", "rpm", "apk"} {
+		a := filepath.Join(dir, "a."+format)
+		b := filepath.Join(dir, "b."+format)
+		require.NoError(t, WritePackage(a, format, info, files, time.Unix(1700000000, 0)))
+		require.NoError(t, WritePackage(b, format, info, files, time.Unix(1700000000, 0)))
+		dataA, _ := os.ReadFile(a)
+		dataB, _ := os.ReadFile(b)
+		assert.Equal(t, dataA, dataB, format)
+	}
+}
+
+func TestWritePackageErrors(t *testing.T) {
+	info, files := testPackage(t)
+	dir := t.TempDir()
+	assert.NotNil(t, WritePackage(filepath.Join(dir, "x"), "msi", info, files, time.Time{}))
+	assert.NotNil(t, WritePackage(filepath.Join(dir, "x"), "deb", info, []PackageFile{{Dest: "usr/bin/tool", Source: files[0].Source}}, time.Time{}))
+	assert.NotNil(t, WritePackage(filepath.Join(dir, "x"), "deb", info, []PackageFile{{Dest: "/usr/bin/tool", Source: "/nonexistent"}}, time.Time{}))
+}
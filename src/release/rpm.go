@@ -0,0 +1,389 @@
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RPM header data types.
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeBin         = 7
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+)
+
+// RPM header tags (rpmtag.h). Signature tags share the numbering space.
+const (
+	rpmTagHeaderSignatures = 62
+	rpmTagHeaderImmutable  = 63
+	rpmTagHeaderI18NTable  = 100
+
+	rpmSigTagSHA1        = 269
+	rpmSigTagSHA256      = 273
+	rpmSigTagSize        = 1000
+	rpmSigTagMD5         = 1004
+	rpmSigTagPayloadSize = 1007
+
+	rpmTagName              = 1000
+	rpmTagVersion           = 1001
+	rpmTagRelease           = 1002
+	rpmTagSummary           = 1004
+	rpmTagDescription       = 1005
+	rpmTagBuildTime         = 1006
+	rpmTagSize              = 1009
+	rpmTagLicense           = 1014
+	rpmTagPackager          = 1015
+	rpmTagGroup             = 1016
+	rpmTagURL               = 1020
+	rpmTagOS                = 1021
+	rpmTagArch              = 1022
+	rpmTagFileSizes         = 1028
+	rpmTagFileModes         = 1030
+	rpmTagFileRdevs         = 1033
+	rpmTagFileMtimes        = 1034
+	rpmTagFileDigests       = 1035
+	rpmTagFileLinkTos       = 1036
+	rpmTagFileFlags         = 1037
+	rpmTagFileUserName      = 1039
+	rpmTagFileGroupName     = 1040
+	rpmTagFileVerifyFlags   = 1045
+	rpmTagProvideName       = 1047
+	rpmTagRequireFlags      = 1048
+	rpmTagRequireName       = 1049
+	rpmTagRequireVersion    = 1050
+	rpmTagFileDevices       = 1095
+	rpmTagFileInodes        = 1096
+	rpmTagFileLangs         = 1097
+	rpmTagProvideFlags      = 1112
+	rpmTagProvideVersion    = 1113
+	rpmTagDirIndexes        = 1116
+	rpmTagBaseNames         = 1117
+	rpmTagDirNames          = 1118
+	rpmTagPayloadFormat     = 1124
+	rpmTagPayloadCompressor = 1125
+	rpmTagPayloadFlags      = 1126
+	rpmTagFileDigestAlgo    = 5011
+)
+
+// Dependency flags.
+const (
+	rpmSenseLess   = 1 << 1
+	rpmSenseEqual  = 1 << 3
+	rpmSenseRPMLib = 1 << 24
+)
+
+// writeRPM writes an RPM v4 binary package: the legacy lead, a signature
+// header carrying digests, the main header, and a gzipped cpio payload.
+func writeRPM(w io.Writer, info PackageInfo, contents []packageContent, mtime time.Time) error {
+	arch, err := mapArch(rpmArch, "rpm", info)
+	if err != nil {
+		return err
+	}
+	version, rel := rpmVersion(info.Version)
+
+	cpioData := rpmCpio(contents, mtime)
+	var payload bytes.Buffer
+	gz := gzip.NewWriter(&payload)
+	if _, err := gz.Write(cpioData); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	header := rpmMainHeader(info, arch, version, rel, contents, mtime).marshal(rpmTagHeaderImmutable)
+
+	md5sum := md5.New()
+	md5sum.Write(header)
+	md5sum.Write(payload.Bytes())
+	sig := &rpmHeader{}
+	sig.addString(rpmSigTagSHA1, fmt.Sprintf("%x", sha1.Sum(header)))
+	sig.addString(rpmSigTagSHA256, fmt.Sprintf("%x", sha256.Sum256(header)))
+	sig.addInt32(rpmSigTagSize, int32(len(header)+payload.Len()))
+	sig.addBin(rpmSigTagMD5, md5sum.Sum(nil))
+	sig.addInt32(rpmSigTagPayloadSize, int32(len(cpioData)))
+	sigData := sig.marshal(rpmTagHeaderSignatures)
+	// The signature header is padded to an 8-byte boundary
+	if pad := len(sigData) % 8; pad != 0 {
+		sigData = append(sigData, make([]byte, 8-pad)...)
+	}
+
+	for _, part := range [][]byte{rpmLead(info.Name + "-" + version + "-" + rel), sigData, header, payload.Bytes()} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rpmLead returns the 96-byte legacy lead. Modern rpm only checks the magic,
+// but the remaining fields are filled in for older tools.
+func rpmLead(name string) []byte {
+	lead := make([]byte, 96)
+	copy(lead[0:], []byte{0xed, 0xab, 0xee, 0xdb, 3, 0}) // magic, format 3.0
+	binary.BigEndian.PutUint16(lead[6:], 0)              // binary package
+	binary.BigEndian.PutUint16(lead[8:], 1)              // archnum
+	if len(name) > 65 {
+		name = name[:65]
+	}
+	copy(lead[10:76], name)
+	binary.BigEndian.PutUint16(lead[76:], 1) // osnum: linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // signature type: header-style
+	return lead
+}
+
+func rpmMainHeader(info PackageInfo, arch, version, rel string, contents []packageContent, mtime time.Time) *rpmHeader {
+	summary, _, _ := strings.Cut(strings.TrimSpace(info.Description), "\n")
+	if summary == "" {
+		summary = info.Name
+	}
+	description := strings.TrimSpace(info.Description)
+	if description == "" {
+		description = summary
+	}
+
+	h := &rpmHeader{}
+	h.addStrings(rpmTagHeaderI18NTable, []string{"C"})
+	h.addString(rpmTagName, info.Name)
+	h.addString(rpmTagVersion, version)
+	h.addString(rpmTagRelease, rel)
+	h.addI18N(rpmTagSummary, summary)
+	h.addI18N(rpmTagDescription, description)
+	h.addInt32(rpmTagBuildTime, int32(mtime.Unix()))
+	if info.License != "" {
+		h.addString(rpmTagLicense, info.License)
+	}
+	if info.Maintainer != "" {
+		h.addString(rpmTagPackager, info.Maintainer)
+	}
+	h.addI18N(rpmTagGroup, "Unspecified")
+	if info.Homepage != "" {
+		h.addString(rpmTagURL, info.Homepage)
+	}
+	h.addString(rpmTagOS, "linux")
+	h.addString(rpmTagArch, arch)
+
+	// Dependencies: rpmlib features used by this header layout, then user deps
+	requireNames := []string{"rpmlib(CompressedFileNames)", "rpmlib(PayloadFilesHavePrefix)", "rpmlib(FileDigests)"}
+	requireVersions := []string{"3.0.4-1", "4.0-1", "4.6.0-1"}
+	var requireFlags []int32
+	for range requireNames {
+		requireFlags = append(requireFlags, rpmSenseRPMLib|rpmSenseLess|rpmSenseEqual)
+	}
+	for _, dep := range info.Depends {
+		requireNames = append(requireNames, dep)
+		requireVersions = append(requireVersions, "")
+		requireFlags = append(requireFlags, 0)
+	}
+	h.addStrings(rpmTagRequireName, requireNames)
+	h.addStrings(rpmTagRequireVersion, requireVersions)
+	h.addInt32(rpmTagRequireFlags, requireFlags...)
+	h.addStrings(rpmTagProvideName, []string{info.Name})
+	h.addStrings(rpmTagProvideVersion, []string{version + "-" + rel})
+	h.addInt32(rpmTagProvideFlags, rpmSenseEqual)
+
+	// File list, split into dirname/basename pairs as rpm expects
+	var dirNames []string
+	dirIndex := make(map[string]int32)
+	var (
+		sizes, mtimes, flags, verify, devices, inodes, dirIndexes []int32
+		modes, rdevs                                              []int16
+		digests, links, users, groups, langs, baseNames           []string
+		totalSize                                                 int32
+	)
+	for i, c := range contents {
+		slash := strings.LastIndex(c.Dest, "/")
+		dir, base := c.Dest[:slash+1], c.Dest[slash+1:]
+		idx, ok := dirIndex[dir]
+		if !ok {
+			idx = int32(len(dirNames))
+			dirIndex[dir] = idx
+			dirNames = append(dirNames, dir)
+		}
+		size := int32(len(c.data))
+		totalSize += size
+		sizes = append(sizes, size)
+		modes = append(modes, int16(0o100000|c.Mode.Perm()))
+		rdevs = append(rdevs, 0)
+		mtimes = append(mtimes, int32(mtime.Unix()))
+		digests = append(digests, fmt.Sprintf("%x", sha256.Sum256(c.data)))
+		links = append(links, "")
+		flags = append(flags, 0)
+		users = append(users, "root")
+		groups = append(groups, "root")
+		verify = append(verify, -1)
+		devices = append(devices, 1)
+		inodes = append(inodes, int32(i+1))
+		langs = append(langs, "")
+		dirIndexes = append(dirIndexes, idx)
+		baseNames = append(baseNames, base)
+	}
+	h.addInt32(rpmTagSize, totalSize)
+	h.addInt32(rpmTagFileSizes, sizes...)
+	h.addInt16(rpmTagFileModes, modes...)
+	h.addInt16(rpmTagFileRdevs, rdevs...)
+	h.addInt32(rpmTagFileMtimes, mtimes...)
+	h.addStrings(rpmTagFileDigests, digests)
+	h.addStrings(rpmTagFileLinkTos, links)
+	h.addInt32(rpmTagFileFlags, flags...)
+	h.addStrings(rpmTagFileUserName, users)
+	h.addStrings(rpmTagFileGroupName, groups)
+	h.addInt32(rpmTagFileVerifyFlags, verify...)
+	h.addInt32(rpmTagFileDevices, devices...)
+	h.addInt32(rpmTagFileInodes, inodes...)
+	h.addStrings(rpmTagFileLangs, langs)
+	h.addInt32(rpmTagDirIndexes, dirIndexes...)
+	h.addStrings(rpmTagBaseNames, baseNames)
+	h.addStrings(rpmTagDirNames, dirNames)
+	h.addInt32(rpmTagFileDigestAlgo, 8) // PGPHASHALGO_SHA256
+
+	h.addString(rpmTagPayloadFormat, "cpio")
+	h.addString(rpmTagPayloadCompressor, "gzip")
+	h.addString(rpmTagPayloadFlags, "9")
+	return h
+}
+
+// rpmCpio builds the uncompressed payload in SVR4 "newc" cpio format, with
+// paths prefixed by "." as rpmlib(PayloadFilesHavePrefix) requires.
+func rpmCpio(contents []packageContent, mtime time.Time) []byte {
+	var buf bytes.Buffer
+	writeEntry := func(name string, ino, mode, nlink int, data []byte) {
+		fmt.Fprintf(&buf, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			ino, mode, 0, 0, nlink, mtime.Unix(), len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+		buf.Write(data)
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	for i, c := range contents {
+		writeEntry("."+c.Dest, i+1, 0o100000|int(c.Mode.Perm()), 1, c.data)
+	}
+	writeEntry("TRAILER!!!", 0, 0, 1, nil)
+	return buf.Bytes()
+}
+
+// rpmHeader accumulates tag entries and serializes them in the rpm header
+// structure format.
+type rpmHeader struct {
+	entries []rpmEntry
+}
+
+type rpmEntry struct {
+	tag   int32
+	typ   uint32
+	count uint32
+	data  []byte
+}
+
+func (h *rpmHeader) add(tag int32, typ uint32, count int, data []byte) {
+	h.entries = append(h.entries, rpmEntry{tag: tag, typ: typ, count: uint32(count), data: data})
+}
+
+func (h *rpmHeader) addString(tag int32, s string) {
+	h.add(tag, rpmTypeString, 1, append([]byte(s), 0))
+}
+
+func (h *rpmHeader) addI18N(tag int32, s string) {
+	h.add(tag, rpmTypeI18NString, 1, append([]byte(s), 0))
+}
+
+func (h *rpmHeader) addStrings(tag int32, ss []string) {
+	var data []byte
+	for _, s := range ss {
+		data = append(append(data, s...), 0)
+	}
+	h.add(tag, rpmTypeStringArray, len(ss), data)
+}
+
+func (h *rpmHeader) addInt32(tag int32, vals ...int32) {
+	data := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(data[4*i:], uint32(v))
+	}
+	h.add(tag, rpmTypeInt32, len(vals), data)
+}
+
+func (h *rpmHeader) addInt16(tag int32, vals ...int16) {
+	data := make([]byte, 2*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint16(data[2*i:], uint16(v))
+	}
+	h.add(tag, rpmTypeInt16, len(vals), data)
+}
+
+func (h *rpmHeader) addBin(tag int32, data []byte) {
+	h.add(tag, rpmTypeBin, len(data), data)
+}
+
+// marshal serializes the header, wrapping all entries in an immutable region
+// tagged regionTag (62 for signatures, 63 for the main header).
+func (h *rpmHeader) marshal(regionTag int32) []byte {
+	entries := make([]rpmEntry, len(h.entries))
+	copy(entries, h.entries)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	nindex := len(entries) + 1
+	var index, store bytes.Buffer
+	writeIndex := func(tag int32, typ uint32, offset int32, count uint32) {
+		binary.Write(&index, binary.BigEndian, tag)
+		binary.Write(&index, binary.BigEndian, typ)
+		binary.Write(&index, binary.BigEndian, offset)
+		binary.Write(&index, binary.BigEndian, count)
+	}
+
+	var body bytes.Buffer
+	for _, e := range entries {
+		align := 1
+		switch e.typ {
+		case rpmTypeInt16:
+			align = 2
+		case rpmTypeInt32:
+			align = 4
+		}
+		for store.Len()%align != 0 {
+			store.WriteByte(0)
+		}
+		offset := int32(store.Len())
+		store.Write(e.data)
+		binary.Write(&body, binary.BigEndian, e.tag)
+		binary.Write(&body, binary.BigEndian, e.typ)
+		binary.Write(&body, binary.BigEndian, offset)
+		binary.Write(&body, binary.BigEndian, e.count)
+	}
+
+	// Region trailer: a copy of the region entry whose offset points back
+	// over every index entry in the region
+	trailerOffset := int32(store.Len())
+	binary.Write(&store, binary.BigEndian, regionTag)
+	binary.Write(&store, binary.BigEndian, uint32(rpmTypeBin))
+	binary.Write(&store, binary.BigEndian, int32(-nindex*16))
+	binary.Write(&store, binary.BigEndian, uint32(16))
+
+	writeIndex(regionTag, rpmTypeBin, trailerOffset, 16)
+	index.Write(body.Bytes())
+
+	var out bytes.Buffer
+	out.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	binary.Write(&out, binary.BigEndian, uint32(nindex))
+	binary.Write(&out, binary.BigEndian, uint32(store.Len()))
+	out.Write(index.Bytes())
+	out.Write(store.Bytes())
+	return out.Bytes()
+}
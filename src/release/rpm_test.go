@@ -0,0 +1,146 @@
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+// parsedHeader is an rpm header read back by parseRPMHeader.
+type parsedHeader struct {
+	tags  map[int32][]byte // raw data from offset to the next entry
+	types map[int32]uint32
+	size  int
+}
+
+// parseRPMHeader reads a header structure from data, checking the magic and
+// the region trailer.
+func parseRPMHeader(t *testing.T, data []byte, regionTag int32) parsedHeader {
+	require.Equal(t, []byte{0x8e, 0xad, 0xe8, 0x01}, data[:4])
+	nindex := int(binary.BigEndian.Uint32(data[8:]))
+	hsize := int(binary.BigEndian.Uint32(data[12:]))
+	index := data[16 : 16+nindex*16]
+	store := data[16+nindex*16 : 16+nindex*16+hsize]
+
+	h := parsedHeader{tags: make(map[int32][]byte), types: make(map[int32]uint32), size: 16 + nindex*16 + hsize}
+	offsets := make([]int, nindex)
+	for i := 0; i < nindex; i++ {
+		e := index[i*16:]
+		tag := int32(binary.BigEndian.Uint32(e))
+		h.types[tag] = binary.BigEndian.Uint32(e[4:])
+		offsets[i] = int(int32(binary.BigEndian.Uint32(e[8:])))
+		if i > 0 {
+			prev := int32(binary.BigEndian.Uint32(index[(i-1)*16:]))
+			assert.True(t, tag > prev, "tags must be sorted")
+		}
+	}
+	for i := 0; i < nindex; i++ {
+		tag := int32(binary.BigEndian.Uint32(index[i*16:]))
+		end := len(store)
+		for _, o := range offsets {
+			if o > offsets[i] && o < end {
+				end = o
+			}
+		}
+		h.tags[tag] = store[offsets[i]:end]
+	}
+
+	region := int32(binary.BigEndian.Uint32(index))
+	assert.Equal(t, regionTag, region)
+	trailer := h.tags[regionTag]
+	require.Equal(t, 16, len(trailer))
+	assert.Equal(t, regionTag, int32(binary.BigEndian.Uint32(trailer)))
+	assert.Equal(t, int32(-nindex*16), int32(binary.BigEndian.Uint32(trailer[8:])))
+	return h
+}
+
+func (h parsedHeader) str(tag int32) string {
+	s, _, _ := strings.Cut(string(h.tags[tag]), "\x00")
+	return s
+}
+
+func (h parsedHeader) int32(tag int32) int32 {
+	return int32(binary.BigEndian.Uint32(h.tags[tag]))
+}
+
+func TestWriteRPM(t *testing.T) {
+	info, files := testPackage(t)
+	contents, err := loadPackageFiles(files)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeRPM(&buf, info, contents, time.Unix(1700000000, 0)))
+	data := buf.Bytes()
+
+	require.Equal(t, []byte{0xed, 0xab, 0xee, 0xdb}, data[:4])
+	sig := parseRPMHeader(t, data[96:], rpmTagHeaderSignatures)
+	headerStart := 96 + sig.size
+	if pad := sig.size % 8; pad != 0 {
+		headerStart += 8 - pad
+	}
+	main := parseRPMHeader(t, data[headerStart:], rpmTagHeaderImmutable)
+	header := data[headerStart : headerStart+main.size]
+	payload := data[headerStart+main.size:]
+
+	assert.Equal(t, "tool", main.str(rpmTagName))
+	assert.Equal(t, "1.2.3", main.str(rpmTagVersion))
+	assert.Equal(t, "1", main.str(rpmTagRelease))
+	assert.Equal(t, "x86_64", main.str(rpmTagArch))
+	assert.Equal(t, "linux", main.str(rpmTagOS))
+	assert.Equal(t, "MIT", main.str(rpmTagLicense))
+	assert.Equal(t, "https://example.com", main.str(rpmTagURL))
+	assert.Equal(t, int32(1700000000), main.int32(rpmTagBuildTime))
+	assert.Contains(t, string(main.tags[rpmTagBaseNames]), "tool\x00")
+	assert.Contains(t, string(main.tags[rpmTagDirNames]), "/usr/bin/\x00")
+	assert.Contains(t, string(main.tags[rpmTagRequireName]), "ca-certificates\x00")
+
+	// Signature digests cover the main header and payload
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(header)), sig.str(rpmSigTagSHA256))
+	sum := md5.Sum(append(append([]byte(nil), header...), payload...))
+	assert.Equal(t, sum[:], sig.tags[rpmSigTagMD5][:16])
+	assert.Equal(t, int32(len(header)+len(payload)), sig.int32(rpmSigTagSize))
+
+	gz, err := gzip.NewReader(bytes.NewReader(payload))
+	require.NoError(t, err)
+	cpio, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, sig.int32(rpmSigTagPayloadSize), int32(len(cpio)))
+
+	entries := readCpio(t, cpio)
+	assert.Equal(t, "#!/bin/sh\necho hi\n", entries["./usr/bin/tool"])
+}
+
+// readCpio returns the regular files in a newc cpio archive keyed by name.
+func readCpio(t *testing.T, data []byte) map[string]string {
+	entries := make(map[string]string)
+	field := func(hdr []byte, i int) int {
+		n, err := strconv.ParseUint(string(hdr[6+8*i:14+8*i]), 16, 32)
+		require.NoError(t, err)
+		return int(n)
+	}
+	align := func(n int) int { return (n + 3) &^ 3 }
+	for off := 0; off < len(data); {
+		hdr := data[off : off+110]
+		require.Equal(t, "070701", string(hdr[:6]))
+		fileSize, nameSize := field(hdr, 6), field(hdr, 11)
+		name := string(data[off+110 : off+110+nameSize-1])
+		off = align(off + 110 + nameSize)
+		if name == "TRAILER!!!" {
+			break
+		}
+		entries[name] = string(data[off : off+fileSize])
+		off = align(off + fileSize)
+	}
+	return entries
+}
@@ -0,0 +1,119 @@
+package sbom
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// CycloneDX 1.5 JSON document, limited to the fields go-toolchain fills in.
+type cdxDocument struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// CycloneDX renders b as a CycloneDX 1.5 JSON SBOM.
+func CycloneDX(b *Binary, created time.Time) ([]byte, error) {
+	main := cdxComponent{
+		Type:    "application",
+		BOMRef:  b.Main.PURL(),
+		Name:    b.Main.Path,
+		Version: b.Main.Version,
+		PURL:    b.Main.PURL(),
+	}
+	if b.SHA256 != "" {
+		main.Hashes = []cdxHash{{Alg: "SHA-256", Content: b.SHA256}}
+	}
+	if b.Name != "" {
+		main.Properties = append(main.Properties, cdxProperty{"go:binary", b.Name})
+	}
+	keys := make([]string, 0, len(b.Settings))
+	for k := range b.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		main.Properties = append(main.Properties, cdxProperty{"go:build:" + k, b.Settings[k]})
+	}
+
+	doc := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + b.serial("cyclonedx"),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: "go-toolchain"}}},
+			Component: main,
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{{Ref: main.BOMRef, DependsOn: []string{}}},
+	}
+
+	for _, c := range append([]Component{b.stdlib()}, b.Deps...) {
+		comp := cdxComponent{
+			Type:    "library",
+			BOMRef:  c.PURL(),
+			Name:    c.Path,
+			Version: c.Version,
+			PURL:    c.PURL(),
+		}
+		if c.Sum != "" {
+			comp.Properties = append(comp.Properties, cdxProperty{"go:sum", c.Sum})
+		}
+		if c.Replace != "" {
+			comp.Properties = append(comp.Properties, cdxProperty{"go:replace", c.Replace})
+		}
+		if c.Indirect {
+			comp.Properties = append(comp.Properties, cdxProperty{"go:indirect", "true"})
+		}
+		doc.Components = append(doc.Components, comp)
+		doc.Dependencies[0].DependsOn = append(doc.Dependencies[0].DependsOn, comp.BOMRef)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,127 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+)
+
+func testBinary() *Binary {
+	return &Binary{
+		Name:      "app_linux_amd64",
+		SHA256:    "abcd",
+		GOOS:      "linux",
+		GOARCH:    "amd64",
+		GoVersion: "go1.24.1",
+		Main:      Component{Path: "example.com/app", Version: "v1.2.0"},
+		Deps: []Component{
+			{Path: "golang.org/x/text", Version: "v0.14.0", Sum: "h1:abc="},
+			{Path: "example.com/forked", Version: "v1.0.0", Replace: "../forked"},
+		},
+		Settings: map[string]string{"CGO_ENABLED": "0", "GOOS": "linux"},
+	}
+}
+
+func TestReadBinary(t *testing.T) {
+	// The test binary itself carries build info
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	b, err := ReadBinary(exe)
+	require.NoError(t, err)
+	assert.NotEqual(t, "", b.GoVersion)
+	assert.NotEqual(t, "", b.Main.Path)
+
+	var paths []string
+	for _, d := range b.Deps {
+		paths = append(paths, d.Path)
+	}
+	assert.Contains(t, paths, "github.com/wow-look-at-my/testify")
+
+	_, err = ReadBinary("sbom.go")
+	assert.NotNil(t, err)
+}
+
+func TestAnnotate(t *testing.T) {
+	b := testBinary()
+	b.Annotate([]build.Module{
+		{Path: "example.com/app", Main: true},
+		{Path: "golang.org/x/text", Version: "v0.14.0", Indirect: true},
+		{Path: "example.com/forked", Version: "v1.0.0"},
+	})
+	assert.True(t, b.Deps[0].Indirect)
+	assert.False(t, b.Deps[1].Indirect)
+}
+
+func TestPURL(t *testing.T) {
+	assert.Equal(t, "pkg:golang/golang.org/x/text@v0.14.0", Component{Path: "golang.org/x/text", Version: "v0.14.0"}.PURL())
+	assert.Equal(t, "pkg:golang/example.com/app", Component{Path: "example.com/app", Version: "(devel)"}.PURL())
+	assert.Equal(t, "pkg:golang/example.com/a@v1.0.0%2Bincompatible", Component{Path: "example.com/a", Version: "v1.0.0+incompatible"}.PURL())
+}
+
+func TestCycloneDX(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := Generate("cyclonedx", testBinary(), created)
+	require.NoError(t, err)
+
+	var doc cdxDocument
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "CycloneDX", doc.BOMFormat)
+	assert.Equal(t, "2024-01-02T03:04:05Z", doc.Metadata.Timestamp)
+	assert.Equal(t, "pkg:golang/example.com/app@v1.2.0", doc.Metadata.Component.PURL)
+	assert.Equal(t, []cdxHash{{Alg: "SHA-256", Content: "abcd"}}, doc.Metadata.Component.Hashes)
+	require.Equal(t, 3, len(doc.Components))
+	assert.Equal(t, "pkg:golang/stdlib@1.24.1", doc.Components[0].PURL)
+	assert.Equal(t, "golang.org/x/text", doc.Components[1].Name)
+	assert.Contains(t, doc.Components[1].Properties, cdxProperty{"go:sum", "h1:abc="})
+	assert.Contains(t, doc.Components[2].Properties, cdxProperty{"go:replace", "../forked"})
+	require.Equal(t, 1, len(doc.Dependencies))
+	assert.Equal(t, 3, len(doc.Dependencies[0].DependsOn))
+
+	// Identical binaries give identical documents
+	again, err := Generate("cyclonedx", testBinary(), created)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+
+	other := testBinary()
+	other.SHA256 = "ef01"
+	otherData, err := Generate("cyclonedx", other, created)
+	require.NoError(t, err)
+	var otherDoc cdxDocument
+	require.NoError(t, json.Unmarshal(otherData, &otherDoc))
+	assert.NotEqual(t, doc.SerialNumber, otherDoc.SerialNumber)
+}
+
+func TestSPDX(t *testing.T) {
+	data, err := Generate("spdx", testBinary(), time.Unix(0, 0))
+	require.NoError(t, err)
+
+	var doc spdxDocument
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, "app_linux_amd64", doc.Name)
+	assert.Contains(t, doc.DocumentNamespace, "https://spdx.org/spdxdocs/app-linux-amd64-")
+	require.Equal(t, 4, len(doc.Packages))
+	assert.Equal(t, "SPDXRef-Package-main", doc.Packages[0].SPDXID)
+	assert.Equal(t, []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: "abcd"}}, doc.Packages[0].Checksums)
+	assert.Equal(t, "SPDXRef-Package-golang.org-x-text", doc.Packages[2].SPDXID)
+	assert.Equal(t, "pkg:golang/golang.org/x/text@v0.14.0", doc.Packages[2].ExternalRefs[0].ReferenceLocator)
+	assert.Equal(t, "replaced by ../forked", doc.Packages[3].Comment)
+	require.Equal(t, 4, len(doc.Relationships))
+	assert.Equal(t, "DESCRIBES", doc.Relationships[0].RelationshipType)
+	assert.Equal(t, "DEPENDS_ON", doc.Relationships[1].RelationshipType)
+}
+
+func TestGenerateUnknownFormat(t *testing.T) {
+	_, err := Generate("swid", testBinary(), time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestFileSuffix(t *testing.T) {
+	assert.Equal(t, ".cdx.json", FileSuffix("cyclonedx"))
+	assert.Equal(t, ".spdx.json", FileSuffix("spdx"))
+}
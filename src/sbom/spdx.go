@@ -0,0 +1,143 @@
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SPDX 2.3 JSON document, limited to the fields go-toolchain fills in.
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	PackageFileName  string            `json:"packageFileName,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX renders b as an SPDX 2.3 JSON SBOM.
+func SPDX(b *Binary, created time.Time) ([]byte, error) {
+	name := b.Name
+	if name == "" {
+		name = b.Main.Path
+	}
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + spdxIDChars(name) + "-" + b.serial("spdx"),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: go-toolchain"},
+		},
+	}
+
+	mainID := "SPDXRef-Package-main"
+	main := spdxPackage{
+		Name:             b.Main.Path,
+		SPDXID:           mainID,
+		VersionInfo:      b.Main.Version,
+		PackageFileName:  b.Name,
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		CopyrightText:    "NOASSERTION",
+		ExternalRefs:     []spdxExternalRef{purlRef(b.Main)},
+	}
+	if b.SHA256 != "" {
+		main.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: b.SHA256}}
+	}
+	doc.Packages = append(doc.Packages, main)
+	doc.Relationships = append(doc.Relationships, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", mainID})
+
+	for i, c := range append([]Component{b.stdlib()}, b.Deps...) {
+		id := "SPDXRef-Package-" + spdxIDChars(c.Path)
+		if i == 0 {
+			id = "SPDXRef-Package-stdlib"
+		}
+		var notes []string
+		if c.Sum != "" {
+			notes = append(notes, "go.sum "+c.Sum)
+		}
+		if c.Replace != "" {
+			notes = append(notes, "replaced by "+c.Replace)
+		}
+		if c.Indirect {
+			notes = append(notes, "indirect")
+		}
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             c.Path,
+			SPDXID:           id,
+			VersionInfo:      c.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{purlRef(c)},
+			Comment:          strings.Join(notes, "; "),
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{mainID, "DEPENDS_ON", id})
+	}
+
+	data, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func purlRef(c Component) spdxExternalRef {
+	return spdxExternalRef{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: c.PURL()}
+}
+
+// spdxIDChars replaces characters not allowed in SPDX identifiers (anything
+// but letters, digits, '.' and '-') with '-'.
+func spdxIDChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, s)
+}
@@ -0,0 +1,112 @@
+package size
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+// NotesRef is the git notes reference for binary size data
+const NotesRef = "refs/notes/sizes"
+
+// maxBaseSearch bounds how far back FindBase looks for a commit with sizes.
+const maxBaseSearch = 200
+
+// StoreNotes merges report into the size notes for HEAD, keeping entries
+// for binaries that this build didn't produce.
+func StoreNotes(r runner.CommandRunner, report *Report) error {
+	merged := &Report{}
+	if existing, err := FetchForCommit(r, "HEAD"); err == nil {
+		merged = existing
+	}
+	merged.Merge(report)
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal size report: %w", err)
+	}
+	proc, err := runner.Cmd("git", "notes", "--ref=sizes", "add", "-f", "-m", string(data), "HEAD").
+		WithQuiet().
+		Run(r)
+	if err != nil {
+		return fmt.Errorf("failed to store git notes: %w", err)
+	}
+	if err := proc.Wait(); err != nil {
+		return fmt.Errorf("failed to store git notes: %w", err)
+	}
+	return nil
+}
+
+// FetchForCommit retrieves stored binary sizes for a specific commit
+func FetchForCommit(r runner.CommandRunner, sha string) (*Report, error) {
+	out, err := gitOutput(r, "notes", "--ref=sizes", "show", sha)
+	if err != nil {
+		return nil, fmt.Errorf("no size data for commit %s", sha)
+	}
+	var report Report
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse size notes for %s: %w", sha, err)
+	}
+	return &report, nil
+}
+
+// FindBase returns the commit to compare HEAD against and its sizes. With a
+// base ref (e.g. origin/main) that is the merge base of HEAD and the ref;
+// otherwise the nearest ancestor of HEAD with size notes. Returns a nil
+// report when there is nothing to compare against.
+func FindBase(r runner.CommandRunner, baseRef string) (*Report, string, error) {
+	if baseRef != "" {
+		out, err := gitOutput(r, "merge-base", "HEAD", baseRef)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to find merge base with %s: %w", baseRef, err)
+		}
+		sha := strings.TrimSpace(out)
+		report, err := FetchForCommit(r, sha)
+		if err != nil {
+			return nil, sha, nil // base has no recorded sizes
+		}
+		return report, sha, nil
+	}
+
+	// `git notes list` prints "<note blob> <annotated commit>" per line
+	out, err := gitOutput(r, "notes", "--ref=sizes", "list")
+	if err != nil {
+		return nil, "", nil // no notes yet
+	}
+	noted := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			noted[fields[1]] = true
+		}
+	}
+	if len(noted) == 0 {
+		return nil, "", nil
+	}
+
+	out, err = gitOutput(r, "rev-list", fmt.Sprintf("--max-count=%d", maxBaseSearch), "HEAD^")
+	if err != nil {
+		return nil, "", nil // no parent commit
+	}
+	for _, sha := range strings.Fields(out) {
+		if noted[sha] {
+			report, err := FetchForCommit(r, sha)
+			return report, sha, err
+		}
+	}
+	return nil, "", nil
+}
+
+func gitOutput(r runner.CommandRunner, args ...string) (string, error) {
+	proc, err := runner.Cmd("git", args...).WithQuiet().Run(r)
+	if err != nil {
+		return "", err
+	}
+	out, _ := io.ReadAll(proc.Stdout())
+	if err := proc.Wait(); err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
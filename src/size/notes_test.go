@@ -0,0 +1,74 @@
+package size
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"github.com/wow-look-at-my/go-toolchain/src/runner"
+)
+
+func TestStoreNotesMergesExisting(t *testing.T) {
+	mock := runner.NewMock()
+	existing, _ := json.Marshal(&Report{Binaries: []Binary{{Name: "app", GOOS: "darwin", GOARCH: "arm64", Size: 5}}})
+	mock.SetResponse("git", []string{"notes", "--ref=sizes", "show", "HEAD"}, existing, nil)
+
+	require.NoError(t, StoreNotes(mock, &Report{Binaries: []Binary{{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 7}}}))
+
+	calls := mock.Calls()
+	add := calls[len(calls)-1]
+	require.True(t, add.IsCmd("git", "notes", "--ref=sizes", "add", "-f", "-m"))
+	var stored Report
+	require.NoError(t, json.Unmarshal([]byte(add.Args[5]), &stored))
+	assert.Equal(t, 2, len(stored.Binaries))
+}
+
+func TestStoreNotesError(t *testing.T) {
+	mock := runner.NewMock()
+	mock.Handler = func(cfg runner.Config) (runner.IProcess, error) {
+		if cfg.IsCmd("git", "notes", "--ref=sizes", "add") {
+			return runner.MockProcess(nil, fmt.Errorf("git error")), nil
+		}
+		return nil, nil
+	}
+	assert.NotNil(t, StoreNotes(mock, &Report{}))
+}
+
+func TestFindBaseNearestAncestor(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"notes", "--ref=sizes", "list"}, []byte("n1 aaa\nn2 ccc\n"), nil)
+	mock.SetResponse("git", []string{"rev-list", "--max-count=200", "HEAD^"}, []byte("bbb\nccc\naaa\n"), nil)
+	note, _ := json.Marshal(&Report{Binaries: []Binary{{Name: "app", Size: 42}}})
+	mock.SetResponse("git", []string{"notes", "--ref=sizes", "show", "ccc"}, note, nil)
+
+	report, sha, err := FindBase(mock, "")
+	require.NoError(t, err)
+	assert.Equal(t, "ccc", sha)
+	require.NotNil(t, report)
+	assert.Equal(t, int64(42), report.Binaries[0].Size)
+}
+
+func TestFindBaseNoNotes(t *testing.T) {
+	report, sha, err := FindBase(runner.NewMock(), "")
+	require.NoError(t, err)
+	assert.Nil(t, report)
+	assert.Equal(t, "", sha)
+}
+
+func TestFindBaseRef(t *testing.T) {
+	mock := runner.NewMock()
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/main"}, []byte("abc123\n"), nil)
+	note, _ := json.Marshal(&Report{Binaries: []Binary{{Name: "app", Size: 1}}})
+	mock.SetResponse("git", []string{"notes", "--ref=sizes", "show", "abc123"}, note, nil)
+
+	report, sha, err := FindBase(mock, "origin/main")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+	require.NotNil(t, report)
+
+	mock.SetResponse("git", []string{"merge-base", "HEAD", "origin/gone"}, nil, fmt.Errorf("exit status 1"))
+	_, _, err = FindBase(mock, "origin/gone")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,153 @@
+package size
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Binary is the recorded size of one built binary.
+type Binary struct {
+	Name    string `json:"name"`
+	GOOS    string `json:"goos"`
+	GOARCH  string `json:"goarch"`
+	Variant string `json:"variant,omitempty"`
+	Size    int64  `json:"size"`
+}
+
+// Key identifies a binary across commits: name plus platform.
+func (b Binary) Key() string {
+	key := b.Name + " " + b.GOOS + "/" + b.GOARCH
+	if b.Variant != "" {
+		key += "/" + b.Variant
+	}
+	return key
+}
+
+// Report holds binary sizes for one commit.
+type Report struct {
+	Binaries []Binary `json:"binaries"`
+}
+
+// Merge adds or replaces binaries in r, keyed by name and platform, so a
+// native build and a matrix build of the same commit accumulate.
+func (r *Report) Merge(other *Report) {
+	index := make(map[string]int, len(r.Binaries))
+	for i, b := range r.Binaries {
+		index[b.Key()] = i
+	}
+	for _, b := range other.Binaries {
+		if i, ok := index[b.Key()]; ok {
+			r.Binaries[i] = b
+		} else {
+			index[b.Key()] = len(r.Binaries)
+			r.Binaries = append(r.Binaries, b)
+		}
+	}
+	sort.Slice(r.Binaries, func(i, j int) bool { return r.Binaries[i].Key() < r.Binaries[j].Key() })
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "12.3 MiB".
+func FormatBytes(n int64) string {
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	var s string
+	switch {
+	case n >= 1<<30:
+		s = fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		s = fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		s = fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		s = fmt.Sprintf("%d B", n)
+	}
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+// Delta is the size change of one binary against the base commit.
+type Delta struct {
+	Binary
+	BaseSize int64   `json:"base_size"`
+	HasBase  bool    `json:"has_base"`
+	Bytes    int64   `json:"delta_bytes"`
+	Percent  float64 `json:"delta_percent"`
+}
+
+// Compare computes per-binary deltas of current against base (which may be nil).
+func Compare(current, base *Report) []Delta {
+	baseSizes := make(map[string]int64)
+	if base != nil {
+		for _, b := range base.Binaries {
+			baseSizes[b.Key()] = b.Size
+		}
+	}
+	deltas := make([]Delta, 0, len(current.Binaries))
+	for _, b := range current.Binaries {
+		d := Delta{Binary: b}
+		if prev, ok := baseSizes[b.Key()]; ok {
+			d.HasBase = true
+			d.BaseSize = prev
+			d.Bytes = b.Size - prev
+			if prev > 0 {
+				d.Percent = float64(d.Bytes) / float64(prev) * 100
+			}
+		}
+		deltas = append(deltas, d)
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Key() < deltas[j].Key() })
+	return deltas
+}
+
+// Budget limits binary growth against the base commit. A zero field is not
+// enforced.
+type Budget struct {
+	MaxGrowthPercent float64
+	MaxGrowthBytes   int64
+}
+
+// Check returns one message per binary that grew beyond the budget.
+func (b Budget) Check(deltas []Delta) []string {
+	var violations []string
+	for _, d := range deltas {
+		if !d.HasBase || d.Bytes <= 0 {
+			continue
+		}
+		var over []string
+		if b.MaxGrowthPercent > 0 && d.Percent > b.MaxGrowthPercent {
+			over = append(over, fmt.Sprintf("%.1f%% > %.1f%%", d.Percent, b.MaxGrowthPercent))
+		}
+		if b.MaxGrowthBytes > 0 && d.Bytes > b.MaxGrowthBytes {
+			over = append(over, fmt.Sprintf("%s > %s", FormatBytes(d.Bytes), FormatBytes(b.MaxGrowthBytes)))
+		}
+		if len(over) > 0 {
+			violations = append(violations, fmt.Sprintf("%s grew by %s (%s)", d.Key(), FormatBytes(d.Bytes), strings.Join(over, ", ")))
+		}
+	}
+	return violations
+}
+
+// PrintDeltas prints a size table with deltas against baseCommit.
+func PrintDeltas(deltas []Delta, baseCommit string) {
+	if baseCommit != "" {
+		fmt.Printf("==> Binary sizes vs %s\n", baseCommit)
+	} else {
+		fmt.Println("==> Binary sizes (no base data for comparison)")
+	}
+	for _, d := range deltas {
+		delta := "     -"
+		if d.HasBase {
+			sign := ""
+			if d.Bytes > 0 {
+				sign = "+"
+			}
+			delta = fmt.Sprintf("%s%s (%s%.1f%%)", sign, FormatBytes(d.Bytes), sign, d.Percent)
+		}
+		fmt.Printf("  %10s  %-22s  %s\n", FormatBytes(d.Size), delta, d.Key())
+	}
+}
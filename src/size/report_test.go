@@ -0,0 +1,66 @@
+package size
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestReportMerge(t *testing.T) {
+	r := &Report{Binaries: []Binary{
+		{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 100},
+		{Name: "app", GOOS: "darwin", GOARCH: "arm64", Size: 200},
+	}}
+	r.Merge(&Report{Binaries: []Binary{
+		{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 150},
+		{Name: "app", GOOS: "linux", GOARCH: "arm", Variant: "7", Size: 90},
+	}})
+	require.Equal(t, 3, len(r.Binaries))
+	assert.Equal(t, "app darwin/arm64", r.Binaries[0].Key())
+	assert.Equal(t, "app linux/amd64", r.Binaries[1].Key())
+	assert.Equal(t, int64(150), r.Binaries[1].Size)
+	assert.Equal(t, "app linux/arm/7", r.Binaries[2].Key())
+}
+
+func TestCompareAndBudget(t *testing.T) {
+	base := &Report{Binaries: []Binary{
+		{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 10 << 20},
+		{Name: "tool", GOOS: "linux", GOARCH: "amd64", Size: 4 << 20},
+	}}
+	current := &Report{Binaries: []Binary{
+		{Name: "app", GOOS: "linux", GOARCH: "amd64", Size: 30 << 20},
+		{Name: "tool", GOOS: "linux", GOARCH: "amd64", Size: 3 << 20},
+		{Name: "new", GOOS: "linux", GOARCH: "amd64", Size: 50 << 20},
+	}}
+
+	deltas := Compare(current, base)
+	require.Equal(t, 3, len(deltas))
+	assert.Equal(t, "app", deltas[0].Name)
+	assert.True(t, deltas[0].HasBase)
+	assert.Equal(t, int64(20<<20), deltas[0].Bytes)
+	assert.InDelta(t, 200.0, deltas[0].Percent, 0.001)
+	assert.False(t, deltas[1].HasBase) // "new" has no base
+	assert.InDelta(t, -25.0, deltas[2].Percent, 0.001)
+
+	violations := Budget{MaxGrowthPercent: 5}.Check(deltas)
+	require.Equal(t, 1, len(violations))
+	assert.Contains(t, violations[0], "app linux/amd64 grew by 20.0 MiB")
+
+	assert.Equal(t, 1, len(Budget{MaxGrowthBytes: 1 << 20}.Check(deltas)))
+	assert.Equal(t, 0, len(Budget{MaxGrowthPercent: 250}.Check(deltas)))
+	assert.Equal(t, 0, len(Budget{}.Check(deltas)))
+}
+
+func TestCompareNoBase(t *testing.T) {
+	deltas := Compare(&Report{Binaries: []Binary{{Name: "app", Size: 1}}}, nil)
+	require.Equal(t, 1, len(deltas))
+	assert.False(t, deltas[0].HasBase)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "20.0 MiB", FormatBytes(20<<20))
+	assert.Equal(t, "-2.0 GiB", FormatBytes(-2<<30))
+}
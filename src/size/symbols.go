@@ -0,0 +1,218 @@
+package size
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Section is the size of one section of an executable.
+type Section struct {
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+}
+
+// Symbol is a sized symbol from an executable's symbol table.
+type Symbol struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Size    uint64 `json:"size"`
+}
+
+// PackageSize is the total size of the symbols attributed to a package.
+type PackageSize struct {
+	Name    string `json:"name"`
+	Size    uint64 `json:"size"`
+	Symbols int    `json:"symbols"`
+}
+
+// Breakdown describes where the bytes in an executable go.
+type Breakdown struct {
+	Path     string        `json:"path"`
+	Format   string        `json:"format"` // elf, macho or pe
+	FileSize int64         `json:"file_size"`
+	Sections []Section     `json:"sections"`
+	Symbols  []Symbol      `json:"symbols"`  // largest first
+	Packages []PackageSize `json:"packages"` // largest first
+}
+
+// rawSymbol is a symbol before sizing; formats without symbol sizes
+// (Mach-O, PE) are sized by the gap to the next symbol in the section.
+type rawSymbol struct {
+	name    string
+	section int
+	addr    uint64
+	size    uint64
+}
+
+// Analyze reads the symbol table of an ELF, Mach-O or PE executable.
+// Binaries linked with -s have no symbol table, only section sizes.
+func Analyze(path string) (*Breakdown, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Breakdown{Path: path, FileSize: st.Size()}
+
+	var syms []rawSymbol
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		b.Format = "elf"
+		syms, err = elfSymbols(f, b)
+		if err != nil {
+			return nil, err
+		}
+	} else if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		b.Format = "macho"
+		syms = machoSymbols(f, b)
+	} else if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		b.Format = "pe"
+		syms = peSymbols(f, b)
+	} else {
+		return nil, fmt.Errorf("%s: not an ELF, Mach-O or PE executable", path)
+	}
+
+	byPkg := make(map[string]*PackageSize)
+	for _, s := range syms {
+		if s.size == 0 {
+			continue
+		}
+		pkg := SymbolPackage(s.name)
+		b.Symbols = append(b.Symbols, Symbol{Name: s.name, Package: pkg, Size: s.size})
+		p := byPkg[pkg]
+		if p == nil {
+			p = &PackageSize{Name: pkg}
+			byPkg[pkg] = p
+		}
+		p.Size += s.size
+		p.Symbols++
+	}
+	for _, p := range byPkg {
+		b.Packages = append(b.Packages, *p)
+	}
+	sort.Slice(b.Symbols, func(i, j int) bool {
+		if b.Symbols[i].Size != b.Symbols[j].Size {
+			return b.Symbols[i].Size > b.Symbols[j].Size
+		}
+		return b.Symbols[i].Name < b.Symbols[j].Name
+	})
+	sort.Slice(b.Packages, func(i, j int) bool {
+		if b.Packages[i].Size != b.Packages[j].Size {
+			return b.Packages[i].Size > b.Packages[j].Size
+		}
+		return b.Packages[i].Name < b.Packages[j].Name
+	})
+	return b, nil
+}
+
+func elfSymbols(f *elf.File, b *Breakdown) ([]rawSymbol, error) {
+	for _, s := range f.Sections {
+		if s.Size > 0 && s.Type != elf.SHT_NULL {
+			b.Sections = append(b.Sections, Section{Name: s.Name, Size: s.Size})
+		}
+	}
+	symbols, err := f.Symbols()
+	if errors.Is(err, elf.ErrNoSymbols) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ELF symbols: %w", err)
+	}
+	var syms []rawSymbol
+	for _, s := range symbols {
+		switch elf.ST_TYPE(s.Info) {
+		case elf.STT_FUNC, elf.STT_OBJECT:
+			syms = append(syms, rawSymbol{name: s.Name, addr: s.Value, size: s.Size})
+		}
+	}
+	return syms, nil
+}
+
+func machoSymbols(f *macho.File, b *Breakdown) []rawSymbol {
+	ends := make(map[int]uint64)
+	for i, s := range f.Sections {
+		b.Sections = append(b.Sections, Section{Name: s.Seg + "," + s.Name, Size: s.Size})
+		ends[i+1] = s.Addr + s.Size
+	}
+	if f.Symtab == nil {
+		return nil
+	}
+	var syms []rawSymbol
+	for _, s := range f.Symtab.Syms {
+		// N_STAB debugging entries and undefined symbols have no bytes
+		if s.Type&0xe0 != 0 || s.Sect == 0 {
+			continue
+		}
+		syms = append(syms, rawSymbol{name: strings.TrimPrefix(s.Name, "_"), section: int(s.Sect), addr: s.Value})
+	}
+	return sizeByGaps(syms, ends)
+}
+
+func peSymbols(f *pe.File, b *Breakdown) []rawSymbol {
+	ends := make(map[int]uint64)
+	for i, s := range f.Sections {
+		size := uint64(s.VirtualSize)
+		if size == 0 {
+			size = uint64(s.Size)
+		}
+		b.Sections = append(b.Sections, Section{Name: s.Name, Size: size})
+		ends[i+1] = size
+	}
+	var syms []rawSymbol
+	for _, s := range f.Symbols {
+		if s.SectionNumber <= 0 {
+			continue
+		}
+		syms = append(syms, rawSymbol{name: s.Name, section: int(s.SectionNumber), addr: uint64(s.Value)})
+	}
+	return sizeByGaps(syms, ends)
+}
+
+// sizeByGaps sizes each symbol as the distance to the next symbol in the
+// same section, or to the section end for the last one.
+func sizeByGaps(syms []rawSymbol, sectionEnds map[int]uint64) []rawSymbol {
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].section != syms[j].section {
+			return syms[i].section < syms[j].section
+		}
+		return syms[i].addr < syms[j].addr
+	})
+	for i := range syms {
+		end := sectionEnds[syms[i].section]
+		if i+1 < len(syms) && syms[i+1].section == syms[i].section {
+			end = syms[i+1].addr
+		}
+		if end > syms[i].addr {
+			syms[i].size = end - syms[i].addr
+		}
+	}
+	return syms
+}
+
+// SymbolPackage returns the Go package a linker symbol belongs to, e.g.
+// "github.com/a/b" for "github.com/a/b.(*T).Method". Runtime type data and
+// other linker-generated symbols are grouped as "(go metadata)", and
+// symbols without a package qualifier as "(C)".
+func SymbolPackage(name string) string {
+	if strings.HasPrefix(name, "type:") || strings.HasPrefix(name, "go:") ||
+		strings.HasPrefix(name, "type.") || strings.HasPrefix(name, "go.") {
+		return "(go metadata)"
+	}
+	// Generic instantiations carry arbitrary types in brackets
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot <= 0 {
+		return "(C)"
+	}
+	return name[:slash+1+dot]
+}
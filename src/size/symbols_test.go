@@ -0,0 +1,83 @@
+package size
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestSymbolPackage(t *testing.T) {
+	tests := map[string]string{
+		"main.main":                         "main",
+		"runtime.mallocgc":                  "runtime",
+		"github.com/a/b.(*T).Method":        "github.com/a/b",
+		"github.com/a/b.Func.func1":         "github.com/a/b",
+		"github.com/a/b.Map[go.shape.int]":  "github.com/a/b",
+		"golang.org/x/net/http2.init":       "golang.org/x/net/http2",
+		"vendor/golang.org/x/net/idna.Data": "vendor/golang.org/x/net/idna",
+		"type:*":                            "(go metadata)",
+		"go:func.*":                         "(go metadata)",
+		"memcpy":                            "(C)",
+	}
+	for sym, want := range tests {
+		assert.Equal(t, want, SymbolPackage(sym), sym)
+	}
+}
+
+func TestSizeByGaps(t *testing.T) {
+	syms := sizeByGaps([]rawSymbol{
+		{name: "b", section: 1, addr: 0x30},
+		{name: "a", section: 1, addr: 0x10},
+		{name: "c", section: 2, addr: 0x0},
+	}, map[int]uint64{1: 0x100, 2: 0x8})
+	assert.Equal(t, "a", syms[0].name)
+	assert.Equal(t, uint64(0x20), syms[0].size)
+	assert.Equal(t, uint64(0xd0), syms[1].size)
+	assert.Equal(t, uint64(0x8), syms[2].size)
+}
+
+func TestAnalyze(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds binaries")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module sizetest\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n"), 0644))
+
+	for goos, format := range map[string]string{"linux": "elf", "darwin": "macho", "windows": "pe"} {
+		out := filepath.Join(dir, "app_"+goos)
+		cmd := exec.Command("go", "build", "-o", out, ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=amd64", "CGO_ENABLED=0")
+		require.NoError(t, cmd.Run(), goos)
+
+		b, err := Analyze(out)
+		require.NoError(t, err, goos)
+		assert.Equal(t, format, b.Format)
+		assert.NotEqual(t, 0, len(b.Sections), goos)
+		assert.Equal(t, "runtime", b.Packages[0].Name, goos)
+		var hasFmt bool
+		for _, p := range b.Packages {
+			hasFmt = hasFmt || p.Name == "fmt"
+		}
+		assert.True(t, hasFmt, goos)
+	}
+
+	// Stripped binaries only report sections
+	out := filepath.Join(dir, "stripped")
+	cmd := exec.Command("go", "build", "-ldflags=-s", "-o", out, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	require.NoError(t, cmd.Run())
+	b, err := Analyze(out)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(b.Symbols))
+	assert.NotEqual(t, 0, len(b.Sections))
+
+	_, err = Analyze(filepath.Join(dir, "main.go"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,105 @@
+// Package update decides which version, if any, a dependency should be
+// updated to under a per-module update policy.
+package update
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+// Level is how far a dependency may be updated automatically.
+type Level int
+
+const (
+	Never  Level = iota // report available updates, never apply them
+	Patch               // same major.minor
+	Minor               // same major
+	Latest              // any newer version
+	Pinned              // keep the current version and don't report updates
+)
+
+// Levels are the level names, in Level order.
+var Levels = []string{"never", "patch", "minor", "latest", "pinned"}
+
+func (l Level) String() string {
+	return Levels[l]
+}
+
+// MarshalText encodes the level by name for JSON output.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLevel parses a level name.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range Levels {
+		if s == name {
+			return Level(i), nil
+		}
+	}
+	return Never, fmt.Errorf("unknown update policy %q (use: %s)", s, strings.Join(Levels, ", "))
+}
+
+// Rule applies a level to the modules matching Pattern.
+type Rule struct {
+	// Pattern is a comma-separated list of module path globs, matched
+	// against path prefixes as in GOPRIVATE ("github.com/org/*").
+	Pattern string
+	Level   Level
+	// MinAge holds back versions published less than this long ago.
+	MinAge time.Duration
+}
+
+// ParseRule builds a Rule from config values. minAge may be empty.
+func ParseRule(pattern, level, minAge string) (Rule, error) {
+	rule := Rule{Pattern: pattern}
+	if pattern == "" {
+		return rule, fmt.Errorf("update rule without a module pattern")
+	}
+	var err error
+	if rule.Level, err = ParseLevel(level); err != nil {
+		return rule, err
+	}
+	if minAge != "" {
+		if rule.MinAge, err = ParseAge(minAge); err != nil {
+			return rule, err
+		}
+	}
+	return rule, nil
+}
+
+// ParseAge parses a duration, additionally accepting whole days ("7d").
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 72h or 7d)", s)
+	}
+	return d, nil
+}
+
+// Policy is an ordered list of rules; the first rule matching a module
+// path applies. Modules no rule matches are never updated.
+type Policy struct {
+	Rules []Rule
+}
+
+// Rule returns the rule for the module path.
+func (p Policy) Rule(path string) Rule {
+	for _, r := range p.Rules {
+		if module.MatchPrefixPatterns(r.Pattern, path) {
+			return r
+		}
+	}
+	return Rule{Pattern: "", Level: Never}
+}
@@ -0,0 +1,56 @@
+package update
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("github.com/org/*", "minor", "7d")
+	require.NoError(t, err)
+	assert.Equal(t, Minor, rule.Level)
+	assert.Equal(t, 7*24*time.Hour, rule.MinAge)
+
+	rule, err = ParseRule("example.com/x", "pinned", "")
+	require.NoError(t, err)
+	assert.Equal(t, Pinned, rule.Level)
+	assert.Equal(t, time.Duration(0), rule.MinAge)
+
+	for _, bad := range [][3]string{{"", "latest", ""}, {"x", "major", ""}, {"x", "latest", "soon"}, {"x", "latest", "-3d"}, {"x", "latest", "-1h"}} {
+		_, err := ParseRule(bad[0], bad[1], bad[2])
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	d, err := ParseAge("72h")
+	require.NoError(t, err)
+	assert.Equal(t, 72*time.Hour, d)
+	d, err = ParseAge("0d")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), d)
+}
+
+func TestPolicyRule(t *testing.T) {
+	p := Policy{Rules: []Rule{
+		{Pattern: "github.com/org/pinned", Level: Pinned},
+		{Pattern: "github.com/org/*,example.com/lib", Level: Latest},
+		{Pattern: "*", Level: Patch},
+	}}
+	assert.Equal(t, Pinned, p.Rule("github.com/org/pinned").Level)
+	assert.Equal(t, Latest, p.Rule("github.com/org/repo").Level)
+	assert.Equal(t, Latest, p.Rule("github.com/org/repo/v2").Level)
+	assert.Equal(t, Latest, p.Rule("example.com/lib").Level)
+	assert.Equal(t, Patch, p.Rule("golang.org/x/mod").Level)
+	assert.Equal(t, Never, Policy{}.Rule("golang.org/x/mod").Level)
+}
+
+func TestLevelString(t *testing.T) {
+	text, err := Minor.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "minor", string(text))
+	assert.Equal(t, "pinned", Pinned.String())
+}
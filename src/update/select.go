@@ -0,0 +1,94 @@
+package update
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// Decision is the outcome of applying a rule to one dependency.
+type Decision struct {
+	Version string // version to update to; empty to stay
+	Reason  string // why nothing (or not the newest version) was chosen
+}
+
+// Select picks the newest version in available that the rule allows over
+// current. available holds tagged versions and, for modules without tags,
+// the latest pseudo-version. Pre-release tags are only considered when
+// current is itself a pre-release or pseudo-version. timeOf returns a
+// version's publication time and is only called for rules with a MinAge.
+func Select(rule Rule, current string, available []string, timeOf func(version string) (time.Time, error), now time.Time) Decision {
+	switch rule.Level {
+	case Pinned:
+		return Decision{Reason: "pinned"}
+	case Never:
+		return Decision{Reason: "updates disabled by policy"}
+	}
+
+	var candidates []string
+	newer := false
+	for _, v := range available {
+		if !semver.IsValid(v) || semver.Compare(v, current) <= 0 {
+			continue
+		}
+		if semver.Prerelease(v) != "" && semver.Prerelease(current) == "" {
+			continue
+		}
+		newer = true
+		if allows(rule.Level, current, v) {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		if newer {
+			return Decision{Reason: fmt.Sprintf("no %s update", rule.Level)}
+		}
+		return Decision{Reason: "up to date"}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return semver.Compare(candidates[i], candidates[j]) > 0 })
+
+	if rule.MinAge <= 0 {
+		return Decision{Version: candidates[0]}
+	}
+	var held []string
+	for _, v := range candidates {
+		published, err := timeOf(v)
+		if err != nil {
+			held = append(held, fmt.Sprintf("%s (unknown age)", v))
+			continue
+		}
+		if age := now.Sub(published); age < rule.MinAge {
+			held = append(held, fmt.Sprintf("%s (%s old)", v, formatAge(age)))
+			continue
+		}
+		d := Decision{Version: v}
+		if len(held) > 0 {
+			d.Reason = fmt.Sprintf("newer versions younger than %s: %s", formatAge(rule.MinAge), held[0])
+		}
+		return d
+	}
+	return Decision{Reason: fmt.Sprintf("held back until %s old: %s", formatAge(rule.MinAge), held[0])}
+}
+
+// allows reports whether the level permits updating from current to v.
+func allows(level Level, current, v string) bool {
+	switch level {
+	case Patch:
+		return semver.MajorMinor(v) == semver.MajorMinor(current)
+	case Minor:
+		return semver.Major(v) == semver.Major(current)
+	case Latest:
+		return true
+	}
+	return false
+}
+
+// formatAge prints a duration in days when it's at least one, else hours.
+func formatAge(d time.Duration) string {
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%dh", int(d/time.Hour))
+}
@@ -0,0 +1,65 @@
+package update
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wow-look-at-my/testify/assert"
+)
+
+var (
+	now      = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	versions = []string{"v1.2.0", "v1.2.3", "v1.2.4", "v1.2.5", "v1.3.0", "v1.4.0-rc.1", "v1.4.1", "bogus"}
+	// published maps versions to their age in days
+	published = map[string]int{"v1.2.4": 30, "v1.2.5": 2, "v1.3.0": 20, "v1.4.1": 1}
+)
+
+func timeOf(v string) (time.Time, error) {
+	days, ok := published[v]
+	if !ok {
+		return time.Time{}, errors.New("not found")
+	}
+	return now.Add(-time.Duration(days) * 24 * time.Hour), nil
+}
+
+func TestSelect(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		name    string
+		rule    Rule
+		current string
+		want    Decision
+	}{
+		{"latest", Rule{Level: Latest}, "v1.2.3", Decision{Version: "v1.4.1"}},
+		{"minor", Rule{Level: Minor}, "v1.2.3", Decision{Version: "v1.4.1"}},
+		{"patch", Rule{Level: Patch}, "v1.2.3", Decision{Version: "v1.2.5"}},
+		{"patch none", Rule{Level: Patch}, "v1.3.0", Decision{Reason: "no patch update"}},
+		{"up to date", Rule{Level: Latest}, "v1.4.1", Decision{Reason: "up to date"}},
+		{"never", Rule{Level: Never}, "v1.2.3", Decision{Reason: "updates disabled by policy"}},
+		{"pinned", Rule{Level: Pinned}, "v1.2.3", Decision{Reason: "pinned"}},
+		{"min age", Rule{Level: Latest, MinAge: week}, "v1.2.3", Decision{Version: "v1.3.0", Reason: "newer versions younger than 7d: v1.4.1 (1d old)"}},
+		{"min age patch", Rule{Level: Patch, MinAge: week}, "v1.2.3", Decision{Version: "v1.2.4", Reason: "newer versions younger than 7d: v1.2.5 (2d old)"}},
+		{"min age held", Rule{Level: Patch, MinAge: week}, "v1.2.4", Decision{Reason: "held back until 7d old: v1.2.5 (2d old)"}},
+		{"prerelease current", Rule{Level: Minor}, "v1.4.0-rc.0", Decision{Version: "v1.4.1"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Select(tt.rule, tt.current, versions, timeOf, now), tt.name)
+	}
+}
+
+func TestSelectPseudoVersions(t *testing.T) {
+	current := "v0.0.0-20260101000000-abcdefabcdef"
+	latest := "v0.0.0-20260301000000-123456123456"
+	d := Select(Rule{Level: Patch}, current, []string{latest}, timeOf, now)
+	assert.Equal(t, latest, d.Version)
+
+	// A pseudo-version update of a tagged release is skipped
+	d = Select(Rule{Level: Latest}, "v1.2.3", []string{"v1.2.4-0.20260301000000-123456123456"}, timeOf, now)
+	assert.Equal(t, Decision{Reason: "up to date"}, d)
+}
+
+func TestSelectUnknownAge(t *testing.T) {
+	d := Select(Rule{Level: Latest, MinAge: time.Hour}, "v1.0.0", []string{"v1.1.0"}, timeOf, now)
+	assert.Equal(t, Decision{Reason: "held back until 1h old: v1.1.0 (unknown age)"}, d)
+}
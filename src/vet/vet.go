@@ -66,20 +66,29 @@ func Analyzers() []*analysis.Analyzer {
 	}
 }
 
+// Result is the outcome of a vet run.
+type Result struct {
+	// FilesChanged reports whether any fixes were applied.
+	FilesChanged bool
+	// Packages are the packages (including test variants) analyzed by the
+	// last pass, with full syntax and type information, so later phases can
+	// reuse them instead of loading the module again. They are loaded after
+	// any fixes, so they match the source on disk; nil if vet loaded nothing.
+	Packages []*packages.Package
+}
+
 // Run executes all analyzers on the current module.
 // If fix is true, auto-fixes are applied for analyzers that support them.
-// Returns (filesChanged, error) where filesChanged indicates if any fixes were applied.
-// Returns (false, nil) if no go.mod exists (nothing to vet).
-func Run(fix bool) (bool, error) {
+// Returns an empty Result if no go.mod exists (nothing to vet).
+func Run(fix bool) (Result, error) {
 	if _, err := os.Stat("go.mod"); os.IsNotExist(err) {
-		return false, nil
+		return Result{}, nil
 	}
 	return RunOnPattern("./...", fix)
 }
 
 // RunOnPattern executes all analyzers on packages matching the pattern.
-// Returns (filesChanged, error) where filesChanged indicates if any fixes were applied.
-func RunOnPattern(pattern string, fix bool) (bool, error) {
+func RunOnPattern(pattern string, fix bool) (Result, error) {
 	return vetSemantic(pattern, fix)
 }
 
@@ -106,15 +115,14 @@ func isUnusedImportError(errMsg string) bool {
 }
 
 // vetSemantic runs type-aware analysis using go/packages and the analysis framework.
-// Returns (filesChanged, error) where filesChanged indicates if any fixes were applied.
-func vetSemantic(pattern string, fix bool) (bool, error) {
+func vetSemantic(pattern string, fix bool) (Result, error) {
 	filesChanged := false
 
 	// Fix broken testify imports before loading packages
 	if fix {
 		fixed, err := FixTestifyImports()
 		if err != nil {
-			return false, fmt.Errorf("fixing testify imports: %w", err)
+			return Result{}, fmt.Errorf("fixing testify imports: %w", err)
 		}
 		if fixed {
 			filesChanged = true
@@ -122,13 +130,14 @@ func vetSemantic(pattern string, fix bool) (bool, error) {
 	}
 
 	cfg := &packages.Config{
-		Mode:  packages.LoadAllSyntax,
+		// Modules too: the vulnerability scan reuses these packages
+		Mode:  packages.LoadAllSyntax | packages.NeedModule,
 		Tests: true,
 	}
 
 	pkgs, err := packages.Load(cfg, pattern)
 	if err != nil {
-		return false, fmt.Errorf("failed to load packages: %w", err)
+		return Result{}, fmt.Errorf("failed to load packages: %w", err)
 	}
 
 	// Check for load errors - separate unused import errors from others
@@ -146,33 +155,33 @@ func vetSemantic(pattern string, fix bool) (bool, error) {
 
 	// If there are non-import errors, fail
 	if len(loadErrors) > 0 {
-		return false, fmt.Errorf("package load errors:\n%s", strings.Join(loadErrors, "\n"))
+		return Result{}, fmt.Errorf("package load errors:\n%s", strings.Join(loadErrors, "\n"))
 	}
-
 	// If there are unused import errors, fix them and re-run
 	if len(unusedImportErrors) > 0 {
 		if !fix {
-			return false, fmt.Errorf("package load errors:\n%s", strings.Join(unusedImportErrors, "\n"))
+			return Result{}, fmt.Errorf("package load errors:\n%s", strings.Join(unusedImportErrors, "\n"))
 		}
 
 		// Find and apply fixes using the loaded AST
 		importFixes := FindUnusedImportFixes(pkgs)
 		for _, f := range importFixes {
 			if err := f.Apply(); err != nil {
-				return false, fmt.Errorf("failed to fix unused imports: %w", err)
+				return Result{}, fmt.Errorf("failed to fix unused imports: %w", err)
 			}
 		}
 		if len(importFixes) > 0 {
 			// Re-run semantic analysis with fixed files (already changed files)
-			_, err := vetSemantic(pattern, fix)
-			return true, err
+			res, err := vetSemantic(pattern, fix)
+			res.FilesChanged = true
+			return res, err
 		}
 	}
 
 	// Run analyzers
 	graph, err := checker.Analyze(Analyzers(), pkgs, nil)
 	if err != nil {
-		return false, fmt.Errorf("analysis failed: %w", err)
+		return Result{}, fmt.Errorf("analysis failed: %w", err)
 	}
 
 	// Collect diagnostics
@@ -200,10 +209,10 @@ func vetSemantic(pattern string, fix bool) (bool, error) {
 						continue
 					}
 					if err := checkFileCommitted(result); err != nil {
-						return false, err
+						return Result{}, err
 					}
 					if err := result.Apply(); err != nil {
-						return false, fmt.Errorf("failed to apply fixes: %w", err)
+						return Result{}, fmt.Errorf("failed to apply fixes: %w", err)
 					}
 					filesChanged = true
 				}
@@ -214,25 +223,26 @@ func vetSemantic(pattern string, fix bool) (bool, error) {
 	// After applying fixes, clean up any side effects (unused vars/imports)
 	if filesChanged && fix {
 		if _, err := FixUnusedRangeVars("./..."); err != nil {
-			return filesChanged, fmt.Errorf("fixing unused range vars: %w", err)
+			return Result{FilesChanged: filesChanged}, fmt.Errorf("fixing unused range vars: %w", err)
 		}
 		if _, err := FixUnusedImports("./..."); err != nil {
-			return filesChanged, fmt.Errorf("fixing unused imports: %w", err)
+			return Result{FilesChanged: filesChanged}, fmt.Errorf("fixing unused imports: %w", err)
 		}
 		// Run go mod tidy to add any new dependencies (e.g., testify)
 		tidyCmd := exec.Command("go", "mod", "tidy")
 		tidyCmd.Stdout = os.Stdout
 		tidyCmd.Stderr = os.Stderr
 		if err := tidyCmd.Run(); err != nil {
-			return filesChanged, fmt.Errorf("go mod tidy failed: %w", err)
+			return Result{FilesChanged: filesChanged}, fmt.Errorf("go mod tidy failed: %w", err)
 		}
 		// Re-run analysis to verify fixes worked (don't report old diagnostics)
-		_, err := vetSemantic(pattern, fix)
-		return true, err
+		res, err := vetSemantic(pattern, fix)
+		res.FilesChanged = true
+		return res, err
 	}
 
 	if len(diagnostics) == 0 {
-		return filesChanged, nil
+		return Result{FilesChanged: filesChanged, Packages: pkgs}, nil
 	}
 
 	// Sort diagnostics by file, then line
@@ -249,7 +259,7 @@ func vetSemantic(pattern string, fix bool) (bool, error) {
 	for _, d := range diagnostics {
 		fmt.Fprintf(&sb, "%s:%d:%d: %s\n", d.File, d.Line, d.Column, d.Message)
 	}
-	return filesChanged, fmt.Errorf("%s", sb.String())
+	return Result{FilesChanged: filesChanged}, fmt.Errorf("%s", sb.String())
 }
 
 // Diagnostic represents a single analyzer finding.
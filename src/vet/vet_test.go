@@ -178,8 +178,14 @@ func main() {
 	os.Chdir(dir)
 	defer os.Chdir(oldWd)
 
-	_, err := RunOnPattern("./...", false)
+	res, err := RunOnPattern("./...", false)
 	assert.Nil(t, err)
+
+	// The loaded packages are returned for later phases
+	pkgs := res.Packages
+	require.Equal(t, 1, len(pkgs))
+	assert.Equal(t, "testmod", pkgs[0].PkgPath)
+	assert.NotNil(t, pkgs[0].TypesInfo)
 }
 
 func TestASTFixesFprint(t *testing.T) {
@@ -1287,12 +1293,24 @@ func main() {
 	defer os.Chdir(oldWd)
 
 	// With fix=true, it should fix the unused import and succeed
-	_, err := vetSemantic("./...", true)
+	res, err := vetSemantic("./...", true)
 	assert.Nil(t, err)
+	assert.True(t, res.FilesChanged)
 
 	// Verify the import was removed
 	content, _ := os.ReadFile(filepath.Join(dir, "main.go"))
 	assert.NotContains(t, string(content), "strings")
+
+	// The packages returned are loaded from the fixed source
+	require.Equal(t, 1, len(res.Packages))
+	require.Equal(t, 1, len(res.Packages[0].Syntax))
+	assert.Equal(t, 1, len(res.Packages[0].Syntax[0].Imports))
+
+	// A later run that fails to load returns no packages
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() { undefined() }\n"), 0644)
+	res, err = vetSemantic("./...", false)
+	assert.NotNil(t, err)
+	assert.Nil(t, res.Packages)
 }
 
 func TestRemoveImport(t *testing.T) {
@@ -1784,9 +1802,9 @@ func TestFoo(t *testing.T) {
 	gitCommit.Run()
 
 	// With fix=true, it should apply fixes, run go mod tidy, and re-run vetSemantic
-	changed, err := vetSemantic("./...", true)
+	res, err := vetSemantic("./...", true)
 	assert.Nil(t, err)
-	assert.True(t, changed)
+	assert.True(t, res.FilesChanged)
 
 	// Verify the fix was applied (should use assert.Equal)
 	content, _ := os.ReadFile(filepath.Join(dir, "main_test.go"))
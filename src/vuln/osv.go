@@ -0,0 +1,229 @@
+// Package vuln checks a module's dependencies against an offline OSV
+// vulnerability database and reports which vulnerable symbols are reachable.
+package vuln
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Entry is an OSV vulnerability record, limited to the fields used here.
+// See https://ossf.github.io/osv-schema/.
+type Entry struct {
+	ID               string     `json:"id"`
+	Aliases          []string   `json:"aliases,omitempty"`
+	Summary          string     `json:"summary,omitempty"`
+	Details          string     `json:"details,omitempty"`
+	Withdrawn        string     `json:"withdrawn,omitempty"`
+	Severity         []Score    `json:"severity,omitempty"`
+	Affected         []Affected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity,omitempty"` // GHSA style: LOW, MODERATE, HIGH, CRITICAL
+		URL      string `json:"url,omitempty"`
+	} `json:"database_specific"`
+}
+
+// Score is a severity score such as a CVSS vector.
+type Score struct {
+	Type  string `json:"type"` // e.g. CVSS_V3
+	Score string `json:"score"`
+}
+
+// Affected lists the vulnerable versions and packages of one module.
+type Affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"` // module path, or "stdlib" / "toolchain"
+	} `json:"package"`
+	Ranges            []Range `json:"ranges,omitempty"`
+	EcosystemSpecific struct {
+		Imports []Import `json:"imports,omitempty"`
+	} `json:"ecosystem_specific"`
+}
+
+// Range is a list of version events; versions carry no "v" prefix.
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is one introduced, fixed or last_affected version boundary.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// Import is a vulnerable package and, optionally, the vulnerable symbols in
+// it ("Func" or "Type.Method"). No symbols means the whole package.
+type Import struct {
+	Path    string   `json:"path"`
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// DB is an in-memory OSV database, indexed by module path.
+type DB struct {
+	Entries  int
+	byModule map[string][]*Entry
+}
+
+// LoadDB reads every OSV entry under dir. Both the Go vulnerability
+// database layout (ID/GO-*.json, index/*.json) and flat OSV exports work;
+// JSON files that aren't OSV entries and withdrawn entries are skipped, as
+// are entries for ecosystems other than Go.
+func LoadDB(dir string) (*DB, error) {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("vulnerability database: %w", err)
+	}
+	if !st.IsDir() {
+		return nil, fmt.Errorf("vulnerability database %s is not a directory", dir)
+	}
+
+	db := &DB{byModule: make(map[string][]*Entry)}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e Entry
+		if json.Unmarshal(data, &e) != nil || e.ID == "" || e.Withdrawn != "" {
+			return nil
+		}
+		db.add(&e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vulnerability database: %w", err)
+	}
+	return db, nil
+}
+
+func (db *DB) add(e *Entry) {
+	seen := make(map[string]bool)
+	for _, a := range e.Affected {
+		if a.Package.Ecosystem != "Go" || seen[a.Package.Name] {
+			continue
+		}
+		seen[a.Package.Name] = true
+		db.byModule[a.Package.Name] = append(db.byModule[a.Package.Name], e)
+	}
+	db.Entries++
+}
+
+// ForModule returns the entries affecting module path at version (a Go
+// module version such as v1.2.3).
+func (db *DB) ForModule(path, version string) []*Entry {
+	var entries []*Entry
+	for _, e := range db.byModule[path] {
+		for _, a := range e.Affected {
+			if a.Package.Name == path && a.Affects(version) {
+				entries = append(entries, e)
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// Affects reports whether version falls in any of a's SEMVER ranges. No
+// ranges means every version is affected.
+func (a Affected) Affects(version string) bool {
+	if len(a.Ranges) == 0 {
+		return true
+	}
+	for _, r := range a.Ranges {
+		if r.Type == "SEMVER" && r.affects(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// affects follows the OSV evaluation rules: walking events in version
+// order, an introduced event at or below version turns it on, and a fixed
+// (at or below) or last_affected (below) event turns it off.
+func (r Range) affects(version string) bool {
+	events := append([]Event(nil), r.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return semver.Compare(events[i].version(), events[j].version()) < 0
+	})
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if semver.Compare(version, e.version()) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if semver.Compare(version, e.version()) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if semver.Compare(version, e.version()) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// version returns the event's boundary as a comparable semver string.
+// Introduced "0" sorts before every real version.
+func (e Event) version() string {
+	v := e.Introduced + e.Fixed + e.LastAffected
+	if v == "0" {
+		return "v0.0.0-0"
+	}
+	return "v" + v
+}
+
+// Fixed returns the lowest fixed version above version, without the "v"
+// prefix OSV omits, or "" if there is none.
+func (a Affected) Fixed(version string) string {
+	best := ""
+	for _, r := range a.Ranges {
+		for _, e := range r.Events {
+			if e.Fixed == "" || semver.Compare(e.version(), version) <= 0 {
+				continue
+			}
+			if best == "" || semver.Compare(e.version(), "v"+best) < 0 {
+				best = e.Fixed
+			}
+		}
+	}
+	return best
+}
+
+var goVersionRE = regexp.MustCompile(`^go(\d+\.\d+(?:\.\d+)?)(?:(rc|beta)(\d+))?`)
+
+// GoSemver converts a Go release name (go1.22.3, go1.23rc1) to the semver
+// form the vulnerability database uses for stdlib versions.
+func GoSemver(goVersion string) string {
+	m := goVersionRE.FindStringSubmatch(goVersion)
+	if m == nil {
+		return ""
+	}
+	v := "v" + m[1]
+	if strings.Count(m[1], ".") == 1 {
+		v += ".0"
+	}
+	if m[2] != "" {
+		v += "-" + m[2] + "." + m[3]
+	}
+	return v
+}
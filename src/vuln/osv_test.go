@@ -0,0 +1,74 @@
+package vuln
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestLoadDB(t *testing.T) {
+	db, err := LoadDB("testdata/db")
+	require.NoError(t, err)
+	// Withdrawn entries and index files are skipped; the PyPI entry is
+	// counted but not indexed under any Go module
+	assert.Equal(t, 6, db.Entries)
+
+	var ids []string
+	for _, e := range db.ForModule("example.com/dep", "v1.1.0") {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{"GO-2099-0001", "GO-2099-0002", "GO-2099-0003"}, ids)
+
+	ids = nil
+	for _, e := range db.ForModule("example.com/dep", "v1.2.0") {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{"GO-2099-0003"}, ids)
+}
+
+func TestLoadDBMissing(t *testing.T) {
+	_, err := LoadDB("testdata/nope")
+	assert.NotNil(t, err)
+	_, err = LoadDB("osv.go")
+	assert.NotNil(t, err)
+}
+
+func TestRangeAffects(t *testing.T) {
+	r := Range{Type: "SEMVER", Events: []Event{{Introduced: "0"}, {Fixed: "1.22.4"}, {Introduced: "1.23.0"}, {Fixed: "1.23.1"}}}
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"v1.0.0", true},
+		{"v1.22.3", true},
+		{"v1.22.4", false},
+		{"v1.22.9", false},
+		{"v1.23.0", true},
+		{"v1.23.1", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.affects(tt.version), tt.version)
+	}
+
+	last := Range{Type: "SEMVER", Events: []Event{{Introduced: "1.0.0"}, {LastAffected: "1.1.0"}}}
+	assert.False(t, last.affects("v0.9.0"))
+	assert.True(t, last.affects("v1.1.0"))
+	assert.False(t, last.affects("v1.1.1"))
+}
+
+func TestAffectedFixed(t *testing.T) {
+	a := Affected{Ranges: []Range{{Type: "SEMVER", Events: []Event{{Introduced: "0"}, {Fixed: "1.22.4"}, {Introduced: "1.23.0"}, {Fixed: "1.23.1"}}}}}
+	assert.Equal(t, "1.22.4", a.Fixed("v1.22.0"))
+	assert.Equal(t, "1.23.1", a.Fixed("v1.23.0"))
+	assert.Equal(t, "", a.Fixed("v1.24.0"))
+	assert.True(t, Affected{}.Affects("v1.0.0"))
+}
+
+func TestGoSemver(t *testing.T) {
+	assert.Equal(t, "v1.22.3", GoSemver("go1.22.3"))
+	assert.Equal(t, "v1.21.0", GoSemver("go1.21"))
+	assert.Equal(t, "v1.23.0-rc.1", GoSemver("go1.23rc1"))
+	assert.Equal(t, "v1.24.1", GoSemver("go1.24.1 X:nocoverageredesign"))
+	assert.Equal(t, "", GoSemver("devel"))
+}
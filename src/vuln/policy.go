@@ -0,0 +1,58 @@
+package vuln
+
+import "slices"
+
+// Policy decides which findings are reported and which fail the build.
+type Policy struct {
+	FailOn    Severity // reachable findings at least this severe fail
+	WarnOnly  bool     // never fail
+	UnknownAs Severity // severity assumed for unrated findings
+	Ignore    []string // IDs or aliases to drop
+}
+
+// ParsePolicy builds a Policy from config values. failOn may be "none";
+// empty values default to high.
+func ParsePolicy(failOn, unknownAs string, ignore []string) (Policy, error) {
+	p := Policy{FailOn: High, UnknownAs: High, Ignore: ignore}
+	var err error
+	switch failOn {
+	case "":
+	case "none":
+		p.WarnOnly = true
+	default:
+		if p.FailOn, err = ParseSeverity(failOn); err != nil {
+			return p, err
+		}
+	}
+	if unknownAs != "" {
+		if p.UnknownAs, err = ParseSeverity(unknownAs); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// Filter drops ignored findings.
+func (p Policy) Filter(findings []Finding) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		if !slices.Contains(p.Ignore, f.ID) && !slices.ContainsFunc(f.Aliases, func(a string) bool { return slices.Contains(p.Ignore, a) }) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Severity returns f's severity, substituting UnknownAs for unrated ones.
+func (p Policy) Severity(f Finding) Severity {
+	if f.Severity == Unknown {
+		return p.UnknownAs
+	}
+	return f.Severity
+}
+
+// Fails reports whether f should fail the build: vulnerable code must be
+// reachable and severe enough. Everything else is a warning.
+func (p Policy) Fails(f Finding) bool {
+	return !p.WarnOnly && f.Level == LevelCalled && p.Severity(f) >= p.FailOn
+}
@@ -0,0 +1,46 @@
+package vuln
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, High, p.FailOn)
+	assert.Equal(t, High, p.UnknownAs)
+
+	p, err = ParsePolicy("none", "low", nil)
+	require.NoError(t, err)
+	assert.True(t, p.WarnOnly)
+	assert.Equal(t, Low, p.UnknownAs)
+
+	_, err = ParsePolicy("severe", "", nil)
+	assert.NotNil(t, err)
+	_, err = ParsePolicy("", "none", nil)
+	assert.NotNil(t, err)
+}
+
+func TestPolicyFails(t *testing.T) {
+	p := Policy{FailOn: High, UnknownAs: Medium}
+	assert.True(t, p.Fails(Finding{Level: LevelCalled, Severity: Critical}))
+	assert.False(t, p.Fails(Finding{Level: LevelImported, Severity: Critical}))
+	assert.False(t, p.Fails(Finding{Level: LevelCalled, Severity: Medium}))
+	// Unrated findings take UnknownAs
+	assert.False(t, p.Fails(Finding{Level: LevelCalled}))
+	p.UnknownAs = High
+	assert.True(t, p.Fails(Finding{Level: LevelCalled}))
+
+	p.WarnOnly = true
+	assert.False(t, p.Fails(Finding{Level: LevelCalled, Severity: Critical}))
+}
+
+func TestPolicyFilter(t *testing.T) {
+	p := Policy{Ignore: []string{"GO-1", "CVE-2"}}
+	kept := p.Filter([]Finding{{ID: "GO-1"}, {ID: "GO-2", Aliases: []string{"CVE-2"}}, {ID: "GO-3"}})
+	require.Equal(t, 1, len(kept))
+	assert.Equal(t, "GO-3", kept[0].ID)
+}
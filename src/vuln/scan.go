@@ -0,0 +1,372 @@
+package vuln
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+)
+
+// Level is how far a vulnerability reaches into the scanned code.
+type Level int
+
+const (
+	LevelModule   Level = iota // a vulnerable version of the module is required
+	LevelImported              // a vulnerable package is imported, but no vulnerable symbol is reachable
+	LevelCalled                // vulnerable code is reachable from the module's packages
+)
+
+var levelNames = []string{"module", "imported", "called"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// MarshalText encodes the level by name for JSON output.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Finding is a vulnerability affecting a required module version.
+type Finding struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Module   string   `json:"module"`
+	Version  string   `json:"version"`
+	Fixed    string   `json:"fixed,omitempty"` // first fixed version, if any
+	Severity Severity `json:"severity"`
+	Level    Level    `json:"level"`
+	Symbols  []string `json:"symbols,omitempty"` // reachable vulnerable symbols
+	Trace    []string `json:"trace,omitempty"`   // call path to the first of them
+	// Unanalyzed lists the packages missing from the call graph because
+	// they failed to build, when the finding is imported but no call to it
+	// was found: one may still be made through them.
+	Unanalyzed []string `json:"unanalyzed,omitempty"`
+	imports    []Import
+}
+
+// LoadMode is the packages.Load mode Scan needs: full syntax and types for
+// the call graph, and modules to tell the standard library apart.
+const LoadMode = packages.LoadAllSyntax | packages.NeedModule
+
+// Scan matches the build list against db. The standard library is checked
+// at goVersion (e.g. "go1.24.1"). When pkgs, the module's own loaded
+// packages, are given, findings are refined to LevelImported or LevelCalled
+// by walking their imports and a call graph built from them; pkgs must have
+// been loaded with LoadMode.
+func Scan(db *DB, mods []build.Module, goVersion string, pkgs []*packages.Package) ([]Finding, error) {
+	type modVersion struct{ path, version string }
+	var required []modVersion
+	for _, m := range mods {
+		if m.Main {
+			continue
+		}
+		if m.Replace != nil {
+			if m.Replace.Version == "" {
+				continue // local directory; no version to match
+			}
+			m = *m.Replace
+		}
+		required = append(required, modVersion{m.Path, m.Version})
+	}
+	if v := GoSemver(goVersion); v != "" {
+		required = append(required, modVersion{"stdlib", v}, modVersion{"toolchain", v})
+	}
+
+	var findings []Finding
+	for _, mv := range required {
+		for _, e := range db.ForModule(mv.path, mv.version) {
+			f := Finding{
+				ID:       e.ID,
+				Aliases:  e.Aliases,
+				Summary:  e.Summary,
+				Module:   mv.path,
+				Version:  mv.version,
+				Severity: EntrySeverity(e),
+			}
+			for _, a := range e.Affected {
+				if a.Package.Name != mv.path {
+					continue
+				}
+				if fixed := a.Fixed(mv.version); fixed != "" {
+					f.Fixed = "v" + fixed
+				}
+				f.imports = append(f.imports, a.EcosystemSpecific.Imports...)
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	if len(pkgs) > 0 && len(findings) > 0 {
+		if err := refineReachability(findings, pkgs); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Level != findings[j].Level {
+			return findings[i].Level > findings[j].Level
+		}
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		return findings[i].ID < findings[j].ID
+	})
+	return findings, nil
+}
+
+// refineReachability raises each finding's level based on what the
+// module's non-test packages import and call.
+func refineReachability(findings []Finding, pkgs []*packages.Package) error {
+	var roots []*packages.Package
+	for _, p := range pkgs {
+		// Test variants have IDs like "pkg [pkg.test]"; tests don't ship
+		if p.ID == p.PkgPath && !strings.HasSuffix(p.PkgPath, ".test") {
+			roots = append(roots, p)
+		}
+	}
+	var loadErrs []string
+	imported := make(map[string]*packages.Package)
+	packages.Visit(roots, nil, func(p *packages.Package) {
+		imported[p.PkgPath] = p
+		for _, e := range p.Errors {
+			loadErrs = append(loadErrs, e.Error())
+		}
+	})
+	if len(loadErrs) > 0 {
+		return fmt.Errorf("package load errors:\n%s", strings.Join(loadErrs, "\n"))
+	}
+
+	// Vulnerable symbols in imported packages need the call graph
+	targets := make(map[string]bool)
+	for i := range findings {
+		f := &findings[i]
+		if len(f.imports) == 0 {
+			// No package information: any package of the module counts
+			for path, p := range imported {
+				if inModule(p, f.Module) {
+					f.Level = LevelCalled
+					f.Symbols = append(f.Symbols, path)
+				}
+			}
+			sort.Strings(f.Symbols)
+			continue
+		}
+		for _, imp := range f.imports {
+			if imported[imp.Path] == nil {
+				continue
+			}
+			if f.Level < LevelImported {
+				f.Level = LevelImported
+			}
+			if len(imp.Symbols) == 0 {
+				f.Level = LevelCalled
+				f.Symbols = append(f.Symbols, imp.Path)
+			}
+			for _, sym := range imp.Symbols {
+				targets[imp.Path+" "+sym] = true
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	paths, unbuilt := callPaths(roots, targets)
+	for i := range findings {
+		f := &findings[i]
+		for _, imp := range f.imports {
+			for _, sym := range imp.Symbols {
+				if path, ok := paths[imp.Path+" "+sym]; ok {
+					f.Level = LevelCalled
+					f.Symbols = append(f.Symbols, imp.Path+"."+sym)
+					if f.Trace == nil {
+						f.Trace = path
+					}
+				}
+			}
+		}
+		if f.Level == LevelImported && len(f.imports) > 0 {
+			f.Unanalyzed = unbuilt
+		}
+	}
+	return nil
+}
+
+// inModule reports whether package p belongs to module mod, which is
+// either a module path (as replaced) or "stdlib". Standard library
+// packages are the ones without a module.
+func inModule(p *packages.Package, mod string) bool {
+	if p.Module == nil {
+		return mod == "stdlib"
+	}
+	return p.Module.Path == mod || (p.Module.Replace != nil && p.Module.Replace.Path == mod)
+}
+
+// callPaths returns a call path from the root packages' functions to each
+// reachable target ("pkgpath Symbol"). Like govulncheck, it builds a cheap
+// class hierarchy analysis call graph of the whole program, slices it down
+// to functions both reachable from the roots and reaching a target, and
+// only runs the more precise (and much more expensive) variable type
+// analysis on that slice. unbuilt lists the packages the SSA builder
+// failed on, whose calls are missing from the graph.
+func callPaths(roots []*packages.Package, targets map[string]bool) (paths map[string][]string, unbuilt []string) {
+	prog, ssaPkgs := ssautil.AllPackages(roots, ssa.InstantiateGenerics)
+	// Build package by package: the SSA builder can lag behind the Go
+	// release and panic on new syntax in some package, which then
+	// contributes no call edges
+	for _, p := range prog.AllPackages() {
+		if !buildSSA(p) {
+			unbuilt = append(unbuilt, p.Pkg.Path())
+		}
+	}
+	sort.Strings(unbuilt)
+	chaGraph := cha.CallGraph(prog)
+
+	rootPkgs := make(map[*ssa.Package]bool)
+	for _, p := range ssaPkgs {
+		if p != nil {
+			rootPkgs[p] = true
+		}
+	}
+	var entries, sinks []*ssa.Function
+	for fn := range ssautil.AllFunctions(prog) {
+		if fn.Pkg != nil && rootPkgs[fn.Pkg] {
+			entries = append(entries, fn)
+		}
+		if pkg, sym := symbol(fn); targets[pkg+" "+sym] {
+			sinks = append(sinks, fn)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, unbuilt
+	}
+	// Deterministic traces regardless of map order
+	sort.Slice(entries, func(i, j int) bool { return entries[i].String() < entries[j].String() })
+
+	forward := walk(chaGraph, entries, func(n *callgraph.Node) []*callgraph.Edge { return n.Out }, func(e *callgraph.Edge) *callgraph.Node { return e.Callee })
+	backward := walk(chaGraph, sinks, func(n *callgraph.Node) []*callgraph.Edge { return n.In }, func(e *callgraph.Edge) *callgraph.Node { return e.Caller })
+	slice := make(map[*ssa.Function]bool)
+	for fn := range forward {
+		if backward[fn] {
+			slice[fn] = true
+		}
+	}
+	if len(slice) == 0 {
+		return nil, unbuilt
+	}
+	cg := vta.CallGraph(slice, chaGraph)
+
+	// Breadth-first from the entries, so each trace is a shortest path
+	parent := make(map[*ssa.Function]*ssa.Function)
+	var queue []*ssa.Function
+	for _, fn := range entries {
+		if slice[fn] {
+			parent[fn] = nil
+			queue = append(queue, fn)
+		}
+	}
+	paths = make(map[string][]string)
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if pkg, sym := symbol(fn); targets[pkg+" "+sym] {
+			if _, ok := paths[pkg+" "+sym]; !ok {
+				var path []string
+				for f := fn; f != nil; f = parent[f] {
+					path = append([]string{f.String()}, path...)
+				}
+				paths[pkg+" "+sym] = path
+			}
+		}
+		node := cg.Nodes[fn]
+		if node == nil {
+			continue
+		}
+		for _, edge := range sortedOut(node) {
+			callee := edge.Callee.Func
+			if _, seen := parent[callee]; !seen && slice[callee] {
+				parent[callee] = fn
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return paths, unbuilt
+}
+
+// buildSSA builds p's SSA form, reporting false if the builder panicked.
+func buildSSA(p *ssa.Package) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	p.Build()
+	return true
+}
+
+// walk returns the functions reachable from start in g, following edges
+// in the direction next and step choose.
+func walk(g *callgraph.Graph, start []*ssa.Function, next func(*callgraph.Node) []*callgraph.Edge, step func(*callgraph.Edge) *callgraph.Node) map[*ssa.Function]bool {
+	seen := make(map[*ssa.Function]bool)
+	var stack []*callgraph.Node
+	for _, fn := range start {
+		if n := g.Nodes[fn]; n != nil && !seen[fn] {
+			seen[fn] = true
+			stack = append(stack, n)
+		}
+	}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, e := range next(n) {
+			if m := step(e); !seen[m.Func] {
+				seen[m.Func] = true
+				stack = append(stack, m)
+			}
+		}
+	}
+	return seen
+}
+
+func sortedOut(node *callgraph.Node) []*callgraph.Edge {
+	out := append([]*callgraph.Edge(nil), node.Out...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Callee.Func.String() < out[j].Callee.Func.String() })
+	return out
+}
+
+// symbol names fn the way OSV does: "Func" or "Type.Method", in its
+// package. Closures count as their enclosing function and generic
+// instantiations as their origin.
+func symbol(fn *ssa.Function) (pkg, sym string) {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if o := fn.Origin(); o != nil {
+		fn = o
+	}
+	if fn.Pkg == nil {
+		return "", ""
+	}
+	pkg = fn.Pkg.Pkg.Path()
+	if recv := fn.Signature.Recv(); recv != nil {
+		t := recv.Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			return pkg, named.Obj().Name() + "." + fn.Name()
+		}
+	}
+	return pkg, fn.Name()
+}
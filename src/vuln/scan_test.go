@@ -0,0 +1,112 @@
+package vuln
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/wow-look-at-my/go-toolchain/src/build"
+)
+
+var testMods = []build.Module{
+	{Path: "example.com/app", Main: true},
+	{Path: "example.com/dep", Version: "v1.1.0"},
+	{Path: "example.com/local", Version: "v1.0.0", Replace: &build.Module{Path: "../local"}},
+}
+
+func loadTestApp(t *testing.T) []*packages.Package {
+	// Same mode as vet, including test variants
+	cfg := &packages.Config{Mode: LoadMode, Tests: true, Dir: "testdata/app"}
+	pkgs, err := packages.Load(cfg, "./...")
+	require.NoError(t, err)
+	return pkgs
+}
+
+func findingsByID(findings []Finding) map[string]Finding {
+	m := make(map[string]Finding)
+	for _, f := range findings {
+		m[f.ID] = f
+	}
+	return m
+}
+
+func TestScanModuleLevel(t *testing.T) {
+	db, err := LoadDB("testdata/db")
+	require.NoError(t, err)
+
+	findings, err := Scan(db, testMods, "go1.22.1", nil)
+	require.NoError(t, err)
+	byID := findingsByID(findings)
+	assert.Equal(t, 4, len(findings))
+	for _, f := range findings {
+		assert.Equal(t, LevelModule, f.Level)
+	}
+	assert.Equal(t, "v1.2.0", byID["GO-2099-0001"].Fixed)
+	assert.Equal(t, High, byID["GO-2099-0001"].Severity)
+	assert.Equal(t, Medium, byID["GO-2099-0002"].Severity)
+	assert.Equal(t, "stdlib", byID["GO-2099-0005"].Module)
+	assert.Equal(t, "v1.22.4", byID["GO-2099-0005"].Fixed)
+
+	// A newer Go release isn't affected
+	findings, err = Scan(db, testMods, "go1.23.1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(findings))
+}
+
+func TestScanReachability(t *testing.T) {
+	db, err := LoadDB("testdata/db")
+	require.NoError(t, err)
+
+	findings, err := Scan(db, testMods, "go1.22.1", loadTestApp(t))
+	require.NoError(t, err)
+	byID := findingsByID(findings)
+
+	parse := byID["GO-2099-0001"]
+	assert.Equal(t, LevelCalled, parse.Level)
+	assert.Equal(t, []string{"example.com/dep.Parser.Parse", "example.com/dep.parseInner"}, parse.Symbols)
+	require.True(t, len(parse.Trace) >= 2)
+	assert.Equal(t, "(*example.com/dep.Parser).Parse", parse.Trace[len(parse.Trace)-1])
+
+	// Imported, but Bad is never called
+	assert.Equal(t, LevelImported, byID["GO-2099-0002"].Level)
+	// Only tests call Vulnerable
+	assert.Equal(t, LevelImported, byID["GO-2099-0003"].Level)
+	assert.Equal(t, LevelCalled, byID["GO-2099-0005"].Level)
+
+	// Reachable findings sort first, most severe first
+	assert.Equal(t, "GO-2099-0005", findings[0].ID)
+	assert.Equal(t, "GO-2099-0001", findings[1].ID)
+}
+
+func TestInModule(t *testing.T) {
+	fmtPkg := &packages.Package{PkgPath: "fmt"}
+	dep := &packages.Package{PkgPath: "example.com/dep/other", Module: &packages.Module{Path: "example.com/dep"}}
+	depot := &packages.Package{PkgPath: "example.com/depot", Module: &packages.Module{Path: "example.com/depot"}}
+	// A main module without a dot isn't the standard library
+	main := &packages.Package{PkgPath: "testmod/cmd", Module: &packages.Module{Path: "testmod", Main: true}}
+	replaced := &packages.Package{PkgPath: "example.com/lib", Module: &packages.Module{Path: "example.com/lib", Replace: &packages.Module{Path: "example.com/fork"}}}
+
+	assert.True(t, inModule(fmtPkg, "stdlib"))
+	assert.False(t, inModule(dep, "stdlib"))
+	assert.False(t, inModule(main, "stdlib"))
+	assert.True(t, inModule(dep, "example.com/dep"))
+	assert.False(t, inModule(depot, "example.com/dep"))
+	assert.False(t, inModule(fmtPkg, "example.com/dep"))
+	assert.True(t, inModule(replaced, "example.com/fork"))
+}
+
+func TestRefineReachabilityStdlibWithoutPackages(t *testing.T) {
+	fmtPkg := &packages.Package{ID: "fmt", PkgPath: "fmt"}
+	main := &packages.Package{
+		ID:      "testmod",
+		PkgPath: "testmod",
+		Module:  &packages.Module{Path: "testmod", Main: true},
+		Imports: map[string]*packages.Package{"fmt": fmtPkg},
+	}
+	findings := []Finding{{ID: "GO-2099-0009", Module: "stdlib"}}
+	require.NoError(t, refineReachability(findings, []*packages.Package{main}))
+	assert.Equal(t, LevelCalled, findings[0].Level)
+	assert.Equal(t, []string{"fmt"}, findings[0].Symbols)
+}
@@ -0,0 +1,158 @@
+package vuln
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Severity ranks how serious a vulnerability is.
+type Severity int
+
+const (
+	Unknown Severity = iota
+	Low
+	Medium
+	High
+	Critical
+)
+
+var severityNames = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity by name for JSON output.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name, case-insensitively. GHSA's
+// "moderate" is accepted as medium.
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "low":
+		return Low, nil
+	case "medium", "moderate":
+		return Medium, nil
+	case "high":
+		return High, nil
+	case "critical":
+		return Critical, nil
+	}
+	return Unknown, fmt.Errorf("unknown severity %q (use: low, medium, high, critical)", s)
+}
+
+// EntrySeverity returns the entry's severity: the database's own rating if
+// it has one, otherwise the rating of its CVSS v3 base score. The Go
+// vulnerability database rates nothing, so its entries are Unknown.
+func EntrySeverity(e *Entry) Severity {
+	if s, err := ParseSeverity(e.DatabaseSpecific.Severity); err == nil {
+		return s
+	}
+	for _, sc := range e.Severity {
+		if sc.Type != "CVSS_V3" {
+			continue
+		}
+		if score, err := CVSS3Score(sc.Score); err == nil {
+			return ratingForScore(score)
+		}
+	}
+	return Unknown
+}
+
+// ratingForScore maps a CVSS score to its qualitative rating. A score of 0
+// ("none") is reported as low.
+func ratingForScore(score float64) Severity {
+	switch {
+	case score >= 9:
+		return Critical
+	case score >= 7:
+		return High
+	case score >= 4:
+		return Medium
+	}
+	return Low
+}
+
+// CVSS v3 base metric weights.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// CVSS3Score computes the base score of a CVSS v3.0 or v3.1 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func CVSS3Score(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, fmt.Errorf("not a CVSS v3 vector: %q", vector)
+	}
+	metrics := make(map[string]string)
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, ":")
+		if !ok {
+			return 0, fmt.Errorf("malformed CVSS metric %q", p)
+		}
+		metrics[k] = v
+	}
+
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0, fmt.Errorf("CVSS vector %q has no scope", vector)
+	}
+	w := make(map[string]float64)
+	for m, values := range cvss3Weights {
+		v, ok := values[metrics[m]]
+		if !ok {
+			return 0, fmt.Errorf("CVSS vector %q has no valid %s metric", vector, m)
+		}
+		w[m] = v
+	}
+	// Privileges required weigh more when the scope changes
+	switch metrics["PR"] {
+	case "N":
+		w["PR"] = 0.85
+	case "L":
+		w["PR"] = 0.62
+		if changed {
+			w["PR"] = 0.68
+		}
+	case "H":
+		w["PR"] = 0.27
+		if changed {
+			w["PR"] = 0.5
+		}
+	default:
+		return 0, fmt.Errorf("CVSS vector %q has no valid PR metric", vector)
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp rounds up to one decimal place, as defined in CVSS v3.1
+// appendix A (avoiding floating point artifacts like 4.000001 -> 4.1).
+func roundUp(x float64) float64 {
+	i := math.Round(x * 100000)
+	if math.Mod(i, 10000) == 0 {
+		return i / 100000
+	}
+	return (math.Floor(i/10000) + 1) / 10
+}
@@ -0,0 +1,55 @@
+package vuln
+
+import (
+	"testing"
+
+	"github.com/wow-look-at-my/testify/assert"
+	"github.com/wow-look-at-my/testify/require"
+)
+
+func TestCVSS3Score(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", 10.0},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H", 7.5},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1},
+		{"CVSS:3.0/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", 1.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:L/I:N/A:N", 5.0},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0},
+	}
+	for _, tt := range tests {
+		got, err := CVSS3Score(tt.vector)
+		require.NoError(t, err, tt.vector)
+		assert.Equal(t, tt.want, got, tt.vector)
+	}
+
+	for _, bad := range []string{"", "CVSS:2.0/AV:N", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/C:H/I:H/A:H", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "CVSS:3.1/AV"} {
+		_, err := CVSS3Score(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestEntrySeverity(t *testing.T) {
+	e := &Entry{}
+	assert.Equal(t, Unknown, EntrySeverity(e))
+
+	e.Severity = []Score{{Type: "CVSS_V3", Score: "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"}}
+	assert.Equal(t, Medium, EntrySeverity(e))
+
+	// The database's own rating wins
+	e.DatabaseSpecific.Severity = "CRITICAL"
+	assert.Equal(t, Critical, EntrySeverity(e))
+}
+
+func TestParseSeverity(t *testing.T) {
+	s, err := ParseSeverity("Moderate")
+	require.NoError(t, err)
+	assert.Equal(t, Medium, s)
+	assert.Equal(t, "MEDIUM", s.String())
+
+	_, err = ParseSeverity("severe")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,13 @@
+package dep
+
+func Safe() {}
+
+func Vulnerable() {}
+
+type Parser struct{}
+
+func (p *Parser) Parse() {
+	func() { parseInner() }()
+}
+
+func parseInner() {}
@@ -0,0 +1,7 @@
+module example.com/app
+
+go 1.21
+
+require example.com/dep v1.1.0
+
+replace example.com/dep => ./dep
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/dep"
+	"example.com/dep/other"
+)
+
+func main() {
+	dep.Safe()
+	run(&dep.Parser{})
+	other.Fine()
+	fmt.Println("ok")
+}
+
+func run(p *dep.Parser) {
+	p.Parse()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/dep"
+)
+
+func TestVulnerable(t *testing.T) {
+	dep.Vulnerable()
+}